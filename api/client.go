@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devylongs/gean/types"
+)
+
+// maxSSZResponse bounds the size of a block or state fetched from a node.
+const maxSSZResponse = 1 << 28
+
+// Client fetches blocks and states from another node's API.
+type Client struct {
+	baseURL string
+	http    *http.Client
+}
+
+// NewClient creates a client for the API served at baseURL, e.g.
+// http://127.0.0.1:5052.
+func NewClient(baseURL string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		http:    &http.Client{Timeout: time.Minute},
+	}
+}
+
+// SignedBlock fetches the block with the given block id.
+func (c *Client) SignedBlock(ctx context.Context, id string) (*types.SignedBlock, error) {
+	data, err := c.getSSZ(ctx, "/lean/v0/blocks/"+id)
+	if err != nil {
+		return nil, err
+	}
+	block := new(types.SignedBlock)
+	if err := block.UnmarshalSSZ(data); err != nil {
+		return nil, fmt.Errorf("decode block: %w", err)
+	}
+	return block, nil
+}
+
+// State fetches the post-state of the block with the given block id.
+func (c *Client) State(ctx context.Context, id string) (*types.State, error) {
+	data, err := c.getSSZ(ctx, "/lean/v0/states/"+id)
+	if err != nil {
+		return nil, err
+	}
+	state := new(types.State)
+	if err := state.UnmarshalSSZ(data); err != nil {
+		return nil, fmt.Errorf("decode state: %w", err)
+	}
+	return state, nil
+}
+
+func (c *Client) getSSZ(ctx context.Context, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", ContentTypeSSZ)
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSSZResponse))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s: %s", path, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != ContentTypeSSZ {
+		return nil, fmt.Errorf("GET %s: unexpected content type %q", path, ct)
+	}
+	return data, nil
+}
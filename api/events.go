@@ -0,0 +1,73 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devylongs/gean/events"
+)
+
+// keepAliveInterval is how often an idle event stream sends a comment so
+// proxies and clients do not time it out.
+const keepAliveInterval = 15 * time.Second
+
+// handleEvents streams chain events as server-sent events. The topics query
+// parameter is a comma-separated list of topics; it defaults to all of them.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	var topics []events.Topic
+	for _, param := range r.URL.Query()["topics"] {
+		for _, name := range strings.Split(param, ",") {
+			topic, ok := events.ParseTopic(strings.TrimSpace(name))
+			if !ok {
+				s.writeError(w, http.StatusBadRequest, fmt.Errorf("unknown topic %q", name))
+				return
+			}
+			topics = append(topics, topic)
+		}
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		s.writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming unsupported"))
+		return
+	}
+
+	sub := s.events.Subscribe(topics...)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", ContentTypeSSE)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(newEventJSON(e))
+			if err != nil {
+				s.logger.Error("encode event failed", "topic", e.Topic(), "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Topic(), data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
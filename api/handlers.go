@@ -0,0 +1,273 @@
+package api
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/devylongs/gean/storage"
+	"github.com/devylongs/gean/types"
+)
+
+// errBadBlockID is returned for a block id that is not a name, slot or root.
+var errBadBlockID = errors.New("invalid block id")
+
+type identityJSON struct {
+	PeerID string   `json:"peer_id"`
+	Addrs  []string `json:"addrs"`
+}
+
+func (s *Server) handleIdentity(w http.ResponseWriter, r *http.Request) {
+	id := s.network.ID()
+	addrs := make([]string, 0)
+	for _, addr := range s.network.Addrs() {
+		addrs = append(addrs, fmt.Sprintf("%s/p2p/%s", addr, id))
+	}
+	s.writeJSON(w, identityJSON{PeerID: id.String(), Addrs: addrs})
+}
+
+type peerJSON struct {
+	PeerID        string          `json:"peer_id"`
+	Direction     string          `json:"direction"`
+	ConnectedAt   int64           `json:"connected_at"`
+	ClientVersion string          `json:"client_version"`
+	Score         float64         `json:"score"`
+	Head          *checkpointJSON `json:"head,omitempty"`
+	Finalized     *checkpointJSON `json:"finalized,omitempty"`
+}
+
+func (s *Server) handlePeers(w http.ResponseWriter, r *http.Request) {
+	peers := make([]peerJSON, 0)
+	for _, info := range s.network.PeerInfos() {
+		p := peerJSON{
+			PeerID:        info.ID.String(),
+			Direction:     strings.ToLower(info.Direction.String()),
+			ConnectedAt:   info.ConnectedAt.Unix(),
+			ClientVersion: info.ClientVersion,
+			Score:         info.Score,
+		}
+		if info.Status != nil {
+			head, finalized := newCheckpointJSON(info.Status.Head), newCheckpointJSON(info.Status.Finalized)
+			p.Head, p.Finalized = &head, &finalized
+		}
+		peers = append(peers, p)
+	}
+	s.writeJSON(w, peers)
+}
+
+type syncingJSON struct {
+	State         string `json:"state"`
+	IsSynced      bool   `json:"is_synced"`
+	HeadSlot      uint64 `json:"head_slot"`
+	CurrentSlot   uint64 `json:"current_slot"`
+	SyncDistance  uint64 `json:"sync_distance"`
+	PendingBlocks int    `json:"pending_blocks"`
+}
+
+func (s *Server) handleSyncing(w http.ResponseWriter, r *http.Request) {
+	head := s.store.HeadCheckpoint().Slot
+	current := s.store.CurrentSlot()
+	var distance uint64
+	if current > head {
+		distance = uint64(current - head)
+	}
+	s.writeJSON(w, syncingJSON{
+		State:         s.sync.State().String(),
+		IsSynced:      s.sync.IsSynced(),
+		HeadSlot:      uint64(head),
+		CurrentSlot:   uint64(current),
+		SyncDistance:  distance,
+		PendingBlocks: s.sync.PendingCount(),
+	})
+}
+
+// handleHealth answers 200 when synced and 206 while syncing.
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.sync.IsSynced() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.WriteHeader(http.StatusPartialContent)
+}
+
+type checkpointsJSON struct {
+	Head       checkpointJSON `json:"head"`
+	SafeTarget checkpointJSON `json:"safe_target"`
+	Justified  checkpointJSON `json:"justified"`
+	Finalized  checkpointJSON `json:"finalized"`
+}
+
+func (s *Server) handleCheckpoints(w http.ResponseWriter, r *http.Request) {
+	cp := s.store.Checkpoints()
+	s.writeJSON(w, checkpointsJSON{
+		Head:       newCheckpointJSON(s.checkpoint(cp.Head)),
+		SafeTarget: newCheckpointJSON(s.checkpoint(cp.SafeTarget)),
+		Justified:  newCheckpointJSON(cp.LatestJustified),
+		Finalized:  newCheckpointJSON(cp.LatestFinalized),
+	})
+}
+
+func (s *Server) handleBlock(w http.ResponseWriter, r *http.Request) {
+	root, err := s.resolveBlockID(r.PathValue("block_id"))
+	if err != nil {
+		s.writeLookupError(w, err)
+		return
+	}
+	block, err := s.signedBlock(root)
+	if err != nil {
+		s.writeLookupError(w, err)
+		return
+	}
+
+	if wantsSSZ(r) {
+		data, err := block.MarshalSSZ()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.writeSSZ(w, data)
+		return
+	}
+	s.writeJSON(w, blockResponse{Root: rootJSON(root), signedBlockJSON: newSignedBlockJSON(block)})
+}
+
+// handleState serves the post-state of a block.
+func (s *Server) handleState(w http.ResponseWriter, r *http.Request) {
+	root, err := s.resolveBlockID(r.PathValue("block_id"))
+	if err != nil {
+		s.writeLookupError(w, err)
+		return
+	}
+	state, err := s.state(root)
+	if err != nil {
+		s.writeLookupError(w, err)
+		return
+	}
+
+	if wantsSSZ(r) {
+		data, err := state.MarshalSSZ()
+		if err != nil {
+			s.writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		s.writeSSZ(w, data)
+		return
+	}
+	s.writeJSON(w, newStateJSON(state))
+}
+
+func (s *Server) handleForkChoice(w http.ResponseWriter, r *http.Request) {
+	cp := s.store.Checkpoints()
+	tree := s.store.Tree()
+
+	nodes := make([]treeNodeJSON, len(tree))
+	for i, n := range tree {
+		nodes[i] = treeNodeJSON{
+			Root:          rootJSON(n.Root),
+			ParentRoot:    rootJSON(n.ParentRoot),
+			Slot:          uint64(n.Slot),
+			ProposerIndex: n.ProposerIndex,
+			Weight:        n.Weight,
+		}
+	}
+	s.writeJSON(w, forkChoiceJSON{
+		Head:       newCheckpointJSON(s.checkpoint(cp.Head)),
+		SafeTarget: newCheckpointJSON(s.checkpoint(cp.SafeTarget)),
+		Justified:  newCheckpointJSON(cp.LatestJustified),
+		Finalized:  newCheckpointJSON(cp.LatestFinalized),
+		Nodes:      nodes,
+	})
+}
+
+func (s *Server) handleEquivocations(w http.ResponseWriter, r *http.Request) {
+	evidence := s.store.Evidence()
+	out := make([]equivocationJSON, len(evidence))
+	for i := range evidence {
+		out[i] = newEquivocationJSON(&evidence[i])
+	}
+	s.writeJSON(w, out)
+}
+
+// checkpoint pairs a block root held by the store with its slot.
+func (s *Server) checkpoint(root types.Root) types.Checkpoint {
+	cp := types.Checkpoint{Root: root}
+	if block, exists := s.store.Block(root); exists {
+		cp.Slot = block.Slot
+	}
+	return cp
+}
+
+// resolveBlockID maps a block id to a block root. An id is "head",
+// "genesis", "justified", "finalized", a slot on the canonical chain or a
+// 0x-prefixed block root.
+func (s *Server) resolveBlockID(id string) (types.Root, error) {
+	switch id {
+	case "head":
+		return s.store.HeadCheckpoint().Root, nil
+	case "genesis":
+		return s.canonicalRoot(0)
+	case "justified", "finalized":
+		cp := s.store.Justified()
+		if id == "finalized" {
+			cp = s.store.Finalized()
+		}
+		// The genesis checkpoints carry a zero root
+		if cp.Root.IsZero() {
+			return s.canonicalRoot(cp.Slot)
+		}
+		return cp.Root, nil
+	}
+
+	if strings.HasPrefix(id, "0x") {
+		var root types.Root
+		b, err := hex.DecodeString(id[2:])
+		if err != nil || len(b) != len(root) {
+			return root, fmt.Errorf("%w: %q", errBadBlockID, id)
+		}
+		copy(root[:], b)
+		return root, nil
+	}
+
+	slot, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return types.Root{}, fmt.Errorf("%w: %q", errBadBlockID, id)
+	}
+	return s.canonicalRoot(types.Slot(slot))
+}
+
+// canonicalRoot returns the root of the block at slot on the head's chain.
+func (s *Server) canonicalRoot(slot types.Slot) (types.Root, error) {
+	root, ok := s.store.CanonicalRoot(slot)
+	if !ok {
+		return root, fmt.Errorf("%w: no canonical block at slot %d", storage.ErrNotFound, slot)
+	}
+	return root, nil
+}
+
+func (s *Server) signedBlock(root types.Root) (*types.SignedBlock, error) {
+	if block, exists := s.store.SignedBlock(root); exists {
+		return block, nil
+	}
+	if s.db == nil {
+		return nil, fmt.Errorf("%w: block %x", storage.ErrNotFound, root[:4])
+	}
+	return s.db.Block(root)
+}
+
+func (s *Server) state(root types.Root) (*types.State, error) {
+	if state, exists := s.store.State(root); exists {
+		return state, nil
+	}
+	if s.db == nil {
+		return nil, fmt.Errorf("%w: state %x", storage.ErrNotFound, root[:4])
+	}
+	return s.db.State(root)
+}
+
+// wantsSSZ reports whether the client accepts SSZ responses.
+func wantsSSZ(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ContentTypeSSZ)
+}
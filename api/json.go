@@ -0,0 +1,240 @@
+package api
+
+import (
+	"encoding/hex"
+
+	"github.com/devylongs/gean/events"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/types"
+)
+
+// JSON views of the consensus containers: snake_case fields and 0x-prefixed
+// hex for roots, keys, signatures and bitlists.
+
+// hexBytes marshals as a 0x-prefixed hex string.
+type hexBytes []byte
+
+func (b hexBytes) MarshalText() ([]byte, error) {
+	out := make([]byte, 2+hex.EncodedLen(len(b)))
+	copy(out, "0x")
+	hex.Encode(out[2:], b)
+	return out, nil
+}
+
+func rootJSON(r types.Root) hexBytes { return hexBytes(r[:]) }
+
+type checkpointJSON struct {
+	Root hexBytes `json:"root"`
+	Slot uint64   `json:"slot"`
+}
+
+func newCheckpointJSON(c types.Checkpoint) checkpointJSON {
+	return checkpointJSON{Root: rootJSON(c.Root), Slot: uint64(c.Slot)}
+}
+
+type voteJSON struct {
+	ValidatorID uint64         `json:"validator_id"`
+	Slot        uint64         `json:"slot"`
+	Head        checkpointJSON `json:"head"`
+	Target      checkpointJSON `json:"target"`
+	Source      checkpointJSON `json:"source"`
+}
+
+type signedVoteJSON struct {
+	Data      voteJSON `json:"data"`
+	Signature hexBytes `json:"signature"`
+}
+
+func newSignedVoteJSON(v *types.SignedVote) signedVoteJSON {
+	return signedVoteJSON{
+		Data: voteJSON{
+			ValidatorID: v.Data.ValidatorID,
+			Slot:        uint64(v.Data.Slot),
+			Head:        newCheckpointJSON(v.Data.Head),
+			Target:      newCheckpointJSON(v.Data.Target),
+			Source:      newCheckpointJSON(v.Data.Source),
+		},
+		Signature: v.Signature[:],
+	}
+}
+
+type blockBodyJSON struct {
+	Attestations []signedVoteJSON `json:"attestations"`
+}
+
+type blockJSON struct {
+	Slot          uint64        `json:"slot"`
+	ProposerIndex uint64        `json:"proposer_index"`
+	ParentRoot    hexBytes      `json:"parent_root"`
+	StateRoot     hexBytes      `json:"state_root"`
+	Body          blockBodyJSON `json:"body"`
+}
+
+type signedBlockJSON struct {
+	Message   blockJSON `json:"message"`
+	Signature hexBytes  `json:"signature"`
+}
+
+func newSignedBlockJSON(b *types.SignedBlock) signedBlockJSON {
+	attestations := make([]signedVoteJSON, len(b.Message.Body.Attestations))
+	for i := range b.Message.Body.Attestations {
+		attestations[i] = newSignedVoteJSON(&b.Message.Body.Attestations[i])
+	}
+	return signedBlockJSON{
+		Message: blockJSON{
+			Slot:          uint64(b.Message.Slot),
+			ProposerIndex: b.Message.ProposerIndex,
+			ParentRoot:    rootJSON(b.Message.ParentRoot),
+			StateRoot:     rootJSON(b.Message.StateRoot),
+			Body:          blockBodyJSON{Attestations: attestations},
+		},
+		Signature: b.Signature[:],
+	}
+}
+
+// blockResponse is a signed block together with its root.
+type blockResponse struct {
+	Root hexBytes `json:"root"`
+	signedBlockJSON
+}
+
+type blockHeaderJSON struct {
+	Slot          uint64   `json:"slot"`
+	ProposerIndex uint64   `json:"proposer_index"`
+	ParentRoot    hexBytes `json:"parent_root"`
+	StateRoot     hexBytes `json:"state_root"`
+	BodyRoot      hexBytes `json:"body_root"`
+}
+
+type configJSON struct {
+	NumValidators uint64 `json:"num_validators"`
+	GenesisTime   uint64 `json:"genesis_time"`
+}
+
+type validatorJSON struct {
+	Pubkey hexBytes `json:"pubkey"`
+}
+
+type stateJSON struct {
+	Config                  configJSON      `json:"config"`
+	Slot                    uint64          `json:"slot"`
+	LatestBlockHeader       blockHeaderJSON `json:"latest_block_header"`
+	LatestJustified         checkpointJSON  `json:"latest_justified"`
+	LatestFinalized         checkpointJSON  `json:"latest_finalized"`
+	HistoricalBlockHashes   []hexBytes      `json:"historical_block_hashes"`
+	JustifiedSlots          hexBytes        `json:"justified_slots"`
+	JustificationRoots      []hexBytes      `json:"justification_roots"`
+	JustificationValidators hexBytes        `json:"justification_validators"`
+	Validators              []validatorJSON `json:"validators"`
+}
+
+func newStateJSON(s *types.State) stateJSON {
+	roots := func(in []types.Root) []hexBytes {
+		out := make([]hexBytes, len(in))
+		for i, r := range in {
+			out[i] = rootJSON(r)
+		}
+		return out
+	}
+	validators := make([]validatorJSON, len(s.Validators))
+	for i, v := range s.Validators {
+		validators[i] = validatorJSON{Pubkey: hexBytes(v.Pubkey[:])}
+	}
+	header := s.LatestBlockHeader
+	return stateJSON{
+		Config: configJSON{NumValidators: s.Config.NumValidators, GenesisTime: s.Config.GenesisTime},
+		Slot:   uint64(s.Slot),
+		LatestBlockHeader: blockHeaderJSON{
+			Slot:          uint64(header.Slot),
+			ProposerIndex: header.ProposerIndex,
+			ParentRoot:    rootJSON(header.ParentRoot),
+			StateRoot:     rootJSON(header.StateRoot),
+			BodyRoot:      rootJSON(header.BodyRoot),
+		},
+		LatestJustified:         newCheckpointJSON(s.LatestJustified),
+		LatestFinalized:         newCheckpointJSON(s.LatestFinalized),
+		HistoricalBlockHashes:   roots(s.HistoricalBlockHashes),
+		JustifiedSlots:          s.JustifiedSlots,
+		JustificationRoots:      roots(s.JustificationRoots),
+		JustificationValidators: s.JustificationValidators,
+		Validators:              validators,
+	}
+}
+
+type treeNodeJSON struct {
+	Root          hexBytes `json:"root"`
+	ParentRoot    hexBytes `json:"parent_root"`
+	Slot          uint64   `json:"slot"`
+	ProposerIndex uint64   `json:"proposer_index"`
+	Weight        int      `json:"weight"`
+}
+
+type forkChoiceJSON struct {
+	Head       checkpointJSON `json:"head"`
+	SafeTarget checkpointJSON `json:"safe_target"`
+	Justified  checkpointJSON `json:"justified"`
+	Finalized  checkpointJSON `json:"finalized"`
+	Nodes      []treeNodeJSON `json:"nodes"`
+}
+
+type equivocationJSON struct {
+	Kind      string            `json:"kind"`
+	Validator uint64            `json:"validator"`
+	Slot      uint64            `json:"slot"`
+	Blocks    []signedBlockJSON `json:"blocks,omitempty"`
+	Votes     []signedVoteJSON  `json:"votes,omitempty"`
+}
+
+func newEquivocationJSON(e *forkchoice.Equivocation) equivocationJSON {
+	out := equivocationJSON{
+		Kind:      e.Kind(),
+		Validator: uint64(e.Validator),
+		Slot:      uint64(e.Slot),
+	}
+	for i := range e.Blocks {
+		out.Blocks = append(out.Blocks, newSignedBlockJSON(&e.Blocks[i]))
+	}
+	for i := range e.Votes {
+		out.Votes = append(out.Votes, newSignedVoteJSON(&e.Votes[i]))
+	}
+	return out
+}
+
+type headEventJSON struct {
+	Slot    uint64   `json:"slot"`
+	Root    hexBytes `json:"root"`
+	OldRoot hexBytes `json:"old_root"`
+}
+
+type blockEventJSON struct {
+	Slot          uint64   `json:"slot"`
+	Root          hexBytes `json:"root"`
+	ProposerIndex uint64   `json:"proposer_index"`
+}
+
+type reorgEventJSON struct {
+	Slot    uint64   `json:"slot"`
+	Depth   uint64   `json:"depth"`
+	OldHead hexBytes `json:"old_head"`
+	NewHead hexBytes `json:"new_head"`
+}
+
+// newEventJSON returns the JSON view of an event's data.
+func newEventJSON(e events.Event) any {
+	switch e := e.(type) {
+	case events.Head:
+		return headEventJSON{Slot: uint64(e.Slot), Root: rootJSON(e.Root), OldRoot: rootJSON(e.OldRoot)}
+	case events.Block:
+		return blockEventJSON{Slot: uint64(e.Slot), Root: rootJSON(e.Root), ProposerIndex: e.ProposerIndex}
+	case events.Vote:
+		return newSignedVoteJSON(&e.SignedVote)
+	case events.Justified:
+		return newCheckpointJSON(e.Checkpoint)
+	case events.Finalized:
+		return newCheckpointJSON(e.Checkpoint)
+	case events.Reorg:
+		return reorgEventJSON{Slot: uint64(e.Slot), Depth: e.Depth, OldHead: rootJSON(e.OldHead), NewHead: rootJSON(e.NewHead)}
+	default:
+		return e
+	}
+}
@@ -0,0 +1,181 @@
+// Package api serves a read-only HTTP API for node, chain and fork choice
+// status. Responses are JSON wrapped in {"data": ...}; blocks and states are
+// also served as SSZ to clients that accept application/octet-stream. Chain
+// events are streamed as server-sent events.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/devylongs/gean/events"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/p2p"
+	"github.com/devylongs/gean/storage"
+	"github.com/devylongs/gean/syncer"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Content types
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeSSZ  = "application/octet-stream"
+	ContentTypeSSE  = "text/event-stream"
+)
+
+// Network is the p2p state reported by the API.
+type Network interface {
+	ID() peer.ID
+	Addrs() []multiaddr.Multiaddr
+	PeerInfos() []p2p.PeerInfo
+}
+
+// Syncer is the sync state reported by the API.
+type Syncer interface {
+	State() syncer.State
+	IsSynced() bool
+	PendingCount() int
+}
+
+// Config holds API server configuration.
+type Config struct {
+	Addr    string // listen address, e.g. 127.0.0.1:5052
+	Store   *forkchoice.Store
+	DB      *storage.DB // nil limits block and state history to the store
+	Network Network
+	Sync    Syncer
+	Events  *events.Bus // nil disables the event stream
+	Logger  *slog.Logger
+}
+
+// Server is the HTTP API server.
+type Server struct {
+	store   *forkchoice.Store
+	db      *storage.DB
+	network Network
+	sync    Syncer
+	events  *events.Bus
+	logger  *slog.Logger
+
+	mux  *http.ServeMux
+	http *http.Server
+
+	// ctx is the base context of every request; Stop cancels it to end
+	// event streams, which would otherwise hold up the shutdown.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// New creates an API server and registers its routes.
+func New(cfg Config) *Server {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	s := &Server{
+		store:   cfg.Store,
+		db:      cfg.DB,
+		network: cfg.Network,
+		sync:    cfg.Sync,
+		events:  cfg.Events,
+		logger:  logger,
+		mux:     http.NewServeMux(),
+	}
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.http = &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return s.ctx },
+	}
+
+	s.mux.HandleFunc("GET /lean/v0/node/identity", s.handleIdentity)
+	s.mux.HandleFunc("GET /lean/v0/node/peers", s.handlePeers)
+	s.mux.HandleFunc("GET /lean/v0/node/syncing", s.handleSyncing)
+	s.mux.HandleFunc("GET /lean/v0/node/health", s.handleHealth)
+	s.mux.HandleFunc("GET /lean/v0/checkpoints", s.handleCheckpoints)
+	s.mux.HandleFunc("GET /lean/v0/blocks/{block_id}", s.handleBlock)
+	s.mux.HandleFunc("GET /lean/v0/states/{block_id}", s.handleState)
+	s.mux.HandleFunc("GET /lean/v0/fork_choice", s.handleForkChoice)
+	s.mux.HandleFunc("GET /lean/v0/equivocations", s.handleEquivocations)
+	if s.events != nil {
+		s.mux.HandleFunc("GET /lean/v0/events", s.handleEvents)
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving all routes.
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start listens on the configured address and serves in the background.
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.http.Addr)
+	if err != nil {
+		return fmt.Errorf("listen api: %w", err)
+	}
+	go func() {
+		if err := s.http.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("api server failed", "error", err)
+		}
+	}()
+	s.logger.Info("api server started", "addr", ln.Addr().String())
+	return nil
+}
+
+// Stop shuts the server down, waiting for active requests until ctx is done.
+func (s *Server) Stop(ctx context.Context) error {
+	s.cancel()
+	return s.http.Shutdown(ctx)
+}
+
+// errorJSON is the body of an error response.
+type errorJSON struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// writeJSON writes v wrapped in {"data": v}.
+func (s *Server) writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	if err := json.NewEncoder(w).Encode(struct {
+		Data any `json:"data"`
+	}{v}); err != nil {
+		s.logger.Debug("api write failed", "error", err)
+	}
+}
+
+// writeSSZ writes an SSZ-encoded object.
+func (s *Server) writeSSZ(w http.ResponseWriter, data []byte) {
+	w.Header().Set("Content-Type", ContentTypeSSZ)
+	if _, err := w.Write(data); err != nil {
+		s.logger.Debug("api write failed", "error", err)
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, code int, err error) {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(errorJSON{Code: code, Message: err.Error()})
+}
+
+// writeLookupError maps block and state lookup errors to a status code.
+func (s *Server) writeLookupError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errBadBlockID):
+		s.writeError(w, http.StatusBadRequest, err)
+	case errors.Is(err, storage.ErrNotFound):
+		s.writeError(w, http.StatusNotFound, err)
+	default:
+		s.writeError(w, http.StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/devylongs/gean/chain/chaintest"
+	"github.com/devylongs/gean/events"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/p2p"
+	"github.com/devylongs/gean/syncer"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+type fakeNetwork struct{}
+
+func (fakeNetwork) ID() peer.ID { return "peer" }
+func (fakeNetwork) Addrs() []multiaddr.Multiaddr {
+	return []multiaddr.Multiaddr{multiaddr.StringCast("/ip4/127.0.0.1/udp/9000/quic-v1")}
+}
+func (fakeNetwork) PeerInfos() []p2p.PeerInfo { return nil }
+
+type fakeSyncer struct{ synced bool }
+
+func (f fakeSyncer) State() syncer.State { return syncer.StateSynced }
+func (f fakeSyncer) IsSynced() bool      { return f.synced }
+func (f fakeSyncer) PendingCount() int   { return 0 }
+
+// newTestServer serves a store holding a chain of blocks at slots 1 to slots.
+func newTestServer(t *testing.T, slots int) (*httptest.Server, *forkchoice.Store) {
+	t.Helper()
+	store := forkchoicetest.Store(t, chaintest.Slots(slots)...)
+
+	store.Events = events.NewBus()
+	srv := New(Config{Store: store, Network: fakeNetwork{}, Sync: fakeSyncer{synced: true}, Events: store.Events})
+	ts := httptest.NewServer(srv.Handler())
+	t.Cleanup(ts.Close)
+	return ts, store
+}
+
+func get(t *testing.T, url, accept string) (*http.Response, []byte) {
+	t.Helper()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp, body
+}
+
+func TestBlockByID(t *testing.T) {
+	ts, store := newTestServer(t, 3)
+	head := store.HeadCheckpoint()
+
+	for _, id := range []string{"head", "3", "0x" + hex.EncodeToString(head.Root[:])} {
+		resp, body := get(t, ts.URL+"/lean/v0/blocks/"+id, "")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("block %s: status %d: %s", id, resp.StatusCode, body)
+		}
+		var out struct {
+			Data struct {
+				Root    string `json:"root"`
+				Message struct {
+					Slot uint64 `json:"slot"`
+				} `json:"message"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(body, &out); err != nil {
+			t.Fatalf("decode block %s: %v", id, err)
+		}
+		if out.Data.Root != "0x"+hex.EncodeToString(head.Root[:]) || out.Data.Message.Slot != 3 {
+			t.Errorf("block %s = root %s slot %d, want head at slot 3", id, out.Data.Root, out.Data.Message.Slot)
+		}
+	}
+
+	resp, body := get(t, ts.URL+"/lean/v0/blocks/2", ContentTypeSSZ)
+	if resp.Header.Get("Content-Type") != ContentTypeSSZ {
+		t.Fatalf("content type = %q, want SSZ", resp.Header.Get("Content-Type"))
+	}
+	var block types.SignedBlock
+	if err := block.UnmarshalSSZ(body); err != nil {
+		t.Fatalf("decode SSZ block: %v", err)
+	}
+	if block.Message.Slot != 2 {
+		t.Errorf("SSZ block slot = %d, want 2", block.Message.Slot)
+	}
+
+	for id, want := range map[string]int{"7": http.StatusNotFound, "nope": http.StatusBadRequest, "genesis": http.StatusOK} {
+		if resp, _ := get(t, ts.URL+"/lean/v0/blocks/"+id, ""); resp.StatusCode != want {
+			t.Errorf("block %s: status %d, want %d", id, resp.StatusCode, want)
+		}
+	}
+}
+
+func TestStateByID(t *testing.T) {
+	ts, store := newTestServer(t, 2)
+
+	resp, body := get(t, ts.URL+"/lean/v0/states/head", ContentTypeSSZ)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d: %s", resp.StatusCode, body)
+	}
+	var state types.State
+	if err := state.UnmarshalSSZ(body); err != nil {
+		t.Fatalf("decode SSZ state: %v", err)
+	}
+	want, _ := store.State(store.HeadCheckpoint().Root)
+	gotRoot, _ := state.HashTreeRoot()
+	wantRoot, _ := want.HashTreeRoot()
+	if gotRoot != wantRoot {
+		t.Error("served state differs from the head state")
+	}
+
+	if resp, body := get(t, ts.URL+"/lean/v0/states/finalized", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("finalized state: status %d: %s", resp.StatusCode, body)
+	}
+}
+
+func TestForkChoiceAndStatus(t *testing.T) {
+	ts, _ := newTestServer(t, 2)
+
+	resp, body := get(t, ts.URL+"/lean/v0/fork_choice", "")
+	var tree struct {
+		Data struct {
+			Head struct {
+				Slot uint64 `json:"slot"`
+			} `json:"head"`
+			Nodes []struct {
+				Slot uint64 `json:"slot"`
+			} `json:"nodes"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &tree); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("fork choice: status %d, err %v", resp.StatusCode, err)
+	}
+	if len(tree.Data.Nodes) != 3 || tree.Data.Head.Slot != 2 {
+		t.Errorf("fork choice has %d nodes and head slot %d, want 3 and 2", len(tree.Data.Nodes), tree.Data.Head.Slot)
+	}
+
+	resp, body = get(t, ts.URL+"/lean/v0/node/identity", "")
+	var identity struct {
+		Data identityJSON `json:"data"`
+	}
+	if err := json.Unmarshal(body, &identity); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("identity: status %d, err %v", resp.StatusCode, err)
+	}
+	if len(identity.Data.Addrs) != 1 || identity.Data.Addrs[0] != "/ip4/127.0.0.1/udp/9000/quic-v1/p2p/"+peer.ID("peer").String() {
+		t.Errorf("identity addrs = %v", identity.Data.Addrs)
+	}
+
+	if resp, _ := get(t, ts.URL+"/lean/v0/node/health", ""); resp.StatusCode != http.StatusOK {
+		t.Errorf("health: status %d, want 200", resp.StatusCode)
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	ts, store := newTestServer(t, 2)
+
+	if resp, _ := get(t, ts.URL+"/lean/v0/events?topics=head,nope", ""); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown topic: status %d, want 400", resp.StatusCode)
+	}
+
+	resp, err := http.Get(ts.URL + "/lean/v0/events?topics=block")
+	if err != nil {
+		t.Fatalf("GET events: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != ContentTypeSSE {
+		t.Fatalf("content type = %q, want %q", resp.Header.Get("Content-Type"), ContentTypeSSE)
+	}
+
+	block, err := store.ProduceBlock(3, 3)
+	if err != nil {
+		t.Fatalf("ProduceBlock failed: %v", err)
+	}
+	if err := store.ProcessBlock(chaintest.SignBlock(t, block)); err != nil {
+		t.Fatalf("ProcessBlock failed: %v", err)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	var lines []string
+	for len(lines) < 2 {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event: %v", err)
+		}
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	if lines[0] != "event: block" {
+		t.Fatalf("first line = %q, want a block event", lines[0])
+	}
+	var data struct {
+		Slot uint64 `json:"slot"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &data); err != nil || data.Slot != 3 {
+		t.Errorf("event data = %q, want a block at slot 3", lines[1])
+	}
+}
+
+func TestClientFetchesBlockAndState(t *testing.T) {
+	ts, store := newTestServer(t, 2)
+	client := NewClient(ts.URL + "/")
+
+	block, err := client.SignedBlock(context.Background(), "head")
+	if err != nil {
+		t.Fatalf("SignedBlock failed: %v", err)
+	}
+	root, _ := block.Message.HashTreeRoot()
+	if root != store.HeadCheckpoint().Root {
+		t.Fatal("fetched block is not the head")
+	}
+	state, err := client.State(context.Background(), "0x"+hex.EncodeToString(root[:]))
+	if err != nil {
+		t.Fatalf("State failed: %v", err)
+	}
+	if stateRoot, _ := state.HashTreeRoot(); stateRoot != block.Message.StateRoot {
+		t.Error("fetched state does not match the block's state root")
+	}
+
+	if _, err := client.SignedBlock(context.Background(), "9"); err == nil {
+		t.Error("SignedBlock of a missing slot succeeded")
+	}
+}
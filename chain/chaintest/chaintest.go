@@ -0,0 +1,92 @@
+// Package chaintest builds devnet chains for tests.
+package chaintest
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+// Validators is the number of devnet validators in a test genesis.
+const Validators = 4
+
+// Store is the fork choice store a chain is built on. *forkchoice.Store
+// implements it; the forkchoice tests build chains too, so this package
+// cannot import it.
+type Store interface {
+	ProduceBlock(slot types.Slot, proposer types.ValidatorIndex) (*types.Block, error)
+	ProcessBlock(signedBlock *types.SignedBlock) error
+	UpdateHead()
+}
+
+// Genesis returns the genesis state of Validators devnet validators at time
+// zero and the anchor block to start a store from.
+func Genesis(t testing.TB) (*types.State, *types.Block) {
+	t.Helper()
+	state := chain.GenerateGenesis(0, chain.DevnetValidators(Validators))
+	anchor := &types.Block{Body: types.BlockBody{Attestations: []types.SignedVote{}}}
+	anchor.StateRoot, _ = state.HashTreeRoot()
+	return state, anchor
+}
+
+// Extend produces and imports a block at each of the given slots, signed by
+// its proposer's devnet key, and moves validator 0's known vote to it so the
+// head follows the chain. votes is the store's LatestKnownVotes.
+func Extend(t testing.TB, store Store, votes map[types.ValidatorIndex]types.Checkpoint, slots ...types.Slot) {
+	t.Helper()
+	for _, slot := range slots {
+		block, err := store.ProduceBlock(slot, types.ValidatorIndex(uint64(slot)%Validators))
+		if err != nil {
+			t.Fatalf("ProduceBlock(%d) failed: %v", slot, err)
+		}
+		if err := store.ProcessBlock(SignBlock(t, block)); err != nil {
+			t.Fatalf("ProcessBlock(%d) failed: %v", slot, err)
+		}
+		root, _ := block.HashTreeRoot()
+		votes[0] = types.Checkpoint{Root: root, Slot: slot}
+		store.UpdateHead()
+	}
+}
+
+// Slots returns the slots from 1 to n.
+func Slots(n int) []types.Slot {
+	slots := make([]types.Slot, n)
+	for i := range slots {
+		slots[i] = types.Slot(i + 1)
+	}
+	return slots
+}
+
+// SignBlock signs a block with its proposer's devnet key.
+func SignBlock(t testing.TB, block *types.Block) *types.SignedBlock {
+	t.Helper()
+	signed, err := chain.SignBlock(xmss.DevnetKey(block.ProposerIndex), block)
+	if err != nil {
+		t.Fatalf("SignBlock(%d) failed: %v", block.Slot, err)
+	}
+	return signed
+}
+
+// ChildBlock builds a valid signed block without attestations at slot on the
+// parent block, whose post-state is parentState.
+func ChildBlock(t testing.TB, parentState *types.State, parent types.Root, slot types.Slot) *types.SignedBlock {
+	t.Helper()
+	block := &types.Block{
+		Slot:          slot,
+		ProposerIndex: uint64(slot) % Validators,
+		ParentRoot:    parent,
+		Body:          types.BlockBody{Attestations: []types.SignedVote{}},
+	}
+	advanced, err := chain.ProcessSlots(parentState, slot)
+	if err != nil {
+		t.Fatalf("ProcessSlots failed: %v", err)
+	}
+	post, err := chain.ProcessBlock(advanced, block)
+	if err != nil {
+		t.Fatalf("ProcessBlock failed: %v", err)
+	}
+	block.StateRoot, _ = post.HashTreeRoot()
+	return SignBlock(t, block)
+}
@@ -0,0 +1,81 @@
+package chain
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/devylongs/gean/types"
+	"gopkg.in/yaml.v3"
+)
+
+// GenesisConfig is the network genesis configuration, read from the
+// config.yaml used by lean-quickstart so every client in a devnet starts
+// from the same genesis state.
+type GenesisConfig struct {
+	GenesisTime    uint64 `yaml:"GENESIS_TIME"`
+	ValidatorCount uint64 `yaml:"VALIDATOR_COUNT"`
+
+	// GenesisValidators holds the 0x-prefixed hex public key of every
+	// validator. When empty, only the insecure devnet keys can fill the
+	// registry; see ErrNoGenesisValidators.
+	GenesisValidators []string `yaml:"GENESIS_VALIDATORS"`
+}
+
+// ErrNoGenesisValidators is returned for a genesis config without
+// GENESIS_VALIDATORS. Callers may fall back to DevnetValidators, whose keys
+// anyone can derive, only when explicitly asked to.
+var ErrNoGenesisValidators = errors.New("genesis config has no GENESIS_VALIDATORS")
+
+// ReadGenesisConfig reads and checks a genesis config file.
+func ReadGenesisConfig(path string) (*GenesisConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read genesis config: %w", err)
+	}
+	cfg := new(GenesisConfig)
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parse genesis config: %w", err)
+	}
+
+	if cfg.GenesisTime == 0 {
+		return nil, fmt.Errorf("genesis config: GENESIS_TIME is not set")
+	}
+	if n := uint64(len(cfg.GenesisValidators)); n > 0 {
+		if cfg.ValidatorCount != 0 && cfg.ValidatorCount != n {
+			return nil, fmt.Errorf("genesis config: VALIDATOR_COUNT %d does not match %d GENESIS_VALIDATORS", cfg.ValidatorCount, n)
+		}
+		cfg.ValidatorCount = n
+	}
+	if cfg.ValidatorCount == 0 {
+		return nil, fmt.Errorf("genesis config: VALIDATOR_COUNT is not set")
+	}
+	return cfg, nil
+}
+
+// Validators returns the genesis validator registry.
+func (c *GenesisConfig) Validators() ([]types.Validator, error) {
+	if len(c.GenesisValidators) == 0 {
+		return nil, ErrNoGenesisValidators
+	}
+	validators := make([]types.Validator, len(c.GenesisValidators))
+	for i, s := range c.GenesisValidators {
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil || len(b) != types.PubkeySize {
+			return nil, fmt.Errorf("genesis validator %d: invalid pubkey %q", i, s)
+		}
+		copy(validators[i].Pubkey[:], b)
+	}
+	return validators, nil
+}
+
+// State returns the genesis state the config describes.
+func (c *GenesisConfig) State() (*types.State, error) {
+	validators, err := c.Validators()
+	if err != nil {
+		return nil, err
+	}
+	return GenerateGenesis(c.GenesisTime, validators), nil
+}
@@ -0,0 +1,54 @@
+package chain
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(contents), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadGenesisConfig(t *testing.T) {
+	cfg, err := ReadGenesisConfig(writeConfig(t, "# Genesis Settings\nGENESIS_TIME: 1704085200\n\n# Validator Settings\nVALIDATOR_COUNT: 4\n"))
+	if err != nil {
+		t.Fatalf("ReadGenesisConfig failed: %v", err)
+	}
+	if cfg.GenesisTime != 1704085200 || cfg.ValidatorCount != 4 {
+		t.Errorf("config = time %d, count %d", cfg.GenesisTime, cfg.ValidatorCount)
+	}
+	if _, err := cfg.State(); !errors.Is(err, ErrNoGenesisValidators) {
+		t.Errorf("State without GENESIS_VALIDATORS: err = %v, want ErrNoGenesisValidators", err)
+	}
+
+	pubkey := DevnetValidators(1)[0].Pubkey
+	cfg, err = ReadGenesisConfig(writeConfig(t, "GENESIS_TIME: 5\nGENESIS_VALIDATORS:\n  - \"0x"+hex.EncodeToString(pubkey[:])+"\"\n"))
+	if err != nil {
+		t.Fatalf("ReadGenesisConfig with validators failed: %v", err)
+	}
+	validators, err := cfg.Validators()
+	if err != nil || cfg.ValidatorCount != 1 || validators[0].Pubkey != pubkey {
+		t.Errorf("validators = %v (count %d), err %v, want the listed pubkey", validators, cfg.ValidatorCount, err)
+	}
+}
+
+func TestReadGenesisConfigErrors(t *testing.T) {
+	for name, contents := range map[string]string{
+		"no time":        "VALIDATOR_COUNT: 4\n",
+		"no validators":  "GENESIS_TIME: 5\n",
+		"count mismatch": "GENESIS_TIME: 5\nVALIDATOR_COUNT: 2\nGENESIS_VALIDATORS: [\"0x00\"]\n",
+		"not yaml":       "GENESIS_TIME: [\n",
+	} {
+		if _, err := ReadGenesisConfig(writeConfig(t, contents)); err == nil {
+			t.Errorf("%s: ReadGenesisConfig succeeded", name)
+		}
+	}
+}
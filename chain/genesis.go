@@ -1,9 +1,12 @@
 package chain
 
-import "github.com/devylongs/gean/types"
+import (
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
 
-// GenerateGenesis creates a genesis state with the given parameters.
-func GenerateGenesis(genesisTime, numValidators uint64) *types.State {
+// GenerateGenesis creates a genesis state with the given validator registry.
+func GenerateGenesis(genesisTime uint64, validators []types.Validator) *types.State {
 	emptyBody := &types.BlockBody{Attestations: []types.SignedVote{}}
 	bodyRoot, _ := emptyBody.HashTreeRoot()
 
@@ -17,7 +20,7 @@ func GenerateGenesis(genesisTime, numValidators uint64) *types.State {
 
 	return &types.State{
 		Config: types.Config{
-			NumValidators: numValidators,
+			NumValidators: uint64(len(validators)),
 			GenesisTime:   genesisTime,
 		},
 		Slot:                    0,
@@ -28,10 +31,32 @@ func GenerateGenesis(genesisTime, numValidators uint64) *types.State {
 		JustifiedSlots:          []byte{},
 		JustificationRoots:      []types.Root{},
 		JustificationValidators: []byte{},
+		Validators:              validators,
 	}
 }
 
+// DevnetValidators returns a registry of n validators holding the
+// deterministic devnet keys (see xmss.DevnetKey).
+func DevnetValidators(n uint64) []types.Validator {
+	validators := make([]types.Validator, n)
+	for i := range validators {
+		validators[i].Pubkey = types.Pubkey(xmss.DevnetKey(uint64(i)).PublicKey())
+	}
+	return validators
+}
+
 // IsProposer checks if a validator is the proposer for the current slot.
 func IsProposer(s *types.State, validatorIndex types.ValidatorIndex) bool {
 	return uint64(s.Slot)%s.Config.NumValidators == uint64(validatorIndex)
 }
+
+// GenesisBlock returns the genesis block committing to a genesis state.
+func GenesisBlock(state *types.State) *types.Block {
+	stateRoot, _ := state.HashTreeRoot()
+	return &types.Block{
+		Slot:       0,
+		StateRoot:  stateRoot,
+		ParentRoot: types.Root{},
+		Body:       types.BlockBody{Attestations: []types.SignedVote{}},
+	}
+}
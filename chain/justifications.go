@@ -0,0 +1,63 @@
+package chain
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/devylongs/gean/types"
+)
+
+// justifications maps a target root to the per-validator votes it has received.
+type justifications map[types.Root][]bool
+
+// getJustifications unpacks JustificationRoots and JustificationValidators.
+// Validator votes for root i occupy bits [i*N, (i+1)*N) where N is the
+// validator count.
+func getJustifications(s *types.State) justifications {
+	numValidators := int(s.Config.NumValidators)
+	result := make(justifications, len(s.JustificationRoots))
+	for i, root := range s.JustificationRoots {
+		votes := make([]bool, numValidators)
+		for v := 0; v < numValidators; v++ {
+			votes[v] = getBit(s.JustificationValidators, i*numValidators+v)
+		}
+		result[root] = votes
+	}
+	return result
+}
+
+// setJustifications packs the tallies back into the state, with roots sorted
+// so the encoding is deterministic.
+func setJustifications(s *types.State, j justifications) {
+	roots := make([]types.Root, 0, len(j))
+	for root := range j {
+		roots = append(roots, root)
+	}
+	sort.Slice(roots, func(a, b int) bool {
+		return bytes.Compare(roots[a][:], roots[b][:]) < 0
+	})
+
+	numValidators := int(s.Config.NumValidators)
+	bits := make([]byte, (len(roots)*numValidators+7)/8)
+	for i, root := range roots {
+		for v, voted := range j[root] {
+			if voted {
+				bits = setBit(bits, i*numValidators+v, true)
+			}
+		}
+	}
+
+	s.JustificationRoots = roots
+	s.JustificationValidators = bits
+}
+
+// count returns the number of validators that voted.
+func count(votes []bool) uint64 {
+	var n uint64
+	for _, voted := range votes {
+		if voted {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,99 @@
+package chain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+// ErrInvalidSignature is returned when a block or vote signature does not verify.
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Each slot uses two signing epochs, so a proposer's block and its own vote
+// never share a one-time key. A key therefore lasts KeyLifetimeSlots slots.
+
+// KeyLifetimeSlots is the number of slots from genesis a key can sign for.
+const KeyLifetimeSlots = xmss.Lifetime / 2
+
+// BlockEpoch returns the signing epoch for a block proposed at slot.
+func BlockEpoch(slot types.Slot) uint64 { return 2 * uint64(slot) }
+
+// VoteEpoch returns the signing epoch for a vote cast at slot.
+func VoteEpoch(slot types.Slot) uint64 { return 2*uint64(slot) + 1 }
+
+// SignBlock signs the block root with the proposer's key.
+func SignBlock(key *xmss.PrivateKey, block *types.Block) (*types.SignedBlock, error) {
+	root, err := block.HashTreeRoot()
+	if err != nil {
+		return nil, fmt.Errorf("hash block: %w", err)
+	}
+	sig, err := key.Sign(BlockEpoch(block.Slot), root)
+	if err != nil {
+		return nil, fmt.Errorf("sign block: %w", err)
+	}
+	return &types.SignedBlock{Message: *block, Signature: types.Signature(sig)}, nil
+}
+
+// SignVote signs the vote root with the validator's key.
+func SignVote(key *xmss.PrivateKey, vote *types.Vote) (*types.SignedVote, error) {
+	root, err := vote.HashTreeRoot()
+	if err != nil {
+		return nil, fmt.Errorf("hash vote: %w", err)
+	}
+	sig, err := key.Sign(VoteEpoch(vote.Slot), root)
+	if err != nil {
+		return nil, fmt.Errorf("sign vote: %w", err)
+	}
+	return &types.SignedVote{Data: *vote, Signature: types.Signature(sig)}, nil
+}
+
+// VerifyBlockSignature checks the proposer signature of a block against the
+// validator registry.
+func VerifyBlockSignature(validators []types.Validator, signedBlock *types.SignedBlock) error {
+	block := &signedBlock.Message
+	if block.ProposerIndex >= uint64(len(validators)) {
+		return fmt.Errorf("%w: unknown proposer %d", ErrInvalidSignature, block.ProposerIndex)
+	}
+	root, err := block.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash block: %w", err)
+	}
+	pk := xmss.PublicKey(validators[block.ProposerIndex].Pubkey)
+	if !xmss.Verify(pk, BlockEpoch(block.Slot), root, signedBlock.Signature[:]) {
+		return fmt.Errorf("%w: block at slot %d by proposer %d", ErrInvalidSignature, block.Slot, block.ProposerIndex)
+	}
+	return nil
+}
+
+// VerifyVoteSignature checks a vote signature against the validator registry.
+func VerifyVoteSignature(validators []types.Validator, signedVote *types.SignedVote) error {
+	vote := &signedVote.Data
+	if vote.ValidatorID >= uint64(len(validators)) {
+		return fmt.Errorf("%w: unknown validator %d", ErrInvalidSignature, vote.ValidatorID)
+	}
+	root, err := vote.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash vote: %w", err)
+	}
+	pk := xmss.PublicKey(validators[vote.ValidatorID].Pubkey)
+	if !xmss.Verify(pk, VoteEpoch(vote.Slot), root, signedVote.Signature[:]) {
+		return fmt.Errorf("%w: vote at slot %d by validator %d", ErrInvalidSignature, vote.Slot, vote.ValidatorID)
+	}
+	return nil
+}
+
+// VerifySignatures checks the proposer signature and every attestation
+// signature in a block.
+func VerifySignatures(validators []types.Validator, signedBlock *types.SignedBlock) error {
+	if err := VerifyBlockSignature(validators, signedBlock); err != nil {
+		return err
+	}
+	for i := range signedBlock.Message.Body.Attestations {
+		if err := VerifyVoteSignature(validators, &signedBlock.Message.Body.Attestations[i]); err != nil {
+			return fmt.Errorf("attestation %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -111,56 +111,105 @@ func ProcessBlockHeader(s *types.State, block *types.Block) (*types.State, error
 }
 
 // ProcessAttestations processes attestation votes per Devnet 0 spec.
-// Per the spec, justification happens when source is justified and we vote for a target.
-// Finalization happens when source and target are consecutive justified slots.
+// Votes are tallied per target root; a target is justified once at least 2/3
+// of validators have voted for it from a justified source. The source is
+// finalized when no justifiable slot lies strictly between source and target.
 func ProcessAttestations(s *types.State, attestations []types.SignedVote) (*types.State, error) {
 	newState := Copy(s)
+	tallies := getJustifications(newState)
+	numValidators := newState.Config.NumValidators
 
 	for _, signed := range attestations {
 		vote := signed.Data
+		source := vote.Source
+		target := vote.Target
 
-		// Skip if source slot >= target slot
-		if vote.Source.Slot >= vote.Target.Slot {
+		if vote.ValidatorID >= numValidators {
 			continue
 		}
 
-		sourceSlot := int(vote.Source.Slot)
-		targetSlot := int(vote.Target.Slot)
+		// Source must be justified and target not yet justified
+		if !getBit(newState.JustifiedSlots, int(source.Slot)) {
+			continue
+		}
+		if getBit(newState.JustifiedSlots, int(target.Slot)) {
+			continue
+		}
 
-		// Skip if source is not justified
-		if !getBit(newState.JustifiedSlots, sourceSlot) {
+		// Source and target must be on this chain
+		if !inHistory(newState, source) || !inHistory(newState, target) {
 			continue
 		}
 
-		// Check if target is already justified
-		if getBit(newState.JustifiedSlots, targetSlot) {
-			// Target already justified - check for finalization
-			// Finalization occurs when source and target are consecutive justified checkpoints
-			if vote.Source.Slot+1 == vote.Target.Slot &&
-				newState.LatestJustified.Slot < vote.Target.Slot {
-				newState.LatestFinalized = vote.Source
-				newState.LatestJustified = vote.Target
-			}
+		if target.Slot <= source.Slot {
+			continue
+		}
+		if !target.Slot.IsJustifiableAfter(newState.LatestFinalized.Slot) {
 			continue
 		}
 
-		// Extend JustifiedSlots if needed
-		for targetSlot/8 >= len(newState.JustifiedSlots) {
-			newState.JustifiedSlots = append(newState.JustifiedSlots, 0)
+		// Tally the vote, ignoring repeats from the same validator
+		votes, exists := tallies[target.Root]
+		if !exists {
+			votes = make([]bool, numValidators)
+			tallies[target.Root] = votes
 		}
+		votes[vote.ValidatorID] = true
 
-		// Justify the target
-		newState.JustifiedSlots = setBit(newState.JustifiedSlots, targetSlot, true)
+		// Justify on 2/3 supermajority
+		if 3*count(votes) < 2*numValidators {
+			continue
+		}
+		newState.LatestJustified = target
+		newState.JustifiedSlots = setBit(newState.JustifiedSlots, int(target.Slot), true)
+		delete(tallies, target.Root)
+
+		// Finalize the source if target is the next justifiable slot after it
+		finalize := true
+		for slot := source.Slot + 1; slot < target.Slot; slot++ {
+			if slot.IsJustifiableAfter(newState.LatestFinalized.Slot) {
+				finalize = false
+				break
+			}
+		}
+		if finalize {
+			newState.LatestFinalized = source
+		}
+	}
 
-		// Update latest justified if this target is newer
-		if vote.Target.Slot > newState.LatestJustified.Slot {
-			newState.LatestJustified = vote.Target
+	// Drop tallies for targets that can no longer be justified
+	finalizedSlot := newState.LatestFinalized.Slot
+	for root := range tallies {
+		slot, ok := historySlot(newState, root)
+		if !ok || slot <= finalizedSlot || !slot.IsJustifiableAfter(finalizedSlot) {
+			delete(tallies, root)
 		}
 	}
 
+	setJustifications(newState, tallies)
 	return newState, nil
 }
 
+// inHistory reports whether the checkpoint's root is the block recorded at
+// its slot in the state's historical block hashes.
+func inHistory(s *types.State, cp types.Checkpoint) bool {
+	if int(cp.Slot) >= len(s.HistoricalBlockHashes) {
+		return false
+	}
+	return s.HistoricalBlockHashes[cp.Slot] == cp.Root
+}
+
+// historySlot returns the slot at which root appears in the historical block
+// hashes, searching from the finalized slot onward.
+func historySlot(s *types.State, root types.Root) (types.Slot, bool) {
+	for slot := int(s.LatestFinalized.Slot); slot < len(s.HistoricalBlockHashes); slot++ {
+		if s.HistoricalBlockHashes[slot] == root {
+			return types.Slot(slot), true
+		}
+	}
+	return 0, false
+}
+
 // ProcessBlock applies full block processing.
 func ProcessBlock(s *types.State, block *types.Block) (*types.State, error) {
 	state, err := ProcessBlockHeader(s, block)
@@ -171,10 +220,15 @@ func ProcessBlock(s *types.State, block *types.Block) (*types.State, error) {
 }
 
 // StateTransition applies the complete state transition for a signed block.
-// For Devnet 0, valid_signatures is always true (no signature verification).
+// The proposer and attestation signatures are verified against the validator
+// registry before the block is processed.
 func StateTransition(s *types.State, signedBlock *types.SignedBlock, validateResult bool) (*types.State, error) {
 	block := &signedBlock.Message
 
+	if err := VerifySignatures(s.Validators, signedBlock); err != nil {
+		return nil, err
+	}
+
 	// Process slots up to block slot
 	state, err := ProcessSlots(s, block.Slot)
 	if err != nil {
@@ -208,6 +262,7 @@ func Copy(s *types.State) *types.State {
 	cp.JustifiedSlots = append([]byte{}, s.JustifiedSlots...)
 	cp.JustificationRoots = append([]types.Root{}, s.JustificationRoots...)
 	cp.JustificationValidators = append([]byte{}, s.JustificationValidators...)
+	cp.Validators = append([]types.Validator{}, s.Validators...)
 	return &cp
 }
 
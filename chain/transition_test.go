@@ -0,0 +1,180 @@
+package chain
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+const testValidators = 4
+
+// applyBlock builds a block at slot on top of s carrying the given votes and
+// returns the post-state along with the block root.
+func applyBlock(t *testing.T, s *types.State, slot types.Slot, votes []types.SignedVote) (*types.State, types.Root) {
+	t.Helper()
+	advanced, err := ProcessSlots(s, slot)
+	if err != nil {
+		t.Fatalf("ProcessSlots(%d) failed: %v", slot, err)
+	}
+	parent, _ := advanced.LatestBlockHeader.HashTreeRoot()
+	block := &types.Block{
+		Slot:          slot,
+		ProposerIndex: uint64(slot) % testValidators,
+		ParentRoot:    parent,
+		Body:          types.BlockBody{Attestations: votes},
+	}
+	post, err := ProcessBlock(advanced, block)
+	if err != nil {
+		t.Fatalf("ProcessBlock(%d) failed: %v", slot, err)
+	}
+	block.StateRoot, _ = post.HashTreeRoot()
+	root, _ := block.HashTreeRoot()
+	return post, root
+}
+
+func votesFor(source, target types.Checkpoint, validators ...uint64) []types.SignedVote {
+	votes := make([]types.SignedVote, 0, len(validators))
+	for _, v := range validators {
+		votes = append(votes, types.SignedVote{Data: types.Vote{
+			ValidatorID: v,
+			Slot:        target.Slot,
+			Head:        target,
+			Target:      target,
+			Source:      source,
+		}})
+	}
+	return votes
+}
+
+// setup returns the post-state of block 1 and the genesis and block 1 checkpoints.
+func setup(t *testing.T) (*types.State, types.Checkpoint, types.Checkpoint) {
+	t.Helper()
+	state, root := applyBlock(t, GenerateGenesis(0, DevnetValidators(testValidators)), 1, []types.SignedVote{})
+	genesis := state.LatestJustified
+	return state, genesis, types.Checkpoint{Root: root, Slot: 1}
+}
+
+func TestAttestationsBelowSupermajority(t *testing.T) {
+	state, genesis, target := setup(t)
+
+	state, _ = applyBlock(t, state, 2, votesFor(genesis, target, 0, 1))
+
+	if state.LatestJustified.Slot != 0 {
+		t.Errorf("justified slot %d with 2/%d votes", state.LatestJustified.Slot, testValidators)
+	}
+	if getBit(state.JustifiedSlots, 1) {
+		t.Error("slot 1 marked justified")
+	}
+	if len(state.JustificationRoots) != 1 || state.JustificationRoots[0] != target.Root {
+		t.Errorf("JustificationRoots = %x, want pending tally for target", state.JustificationRoots)
+	}
+}
+
+func TestAttestationsSupermajorityJustifiesAndFinalizes(t *testing.T) {
+	state, genesis, target := setup(t)
+
+	state, _ = applyBlock(t, state, 2, votesFor(genesis, target, 0, 1, 2))
+
+	if state.LatestJustified != target {
+		t.Errorf("LatestJustified = %+v, want %+v", state.LatestJustified, target)
+	}
+	if !getBit(state.JustifiedSlots, 1) {
+		t.Error("slot 1 not marked justified")
+	}
+	if state.LatestFinalized != genesis {
+		t.Errorf("LatestFinalized = %+v, want genesis %+v", state.LatestFinalized, genesis)
+	}
+	if len(state.JustificationRoots) != 0 || len(state.JustificationValidators) != 0 {
+		t.Error("tally for justified target not cleared")
+	}
+}
+
+func TestAttestationsIgnoreDuplicateVotes(t *testing.T) {
+	state, genesis, target := setup(t)
+
+	state, _ = applyBlock(t, state, 2, votesFor(genesis, target, 0, 0, 0, 1))
+
+	if state.LatestJustified.Slot != 0 {
+		t.Error("repeated votes from one validator counted more than once")
+	}
+}
+
+func TestAttestationsTallyAcrossBlocks(t *testing.T) {
+	state, genesis, target := setup(t)
+
+	state, _ = applyBlock(t, state, 2, votesFor(genesis, target, 0, 1))
+	state, _ = applyBlock(t, state, 3, votesFor(genesis, target, 1, 3))
+
+	if state.LatestJustified != target {
+		t.Errorf("LatestJustified = %+v, want %+v", state.LatestJustified, target)
+	}
+}
+
+// signedBlock builds and signs a block at slot on top of s carrying the
+// given votes, signing each vote with its validator's devnet key.
+func signedBlock(t *testing.T, s *types.State, slot types.Slot, votes []types.SignedVote) *types.SignedBlock {
+	t.Helper()
+	for i := range votes {
+		signed, err := SignVote(xmss.DevnetKey(votes[i].Data.ValidatorID), &votes[i].Data)
+		if err != nil {
+			t.Fatalf("SignVote failed: %v", err)
+		}
+		votes[i] = *signed
+	}
+
+	advanced, err := ProcessSlots(s, slot)
+	if err != nil {
+		t.Fatalf("ProcessSlots(%d) failed: %v", slot, err)
+	}
+	parent, _ := advanced.LatestBlockHeader.HashTreeRoot()
+	block := &types.Block{
+		Slot:          slot,
+		ProposerIndex: uint64(slot) % testValidators,
+		ParentRoot:    parent,
+		Body:          types.BlockBody{Attestations: votes},
+	}
+	post, err := ProcessBlock(advanced, block)
+	if err != nil {
+		t.Fatalf("ProcessBlock(%d) failed: %v", slot, err)
+	}
+	block.StateRoot, _ = post.HashTreeRoot()
+
+	signed, err := SignBlock(xmss.DevnetKey(block.ProposerIndex), block)
+	if err != nil {
+		t.Fatalf("SignBlock failed: %v", err)
+	}
+	return signed
+}
+
+func TestStateTransitionVerifiesSignatures(t *testing.T) {
+	state, genesis, target := setup(t)
+
+	valid := signedBlock(t, state, 2, votesFor(genesis, target, 0, 1, 2))
+	if _, err := StateTransition(state, valid, true); err != nil {
+		t.Fatalf("StateTransition failed: %v", err)
+	}
+
+	badProposer := *valid
+	badProposer.Signature[0] ^= 1
+
+	badVote := *valid
+	badVote.Message.Body.Attestations = append([]types.SignedVote{}, valid.Message.Body.Attestations...)
+	badVote.Message.Body.Attestations[1].Signature[0] ^= 1
+
+	wrongKey, err := SignBlock(xmss.DevnetKey(0), &valid.Message)
+	if err != nil {
+		t.Fatalf("SignBlock failed: %v", err)
+	}
+
+	for name, block := range map[string]*types.SignedBlock{
+		"proposer signature":    &badProposer,
+		"attestation signature": &badVote,
+		"wrong proposer key":    wrongKey,
+	} {
+		if _, err := StateTransition(state, block, true); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/types"
+)
+
+// Files written by the genesis command
+const (
+	genesisStateFile = "genesis.ssz"
+	genesisRootFile  = "genesis_root.txt"
+)
+
+type genesisCmd struct {
+	Config             string `required:"" type:"existingfile" help:"Genesis config (lean-quickstart config.yaml)"`
+	OutputDir          string `default:"." help:"Directory for the genesis state and its root"`
+	InsecureDevnetKeys bool   `help:"Use the devnet keys, which anyone can derive, when the config has no GENESIS_VALIDATORS"`
+}
+
+// Run writes the SSZ genesis state described by the config and its root.
+func (c *genesisCmd) Run() error {
+	cfg, err := chain.ReadGenesisConfig(c.Config)
+	if err != nil {
+		return err
+	}
+	validators, err := genesisValidators(cfg, c.InsecureDevnetKeys)
+	if err != nil {
+		return err
+	}
+	state := chain.GenerateGenesis(cfg.GenesisTime, validators)
+	data, err := state.MarshalSSZ()
+	if err != nil {
+		return fmt.Errorf("encode genesis state: %w", err)
+	}
+	stateRoot, err := state.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash genesis state: %w", err)
+	}
+	blockRoot, err := chain.GenesisBlock(state).HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash genesis block: %w", err)
+	}
+
+	if err := os.MkdirAll(c.OutputDir, 0o755); err != nil {
+		return fmt.Errorf("create output dir: %w", err)
+	}
+	statePath := filepath.Join(c.OutputDir, genesisStateFile)
+	if err := os.WriteFile(statePath, data, 0o644); err != nil {
+		return fmt.Errorf("write genesis state: %w", err)
+	}
+	rootHex := "0x" + hex.EncodeToString(stateRoot[:])
+	if err := os.WriteFile(filepath.Join(c.OutputDir, genesisRootFile), []byte(rootHex+"\n"), 0o644); err != nil {
+		return fmt.Errorf("write genesis root: %w", err)
+	}
+
+	fmt.Printf("genesis state: %s\n", statePath)
+	fmt.Printf("genesis time: %d\n", cfg.GenesisTime)
+	fmt.Printf("validators: %d\n", cfg.ValidatorCount)
+	fmt.Printf("state root: %s\n", rootHex)
+	fmt.Printf("block root: 0x%s\n", hex.EncodeToString(blockRoot[:]))
+	return nil
+}
+
+// genesisValidators returns the config's validator registry, falling back to
+// the devnet keys for VALIDATOR_COUNT validators only if insecure is set.
+func genesisValidators(cfg *chain.GenesisConfig, insecure bool) ([]types.Validator, error) {
+	validators, err := cfg.Validators()
+	if errors.Is(err, chain.ErrNoGenesisValidators) && insecure {
+		return chain.DevnetValidators(cfg.ValidatorCount), nil
+	}
+	if errors.Is(err, chain.ErrNoGenesisValidators) {
+		return nil, fmt.Errorf("%w; list the validator pubkeys, or allow the devnet keys for a local devnet with --insecure-devnet-keys", err)
+	}
+	return validators, err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/devylongs/gean/node"
+	"github.com/devylongs/gean/p2p"
+	"github.com/devylongs/gean/p2p/discover"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	manet "github.com/multiformats/go-multiaddr/net"
+)
+
+type identityCmd struct {
+	NodeKey       string `name:"node-key" help:"Node key file (hex secp256k1 key); created if missing"`
+	DataDir       string `name:"datadir" help:"Data directory holding the node key, when --node-key is not set"`
+	Listen        string `default:"/ip4/0.0.0.0/udp/9000/quic-v1" help:"Listen multiaddr (QUIC) the node runs with"`
+	DiscoveryPort int    `default:"9100" help:"UDP discovery port the node runs with"`
+	ENRIP         string `name:"enr-ip" help:"IP to advertise (optional, defaults to the first listen address)"`
+}
+
+// Run prints the peer ID, multiaddr and ENR of the node key, creating the
+// key first if needed, so bootnode lists can be written before the node
+// first starts.
+func (c *identityCmd) Run() error {
+	path := node.NodeKeyPath(c.NodeKey, c.DataDir)
+	if path == "" {
+		return fmt.Errorf("one of --node-key or --datadir is required")
+	}
+	key, created, err := p2p.LoadNodeKey(path)
+	if err != nil {
+		return err
+	}
+	if created {
+		fmt.Printf("created node key %s\n", path)
+	}
+	id, err := peer.IDFromPrivateKey(key)
+	if err != nil {
+		return err
+	}
+
+	listen, err := multiaddr.NewMultiaddr(c.Listen)
+	if err != nil {
+		return fmt.Errorf("invalid --listen: %w", err)
+	}
+	addrs := []multiaddr.Multiaddr{listen}
+	if ifaceAddrs, err := manet.InterfaceMultiaddrs(); err == nil {
+		if resolved, err := manet.ResolveUnspecifiedAddresses(addrs, ifaceAddrs); err == nil {
+			addrs = resolved
+		}
+	}
+	ip, quicPort := p2p.LocalEndpoint(addrs)
+	if c.ENRIP != "" {
+		if ip = net.ParseIP(c.ENRIP); ip == nil {
+			return fmt.Errorf("invalid --enr-ip %q", c.ENRIP)
+		}
+	}
+
+	discoveryKey, err := p2p.Secp256k1Key(key)
+	if err != nil {
+		return err
+	}
+	record, err := discover.LocalRecord(discoveryKey, ip, c.DiscoveryPort, quicPort)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("peer id:   %s\n", id)
+	fmt.Printf("multiaddr: /ip4/%s/udp/%d/quic-v1/p2p/%s\n", ip, quicPort, id)
+	fmt.Printf("enr:       %s\n", record)
+	return nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/devylongs/gean/keystore"
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+// Layout of the keys output directory
+const (
+	keystoresDir = "keystores"
+	registryFile = "validators.json"
+)
+
+type keysCmd struct {
+	Count        uint64 `default:"1" help:"Number of validator keys to generate"`
+	OutputDir    string `default:"keys" help:"Directory for the keystores and validator registry"`
+	PasswordFile string `required:"" type:"existingfile" help:"File holding the keystore password"`
+	Devnet       bool   `help:"Write the deterministic devnet keys for indices 0 to count-1 instead of random keys"`
+	Light        bool   `help:"Use light scrypt parameters (fast to unlock, only for throwaway devnets)"`
+}
+
+// Run generates the keys, writes one keystore per key and a registry of
+// their public keys in validator index order.
+func (c *keysCmd) Run() error {
+	password, err := readPassword(c.PasswordFile)
+	if err != nil {
+		return err
+	}
+	scryptN := keystore.StandardScryptN
+	if c.Light {
+		scryptN = keystore.LightScryptN
+	}
+
+	dir := filepath.Join(c.OutputDir, keystoresDir)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return fmt.Errorf("create keystore dir: %w", err)
+	}
+
+	registry := make([]types.Validator, 0, c.Count)
+	for i := uint64(0); i < c.Count; i++ {
+		key := xmss.DevnetKey(i)
+		if !c.Devnet {
+			if key, err = xmss.GenerateKey(rand.Reader); err != nil {
+				return fmt.Errorf("generate key %d: %w", i, err)
+			}
+		}
+
+		ks, err := keystore.Encrypt(key, password, scryptN)
+		if err != nil {
+			return fmt.Errorf("encrypt key %d: %w", i, err)
+		}
+		path, err := ks.Save(dir)
+		if err != nil {
+			return err
+		}
+		registry = append(registry, types.Validator{Pubkey: types.Pubkey(key.PublicKey())})
+		fmt.Printf("validator %d: %s\n", i, path)
+	}
+
+	path := filepath.Join(c.OutputDir, registryFile)
+	if err := writeRegistry(path, registry); err != nil {
+		return err
+	}
+	fmt.Printf("registry: %s\n", path)
+	return nil
+}
+
+// readPassword reads a password file, ignoring a trailing newline.
+func readPassword(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read password file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// writeRegistry writes the validator public keys as a JSON list of hex strings.
+func writeRegistry(path string, validators []types.Validator) error {
+	pubkeys := make([]string, len(validators))
+	for i, v := range validators {
+		pubkeys[i] = "0x" + hex.EncodeToString(v.Pubkey[:])
+	}
+	data, err := json.MarshalIndent(pubkeys, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write registry: %w", err)
+	}
+	return nil
+}
+
+// readRegistry reads a registry written by writeRegistry.
+func readRegistry(path string) ([]types.Validator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pubkeys []string
+	if err := json.Unmarshal(data, &pubkeys); err != nil {
+		return nil, err
+	}
+
+	validators := make([]types.Validator, len(pubkeys))
+	for i, s := range pubkeys {
+		b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+		if err != nil || len(b) != types.PubkeySize {
+			return nil, fmt.Errorf("validator %d: invalid pubkey %q", i, s)
+		}
+		copy(validators[i].Pubkey[:], b)
+	}
+	return validators, nil
+}
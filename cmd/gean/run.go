@@ -0,0 +1,236 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/keystore"
+	"github.com/devylongs/gean/node"
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+type runCmd struct {
+	GenesisTime          uint64   `help:"Genesis time (Unix timestamp). Defaults to the persisted genesis, or 10 seconds from now."`
+	Validators           uint64   `default:"8" help:"Number of validators in the network"`
+	GenesisConfig        string   `type:"existingfile" help:"Genesis config (lean-quickstart config.yaml); sets the genesis time and validators"`
+	GenesisValidators    string   `type:"existingfile" help:"Validator registry written by 'gean keys' (optional, defaults to the devnet keys for --validators with --insecure-devnet-keys)"`
+	ValidatorIndices     []uint64 `aliases:"validator-index" help:"Validator indices to run with devnet keys (optional, omit for non-validator)"`
+	KeystoreDir          string   `type:"existingdir" help:"Directory of validator keystores; validator indices are taken from the registry"`
+	KeystorePasswordFile string   `type:"existingfile" help:"File holding the keystore password"`
+	Listen               string   `default:"/ip4/0.0.0.0/udp/9000/quic-v1" help:"Listen multiaddr (QUIC)"`
+	NodeKey              string   `name:"node-key" help:"Node key file (hex secp256k1 key), created if missing; defaults to node.key in the data directory"`
+	Bootnodes            []string `help:"Bootnodes, as multiaddrs with a /p2p peer ID or as ENRs"`
+	DiscoveryPort        int      `default:"9100" help:"UDP port for discv5 peer discovery (0 disables discovery)"`
+	ENRIP                string   `name:"enr-ip" help:"IP to advertise in the local node record (optional, defaults to the first listen address)"`
+	TargetPeers          int      `default:"16" help:"Number of peers to keep connected; bootnodes, known and discovered peers are dialed while below it"`
+	MaxInboundPeers      int      `default:"32" help:"Maximum number of peers that dialed us"`
+	CheckpointSyncURL    string   `name:"checkpoint-sync-url" help:"API URL of a gean node to fetch the finalized checkpoint from, e.g. http://10.0.0.2:5052"`
+	CheckpointRoot       string   `help:"Trusted 0x-prefixed root of the finalized block to fetch (required with --checkpoint-sync-url)"`
+	CheckpointState      string   `type:"existingfile" help:"SSZ finalized state file to start from (with --checkpoint-block)"`
+	CheckpointBlock      string   `type:"existingfile" help:"SSZ signed block file of the finalized checkpoint (with --checkpoint-state)"`
+	DataDir              string   `name:"datadir" help:"Directory for the chain database (optional, omit to keep data in memory)"`
+	APIAddr              string   `name:"api-addr" help:"HTTP API listen address, e.g. 127.0.0.1:5052 (optional, omit to disable the API)"`
+	MetricsAddr          string   `name:"metrics-addr" help:"Prometheus metrics listen address, e.g. 127.0.0.1:9090 (optional, omit to disable metrics)"`
+	LogLevel             string   `default:"info" enum:"debug,info,warn,error" help:"Log level"`
+	ExcludeEquivocators  bool     `help:"Ignore the fork choice votes of validators seen signing conflicting blocks or votes"`
+	InsecureDevnetKeys   bool     `help:"Allow the devnet keys, which anyone can derive, for the genesis registry and --validator-indices (local devnets only)"`
+}
+
+func (c *runCmd) Run() error {
+	fmt.Println("\n━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━ gean ━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━━")
+
+	// Setup logger
+	level := slog.LevelInfo
+	switch c.LogLevel {
+	case "debug":
+		level = slog.LevelDebug
+	case "warn":
+		level = slog.LevelWarn
+	case "error":
+		level = slog.LevelError
+	}
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+
+	// Build node config
+	nodeCfg := &node.Config{
+		GenesisTime:      c.GenesisTime,
+		ValidatorCount:   c.Validators,
+		ValidatorIndices: c.ValidatorIndices,
+		ListenAddrs:      []string{c.Listen},
+		NodeKeyFile:      c.NodeKey,
+		Bootnodes:        c.Bootnodes,
+		DiscoveryPort:    c.DiscoveryPort,
+		TargetPeers:      c.TargetPeers,
+		MaxInboundPeers:  c.MaxInboundPeers,
+		DataDir:          c.DataDir,
+		APIAddr:          c.APIAddr,
+		MetricsAddr:      c.MetricsAddr,
+		Logger:           logger,
+
+		ExcludeEquivocators: c.ExcludeEquivocators,
+		InsecureDevnetKeys:  c.InsecureDevnetKeys,
+	}
+
+	if c.ENRIP != "" {
+		ip := net.ParseIP(c.ENRIP)
+		if ip == nil {
+			return fmt.Errorf("invalid --enr-ip %q", c.ENRIP)
+		}
+		nodeCfg.AdvertiseIP = ip
+	}
+
+	if c.GenesisConfig != "" {
+		if err := c.applyGenesisConfig(nodeCfg); err != nil {
+			return err
+		}
+	}
+
+	if c.GenesisValidators != "" {
+		registry, err := readRegistry(c.GenesisValidators)
+		if err != nil {
+			return fmt.Errorf("read validator registry: %w", err)
+		}
+		nodeCfg.GenesisValidators = registry
+	}
+
+	if c.KeystoreDir != "" {
+		keys, err := c.loadKeystores()
+		if err != nil {
+			return err
+		}
+		nodeCfg.ValidatorKeys = keys
+		logger.Info("loaded keystores", "count", len(keys))
+	}
+
+	// Create and start node
+	ctx, cancel := context.WithCancel(context.Background())
+
+	anchor, err := c.loadAnchor(ctx)
+	if err != nil {
+		cancel()
+		return err
+	}
+	if anchor != nil {
+		nodeCfg.Anchor = anchor
+		logger.Info("loaded checkpoint", "slot", anchor.Block.Message.Slot)
+	}
+
+	n, err := node.New(ctx, nodeCfg)
+	if err != nil {
+		logger.Error("failed to create node", "error", err)
+		os.Exit(1)
+	}
+
+	if len(nodeCfg.ValidatorIndices) > 0 {
+		logger.Info("running validators", "indices", nodeCfg.ValidatorIndices)
+	}
+
+	logger.Info("config",
+		"genesis_time", nodeCfg.GenesisTime,
+		"validators", nodeCfg.ValidatorCount,
+		"bootnodes", len(c.Bootnodes),
+		"datadir", c.DataDir,
+	)
+
+	if err := n.Start(); err != nil {
+		logger.Error("failed to start node", "error", err)
+		n.Stop()
+		os.Exit(1)
+	}
+	logger.Info("gean running", "slot", n.CurrentSlot(), "peers", n.PeerCount())
+
+	// Wait for shutdown
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	<-sigCh
+
+	logger.Info("shutting down...")
+	n.Stop()
+	cancel()
+	return nil
+}
+
+// applyGenesisConfig takes the genesis time and validator registry from the
+// genesis config file.
+func (c *runCmd) applyGenesisConfig(nodeCfg *node.Config) error {
+	if c.GenesisValidators != "" {
+		return fmt.Errorf("--genesis-config and --genesis-validators are mutually exclusive")
+	}
+	genesis, err := chain.ReadGenesisConfig(c.GenesisConfig)
+	if err != nil {
+		return err
+	}
+	if c.GenesisTime != 0 && c.GenesisTime != genesis.GenesisTime {
+		return fmt.Errorf("--genesis-time %d does not match the genesis config time %d", c.GenesisTime, genesis.GenesisTime)
+	}
+	validators, err := genesisValidators(genesis, c.InsecureDevnetKeys)
+	if err != nil {
+		return err
+	}
+	nodeCfg.GenesisTime = genesis.GenesisTime
+	nodeCfg.GenesisValidators = validators
+	return nil
+}
+
+// loadAnchor reads or fetches the checkpoint to start from, if one is set.
+func (c *runCmd) loadAnchor(ctx context.Context) (*node.Anchor, error) {
+	switch {
+	case c.CheckpointSyncURL != "" && (c.CheckpointState != "" || c.CheckpointBlock != ""):
+		return nil, fmt.Errorf("--checkpoint-sync-url and checkpoint files are mutually exclusive")
+	case c.CheckpointSyncURL != "":
+		if c.CheckpointRoot == "" {
+			return nil, fmt.Errorf("--checkpoint-root is required with --checkpoint-sync-url")
+		}
+		root, err := parseRoot(c.CheckpointRoot)
+		if err != nil {
+			return nil, fmt.Errorf("--checkpoint-root: %w", err)
+		}
+		return node.FetchAnchor(ctx, c.CheckpointSyncURL, root)
+	case c.CheckpointRoot != "":
+		return nil, fmt.Errorf("--checkpoint-root is only used with --checkpoint-sync-url")
+	case c.CheckpointState != "" && c.CheckpointBlock != "":
+		return node.ReadAnchor(c.CheckpointState, c.CheckpointBlock)
+	case c.CheckpointState != "" || c.CheckpointBlock != "":
+		return nil, fmt.Errorf("--checkpoint-state and --checkpoint-block must be given together")
+	}
+	return nil, nil
+}
+
+// parseRoot decodes a 0x-prefixed hex block root.
+func parseRoot(s string) (types.Root, error) {
+	var root types.Root
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != len(root) {
+		return root, fmt.Errorf("invalid root %q", s)
+	}
+	copy(root[:], b)
+	return root, nil
+}
+
+// loadKeystores decrypts the keystores in the keystore directory.
+func (c *runCmd) loadKeystores() ([]*xmss.PrivateKey, error) {
+	if c.KeystorePasswordFile == "" {
+		return nil, fmt.Errorf("--keystore-password-file is required with --keystore-dir")
+	}
+	password, err := readPassword(c.KeystorePasswordFile)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := keystore.LoadDir(c.KeystoreDir, password)
+	if err != nil {
+		return nil, fmt.Errorf("load keystores: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no keystores found in %s", c.KeystoreDir)
+	}
+	return keys, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/devylongs/gean/node"
+	"github.com/devylongs/gean/slashing"
+)
+
+type slashingCmd struct {
+	Import slashingImportCmd `cmd:"" help:"Import slashing protection records from an interchange file"`
+	Export slashingExportCmd `cmd:"" help:"Export slashing protection records to an interchange file"`
+}
+
+type slashingImportCmd struct {
+	DataDir     string `name:"datadir" required:"" help:"Data directory of the node"`
+	GenesisTime uint64 `required:"" help:"Genesis time (Unix timestamp) of the network the records belong to"`
+	File        string `arg:"" type:"existingfile" help:"Interchange JSON file"`
+}
+
+// Run merges the interchange file into the node's slashing protection database.
+func (c *slashingImportCmd) Run() error {
+	data, err := os.ReadFile(c.File)
+	if err != nil {
+		return err
+	}
+	var ic slashing.Interchange
+	if err := json.Unmarshal(data, &ic); err != nil {
+		return fmt.Errorf("parse interchange: %w", err)
+	}
+
+	if err := os.MkdirAll(c.DataDir, 0o700); err != nil {
+		return fmt.Errorf("create data dir: %w", err)
+	}
+	db, err := slashing.Open(filepath.Join(c.DataDir, node.SlashingDatabaseFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if err := db.Import(&ic, c.GenesisTime); err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+	fmt.Printf("imported records for %d validators\n", len(ic.Data))
+	return nil
+}
+
+type slashingExportCmd struct {
+	DataDir     string `name:"datadir" required:"" type:"existingdir" help:"Data directory of the node"`
+	GenesisTime uint64 `required:"" help:"Genesis time (Unix timestamp) of the node's network"`
+	File        string `arg:"" help:"Interchange JSON file to write"`
+}
+
+// Run writes every record in the node's slashing protection database.
+func (c *slashingExportCmd) Run() error {
+	db, err := slashing.Open(filepath.Join(c.DataDir, node.SlashingDatabaseFile))
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ic, err := db.Export(c.GenesisTime)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+	data, err := json.MarshalIndent(ic, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(c.File, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write interchange: %w", err)
+	}
+	fmt.Printf("exported records for %d validators to %s\n", len(ic.Data), c.File)
+	return nil
+}
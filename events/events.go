@@ -0,0 +1,172 @@
+// Package events is an in-process bus for chain events: new heads, imported
+// blocks, accepted votes, checkpoint changes and reorgs.
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/devylongs/gean/types"
+)
+
+// Topic names a kind of event.
+type Topic string
+
+// Topics
+const (
+	TopicHead      Topic = "head"
+	TopicBlock     Topic = "block"
+	TopicVote      Topic = "vote"
+	TopicJustified Topic = "justified_checkpoint"
+	TopicFinalized Topic = "finalized_checkpoint"
+	TopicReorg     Topic = "chain_reorg"
+)
+
+// Topics lists every topic.
+var Topics = []Topic{TopicHead, TopicBlock, TopicVote, TopicJustified, TopicFinalized, TopicReorg}
+
+// Event is published on the bus.
+type Event interface {
+	Topic() Topic
+}
+
+// Head is published when the fork choice head changes.
+type Head struct {
+	Slot    types.Slot
+	Root    types.Root
+	OldRoot types.Root
+}
+
+// Block is published when a block is imported into fork choice.
+type Block struct {
+	Slot          types.Slot
+	Root          types.Root
+	ProposerIndex uint64
+}
+
+// Vote is published when a vote from gossip or one of our validators is
+// accepted.
+type Vote struct {
+	types.SignedVote
+}
+
+// Justified is published when the latest justified checkpoint changes.
+type Justified struct {
+	types.Checkpoint
+}
+
+// Finalized is published when the latest finalized checkpoint changes.
+type Finalized struct {
+	types.Checkpoint
+}
+
+// Reorg is published when the new head does not descend from the old one.
+// Depth is the number of slots the old head was past the common ancestor.
+type Reorg struct {
+	Slot    types.Slot
+	Depth   uint64
+	OldHead types.Root
+	NewHead types.Root
+}
+
+func (Head) Topic() Topic      { return TopicHead }
+func (Block) Topic() Topic     { return TopicBlock }
+func (Vote) Topic() Topic      { return TopicVote }
+func (Justified) Topic() Topic { return TopicJustified }
+func (Finalized) Topic() Topic { return TopicFinalized }
+func (Reorg) Topic() Topic     { return TopicReorg }
+
+// SubscriptionBuffer is the number of events queued for a subscriber before
+// further events are dropped.
+const SubscriptionBuffer = 256
+
+// Bus fans events out to subscribers. Publishing never blocks: events for a
+// subscriber that has fallen behind are dropped. A nil Bus discards events.
+type Bus struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBus creates an event bus.
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription to the given topics, or to every topic
+// when none are given.
+func (b *Bus) Subscribe(topics ...Topic) *Subscription {
+	sub := &Subscription{
+		bus: b,
+		ch:  make(chan Event, SubscriptionBuffer),
+	}
+	if len(topics) > 0 {
+		sub.topics = make(map[Topic]bool, len(topics))
+		for _, t := range topics {
+			sub.topics[t] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers an event to every subscriber of its topic.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		if sub.topics != nil && !sub.topics[e.Topic()] {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped.Add(1)
+		}
+	}
+}
+
+// Subscription receives the events of its topics.
+type Subscription struct {
+	bus     *Bus
+	topics  map[Topic]bool // nil for every topic
+	ch      chan Event
+	dropped atomic.Uint64
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns the number of events dropped because the subscriber fell
+// behind.
+func (s *Subscription) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+// Close unsubscribes and closes the events channel. It is safe to call more
+// than once.
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, exists := s.bus.subs[s]; exists {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// ParseTopic returns the topic with the given name.
+func ParseTopic(name string) (Topic, bool) {
+	for _, t := range Topics {
+		if string(t) == name {
+			return t, true
+		}
+	}
+	return "", false
+}
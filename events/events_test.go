@@ -0,0 +1,56 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/types"
+)
+
+func TestSubscribeFiltersTopics(t *testing.T) {
+	bus := NewBus()
+	heads := bus.Subscribe(TopicHead)
+	all := bus.Subscribe()
+	defer heads.Close()
+	defer all.Close()
+
+	bus.Publish(Block{Slot: 1})
+	bus.Publish(Head{Slot: 1})
+
+	if got := len(heads.Events()); got != 1 {
+		t.Fatalf("head subscriber has %d events, want 1", got)
+	}
+	if e := <-heads.Events(); e.Topic() != TopicHead {
+		t.Errorf("head subscriber got %s event", e.Topic())
+	}
+	if got := len(all.Events()); got != 2 {
+		t.Errorf("subscriber to every topic has %d events, want 2", got)
+	}
+}
+
+func TestPublishDropsForSlowSubscriber(t *testing.T) {
+	bus := NewBus()
+	sub := bus.Subscribe(TopicVote)
+
+	for i := 0; i < SubscriptionBuffer+3; i++ {
+		bus.Publish(Vote{types.SignedVote{Data: types.Vote{Slot: types.Slot(i)}}})
+	}
+	if sub.Dropped() != 3 {
+		t.Errorf("Dropped() = %d, want 3", sub.Dropped())
+	}
+
+	sub.Close()
+	sub.Close()
+	n := 0
+	for range sub.Events() {
+		n++
+	}
+	if n != SubscriptionBuffer {
+		t.Errorf("drained %d events after Close, want %d", n, SubscriptionBuffer)
+	}
+	bus.Publish(Vote{}) // no subscribers left
+}
+
+func TestNilBusDiscards(t *testing.T) {
+	var bus *Bus
+	bus.Publish(Head{})
+}
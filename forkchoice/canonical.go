@@ -0,0 +1,177 @@
+package forkchoice
+
+import "github.com/devylongs/gean/types"
+
+// canonicalIndex maps slots to the roots of the blocks on the chain ending
+// at the head, from the finalized block on. It covers every slot from low to
+// head; a slot in that range without an entry was skipped. The finalized
+// chain before low is indexed in the database.
+type canonicalIndex struct {
+	roots map[types.Slot]types.Root
+	low   types.Slot
+	head  types.Slot
+}
+
+// CanonicalRoot returns the root of the block at slot on the chain ending at
+// the head. It reports false for a skipped slot, a slot after the head, or a
+// slot before the blocks held by the store and its database.
+func (s *Store) CanonicalRoot(slot types.Slot) (types.Root, bool) {
+	roots := s.CanonicalRoots(slot, 1, 1)
+	if len(roots) == 0 {
+		return types.Root{}, false
+	}
+	return roots[0], true
+}
+
+// CanonicalRoots returns the roots of the canonical blocks at count slots
+// from start, step slots apart, oldest first. Skipped slots are left out.
+// Finalized slots are read from the database without holding the store
+// lock, so serving history does not hold up block imports.
+func (s *Store) CanonicalRoots(start types.Slot, count, step uint64) []types.Root {
+	s.buildCanonical()
+
+	s.mu.RLock()
+	idx, db := s.canonical, s.db
+	var finalized []types.Slot // before the index, looked up in the database
+	var roots []types.Root
+	for i := uint64(0); i < count; i++ {
+		slot := start + types.Slot(i*step)
+		if slot > idx.head {
+			break
+		}
+		if slot < idx.low {
+			finalized = append(finalized, slot)
+			continue
+		}
+		if root, ok := idx.roots[slot]; ok {
+			roots = append(roots, root)
+		}
+	}
+	s.mu.RUnlock()
+
+	if db == nil || len(finalized) == 0 {
+		return roots
+	}
+	var history []types.Root
+	for _, slot := range finalized {
+		if root, err := db.CanonicalRoot(slot); err == nil {
+			history = append(history, root)
+		}
+	}
+	return append(history, roots...)
+}
+
+// buildCanonical builds the index on first use.
+func (s *Store) buildCanonical() {
+	s.mu.RLock()
+	built := s.canonical != nil
+	s.mu.RUnlock()
+	if built {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.canonical == nil {
+		s.resetCanonical()
+	}
+}
+
+// updateCanonical moves the index to a new head. It walks back from the head
+// to the first block already indexed, replacing the old chain's slots above
+// that block.
+func (s *Store) updateCanonical() {
+	idx := s.canonical
+	if idx == nil {
+		s.resetCanonical()
+		return
+	}
+
+	var path []types.Root // new chain above the fork point, newest first
+	root, block := s.Head, s.Blocks[s.Head]
+	for block.Slot < idx.low || block.Slot > idx.head || idx.roots[block.Slot] != root {
+		path = append(path, root)
+		parent, exists := s.Blocks[block.ParentRoot]
+		if !exists || block.Slot <= idx.low {
+			s.resetCanonical()
+			return
+		}
+		root, block = block.ParentRoot, parent
+	}
+
+	for slot := block.Slot + 1; slot <= idx.head; slot++ {
+		delete(idx.roots, slot)
+	}
+	for _, root := range path {
+		idx.roots[s.Blocks[root].Slot] = root
+	}
+	idx.head = s.Blocks[s.Head].Slot
+}
+
+// resetCanonical rebuilds the index from the head back through the blocks
+// held in memory.
+func (s *Store) resetCanonical() {
+	idx := &canonicalIndex{
+		roots: make(map[types.Slot]types.Root),
+		head:  s.Blocks[s.Head].Slot,
+	}
+	for root := s.Head; ; {
+		block, exists := s.Blocks[root]
+		if !exists {
+			break
+		}
+		idx.roots[block.Slot] = root
+		idx.low = block.Slot
+		if block.Slot == 0 {
+			break
+		}
+		root = block.ParentRoot
+	}
+	s.canonical = idx
+}
+
+// pruneCanonical drops the slots before the finalized block from the index
+// once finalization has written them to the database.
+func (s *Store) pruneCanonical() {
+	idx := s.canonical
+	finalizedSlot := s.LatestFinalized.Slot
+	if idx == nil || idx.low >= finalizedSlot {
+		return
+	}
+	for slot := range idx.roots {
+		if slot < finalizedSlot {
+			delete(idx.roots, slot)
+		}
+	}
+	idx.low = finalizedSlot
+}
+
+// finalizedChain returns the roots by slot of the finalized block and its
+// ancestors held in memory.
+func (s *Store) finalizedChain() map[types.Slot]types.Root {
+	chain := make(map[types.Slot]types.Root)
+	for root := s.LatestFinalized.Root; ; {
+		block, exists := s.Blocks[root]
+		if !exists {
+			break
+		}
+		chain[block.Slot] = root
+		root = block.ParentRoot
+	}
+	return chain
+}
+
+// heldBlock returns a block from memory or, failing that, the database.
+func (s *Store) heldBlock(root types.Root) (*types.SignedBlock, bool) {
+	if signedBlock, exists := s.signedBlock(root); exists {
+		return signedBlock, true
+	}
+	if s.db == nil {
+		return nil, false
+	}
+	signedBlock, err := s.db.Block(root)
+	if err != nil {
+		return nil, false
+	}
+	return signedBlock, true
+}
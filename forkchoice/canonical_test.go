@@ -0,0 +1,80 @@
+package forkchoice
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/types"
+)
+
+func TestCanonicalRootFollowsReorg(t *testing.T) {
+	store := newTestStore(t, 10)
+	for slot := types.Slot(0); slot <= 10; slot++ {
+		if root, ok := store.CanonicalRoot(slot); !ok || root != rootAtSlot(t, store, slot) {
+			t.Fatalf("CanonicalRoot(%d) = %x, %v", slot, root[:4], ok)
+		}
+	}
+	if _, ok := store.CanonicalRoot(11); ok {
+		t.Error("CanonicalRoot after the head succeeded")
+	}
+
+	// Reorg onto a block at slot 12 built on slot 5
+	fork := childBlock(t, store, rootAtSlot(t, store, 5), 12)
+	if err := store.ProcessBlock(fork); err != nil {
+		t.Fatalf("ProcessBlock(fork) failed: %v", err)
+	}
+	forkRoot, _ := fork.Message.HashTreeRoot()
+	store.LatestKnownVotes[0] = types.Checkpoint{Root: forkRoot, Slot: 12}
+	store.UpdateHead()
+	if store.Head != forkRoot {
+		t.Fatal("head did not move to the fork")
+	}
+
+	if _, ok := store.CanonicalRoot(8); ok {
+		t.Error("slot 8 of the old chain is still canonical")
+	}
+	got := store.CanonicalRoots(4, 10, 1)
+	want := []types.Root{rootAtSlot(t, store, 4), rootAtSlot(t, store, 5), forkRoot}
+	if len(got) != len(want) {
+		t.Fatalf("CanonicalRoots(4, 10, 1) returned %d roots, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("CanonicalRoots(4, 10, 1)[%d] = %x, want %x", i, got[i][:4], want[i][:4])
+		}
+	}
+	if got := store.CanonicalRoots(0, 3, 2); len(got) != 3 || got[2] != rootAtSlot(t, store, 4) {
+		t.Errorf("CanonicalRoots(0, 3, 2) = %d roots, want slots 0, 2 and 4", len(got))
+	}
+}
+
+func TestCanonicalRootReadsDatabase(t *testing.T) {
+	store := newTestStore(t, 10)
+	want := rootAtSlot(t, store, 3)
+
+	db := &memoryDB{blocks: make(map[types.Root]*types.SignedBlock)}
+	store.SetDatabase(db)
+	if err := store.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	if _, ok := store.CanonicalRoot(3); !ok {
+		t.Fatal("CanonicalRoot(3) failed before pruning")
+	}
+	store.LatestFinalized = types.Checkpoint{Root: rootAtSlot(t, store, 6), Slot: 6}
+	store.prune()
+
+	if _, exists := store.Blocks[want]; exists {
+		t.Fatal("block at slot 3 was not pruned from memory")
+	}
+	if len(db.canonical) != 7 || db.canonical[3] != want {
+		t.Errorf("database indexes %d finalized slots, want slots 0 to 6", len(db.canonical))
+	}
+	if idx := store.canonical; idx.low != 6 || len(idx.roots) != 5 {
+		t.Errorf("index holds %d slots from %d, want only slots 6 to 10", len(idx.roots), idx.low)
+	}
+	if root, ok := store.CanonicalRoot(3); !ok || root != want {
+		t.Errorf("CanonicalRoot(3) = %x, %v, want the pruned block from the database", root[:4], ok)
+	}
+	if _, ok := store.SignedBlock(want); !ok {
+		t.Error("SignedBlock did not fall back to the database")
+	}
+}
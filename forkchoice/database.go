@@ -0,0 +1,159 @@
+package forkchoice
+
+import (
+	"fmt"
+	"maps"
+
+	"github.com/devylongs/gean/types"
+)
+
+// Database persists the fork choice store so a node can resume after a restart.
+type Database interface {
+	// PutBlock stores a signed block together with its post-state.
+	// state is nil when the store no longer holds it.
+	PutBlock(root types.Root, block *types.SignedBlock, state *types.State) error
+
+	// PutForkChoice stores the fork choice checkpoints and latest votes.
+	PutForkChoice(checkpoints *Checkpoints, votes *Votes) error
+
+	// Prune deletes the given blocks and states.
+	Prune(blocks, states []types.Root) error
+
+	// Block returns a stored signed block, including canonical blocks
+	// before the finalized checkpoint that the store no longer holds.
+	Block(root types.Root) (*types.SignedBlock, error)
+
+	// PutCanonical records the roots of finalized blocks by slot.
+	PutCanonical(roots map[types.Slot]types.Root) error
+
+	// CanonicalRoot returns the root of the finalized block at slot.
+	CanonicalRoot(slot types.Slot) (types.Root, error)
+}
+
+// Checkpoints are the fork choice pointers persisted alongside blocks and states.
+type Checkpoints struct {
+	Head            types.Root
+	SafeTarget      types.Root
+	LatestJustified types.Checkpoint
+	LatestFinalized types.Checkpoint
+}
+
+// Votes are the latest votes persisted alongside the checkpoints.
+type Votes struct {
+	Known  map[types.ValidatorIndex]types.Checkpoint
+	New    map[types.ValidatorIndex]types.Checkpoint
+	Signed map[types.ValidatorIndex]types.SignedVote // For inclusion in blocks we propose
+}
+
+// SetDatabase makes the store write through every imported block to db.
+// Fork choice checkpoints and votes are written by FlushForkChoice. Existing
+// contents are not written; see Persist.
+func (s *Store) SetDatabase(db Database) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.db = db
+}
+
+// Persist writes the full store contents to the database.
+func (s *Store) Persist() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db == nil {
+		return nil
+	}
+	for root := range s.Blocks {
+		signedBlock, _ := s.signedBlock(root)
+		if err := s.db.PutBlock(root, signedBlock, s.States[root]); err != nil {
+			return err
+		}
+	}
+	if err := s.db.PutCanonical(s.finalizedChain()); err != nil {
+		return err
+	}
+	return s.writeForkChoice()
+}
+
+// FlushForkChoice writes the fork choice checkpoints and votes to the
+// database if they changed since the last flush. Head updates and votes
+// only mark them changed, so the caller decides how often to write; the
+// node flushes once per slot and on shutdown.
+func (s *Store) FlushForkChoice() error {
+	s.mu.Lock()
+	if s.db == nil || !s.forkChoiceDirty {
+		s.mu.Unlock()
+		return nil
+	}
+	db, checkpoints, votes := s.db, s.checkpoints(), s.votes()
+	s.forkChoiceDirty = false
+	s.mu.Unlock()
+
+	// Write from the copies so imports are not held up by the disk
+	if err := db.PutForkChoice(checkpoints, votes); err != nil {
+		s.mu.Lock()
+		s.forkChoiceDirty = true
+		s.mu.Unlock()
+		return fmt.Errorf("persist fork choice: %w", err)
+	}
+	return nil
+}
+
+// writeForkChoice writes the checkpoints and votes to the database. Pruning
+// calls it before deleting anything, so the persisted head never points at a
+// block or state already gone from disk. The caller holds mu.
+func (s *Store) writeForkChoice() error {
+	if err := s.db.PutForkChoice(s.checkpoints(), s.votes()); err != nil {
+		return err
+	}
+	s.forkChoiceDirty = false
+	return nil
+}
+
+// Checkpoints returns the store's current fork choice pointers.
+func (s *Store) Checkpoints() *Checkpoints {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.checkpoints()
+}
+
+func (s *Store) checkpoints() *Checkpoints {
+	return &Checkpoints{
+		Head:            s.Head,
+		SafeTarget:      s.SafeTarget,
+		LatestJustified: s.LatestJustified,
+		LatestFinalized: s.LatestFinalized,
+	}
+}
+
+// SignedBlock returns the block with the given root and its proposer
+// signature, looking in the database for blocks pruned from memory.
+func (s *Store) SignedBlock(root types.Root) (*types.SignedBlock, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.heldBlock(root)
+}
+
+func (s *Store) signedBlock(root types.Root) (*types.SignedBlock, bool) {
+	block, exists := s.Blocks[root]
+	if !exists {
+		return nil, false
+	}
+	return &types.SignedBlock{Message: *block, Signature: s.Signatures[root]}, true
+}
+
+func (s *Store) persistBlock(root types.Root) error {
+	if s.db == nil {
+		return nil
+	}
+	signedBlock, _ := s.signedBlock(root)
+	return s.db.PutBlock(root, signedBlock, s.States[root])
+}
+
+// votes returns a copy of the store's latest votes.
+func (s *Store) votes() *Votes {
+	return &Votes{
+		Known:  maps.Clone(s.LatestKnownVotes),
+		New:    maps.Clone(s.LatestNewVotes),
+		Signed: maps.Clone(s.SignedVotes),
+	}
+}
@@ -0,0 +1,93 @@
+package forkchoice
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devylongs/gean/types"
+)
+
+// memoryDB keeps written blocks in memory.
+type memoryDB struct {
+	blocks    map[types.Root]*types.SignedBlock
+	canonical map[types.Slot]types.Root
+
+	forkChoiceWrites int
+	votes            *Votes
+}
+
+func (db *memoryDB) PutBlock(root types.Root, block *types.SignedBlock, state *types.State) error {
+	db.blocks[root] = block
+	return nil
+}
+
+func (db *memoryDB) PutForkChoice(checkpoints *Checkpoints, votes *Votes) error {
+	db.forkChoiceWrites++
+	db.votes = votes
+	return nil
+}
+
+func (db *memoryDB) Prune(blocks, states []types.Root) error {
+	for _, root := range blocks {
+		delete(db.blocks, root)
+	}
+	return nil
+}
+
+func (db *memoryDB) Block(root types.Root) (*types.SignedBlock, error) {
+	if block, exists := db.blocks[root]; exists {
+		return block, nil
+	}
+	return nil, errors.New("block not stored")
+}
+
+func (db *memoryDB) PutCanonical(roots map[types.Slot]types.Root) error {
+	if db.canonical == nil {
+		db.canonical = make(map[types.Slot]types.Root)
+	}
+	for slot, root := range roots {
+		db.canonical[slot] = root
+	}
+	return nil
+}
+
+func (db *memoryDB) CanonicalRoot(slot types.Slot) (types.Root, error) {
+	if root, exists := db.canonical[slot]; exists {
+		return root, nil
+	}
+	return types.Root{}, errors.New("slot not indexed")
+}
+
+func TestFlushForkChoiceOnlyWhenChanged(t *testing.T) {
+	store := newTestStore(t, 3)
+	db := &memoryDB{blocks: make(map[types.Root]*types.SignedBlock)}
+	store.SetDatabase(db)
+
+	flush := func() {
+		t.Helper()
+		if err := store.FlushForkChoice(); err != nil {
+			t.Fatalf("FlushForkChoice failed: %v", err)
+		}
+	}
+
+	flush()
+	if db.forkChoiceWrites != 1 {
+		t.Fatalf("first flush made %d writes, want 1", db.forkChoiceWrites)
+	}
+	flush()
+	if db.forkChoiceWrites != 1 {
+		t.Errorf("flush without changes made %d writes, want 1", db.forkChoiceWrites)
+	}
+
+	vote := types.SignedVote{Data: types.Vote{ValidatorID: 1, Slot: 3}}
+	store.mu.Lock()
+	store.processAttestation(&vote, false)
+	store.mu.Unlock()
+	flush()
+	if db.forkChoiceWrites != 2 {
+		t.Fatalf("flush after a vote made %d writes, want 2", db.forkChoiceWrites)
+	}
+	if db.votes.Signed[1] != vote {
+		t.Error("signed vote not persisted")
+	}
+}
@@ -0,0 +1,168 @@
+package forkchoice
+
+import "github.com/devylongs/gean/types"
+
+// MaxEquivocations is the number of most recent equivocations whose evidence
+// the store keeps.
+const MaxEquivocations = 1024
+
+// Equivocation is evidence that a validator signed two conflicting messages
+// for the same slot: two different blocks, or two different votes.
+type Equivocation struct {
+	Validator types.ValidatorIndex
+	Slot      types.Slot
+	Blocks    []types.SignedBlock // Both blocks of a double proposal
+	Votes     []types.SignedVote  // Both votes of a double vote
+}
+
+// Kind returns "proposal" or "vote".
+func (e *Equivocation) Kind() string {
+	if len(e.Blocks) > 0 {
+		return "proposal"
+	}
+	return "vote"
+}
+
+// Evidence returns the equivocations detected so far, oldest first.
+func (s *Store) Evidence() []Equivocation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Equivocation(nil), s.equivocations...)
+}
+
+// IsEquivocator reports whether the validator has been seen equivocating.
+func (s *Store) IsEquivocator(validator types.ValidatorIndex) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.equivocators[validator]
+}
+
+// proposal identifies a proposer's block slot.
+type proposal struct {
+	slot     types.Slot
+	proposer uint64
+}
+
+// checkDoubleProposal records evidence if the store already holds a
+// different block from the same proposer at the same slot, and indexes the
+// block otherwise.
+func (s *Store) checkDoubleProposal(root types.Root, signedBlock *types.SignedBlock) {
+	if s.proposals == nil {
+		s.proposals = make(map[proposal]types.Root, len(s.Blocks))
+		for blockRoot, block := range s.Blocks {
+			s.proposals[proposal{block.Slot, block.ProposerIndex}] = blockRoot
+		}
+	}
+
+	block := &signedBlock.Message
+	key := proposal{block.Slot, block.ProposerIndex}
+	otherRoot, exists := s.proposals[key]
+	other, held := s.Blocks[otherRoot]
+	if !exists || !held {
+		s.proposals[key] = root
+		return
+	}
+	if otherRoot != root {
+		s.recordEquivocation(Equivocation{
+			Validator: types.ValidatorIndex(block.ProposerIndex),
+			Slot:      block.Slot,
+			Blocks: []types.SignedBlock{
+				{Message: *other, Signature: s.Signatures[otherRoot]},
+				*signedBlock,
+			},
+		})
+	}
+}
+
+// checkDoubleVote records evidence if the validator already signed a
+// different vote for the same slot. The first vote of each validator at
+// every slot from the finalized one on is kept for the comparison.
+func (s *Store) checkDoubleVote(signedVote *types.SignedVote) {
+	vote := &signedVote.Data
+	if vote.Slot < s.LatestFinalized.Slot {
+		return
+	}
+	validator := types.ValidatorIndex(vote.ValidatorID)
+	if s.slotVotes == nil {
+		s.slotVotes = make(map[types.ValidatorIndex]map[types.Slot]types.SignedVote)
+	}
+	votes := s.slotVotes[validator]
+	if votes == nil {
+		votes = make(map[types.Slot]types.SignedVote)
+		s.slotVotes[validator] = votes
+	}
+
+	first, exists := votes[vote.Slot]
+	if !exists {
+		votes[vote.Slot] = *signedVote
+		return
+	}
+	if first.Data == *vote {
+		return
+	}
+	s.recordEquivocation(Equivocation{
+		Validator: validator,
+		Slot:      vote.Slot,
+		Votes:     []types.SignedVote{first, *signedVote},
+	})
+}
+
+// pruneEquivocationIndex drops the indexed blocks pruned from the store and
+// the votes from before the finalized slot.
+func (s *Store) pruneEquivocationIndex() {
+	for key, root := range s.proposals {
+		if _, held := s.Blocks[root]; !held {
+			delete(s.proposals, key)
+		}
+	}
+	for validator, votes := range s.slotVotes {
+		for slot := range votes {
+			if slot < s.LatestFinalized.Slot {
+				delete(votes, slot)
+			}
+		}
+		if len(votes) == 0 {
+			delete(s.slotVotes, validator)
+		}
+	}
+}
+
+// recordEquivocation keeps the first evidence of each kind for a validator
+// and slot, marks the validator as an equivocator and calls OnEquivocation.
+func (s *Store) recordEquivocation(e Equivocation) {
+	for i := range s.equivocations {
+		prev := &s.equivocations[i]
+		if prev.Validator == e.Validator && prev.Slot == e.Slot && prev.Kind() == e.Kind() {
+			return
+		}
+	}
+
+	if len(s.equivocations) == MaxEquivocations {
+		s.equivocations = append(s.equivocations[:0], s.equivocations[1:]...)
+	}
+	s.equivocations = append(s.equivocations, e)
+
+	if s.equivocators == nil {
+		s.equivocators = make(map[types.ValidatorIndex]bool)
+	}
+	s.equivocators[e.Validator] = true
+
+	if s.OnEquivocation != nil {
+		s.OnEquivocation(e)
+	}
+}
+
+// headVotes returns the votes that count towards the head, leaving out
+// equivocating validators when ExcludeEquivocators is set.
+func (s *Store) headVotes(votes map[types.ValidatorIndex]types.Checkpoint) map[types.ValidatorIndex]types.Checkpoint {
+	if !s.ExcludeEquivocators || len(s.equivocators) == 0 {
+		return votes
+	}
+	filtered := make(map[types.ValidatorIndex]types.Checkpoint, len(votes))
+	for validator, vote := range votes {
+		if !s.equivocators[validator] {
+			filtered[validator] = vote
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,133 @@
+package forkchoice
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+func TestDoubleProposalEvidence(t *testing.T) {
+	store := newTestStore(t, 1)
+	var seen []Equivocation
+	store.OnEquivocation = func(e Equivocation) { seen = append(seen, e) }
+
+	// Proposer 2 builds slot 2 on both genesis and the slot 1 block
+	forkA := childBlock(t, store, rootAtSlot(t, store, 0), 2)
+	forkB := childBlock(t, store, rootAtSlot(t, store, 1), 2)
+	for _, block := range []*types.SignedBlock{forkA, forkB, forkB} {
+		if err := store.ProcessBlock(block); err != nil {
+			t.Fatalf("ProcessBlock failed: %v", err)
+		}
+	}
+
+	evidence := store.Evidence()
+	if len(evidence) != 1 || len(seen) != 1 {
+		t.Fatalf("got %d equivocations and %d callbacks, want 1", len(evidence), len(seen))
+	}
+	e := evidence[0]
+	if e.Kind() != "proposal" || e.Validator != 2 || e.Slot != 2 || len(e.Blocks) != 2 {
+		t.Errorf("evidence = %s by %d at slot %d with %d blocks", e.Kind(), e.Validator, e.Slot, len(e.Blocks))
+	}
+	for _, block := range e.Blocks {
+		if err := chain.VerifyBlockSignature(store.Validators, &block); err != nil {
+			t.Errorf("evidence block signature: %v", err)
+		}
+	}
+
+	// The equivocator's vote decides the head unless it is excluded
+	rootA, _ := forkA.Message.HashTreeRoot()
+	rootB, _ := forkB.Message.HashTreeRoot()
+	store.LatestKnownVotes = map[types.ValidatorIndex]types.Checkpoint{
+		2: {Root: rootA, Slot: 2},
+		0: {Root: rootB, Slot: 2},
+	}
+	store.UpdateHead()
+	if store.Head != rootA {
+		t.Errorf("head = %x, want fork A %x", store.Head[:4], rootA[:4])
+	}
+	store.ExcludeEquivocators = true
+	store.UpdateHead()
+	if store.Head != rootB {
+		t.Errorf("head with equivocators excluded = %x, want fork B %x", store.Head[:4], rootB[:4])
+	}
+}
+
+func TestDoubleVoteEvidence(t *testing.T) {
+	store := newTestStore(t, 1)
+	genesis := types.Checkpoint{Root: rootAtSlot(t, store, 0), Slot: 0}
+	block1 := types.Checkpoint{Root: rootAtSlot(t, store, 1), Slot: 1}
+
+	sign := func(head, target types.Checkpoint) *types.SignedVote {
+		vote, err := chain.SignVote(xmss.DevnetKey(3), &types.Vote{
+			ValidatorID: 3,
+			Slot:        1,
+			Head:        head,
+			Target:      target,
+			Source:      genesis,
+		})
+		if err != nil {
+			t.Fatalf("SignVote failed: %v", err)
+		}
+		return vote
+	}
+
+	for _, vote := range []*types.SignedVote{sign(block1, block1), sign(block1, block1), sign(genesis, genesis)} {
+		if err := store.ProcessAttestation(vote); err != nil {
+			t.Fatalf("ProcessAttestation failed: %v", err)
+		}
+	}
+
+	evidence := store.Evidence()
+	if len(evidence) != 1 {
+		t.Fatalf("got %d equivocations, want 1", len(evidence))
+	}
+	e := evidence[0]
+	if e.Kind() != "vote" || e.Validator != 3 || e.Slot != 1 || len(e.Votes) != 2 {
+		t.Errorf("evidence = %s by %d at slot %d with %d votes", e.Kind(), e.Validator, e.Slot, len(e.Votes))
+	}
+	if !store.IsEquivocator(3) || store.IsEquivocator(2) {
+		t.Error("IsEquivocator does not match the evidence")
+	}
+}
+
+func TestDoubleVoteBehindLatestVote(t *testing.T) {
+	store := newTestStore(t, 2)
+	genesis := types.Checkpoint{Root: rootAtSlot(t, store, 0), Slot: 0}
+	block1 := types.Checkpoint{Root: rootAtSlot(t, store, 1), Slot: 1}
+	block2 := types.Checkpoint{Root: rootAtSlot(t, store, 2), Slot: 2}
+
+	sign := func(slot types.Slot, head types.Checkpoint) *types.SignedVote {
+		vote, err := chain.SignVote(xmss.DevnetKey(3), &types.Vote{
+			ValidatorID: 3,
+			Slot:        slot,
+			Head:        head,
+			Target:      head,
+			Source:      genesis,
+		})
+		if err != nil {
+			t.Fatalf("SignVote failed: %v", err)
+		}
+		return vote
+	}
+
+	// The conflicting vote for slot 1 arrives after the vote for slot 2
+	for _, vote := range []*types.SignedVote{sign(1, block1), sign(2, block2), sign(1, genesis)} {
+		if err := store.ProcessAttestation(vote); err != nil {
+			t.Fatalf("ProcessAttestation failed: %v", err)
+		}
+	}
+
+	evidence := store.Evidence()
+	if len(evidence) != 1 || evidence[0].Slot != 1 || evidence[0].Kind() != "vote" {
+		t.Fatalf("got %d equivocations, want a double vote at slot 1", len(evidence))
+	}
+
+	// Votes from before the finalized slot are dropped with it
+	store.LatestFinalized = block2
+	store.pruneEquivocationIndex()
+	if votes := store.slotVotes[3]; len(votes) != 1 {
+		t.Errorf("%d votes indexed after finalizing slot 2, want 1", len(votes))
+	}
+}
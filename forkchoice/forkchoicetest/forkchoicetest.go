@@ -0,0 +1,24 @@
+// Package forkchoicetest builds fork choice stores for tests outside the
+// forkchoice package.
+package forkchoicetest
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/chain/chaintest"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/types"
+)
+
+// Store returns a store started from the chaintest genesis, with a block at
+// each of the given slots.
+func Store(t testing.TB, slots ...types.Slot) *forkchoice.Store {
+	t.Helper()
+	state, anchor := chaintest.Genesis(t)
+	store, err := forkchoice.NewStore(state, anchor)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	chaintest.Extend(t, store, store.LatestKnownVotes, slots...)
+	return store
+}
@@ -23,21 +23,7 @@ func GetHead(blocks map[types.Root]*types.Block, root types.Root, latestVotes ma
 	}
 
 	// Count votes for each block (votes for descendants count for ancestors)
-	voteWeights := make(map[types.Root]int)
-	rootSlot := blocks[root].Slot
-
-	for _, vote := range latestVotes {
-		if _, exists := blocks[vote.Root]; !exists {
-			continue
-		}
-
-		// Walk up from vote target, incrementing ancestor weights
-		blockHash := vote.Root
-		for blocks[blockHash].Slot > rootSlot {
-			voteWeights[blockHash]++
-			blockHash = blocks[blockHash].ParentRoot
-		}
-	}
+	voteWeights := VoteWeights(blocks, latestVotes, blocks[root].Slot)
 
 	// Build children mapping for blocks above min score
 	childrenMap := make(map[types.Root][]types.Root)
@@ -78,6 +64,25 @@ func GetHead(blocks map[types.Root]*types.Block, root types.Root, latestVotes ma
 	}
 }
 
+// VoteWeights counts, for every block above minSlot, the votes for the block
+// or one of its descendants.
+func VoteWeights(blocks map[types.Root]*types.Block, latestVotes map[types.ValidatorIndex]types.Checkpoint, minSlot types.Slot) map[types.Root]int {
+	voteWeights := make(map[types.Root]int)
+	for _, vote := range latestVotes {
+		if _, exists := blocks[vote.Root]; !exists {
+			continue
+		}
+
+		// Walk up from vote target, incrementing ancestor weights
+		blockHash := vote.Root
+		for block, exists := blocks[blockHash]; exists && block.Slot > minSlot; block, exists = blocks[blockHash] {
+			voteWeights[blockHash]++
+			blockHash = block.ParentRoot
+		}
+	}
+	return voteWeights
+}
+
 // GetLatestJustified finds the justified checkpoint with the highest slot.
 func GetLatestJustified(states map[types.Root]*types.State) *types.Checkpoint {
 	if len(states) == 0 {
@@ -0,0 +1,180 @@
+package forkchoice
+
+import (
+	"fmt"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/types"
+)
+
+// StateRetentionSlots is how far behind the head full states are kept in
+// memory. Older states are dropped and regenerated on demand.
+const StateRetentionSlots = 64
+
+// PruneStats counts what pruning removed from the store.
+type PruneStats struct {
+	Runs         uint64
+	BlocksPruned uint64
+	StatesPruned uint64
+}
+
+// prune drops every block that does not descend from the finalized
+// checkpoint, and every state outside the retention window that is not
+// needed as a fork choice anchor. Called whenever finalization advances.
+func (s *Store) prune() {
+	finalizedRoot := s.LatestFinalized.Root
+	if _, exists := s.Blocks[finalizedRoot]; !exists {
+		return
+	}
+
+	keep := Descendants(s.Blocks, finalizedRoot)
+
+	// Canonical ancestors of the finalized block keep their block on disk
+	// so history can still be served; forks are deleted entirely.
+	chain := s.finalizedChain()
+
+	var deleteBlocks, deleteStates []types.Root
+	blocksPruned := 0
+	for root, block := range s.Blocks {
+		if keep[root] {
+			continue
+		}
+		blocksPruned++
+		if chain[block.Slot] != root {
+			deleteBlocks = append(deleteBlocks, root)
+		}
+		delete(s.Blocks, root)
+		delete(s.Signatures, root)
+		if _, exists := s.States[root]; exists {
+			delete(s.States, root)
+			deleteStates = append(deleteStates, root)
+		}
+	}
+
+	// The safe target is only recomputed at interval 2 and may still be an
+	// ancestor of the finalized block, now pruned
+	if !keep[s.SafeTarget] {
+		s.SafeTarget = finalizedRoot
+		s.forkChoiceDirty = true
+	}
+
+	deleteStates = append(deleteStates, s.pruneStates()...)
+	s.pruneEquivocationIndex()
+
+	s.PruneStats.Runs++
+	s.PruneStats.BlocksPruned += uint64(blocksPruned)
+	s.PruneStats.StatesPruned += uint64(len(deleteStates))
+
+	if s.db != nil {
+		// Best effort: stale records only cost disk space, and a finalized
+		// slot left unindexed is only missing from served history. Nothing
+		// is deleted unless the new head has been written first.
+		_ = s.db.PutCanonical(chain)
+		if err := s.writeForkChoice(); err == nil {
+			_ = s.db.Prune(deleteBlocks, deleteStates)
+		}
+	}
+	s.pruneCanonical()
+}
+
+// PruneStates drops the full states held beyond the retention window, as
+// finalization does. States regenerated for old blocks, or kept while
+// finalization stalls, would otherwise accumulate, so the node also calls
+// this once per slot. It returns the number of states dropped.
+func (s *Store) PruneStates() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteStates := s.pruneStates()
+	s.PruneStats.StatesPruned += uint64(len(deleteStates))
+	if s.db != nil && len(deleteStates) > 0 {
+		// Best effort: stale records only cost disk space. The head is
+		// written first, as the persisted one may be among the states.
+		if err := s.writeForkChoice(); err == nil {
+			_ = s.db.Prune(nil, deleteStates)
+		}
+	}
+	return len(deleteStates)
+}
+
+// pruneStates drops every state more than StateRetentionSlots behind the
+// head that is not needed as a fork choice anchor, returning their roots.
+func (s *Store) pruneStates() []types.Root {
+	anchors := map[types.Root]bool{
+		s.LatestFinalized.Root: true,
+		s.Head:                 true,
+		s.SafeTarget:           true,
+		s.LatestJustified.Root: true,
+	}
+	var minSlot types.Slot
+	if headSlot := s.Blocks[s.Head].Slot; headSlot > StateRetentionSlots {
+		minSlot = headSlot - StateRetentionSlots
+	}
+
+	var pruned []types.Root
+	for root := range s.States {
+		if anchors[root] || s.Blocks[root].Slot >= minSlot {
+			continue
+		}
+		delete(s.States, root)
+		pruned = append(pruned, root)
+	}
+	return pruned
+}
+
+// stateFor returns the post-state of the block with the given root. If the
+// state was pruned it is regenerated by replaying blocks from the nearest
+// ancestor whose state is still held.
+func (s *Store) stateFor(root types.Root) (*types.State, error) {
+	if state, exists := s.States[root]; exists {
+		return state, nil
+	}
+
+	var replay []*types.Block
+	var state *types.State
+	for cur := root; state == nil; {
+		block, exists := s.Blocks[cur]
+		if !exists {
+			return nil, ErrUnknownParent
+		}
+		replay = append(replay, block)
+		cur = block.ParentRoot
+		state = s.States[cur]
+	}
+
+	for i := len(replay) - 1; i >= 0; i-- {
+		block := replay[i]
+		advanced, err := chain.ProcessSlots(state, block.Slot)
+		if err != nil {
+			return nil, fmt.Errorf("regenerate state: %w", err)
+		}
+		if state, err = chain.ProcessBlock(advanced, block); err != nil {
+			return nil, fmt.Errorf("regenerate state: %w", err)
+		}
+	}
+
+	s.States[root] = state
+	return state, nil
+}
+
+// Descendants returns root and every block descending from it.
+func Descendants(blocks map[types.Root]*types.Block, root types.Root) map[types.Root]bool {
+	children := make(map[types.Root][]types.Root)
+	for blockRoot, block := range blocks {
+		children[block.ParentRoot] = append(children[block.ParentRoot], blockRoot)
+	}
+
+	result := map[types.Root]bool{root: true}
+	queue := []types.Root{root}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for _, child := range children[cur] {
+			if !result[child] {
+				result[child] = true
+				queue = append(queue, child)
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,153 @@
+package forkchoice
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/chain/chaintest"
+	"github.com/devylongs/gean/types"
+)
+
+// newTestStore mirrors forkchoicetest.Store, which tests in this package
+// cannot import: it imports forkchoice.
+func newTestStore(t *testing.T, slots int) *Store {
+	t.Helper()
+	state, anchor := chaintest.Genesis(t)
+	store, err := NewStore(state, anchor)
+	if err != nil {
+		t.Fatalf("NewStore failed: %v", err)
+	}
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(slots)...)
+	return store
+}
+
+func rootAtSlot(t *testing.T, s *Store, slot types.Slot) types.Root {
+	t.Helper()
+	for root := s.Head; ; root = s.Blocks[root].ParentRoot {
+		block, exists := s.Blocks[root]
+		if !exists {
+			t.Fatalf("no canonical block at slot %d", slot)
+		}
+		if block.Slot == slot {
+			return root
+		}
+	}
+}
+
+// childBlock builds a valid empty block at slot on top of parent.
+func childBlock(t *testing.T, s *Store, parent types.Root, slot types.Slot) *types.SignedBlock {
+	t.Helper()
+	parentState, err := s.stateFor(parent)
+	if err != nil {
+		t.Fatalf("stateFor failed: %v", err)
+	}
+	return chaintest.ChildBlock(t, parentState, parent, slot)
+}
+
+func TestPruneDropsNonDescendants(t *testing.T) {
+	store := newTestStore(t, 80)
+
+	fork := childBlock(t, store, rootAtSlot(t, store, 2), 5)
+	if err := store.ProcessBlock(fork); err != nil {
+		t.Fatalf("ProcessBlock(fork) failed: %v", err)
+	}
+	forkRoot, _ := fork.Message.HashTreeRoot()
+
+	finalizedRoot := rootAtSlot(t, store, 10)
+	store.LatestFinalized = types.Checkpoint{Root: finalizedRoot, Slot: 10}
+	store.LatestJustified = store.LatestFinalized
+	store.prune()
+
+	if _, exists := store.Blocks[forkRoot]; exists {
+		t.Error("fork block survived pruning")
+	}
+	for root, block := range store.Blocks {
+		if block.Slot < 10 {
+			t.Errorf("block at slot %d survived pruning", block.Slot)
+		}
+		if _, exists := store.Signatures[root]; !exists {
+			t.Errorf("signature missing for block at slot %d", block.Slot)
+		}
+	}
+	if _, exists := store.States[finalizedRoot]; !exists {
+		t.Error("finalized state was pruned")
+	}
+	if got, max := len(store.States), StateRetentionSlots+2; got > max {
+		t.Errorf("store holds %d states, want at most %d", got, max)
+	}
+	if store.PruneStats.Runs != 1 || store.PruneStats.BlocksPruned != 11 {
+		t.Errorf("PruneStats = %+v, want 1 run and 11 blocks pruned", store.PruneStats)
+	}
+}
+
+func TestPruneResetsSafeTarget(t *testing.T) {
+	store := newTestStore(t, 20)
+
+	// The safe target lags, as at the end of range sync when too few new
+	// votes arrived to move it
+	store.SafeTarget = rootAtSlot(t, store, 1)
+	finalizedRoot := rootAtSlot(t, store, 10)
+	store.LatestFinalized = types.Checkpoint{Root: finalizedRoot, Slot: 10}
+	store.LatestJustified = store.LatestFinalized
+	store.prune()
+
+	if store.SafeTarget != finalizedRoot {
+		t.Errorf("safe target = %x, want the finalized block", store.SafeTarget[:4])
+	}
+	if _, target, _ := store.VoteCheckpoints(); target.Slot < 10 {
+		t.Errorf("vote target slot = %d, want at least the finalized slot", target.Slot)
+	}
+
+	// A pruned safe target read back from an older database
+	store.SafeTarget = types.Root{1}
+	if target := store.GetVoteTarget(); target.Slot < 10 {
+		t.Errorf("vote target slot = %d with a missing safe target, want at least 10", target.Slot)
+	}
+}
+
+func TestProcessBlockRegeneratesPrunedState(t *testing.T) {
+	store := newTestStore(t, 80)
+
+	finalizedRoot := rootAtSlot(t, store, 10)
+	store.LatestFinalized = types.Checkpoint{Root: finalizedRoot, Slot: 10}
+	store.LatestJustified = store.LatestFinalized
+	store.prune()
+
+	parent := rootAtSlot(t, store, 12)
+	if _, exists := store.States[parent]; exists {
+		t.Fatal("expected parent state to be pruned")
+	}
+
+	wantState, _ := store.stateFor(parent)
+	delete(store.States, parent)
+
+	block := childBlock(t, store, parent, 13)
+	if err := store.ProcessBlock(block); err != nil {
+		t.Fatalf("ProcessBlock on pruned parent failed: %v", err)
+	}
+
+	gotRoot, _ := store.States[parent].HashTreeRoot()
+	wantRoot, _ := wantState.HashTreeRoot()
+	if gotRoot != wantRoot {
+		t.Error("regenerated parent state differs")
+	}
+}
+
+func TestPruneStatesWithoutFinalization(t *testing.T) {
+	store := newTestStore(t, 80)
+	if store.LatestFinalized.Slot != 0 {
+		t.Fatalf("finalized slot = %d, want no finalization", store.LatestFinalized.Slot)
+	}
+	if len(store.States) != 81 {
+		t.Fatalf("store holds %d states before pruning, want 81", len(store.States))
+	}
+
+	if pruned := store.PruneStates(); pruned != 81-StateRetentionSlots-2 {
+		t.Errorf("PruneStates dropped %d states, want %d", pruned, 81-StateRetentionSlots-2)
+	}
+	if _, exists := store.States[store.LatestFinalized.Root]; !exists {
+		t.Error("finalized state was pruned")
+	}
+	if _, err := store.stateFor(rootAtSlot(t, store, 5)); err != nil {
+		t.Errorf("pruned state not regenerated: %v", err)
+	}
+}
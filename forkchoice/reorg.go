@@ -0,0 +1,39 @@
+package forkchoice
+
+import "github.com/devylongs/gean/types"
+
+// reorgDepth reports whether moving the head from oldHead to newHead is a
+// reorg, that is newHead does not descend from oldHead, and if so how many
+// slots oldHead is past the common ancestor of the two.
+func reorgDepth(blocks map[types.Root]*types.Block, oldHead, newHead types.Root) (uint64, bool) {
+	oldBlock, exists := blocks[oldHead]
+	if !exists || isAncestor(blocks, oldHead, newHead) {
+		return 0, false
+	}
+	for root := oldBlock.ParentRoot; ; {
+		block, exists := blocks[root]
+		if !exists {
+			return uint64(oldBlock.Slot), true
+		}
+		if isAncestor(blocks, root, newHead) {
+			return uint64(oldBlock.Slot - block.Slot), true
+		}
+		root = block.ParentRoot
+	}
+}
+
+// isAncestor reports whether root is descendant or one of its ancestors.
+func isAncestor(blocks map[types.Root]*types.Block, root, descendant types.Root) bool {
+	target, exists := blocks[root]
+	if !exists {
+		return false
+	}
+	for cur := descendant; cur != root; {
+		block, exists := blocks[cur]
+		if !exists || block.Slot <= target.Slot {
+			return false
+		}
+		cur = block.ParentRoot
+	}
+	return true
+}
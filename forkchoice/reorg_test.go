@@ -0,0 +1,62 @@
+package forkchoice
+
+import (
+	"testing"
+
+	"github.com/devylongs/gean/events"
+	"github.com/devylongs/gean/types"
+)
+
+func TestReorgDepth(t *testing.T) {
+	store := newTestStore(t, 4)
+	head := store.Head
+
+	// A fork from slot 2 that skips slots 3 and 4
+	fork := childBlock(t, store, rootAtSlot(t, store, 2), 5)
+	if err := store.ProcessBlock(fork); err != nil {
+		t.Fatalf("ProcessBlock(fork) failed: %v", err)
+	}
+	forkRoot, _ := fork.Message.HashTreeRoot()
+
+	if depth, ok := reorgDepth(store.Blocks, head, forkRoot); !ok || depth != 2 {
+		t.Errorf("reorgDepth(head, fork) = %d, %v, want 2, true", depth, ok)
+	}
+	if _, ok := reorgDepth(store.Blocks, rootAtSlot(t, store, 2), head); ok {
+		t.Error("moving the head to a descendant reported a reorg")
+	}
+	if _, ok := reorgDepth(store.Blocks, head, head); ok {
+		t.Error("an unchanged head reported a reorg")
+	}
+}
+
+func TestReorgPublishesEvents(t *testing.T) {
+	store := newTestStore(t, 4)
+	oldHead := store.Head
+	store.Events = events.NewBus()
+	sub := store.Events.Subscribe(events.TopicBlock, events.TopicHead, events.TopicReorg)
+	defer sub.Close()
+
+	fork := childBlock(t, store, rootAtSlot(t, store, 2), 5)
+	if err := store.ProcessBlock(fork); err != nil {
+		t.Fatalf("ProcessBlock(fork) failed: %v", err)
+	}
+	forkRoot, _ := fork.Message.HashTreeRoot()
+
+	// Move the only vote to the fork
+	store.LatestKnownVotes[0] = types.Checkpoint{Root: forkRoot, Slot: 5}
+	store.UpdateHead()
+
+	want := []events.Event{
+		events.Block{Slot: 5, Root: forkRoot, ProposerIndex: 1},
+		events.Reorg{Slot: 5, Depth: 2, OldHead: oldHead, NewHead: forkRoot},
+		events.Head{Slot: 5, Root: forkRoot, OldRoot: oldHead},
+	}
+	if got := len(sub.Events()); got != len(want) {
+		t.Fatalf("got %d events, want %d", got, len(want))
+	}
+	for _, w := range want {
+		if e := <-sub.Events(); e != w {
+			t.Errorf("event = %+v, want %+v", e, w)
+		}
+	}
+}
@@ -1,25 +1,78 @@
 package forkchoice
 
 import (
+	"errors"
 	"fmt"
+	"sort"
+	"sync"
+	"time"
 
 	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/events"
+	"github.com/devylongs/gean/metrics"
 	"github.com/devylongs/gean/types"
 )
 
+// ErrUnknownParent is returned by ProcessBlock when the parent state is not in the store.
+var ErrUnknownParent = errors.New("parent state not found")
+
+// Attestation validation errors that may resolve with time, as opposed to
+// votes that are invalid outright.
+var (
+	ErrUnknownVoteRoot = errors.New("vote references unknown block")
+	ErrFutureVote      = errors.New("vote slot too far in future")
+)
+
 // Store tracks all information required for the LMD GHOST fork choice algorithm.
+//
+// Store is safe for concurrent use through its methods. The exported fields
+// may only be accessed directly while the store is not yet shared between
+// goroutines (construction, tests); use the accessor methods otherwise.
 type Store struct {
+	mu sync.RWMutex
+
 	Time            uint64
 	Config          types.Config
 	Head            types.Root
 	SafeTarget      types.Root
 	LatestJustified types.Checkpoint
 	LatestFinalized types.Checkpoint
+	Validators      []types.Validator // Registry that signatures are verified against
 
 	Blocks           map[types.Root]*types.Block
+	Signatures       map[types.Root]types.Signature
 	States           map[types.Root]*types.State
 	LatestKnownVotes map[types.ValidatorIndex]types.Checkpoint
 	LatestNewVotes   map[types.ValidatorIndex]types.Checkpoint
+
+	// Latest signed vote seen from each validator, for block inclusion
+	SignedVotes map[types.ValidatorIndex]types.SignedVote
+
+	PruneStats PruneStats
+
+	// ExcludeEquivocators drops the votes of validators seen equivocating
+	// from the head and safe target computations.
+	ExcludeEquivocators bool
+
+	// OnEquivocation, if set, is called for every new equivocation with the
+	// store locked; it must not call back into the store.
+	OnEquivocation func(Equivocation)
+
+	// Events, if set, receives head, block, vote, checkpoint and reorg
+	// events. It is published to with the store locked.
+	Events *events.Bus
+
+	equivocations []Equivocation                // Most recent evidence, oldest first
+	equivocators  map[types.ValidatorIndex]bool // Validators seen equivocating
+
+	proposals map[proposal]types.Root                                  // Block of each proposer and slot, built on first use
+	slotVotes map[types.ValidatorIndex]map[types.Slot]types.SignedVote // First vote of each validator per unfinalized slot
+
+	canonical *canonicalIndex // Slot index of the head's chain from the finalized block, built on first use
+
+	forkChoiceDirty bool // Checkpoints or votes changed since they were last persisted
+
+	db Database // nil for an in-memory store
 }
 
 // NewStore initializes a fork choice store from an anchor state and block.
@@ -45,15 +98,38 @@ func NewStore(state *types.State, anchorBlock *types.Block) (*Store, error) {
 		SafeTarget:       anchorRoot,
 		LatestJustified:  state.LatestJustified,
 		LatestFinalized:  state.LatestFinalized,
+		Validators:       state.Validators,
 		Blocks:           map[types.Root]*types.Block{anchorRoot: anchorBlock},
+		Signatures:       map[types.Root]types.Signature{anchorRoot: {}},
 		States:           map[types.Root]*types.State{anchorRoot: state},
 		LatestKnownVotes: make(map[types.ValidatorIndex]types.Checkpoint),
 		LatestNewVotes:   make(map[types.ValidatorIndex]types.Checkpoint),
+		SignedVotes:      make(map[types.ValidatorIndex]types.SignedVote),
 	}, nil
 }
 
+// NewCheckpointStore initializes a fork choice store from a trusted finalized
+// block and its post-state, as in checkpoint sync. The state's own
+// checkpoints refer to blocks before the anchor that the store does not
+// hold, so the anchor becomes both the justified and finalized checkpoint.
+func NewCheckpointStore(state *types.State, anchorBlock *types.SignedBlock) (*Store, error) {
+	store, err := NewStore(state, &anchorBlock.Message)
+	if err != nil {
+		return nil, err
+	}
+	anchor := types.Checkpoint{Root: store.Head, Slot: anchorBlock.Message.Slot}
+	store.LatestJustified = anchor
+	store.LatestFinalized = anchor
+	store.Signatures[store.Head] = anchorBlock.Signature
+	return store, nil
+}
+
 // ProcessBlock adds a new block and updates fork choice state.
-func (s *Store) ProcessBlock(block *types.Block) error {
+func (s *Store) ProcessBlock(signedBlock *types.SignedBlock) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	block := &signedBlock.Message
 	blockHash, err := block.HashTreeRoot()
 	if err != nil {
 		return fmt.Errorf("hash block: %w", err)
@@ -63,11 +139,17 @@ func (s *Store) ProcessBlock(block *types.Block) error {
 	if _, exists := s.Blocks[blockHash]; exists {
 		return nil
 	}
+	start := time.Now()
 
 	// Get parent state
-	parentState, exists := s.States[block.ParentRoot]
-	if !exists {
-		return fmt.Errorf("parent state not found")
+	parentState, err := s.stateFor(block.ParentRoot)
+	if err != nil {
+		return err
+	}
+
+	transitionStart := time.Now()
+	if err := chain.VerifySignatures(s.Validators, signedBlock); err != nil {
+		return err
 	}
 
 	// Apply state transition
@@ -79,31 +161,48 @@ func (s *Store) ProcessBlock(block *types.Block) error {
 	if err != nil {
 		return fmt.Errorf("process block: %w", err)
 	}
+	metrics.StateTransitionTime.Observe(time.Since(transitionStart).Seconds())
+
+	s.checkDoubleProposal(blockHash, signedBlock)
 
 	// Store block and state
 	s.Blocks[blockHash] = block
+	s.Signatures[blockHash] = signedBlock.Signature
 	s.States[blockHash] = newState
 
+	if err := s.persistBlock(blockHash); err != nil {
+		return fmt.Errorf("persist block: %w", err)
+	}
+
 	// Process attestations
 	for _, signedVote := range block.Body.Attestations {
 		s.processAttestation(&signedVote, true)
 	}
+	metrics.AttestationsValid.WithLabelValues("block").Add(float64(len(block.Body.Attestations)))
+	s.Events.Publish(events.Block{Slot: block.Slot, Root: blockHash, ProposerIndex: block.ProposerIndex})
 
 	// Update head
-	s.UpdateHead()
+	s.updateHead()
+	metrics.BlockProcessingTime.Observe(time.Since(start).Seconds())
 	return nil
 }
 
 // ValidateAttestation validates an attestation according to Devnet 0 spec.
 func (s *Store) ValidateAttestation(signedVote *types.SignedVote) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.validateAttestation(signedVote)
+}
+
+func (s *Store) validateAttestation(signedVote *types.SignedVote) error {
 	vote := signedVote.Data
 
 	// Validate vote targets exist in store
 	if _, exists := s.Blocks[vote.Source.Root]; !exists {
-		return fmt.Errorf("source root not found in store")
+		return fmt.Errorf("source: %w", ErrUnknownVoteRoot)
 	}
 	if _, exists := s.Blocks[vote.Target.Root]; !exists {
-		return fmt.Errorf("target root not found in store")
+		return fmt.Errorf("target: %w", ErrUnknownVoteRoot)
 	}
 
 	sourceBlock := s.Blocks[vote.Source.Root]
@@ -128,18 +227,30 @@ func (s *Store) ValidateAttestation(signedVote *types.SignedVote) error {
 	// Validate attestation is not too far in future
 	currentSlot := types.Slot(s.Time / types.IntervalsPerSlot)
 	if vote.Slot > currentSlot+1 {
-		return fmt.Errorf("vote slot %d too far in future (current: %d)", vote.Slot, currentSlot)
+		return fmt.Errorf("%w: slot %d, current %d", ErrFutureVote, vote.Slot, currentSlot)
 	}
 
-	return nil
+	return chain.VerifyVoteSignature(s.Validators, signedVote)
+}
+
+// VerifyBlockSignature checks a block's proposer signature against the
+// validator registry.
+func (s *Store) VerifyBlockSignature(signedBlock *types.SignedBlock) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return chain.VerifyBlockSignature(s.Validators, signedBlock)
 }
 
 // ProcessAttestation handles a new attestation vote from network gossip.
 func (s *Store) ProcessAttestation(signedVote *types.SignedVote) error {
-	if err := s.ValidateAttestation(signedVote); err != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.validateAttestation(signedVote); err != nil {
 		return err
 	}
 	s.processAttestation(signedVote, false)
+	s.Events.Publish(events.Vote{SignedVote: *signedVote})
 	return nil
 }
 
@@ -148,94 +259,207 @@ func (s *Store) processAttestation(signedVote *types.SignedVote, isFromBlock boo
 	vote := signedVote.Data
 	validatorID := types.ValidatorIndex(vote.ValidatorID)
 
+	s.checkDoubleVote(signedVote)
+	if latest, exists := s.SignedVotes[validatorID]; !exists || latest.Data.Slot < vote.Slot {
+		s.SignedVotes[validatorID] = *signedVote
+		s.forkChoiceDirty = true
+	}
+
 	if isFromBlock {
 		// On-chain attestation
 		if known, exists := s.LatestKnownVotes[validatorID]; !exists || known.Slot < vote.Slot {
 			s.LatestKnownVotes[validatorID] = vote.Target
+			s.forkChoiceDirty = true
 		}
 		if newVote, exists := s.LatestNewVotes[validatorID]; exists && newVote.Slot <= vote.Target.Slot {
 			delete(s.LatestNewVotes, validatorID)
+			s.forkChoiceDirty = true
 		}
 	} else {
 		// Network gossip attestation
 		if newVote, exists := s.LatestNewVotes[validatorID]; !exists || newVote.Slot < vote.Target.Slot {
 			s.LatestNewVotes[validatorID] = vote.Target
+			s.forkChoiceDirty = true
 		}
 	}
 }
 
 // UpdateHead updates the store's head based on latest justified checkpoint and votes.
+// When finalization advances, blocks and states it made obsolete are pruned.
 func (s *Store) UpdateHead() {
-	if latest := GetLatestJustified(s.States); latest != nil {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateHead()
+}
+
+// takesJustified reports whether cp replaces the store's justified checkpoint.
+func (s *Store) takesJustified(cp types.Checkpoint) bool {
+	if cp.Slot != s.LatestJustified.Slot {
+		return cp.Slot > s.LatestJustified.Slot
+	}
+	_, known := s.Blocks[cp.Root]
+	return known
+}
+
+func (s *Store) updateHead() {
+	prevHead := s.Head
+	prevJustified := s.LatestJustified
+	prevFinalized := s.LatestFinalized
+
+	// Justification never moves backwards, even after pruning drops the
+	// states that carried it. At the same slot only a checkpoint on a block
+	// we hold is taken: the genesis state justifies slot 0 with a zero root,
+	// and which state the map yields first must not decide the head.
+	if latest := GetLatestJustified(s.States); latest != nil && s.takesJustified(*latest) {
 		s.LatestJustified = *latest
 	}
 
-	s.Head = GetHead(s.Blocks, s.LatestJustified.Root, s.LatestKnownVotes, 0)
+	s.Head = GetHead(s.Blocks, s.LatestJustified.Root, s.headVotes(s.LatestKnownVotes), 0)
+	if s.Head != prevHead {
+		headSlot := s.Blocks[s.Head].Slot
+		if s.canonical != nil {
+			s.updateCanonical()
+		}
+		if depth, reorg := reorgDepth(s.Blocks, prevHead, s.Head); reorg {
+			metrics.Reorgs.Inc()
+			metrics.ReorgDepth.Observe(float64(depth))
+			s.Events.Publish(events.Reorg{Slot: headSlot, Depth: depth, OldHead: prevHead, NewHead: s.Head})
+		}
+		s.Events.Publish(events.Head{Slot: headSlot, Root: s.Head, OldRoot: prevHead})
+	}
 
-	if state, exists := s.States[s.Head]; exists {
+	// Like justification, finalization never moves backwards; a checkpoint
+	// anchor's own state finalizes a block from before the anchor
+	if state, exists := s.States[s.Head]; exists && state.LatestFinalized.Slot >= s.LatestFinalized.Slot {
 		s.LatestFinalized = state.LatestFinalized
 	}
+	if s.LatestJustified != prevJustified {
+		s.Events.Publish(events.Justified{Checkpoint: s.LatestJustified})
+	}
+	if s.LatestFinalized != prevFinalized {
+		s.Events.Publish(events.Finalized{Checkpoint: s.LatestFinalized})
+	}
+
+	if s.LatestFinalized.Slot > prevFinalized.Slot {
+		s.prune()
+	}
+
+	if s.Head != prevHead || s.LatestJustified != prevJustified || s.LatestFinalized != prevFinalized {
+		s.forkChoiceDirty = true
+	}
 }
 
 // AcceptNewVotes moves pending votes to known votes and updates head.
 func (s *Store) AcceptNewVotes() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.acceptNewVotes()
+}
+
+func (s *Store) acceptNewVotes() {
+	if len(s.LatestNewVotes) > 0 {
+		s.forkChoiceDirty = true
+	}
 	for validatorID, vote := range s.LatestNewVotes {
 		s.LatestKnownVotes[validatorID] = vote
 	}
 	s.LatestNewVotes = make(map[types.ValidatorIndex]types.Checkpoint)
-	s.UpdateHead()
+	s.updateHead()
 }
 
 // UpdateSafeTarget calculates the safe target with 2/3 majority threshold.
 func (s *Store) UpdateSafeTarget() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateSafeTarget()
+}
+
+func (s *Store) updateSafeTarget() {
 	minScore := int((s.Config.NumValidators*2 + 2) / 3) // ceiling division
-	s.SafeTarget = GetHead(s.Blocks, s.LatestJustified.Root, s.LatestNewVotes, minScore)
+	safeTarget := GetHead(s.Blocks, s.LatestJustified.Root, s.headVotes(s.LatestNewVotes), minScore)
+	if safeTarget != s.SafeTarget {
+		s.SafeTarget = safeTarget
+		s.forkChoiceDirty = true
+	}
 }
 
 // TickInterval advances store time by one interval.
 func (s *Store) TickInterval(hasProposal bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickInterval(hasProposal)
+}
+
+func (s *Store) tickInterval(hasProposal bool) {
 	s.Time++
 	currentInterval := s.Time % types.IntervalsPerSlot
 
 	switch currentInterval {
 	case 0:
 		if hasProposal {
-			s.AcceptNewVotes()
+			s.acceptNewVotes()
 		}
 	case 1:
 		// Validator voting interval - no action
 	case 2:
-		s.UpdateSafeTarget()
+		s.updateSafeTarget()
 	default:
-		s.AcceptNewVotes()
+		s.acceptNewVotes()
 	}
 }
 
 // AdvanceTime ticks the store forward to the given time.
 func (s *Store) AdvanceTime(time uint64, hasProposal bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.advanceTime(time, hasProposal)
+}
+
+func (s *Store) advanceTime(time uint64, hasProposal bool) {
+	if time < s.Config.GenesisTime {
+		return
+	}
 	tickIntervalTime := (time - s.Config.GenesisTime) / types.SecondsPerInterval
 
 	for s.Time < tickIntervalTime {
 		shouldSignal := hasProposal && (s.Time+1) == tickIntervalTime
-		s.TickInterval(shouldSignal)
+		s.tickInterval(shouldSignal)
 	}
 }
 
 // GetProposalHead returns the head for block proposal at the given slot.
 func (s *Store) GetProposalHead(slot types.Slot) types.Root {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getProposalHead(slot)
+}
+
+func (s *Store) getProposalHead(slot types.Slot) types.Root {
 	slotTime := s.Config.GenesisTime + uint64(slot)*types.SecondsPerSlot
-	s.AdvanceTime(slotTime, true)
-	s.AcceptNewVotes()
+	s.advanceTime(slotTime, true)
+	s.acceptNewVotes()
 	return s.Head
 }
 
 // GetVoteTarget calculates the target checkpoint for validator votes.
 func (s *Store) GetVoteTarget() types.Checkpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.getVoteTarget()
+}
+
+func (s *Store) getVoteTarget() types.Checkpoint {
 	targetRoot := s.Head
 
+	// A safe target loaded from an older database may have been pruned
+	safeTarget, exists := s.Blocks[s.SafeTarget]
+	if !exists {
+		safeTarget = s.Blocks[s.LatestFinalized.Root]
+	}
+
 	// Walk back up to 3 steps if safe target is newer
 	for i := 0; i < 3; i++ {
-		if s.Blocks[targetRoot].Slot > s.Blocks[s.SafeTarget].Slot {
+		if s.Blocks[targetRoot].Slot > safeTarget.Slot {
 			targetRoot = s.Blocks[targetRoot].ParentRoot
 		}
 	}
@@ -252,12 +476,137 @@ func (s *Store) GetVoteTarget() types.Checkpoint {
 
 // CurrentSlot returns the current slot based on store time.
 func (s *Store) CurrentSlot() types.Slot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 	return types.Slot(s.Time / types.IntervalsPerSlot)
 }
 
+// CurrentInterval returns the current interval within the slot (0-3).
+func (s *Store) CurrentInterval() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Time % types.IntervalsPerSlot
+}
+
+// NumValidators returns the validator count from the chain config.
+func (s *Store) NumValidators() uint64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Config.NumValidators
+}
+
+// HeadCheckpoint returns the current head root and slot.
+func (s *Store) HeadCheckpoint() types.Checkpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return types.Checkpoint{Root: s.Head, Slot: s.Blocks[s.Head].Slot}
+}
+
+// Justified returns the latest justified checkpoint.
+func (s *Store) Justified() types.Checkpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.LatestJustified
+}
+
+// Finalized returns the latest finalized checkpoint.
+func (s *Store) Finalized() types.Checkpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.LatestFinalized
+}
+
+// HasBlock reports whether the store holds the block with the given root.
+func (s *Store) HasBlock(root types.Root) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.Blocks[root]
+	return exists
+}
+
+// Block returns the block with the given root.
+func (s *Store) Block(root types.Root) (*types.Block, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	block, exists := s.Blocks[root]
+	return block, exists
+}
+
+// State returns the post-state of the block with the given root, if the
+// store still holds it.
+func (s *Store) State(root types.Root) (*types.State, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	state, exists := s.States[root]
+	return state, exists
+}
+
+// VoteCheckpoints returns the head, target and source a validator should
+// vote for, read atomically.
+func (s *Store) VoteCheckpoints() (head, target, source types.Checkpoint) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	head = types.Checkpoint{Root: s.Head, Slot: s.Blocks[s.Head].Slot}
+	return head, s.getVoteTarget(), s.LatestJustified
+}
+
+// Stats holds store sizes for logging and metrics.
+type Stats struct {
+	Blocks int
+	States int
+	Prune  PruneStats
+}
+
+// Stats returns the current store sizes and pruning counters.
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return Stats{Blocks: len(s.Blocks), States: len(s.States), Prune: s.PruneStats}
+}
+
+// TreeNode is a block in the fork choice tree.
+type TreeNode struct {
+	Root          types.Root
+	ParentRoot    types.Root
+	Slot          types.Slot
+	ProposerIndex uint64
+	Weight        int // Latest known votes for the block or a descendant
+}
+
+// Tree returns every block in the store, ordered by slot, weighted by the
+// votes fork choice counts.
+func (s *Store) Tree() []TreeNode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	weights := VoteWeights(s.Blocks, s.headVotes(s.LatestKnownVotes), 0)
+	nodes := make([]TreeNode, 0, len(s.Blocks))
+	for root, block := range s.Blocks {
+		nodes = append(nodes, TreeNode{
+			Root:          root,
+			ParentRoot:    block.ParentRoot,
+			Slot:          block.Slot,
+			ProposerIndex: block.ProposerIndex,
+			Weight:        weights[root],
+		})
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Slot != nodes[j].Slot {
+			return nodes[i].Slot < nodes[j].Slot
+		}
+		return compareRoots(nodes[i].Root, nodes[j].Root) < 0
+	})
+	return nodes
+}
+
 // ProduceBlock creates a new block for the given slot and validator.
 // It iteratively collects valid attestations and computes the state root.
+// The block is not added to the store: the proposer signs it and imports it
+// with ProcessBlock.
 func (s *Store) ProduceBlock(slot types.Slot, validatorIndex types.ValidatorIndex) (*types.Block, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Validate proposer authorization
 	expectedProposer := uint64(slot) % s.Config.NumValidators
 	if uint64(validatorIndex) != expectedProposer {
@@ -266,10 +615,10 @@ func (s *Store) ProduceBlock(slot types.Slot, validatorIndex types.ValidatorInde
 	}
 
 	// Get parent block and state
-	headRoot := s.GetProposalHead(slot)
-	headState, exists := s.States[headRoot]
-	if !exists {
-		return nil, fmt.Errorf("head state not found")
+	headRoot := s.getProposalHead(slot)
+	headState, err := s.stateFor(headRoot)
+	if err != nil {
+		return nil, fmt.Errorf("head state: %w", err)
 	}
 
 	// Iteratively collect valid attestations
@@ -295,29 +644,26 @@ func (s *Store) ProduceBlock(slot types.Slot, validatorIndex types.ValidatorInde
 			return nil, fmt.Errorf("process block: %w", err)
 		}
 
-		// Find new valid attestations
+		// Find new valid attestations: signed votes whose source matches
+		// the post-state's justified checkpoint
 		var newAttestations []types.SignedVote
-		for validatorID, checkpoint := range s.LatestKnownVotes {
-			// Skip if target block unknown
-			if _, exists := s.Blocks[checkpoint.Root]; !exists {
+		for _, signedVote := range s.SignedVotes {
+			vote := signedVote.Data
+			if vote.Source != postState.LatestJustified {
 				continue
 			}
-
-			// Create attestation with post-state's latest justified as source
-			vote := types.Vote{
-				ValidatorID: uint64(validatorID),
-				Slot:        checkpoint.Slot,
-				Head:        checkpoint,
-				Target:      checkpoint,
-				Source:      postState.LatestJustified,
+			// Skip if head or target block unknown
+			if _, exists := s.Blocks[vote.Head.Root]; !exists {
+				continue
+			}
+			if _, exists := s.Blocks[vote.Target.Root]; !exists {
+				continue
 			}
-			signedVote := types.SignedVote{Data: vote, Signature: types.Root{}}
 
 			// Check if already in attestation set
 			found := false
 			for _, existing := range attestations {
-				if existing.Data.ValidatorID == signedVote.Data.ValidatorID &&
-					existing.Data.Slot == signedVote.Data.Slot {
+				if existing.Data.ValidatorID == vote.ValidatorID {
 					found = true
 					break
 				}
@@ -361,21 +707,16 @@ func (s *Store) ProduceBlock(slot types.Slot, validatorIndex types.ValidatorInde
 	}
 	finalBlock.StateRoot = stateRoot
 
-	// Store block and state
-	blockHash, err := finalBlock.HashTreeRoot()
-	if err != nil {
-		return nil, fmt.Errorf("hash final block: %w", err)
-	}
-	s.Blocks[blockHash] = finalBlock
-	s.States[blockHash] = finalPostState
-
 	return finalBlock, nil
 }
 
 // ProduceAttestationVote creates an attestation vote for the given slot and validator.
 func (s *Store) ProduceAttestationVote(slot types.Slot, validatorIndex types.ValidatorIndex) *types.Vote {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	// Get the head block for this slot
-	headRoot := s.GetProposalHead(slot)
+	headRoot := s.getProposalHead(slot)
 	headBlock := s.Blocks[headRoot]
 
 	headCheckpoint := types.Checkpoint{
@@ -384,7 +725,7 @@ func (s *Store) ProduceAttestationVote(slot types.Slot, validatorIndex types.Val
 	}
 
 	// Calculate the target checkpoint
-	targetCheckpoint := s.GetVoteTarget()
+	targetCheckpoint := s.getVoteTarget()
 
 	// Create the vote
 	return &types.Vote{
@@ -0,0 +1,73 @@
+package forkchoice
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/devylongs/gean/types"
+)
+
+// TestConcurrentAccess exercises block import, vote processing, ticking and
+// read-only queries from separate goroutines. Run with -race.
+func TestConcurrentAccess(t *testing.T) {
+	source := newTestStore(t, 16)
+	store := newTestStore(t, 0)
+
+	var blocks []*types.SignedBlock
+	for slot := types.Slot(1); slot <= 16; slot++ {
+		signed, _ := source.SignedBlock(rootAtSlot(t, source, slot))
+		blocks = append(blocks, signed)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(4)
+
+	go func() {
+		defer wg.Done()
+		for _, block := range blocks {
+			if err := store.ProcessBlock(block); err != nil {
+				t.Errorf("ProcessBlock failed: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := uint64(1); i <= 64; i++ {
+			store.AdvanceTime(i*types.SecondsPerInterval, false)
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for _, block := range blocks {
+			root, _ := block.Message.HashTreeRoot()
+			vote := &types.SignedVote{Data: types.Vote{
+				ValidatorID: 1,
+				Slot:        block.Message.Slot,
+				Head:        types.Checkpoint{Root: root, Slot: block.Message.Slot},
+				Target:      types.Checkpoint{Root: root, Slot: block.Message.Slot},
+				Source:      store.Justified(),
+			}}
+			_ = store.ProcessAttestation(vote) // May race ahead of block import
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			head := store.HeadCheckpoint()
+			store.HasBlock(head.Root)
+			store.VoteCheckpoints()
+			store.Finalized()
+			store.Stats()
+			store.CurrentSlot()
+		}
+	}()
+
+	wg.Wait()
+
+	if store.Stats().Blocks != 17 {
+		t.Errorf("store has %d blocks, want 17", store.Stats().Blocks)
+	}
+}
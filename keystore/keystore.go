@@ -0,0 +1,308 @@
+// Package keystore stores XMSS validator keys in password-encrypted JSON
+// files. The layout follows EIP-2335: the key seed is encrypted with
+// AES-128-CTR under a scrypt-derived key, with a SHA-256 checksum to detect
+// a wrong password.
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+	"github.com/google/uuid"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Version is the keystore format version (EIP-2335).
+const Version = 4
+
+// Scrypt cost parameters. StandardScryptN matches EIP-2335; LightScryptN
+// trades security for speed and is meant for tests and throwaway devnets.
+const (
+	StandardScryptN = 1 << 18
+	LightScryptN    = 1 << 12
+	scryptR         = 8
+	scryptP         = 1
+	scryptKeyLen    = 32 // AES-128 key and checksum key, 16 bytes each
+
+	// Limits on the parameters Decrypt accepts, so a crafted keystore cannot
+	// make key derivation take unbounded memory or time
+	maxScryptN  = 1 << 20
+	maxScryptRP = 16
+
+	maxExactInt = 1 << 53 // float64 holds every integer up to this exactly
+)
+
+// Keystore errors
+var (
+	ErrWrongPassword     = errors.New("wrong keystore password")
+	ErrUnsupportedFormat = errors.New("unsupported keystore format")
+)
+
+// Keystore is an encrypted validator key.
+type Keystore struct {
+	Crypto      Crypto `json:"crypto"`
+	Description string `json:"description"`
+	Pubkey      string `json:"pubkey"`
+	Path        string `json:"path"`
+	UUID        string `json:"uuid"`
+	Version     int    `json:"version"`
+}
+
+// Crypto holds the key derivation, checksum and cipher modules.
+type Crypto struct {
+	KDF      Module `json:"kdf"`
+	Checksum Module `json:"checksum"`
+	Cipher   Module `json:"cipher"`
+}
+
+// Module is a single EIP-2335 crypto module.
+type Module struct {
+	Function string         `json:"function"`
+	Params   map[string]any `json:"params"`
+	Message  string         `json:"message"`
+}
+
+// Encrypt seals key under password using scrypt with cost parameter scryptN.
+func Encrypt(key *xmss.PrivateKey, password string, scryptN int) (*Keystore, error) {
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("read salt: %w", err)
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, fmt.Errorf("read iv: %w", err)
+	}
+
+	derived, err := scrypt.Key([]byte(password), salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+
+	seed := key.Seed()
+	ciphertext, err := aesCTR(derived[:16], iv, seed[:])
+	if err != nil {
+		return nil, err
+	}
+
+	pk := key.PublicKey()
+	return &Keystore{
+		Crypto: Crypto{
+			KDF: Module{
+				Function: "scrypt",
+				Params: map[string]any{
+					"dklen": scryptKeyLen,
+					"n":     scryptN,
+					"r":     scryptR,
+					"p":     scryptP,
+					"salt":  hex.EncodeToString(salt),
+				},
+			},
+			Checksum: Module{
+				Function: "sha256",
+				Params:   map[string]any{},
+				Message:  hex.EncodeToString(checksum(derived, ciphertext)),
+			},
+			Cipher: Module{
+				Function: "aes-128-ctr",
+				Params:   map[string]any{"iv": hex.EncodeToString(iv)},
+				Message:  hex.EncodeToString(ciphertext),
+			},
+		},
+		Pubkey:  hex.EncodeToString(pk[:]),
+		UUID:    uuid.NewString(),
+		Version: Version,
+	}, nil
+}
+
+// Decrypt recovers the key, returning ErrWrongPassword if the checksum does
+// not match and ErrUnsupportedFormat for a malformed keystore, including a
+// missing or malformed pubkey and scrypt parameters beyond the limits.
+func (k *Keystore) Decrypt(password string) (*xmss.PrivateKey, error) {
+	if k.Version != Version || k.Crypto.KDF.Function != "scrypt" ||
+		k.Crypto.Checksum.Function != "sha256" || k.Crypto.Cipher.Function != "aes-128-ctr" {
+		return nil, ErrUnsupportedFormat
+	}
+
+	kdf := k.Crypto.KDF.Params
+	salt, err := hexParam(kdf, "salt")
+	if err != nil {
+		return nil, err
+	}
+	n, nOK := intParam(kdf, "n")
+	r, rOK := intParam(kdf, "r")
+	p, pOK := intParam(kdf, "p")
+	dklen, dkOK := intParam(kdf, "dklen")
+	if !nOK || !rOK || !pOK || !dkOK {
+		return nil, fmt.Errorf("%w: scrypt params", ErrUnsupportedFormat)
+	}
+	if dklen != scryptKeyLen {
+		return nil, fmt.Errorf("%w: scrypt dklen %d, want %d", ErrUnsupportedFormat, dklen, scryptKeyLen)
+	}
+	if n < 2 || n > maxScryptN || n&(n-1) != 0 {
+		return nil, fmt.Errorf("%w: scrypt n %d is not a power of two up to %d", ErrUnsupportedFormat, n, maxScryptN)
+	}
+	if r < 1 || p < 1 || r > maxScryptRP/p {
+		return nil, fmt.Errorf("%w: scrypt r*p %d*%d exceeds %d", ErrUnsupportedFormat, r, p, maxScryptRP)
+	}
+	pubkey, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	iv, err := hexParam(k.Crypto.Cipher.Params, "iv")
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("%w: iv length %d", ErrUnsupportedFormat, len(iv))
+	}
+	ciphertext, err := hex.DecodeString(k.Crypto.Cipher.Message)
+	if err != nil || len(ciphertext) != xmss.SeedSize {
+		return nil, fmt.Errorf("%w: cipher message", ErrUnsupportedFormat)
+	}
+	want, err := hex.DecodeString(k.Crypto.Checksum.Message)
+	if err != nil {
+		return nil, fmt.Errorf("%w: checksum message", ErrUnsupportedFormat)
+	}
+
+	derived, err := scrypt.Key([]byte(password), salt, n, r, p, dklen)
+	if err != nil {
+		return nil, fmt.Errorf("derive key: %w", err)
+	}
+	if !bytes.Equal(checksum(derived, ciphertext), want) {
+		return nil, ErrWrongPassword
+	}
+
+	plaintext, err := aesCTR(derived[:16], iv, ciphertext)
+	if err != nil {
+		return nil, err
+	}
+	var seed [xmss.SeedSize]byte
+	copy(seed[:], plaintext)
+	key := xmss.NewKeyFromSeed(seed)
+
+	if pubkey != types.Pubkey(key.PublicKey()) {
+		return nil, fmt.Errorf("decrypted key does not match pubkey %s", k.Pubkey)
+	}
+	return key, nil
+}
+
+// PublicKey returns the public key recorded in the keystore.
+func (k *Keystore) PublicKey() (types.Pubkey, error) {
+	var pk types.Pubkey
+	b, err := hex.DecodeString(k.Pubkey)
+	if err != nil || len(b) != len(pk) {
+		return pk, fmt.Errorf("%w: pubkey", ErrUnsupportedFormat)
+	}
+	copy(pk[:], b)
+	return pk, nil
+}
+
+// Save writes the keystore to dir as keystore-<pubkey prefix>.json and
+// returns the file path.
+func (k *Keystore) Save(dir string) (string, error) {
+	data, err := json.MarshalIndent(k, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode keystore: %w", err)
+	}
+	path := filepath.Join(dir, "keystore-"+k.Pubkey[:16]+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", fmt.Errorf("write keystore: %w", err)
+	}
+	return path, nil
+}
+
+// Read parses a keystore file.
+func Read(path string) (*Keystore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var k Keystore
+	if err := json.Unmarshal(data, &k); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", filepath.Base(path), err)
+	}
+	return &k, nil
+}
+
+// LoadDir decrypts every *.json keystore in dir with password, in file name
+// order.
+func LoadDir(dir, password string) ([]*xmss.PrivateKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]*xmss.PrivateKey, 0, len(paths))
+	for _, path := range paths {
+		k, err := Read(path)
+		if err != nil {
+			return nil, err
+		}
+		key, err := k.Decrypt(password)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt %s: %w", filepath.Base(path), err)
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// checksum is SHA-256 over the second half of the derived key and the ciphertext.
+func checksum(derived, ciphertext []byte) []byte {
+	h := sha256.New()
+	h.Write(derived[16:32])
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+func aesCTR(key, iv, in []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	out := make([]byte, len(in))
+	cipher.NewCTR(block, iv).XORKeyStream(out, in)
+	return out, nil
+}
+
+// intParam reads a numeric parameter, which is a float64 once decoded from JSON.
+// intParam returns an integer parameter. JSON numbers decode as float64, so
+// one without an exact int value is rejected rather than truncated.
+func intParam(params map[string]any, name string) (int, bool) {
+	switch v := params[name].(type) {
+	case int:
+		return v, true
+	case float64:
+		if v != math.Trunc(v) || math.Abs(v) > maxExactInt {
+			return 0, false
+		}
+		return int(v), true
+	}
+	return 0, false
+}
+
+func hexParam(params map[string]any, name string) ([]byte, error) {
+	s, ok := params[name].(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: missing %s", ErrUnsupportedFormat, name)
+	}
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrUnsupportedFormat, name, err)
+	}
+	return b, nil
+}
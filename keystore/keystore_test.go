@@ -0,0 +1,86 @@
+package keystore
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+func TestSaveLoadDir(t *testing.T) {
+	dir := t.TempDir()
+	for i := uint64(0); i < 2; i++ {
+		ks, err := Encrypt(xmss.DevnetKey(i), "secret", LightScryptN)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if _, err := ks.Save(dir); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	keys, err := LoadDir(dir, "secret")
+	if err != nil {
+		t.Fatalf("LoadDir failed: %v", err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("loaded %d keys, want 2", len(keys))
+	}
+	loaded := map[types.Pubkey]bool{}
+	for _, key := range keys {
+		loaded[types.Pubkey(key.PublicKey())] = true
+	}
+	for i := uint64(0); i < 2; i++ {
+		if !loaded[types.Pubkey(xmss.DevnetKey(i).PublicKey())] {
+			t.Errorf("devnet key %d not loaded", i)
+		}
+	}
+
+	if _, err := LoadDir(dir, "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("LoadDir with wrong password: err = %v, want ErrWrongPassword", err)
+	}
+}
+
+func TestDecryptRejectsTampering(t *testing.T) {
+	ks, err := Encrypt(xmss.DevnetKey(0), "secret", LightScryptN)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	msg := []byte(ks.Crypto.Cipher.Message)
+	if msg[0] == '0' {
+		msg[0] = '1'
+	} else {
+		msg[0] = '0'
+	}
+	ks.Crypto.Cipher.Message = string(msg)
+
+	if _, err := ks.Decrypt("secret"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("err = %v, want ErrWrongPassword", err)
+	}
+}
+
+func TestDecryptRejectsBadParams(t *testing.T) {
+	for name, tamper := range map[string]func(*Keystore){
+		"n not a power of two": func(k *Keystore) { k.Crypto.KDF.Params["n"] = float64(LightScryptN + 1) },
+		"n too large":          func(k *Keystore) { k.Crypto.KDF.Params["n"] = float64(1 << 30) },
+		"n too small":          func(k *Keystore) { k.Crypto.KDF.Params["n"] = float64(1) },
+		"r*p too large":        func(k *Keystore) { k.Crypto.KDF.Params["r"] = float64(1 << 20) },
+		"p zero":               func(k *Keystore) { k.Crypto.KDF.Params["p"] = float64(0) },
+		"dklen too large":      func(k *Keystore) { k.Crypto.KDF.Params["dklen"] = float64(1 << 40) },
+		"dklen too small":      func(k *Keystore) { k.Crypto.KDF.Params["dklen"] = float64(16) },
+		"n overflows int":      func(k *Keystore) { k.Crypto.KDF.Params["n"] = float64(1 << 64) },
+		"r not an integer":     func(k *Keystore) { k.Crypto.KDF.Params["r"] = 8.5 },
+		"malformed pubkey":     func(k *Keystore) { k.Pubkey = "0xzz" },
+		"missing pubkey":       func(k *Keystore) { k.Pubkey = "" },
+	} {
+		ks, err := Encrypt(xmss.DevnetKey(0), "secret", LightScryptN)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		tamper(ks)
+		if _, err := ks.Decrypt("secret"); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("%s: err = %v, want ErrUnsupportedFormat", name, err)
+		}
+	}
+}
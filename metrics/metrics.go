@@ -0,0 +1,110 @@
+// Package metrics defines the Prometheus metrics exported by gean.
+//
+// Metrics covered by the lean client metrics specification use its lean_
+// names so shared dashboards work across clients; metrics specific to gean
+// are prefixed gean_.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+// Registry holds every gean metric, plus the Go runtime and process collectors.
+var Registry = prometheus.NewRegistry()
+
+// Chain gauges, updated by the node every tick
+var (
+	HeadSlot = newGauge("lean_head_slot", "Slot of the fork choice head")
+
+	CurrentSlot = newGauge("lean_current_slot", "Current slot from the wall clock")
+
+	SafeTargetSlot = newGauge("lean_safe_target_slot", "Slot of the fork choice safe target")
+
+	LatestJustifiedSlot = newGauge("lean_latest_justified_slot", "Slot of the latest justified checkpoint")
+
+	LatestFinalizedSlot = newGauge("lean_latest_finalized_slot", "Slot of the latest finalized checkpoint")
+
+	ValidatorsCount = newGauge("lean_validators_count", "Number of validators run by this node")
+
+	ValidatorKeyRemainingSlots = newGauge("gean_validator_key_remaining_slots", "Slots left before the validator signing keys run out of one-time keys")
+)
+
+// Fork choice
+var (
+	BlockProcessingTime = newHistogram("lean_fork_choice_block_processing_time_seconds",
+		"Time to import a block into fork choice", []float64{0.005, 0.01, 0.025, 0.05, 0.1, 1})
+
+	Reorgs = newCounter("lean_fork_choice_reorgs_total", "Head changes to a block that does not descend from the previous head")
+
+	ReorgDepth = newHistogram("lean_fork_choice_reorg_depth",
+		"Slots between the previous head and the common ancestor in a reorg", []float64{1, 2, 3, 5, 7, 10, 20, 30, 50, 100})
+
+	StoreBlocks = newGauge("gean_store_blocks", "Blocks held by the fork choice store")
+
+	StoreStates = newGauge("gean_store_states", "States held by the fork choice store")
+
+	Equivocations = newCounterVec("gean_equivocations_total", "Double proposals and double votes detected, by kind", "kind")
+)
+
+// State transition
+var (
+	StateTransitionTime = newHistogram("lean_state_transition_time_seconds",
+		"Time to run the state transition for a block", []float64{0.25, 0.5, 0.75, 1, 1.25, 1.5, 2, 2.5, 3, 4})
+)
+
+// Attestations. The source label is "gossip" or "block".
+var (
+	AttestationsValid = newCounterVec("lean_attestations_valid_total", "Attestations that passed validation", "source")
+
+	AttestationsInvalid = newCounterVec("lean_attestations_invalid_total", "Attestations that failed validation", "source")
+
+	AttestationValidationTime = newHistogram("lean_attestation_validation_time_seconds",
+		"Time to validate a gossiped attestation", []float64{0.005, 0.01, 0.025, 0.05, 0.1, 1})
+)
+
+// Network
+var (
+	ConnectedPeers = newGaugeVec("lean_connected_peers", "Connected peers, by connection direction", "direction")
+
+	GossipMessages = newCounterVec("gean_gossip_messages_total", "Gossip messages received, by topic and validation result", "topic", "result")
+
+	PendingBlocks = newGauge("gean_sync_pending_blocks", "Blocks waiting for their parent to arrive")
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+}
+
+func newGauge(name, help string) prometheus.Gauge {
+	g := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+	Registry.MustRegister(g)
+	return g
+}
+
+func newCounter(name, help string) prometheus.Counter {
+	c := prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})
+	Registry.MustRegister(c)
+	return c
+}
+
+func newHistogram(name, help string, buckets []float64) prometheus.Histogram {
+	h := prometheus.NewHistogram(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets})
+	Registry.MustRegister(h)
+	return h
+}
+
+func newGaugeVec(name, help string, labels ...string) *prometheus.GaugeVec {
+	g := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: name, Help: help}, labels)
+	Registry.MustRegister(g)
+	return g
+}
+
+func newCounterVec(name, help string, labels ...string) *prometheus.CounterVec {
+	c := prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, labels)
+	Registry.MustRegister(c)
+	return c
+}
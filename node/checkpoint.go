@@ -0,0 +1,82 @@
+package node
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/devylongs/gean/api"
+	"github.com/devylongs/gean/types"
+)
+
+// Anchor is a trusted finalized block and its post-state. A node given an
+// anchor starts from it instead of genesis (checkpoint sync) and backfills
+// the blocks before it in the background.
+type Anchor struct {
+	Block *types.SignedBlock
+	State *types.State
+}
+
+// ReadAnchor reads an anchor from SSZ-encoded state and signed block files.
+func ReadAnchor(statePath, blockPath string) (*Anchor, error) {
+	stateData, err := os.ReadFile(statePath)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint state: %w", err)
+	}
+	blockData, err := os.ReadFile(blockPath)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint block: %w", err)
+	}
+
+	anchor := &Anchor{Block: new(types.SignedBlock), State: new(types.State)}
+	if err := anchor.State.UnmarshalSSZ(stateData); err != nil {
+		return nil, fmt.Errorf("decode checkpoint state: %w", err)
+	}
+	if err := anchor.Block.UnmarshalSSZ(blockData); err != nil {
+		return nil, fmt.Errorf("decode checkpoint block: %w", err)
+	}
+	return anchor, anchor.verify()
+}
+
+// FetchAnchor downloads the finalized block with the trusted root and its
+// post-state from the API of another node. The root is obtained out of band,
+// so a node serving a different chain is caught before its state is used.
+func FetchAnchor(ctx context.Context, url string, root types.Root) (*Anchor, error) {
+	client := api.NewClient(url)
+	id := "0x" + hex.EncodeToString(root[:])
+
+	block, err := client.SignedBlock(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch checkpoint block: %w", err)
+	}
+	blockRoot, err := block.Message.HashTreeRoot()
+	if err != nil {
+		return nil, fmt.Errorf("hash checkpoint block: %w", err)
+	}
+	if blockRoot != root {
+		return nil, fmt.Errorf("checkpoint block root %x does not match trusted root %x", blockRoot[:4], root[:4])
+	}
+	state, err := client.State(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("fetch checkpoint state: %w", err)
+	}
+
+	anchor := &Anchor{Block: block, State: state}
+	return anchor, anchor.verify()
+}
+
+// verify checks that the block commits to the state.
+func (a *Anchor) verify() error {
+	stateRoot, err := a.State.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash checkpoint state: %w", err)
+	}
+	if a.Block.Message.StateRoot != stateRoot {
+		return fmt.Errorf("checkpoint block state root %x does not match state %x", a.Block.Message.StateRoot[:4], stateRoot[:4])
+	}
+	if a.Block.Message.Slot != a.State.Slot {
+		return fmt.Errorf("checkpoint block slot %d does not match state slot %d", a.Block.Message.Slot, a.State.Slot)
+	}
+	return nil
+}
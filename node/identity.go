@@ -0,0 +1,42 @@
+package node
+
+import (
+	"log/slog"
+	"path/filepath"
+
+	"github.com/devylongs/gean/p2p"
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// NodeKeyFile is the libp2p identity key file name inside the data directory.
+const NodeKeyFile = "node.key"
+
+// NodeKeyPath returns the node key file to use: keyFile if set, otherwise
+// the key file in dataDir. It is empty when neither is set.
+func NodeKeyPath(keyFile, dataDir string) string {
+	switch {
+	case keyFile != "":
+		return keyFile
+	case dataDir != "":
+		return filepath.Join(dataDir, NodeKeyFile)
+	}
+	return ""
+}
+
+// loadNodeKey loads or creates the node's identity key. Without a key file
+// it returns nil, and the host runs under a random key.
+func loadNodeKey(cfg *Config, logger *slog.Logger) (crypto.PrivKey, error) {
+	path := NodeKeyPath(cfg.NodeKeyFile, cfg.DataDir)
+	if path == "" {
+		logger.Warn("no node key file or data directory, the peer ID changes on every start")
+		return nil, nil
+	}
+	key, created, err := p2p.LoadNodeKey(path)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		logger.Info("created node key", "path", path)
+	}
+	return key, nil
+}
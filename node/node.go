@@ -4,35 +4,83 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"net"
 	"sync"
 	"time"
 
-	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/api"
+	"github.com/devylongs/gean/events"
 	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/metrics"
 	"github.com/devylongs/gean/p2p"
+	"github.com/devylongs/gean/p2p/discover"
+	"github.com/devylongs/gean/p2p/enr"
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/slashing"
+	"github.com/devylongs/gean/storage"
+	"github.com/devylongs/gean/syncer"
 	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // Node is the main consensus client that orchestrates all components.
 type Node struct {
-	config *Config
-	store  *forkchoice.Store
-	p2p    *p2p.Service
-	logger *slog.Logger
+	config    *Config
+	store     *forkchoice.Store
+	db        *storage.DB // nil when running without a data directory
+	p2p       *p2p.Service
+	discovery *discover.Service // nil when discovery is disabled
+	sync      *syncer.Syncer
+	api       *api.Server     // nil when the HTTP API is disabled
+	metrics   *metrics.Server // nil when metrics are disabled
+	logger    *slog.Logger
+
+	validators map[uint64]*xmss.PrivateKey // Signing key of each validator index run by this node
+	protection *slashing.DB                // nil when running without validators
+
+	lastTick tick // Last slot interval handled by onTick
 
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
 }
 
+// tick is a slot interval; the zero value is before the first tick.
+type tick struct {
+	slot     types.Slot
+	interval uint64
+	handled  bool
+}
+
 // Config holds node configuration.
 type Config struct {
-	GenesisTime    uint64
-	ValidatorCount uint64
-	ValidatorIndex *uint64 // nil if not a validator
-	ListenAddrs    []string
-	Bootnodes      []string
-	Logger         *slog.Logger
+	GenesisTime       uint64 // zero resumes the persisted genesis, or starts 10s from now
+	ValidatorCount    uint64
+	GenesisValidators []types.Validator  // genesis registry; nil uses devnet keys for ValidatorCount validators if InsecureDevnetKeys is set
+	ValidatorIndices  []uint64           // validators run with devnet keys; set to the loaded indices with ValidatorKeys
+	ValidatorKeys     []*xmss.PrivateKey // keystore keys; indices are looked up in the genesis registry
+	ListenAddrs       []string
+	NodeKeyFile       string   // hex secp256k1 identity key, created if missing; empty uses DataDir/node.key
+	Bootnodes         []string // multiaddrs with a /p2p peer ID, or ENRs
+	DiscoveryPort     int      // discv5 UDP port; zero disables discovery
+	AdvertiseIP       net.IP   // IP in the local node record; nil picks one from the listen addresses
+	TargetPeers       int      // peers to keep connected; zero uses p2p.DefaultTargetPeers
+	MaxInboundPeers   int      // zero uses p2p.DefaultMaxInboundPeers
+	DataDir           string   // empty keeps all chain data in memory
+	Anchor            *Anchor  // trusted finalized checkpoint to start from instead of genesis
+	APIAddr           string   // HTTP API listen address; empty disables the API
+	MetricsAddr       string   // Prometheus metrics listen address; empty disables metrics
+	Logger            *slog.Logger
+
+	// ExcludeEquivocators drops the fork choice weight of validators seen
+	// signing conflicting blocks or votes.
+	ExcludeEquivocators bool
+
+	// InsecureDevnetKeys allows the devnet keys, which anyone can derive
+	// from the validator index, for the genesis registry and ValidatorIndices.
+	InsecureDevnetKeys bool
 }
 
 // New creates a new node with the given configuration.
@@ -43,91 +91,192 @@ func New(ctx context.Context, cfg *Config) (*Node, error) {
 	if logger == nil {
 		logger = slog.Default()
 	}
-
-	// Generate genesis state
-	genesisState := chain.GenerateGenesis(cfg.GenesisTime, cfg.ValidatorCount)
-
-	// Create genesis block
-	emptyBody := types.BlockBody{Attestations: []types.SignedVote{}}
-	bodyRoot, _ := emptyBody.HashTreeRoot()
-	stateRoot, _ := genesisState.HashTreeRoot()
-
-	genesisBlock := &types.Block{
-		Slot:          0,
-		ProposerIndex: 0,
-		ParentRoot:    types.Root{},
-		StateRoot:     stateRoot,
-		Body:          emptyBody,
+	if cfg.GenesisValidators != nil {
+		cfg.ValidatorCount = uint64(len(cfg.GenesisValidators))
 	}
-
-	// Update state with correct block header
-	genesisState.LatestBlockHeader = types.BlockHeader{
-		Slot:          0,
-		ProposerIndex: 0,
-		ParentRoot:    types.Root{},
-		StateRoot:     types.Root{}, // Empty for genesis
-		BodyRoot:      bodyRoot,
+	if cfg.Anchor != nil {
+		anchorConfig := cfg.Anchor.State.Config
+		if cfg.GenesisTime != 0 && cfg.GenesisTime != anchorConfig.GenesisTime {
+			cancel()
+			return nil, fmt.Errorf("checkpoint genesis time %d does not match config %d", anchorConfig.GenesisTime, cfg.GenesisTime)
+		}
+		cfg.GenesisTime = anchorConfig.GenesisTime
+		cfg.ValidatorCount = anchorConfig.NumValidators
 	}
 
-	// Create fork choice store
-	store, err := forkchoice.NewStore(genesisState, genesisBlock)
+	// Create fork choice store, resuming from the database if one exists
+	store, db, err := openStore(cfg, logger)
 	if err != nil {
 		cancel()
-		return nil, fmt.Errorf("create store: %w", err)
+		return nil, err
 	}
 
-	// Create libp2p host
+	// Create libp2p host under the persistent node key
+	nodeKey, err := loadNodeKey(cfg, logger)
+	if err != nil {
+		cancel()
+		closeDB(db)
+		return nil, err
+	}
 	host, err := p2p.NewHost(ctx, p2p.HostConfig{
+		PrivateKey:  nodeKey,
 		ListenAddrs: cfg.ListenAddrs,
 	})
 	if err != nil {
 		cancel()
+		closeDB(db)
 		return nil, fmt.Errorf("create host: %w", err)
 	}
 
 	node := &Node{
 		config: cfg,
 		store:  store,
+		db:     db,
 		logger: logger,
 		ctx:    ctx,
 		cancel: cancel,
 	}
+	store.ExcludeEquivocators = cfg.ExcludeEquivocators
+	store.OnEquivocation = node.onEquivocation
+	store.Events = events.NewBus()
+	if err := node.loadValidators(); err != nil {
+		cancel()
+		host.Close()
+		closeDB(db)
+		return nil, err
+	}
 
 	// Parse bootnodes
-	bootnodes, err := p2p.ParseBootnodes(cfg.Bootnodes)
+	bootnodes, records, err := p2p.ParseBootnodes(cfg.Bootnodes)
 	if err != nil {
 		cancel()
 		host.Close()
+		closeDB(db)
 		return nil, fmt.Errorf("parse bootnodes: %w", err)
 	}
 
+	var discovery p2p.Discovery
+	if cfg.DiscoveryPort != 0 {
+		node.discovery, err = newDiscovery(cfg, host, records, logger)
+		if err != nil {
+			cancel()
+			host.Close()
+			closeDB(db)
+			return nil, err
+		}
+		discovery = node.discovery
+	}
+
 	// Create p2p service with handlers
 	handlers := &p2p.MessageHandlers{
-		OnBlock: node.handleBlock,
-		OnVote:  node.handleVote,
-		Logger:  logger,
+		OnBlock:  node.handleBlock,
+		OnVote:   node.handleVote,
+		OnStatus: node.handleStatus,
+		Logger:   logger,
 	}
 
 	p2pSvc, err := p2p.NewService(ctx, p2p.ServiceConfig{
 		Host:      host,
 		Handlers:  handlers,
+		ReqResp:   reqresp.NewHandler(store),
+		Chain:     store,
 		Bootnodes: bootnodes,
+		Discovery: discovery,
 		Logger:    logger,
+
+		TargetPeers:     cfg.TargetPeers,
+		MaxInboundPeers: cfg.MaxInboundPeers,
 	})
 	if err != nil {
 		cancel()
 		host.Close()
+		closeDB(db)
 		return nil, fmt.Errorf("create p2p service: %w", err)
 	}
 
 	node.p2p = p2pSvc
+	syncCfg := syncer.Config{
+		Store:   store,
+		Network: p2pSvc,
+		Logger:  logger,
+	}
+	if db != nil {
+		syncCfg.History = db
+	}
+	node.sync = syncer.New(syncCfg)
+
+	if cfg.APIAddr != "" {
+		node.api = api.New(api.Config{
+			Addr:    cfg.APIAddr,
+			Store:   store,
+			DB:      db,
+			Network: p2pSvc,
+			Sync:    node.sync,
+			Events:  store.Events,
+			Logger:  logger,
+		})
+	}
+
+	if cfg.MetricsAddr != "" {
+		node.metrics = metrics.NewServer(cfg.MetricsAddr, logger)
+	}
+
+	if err := node.checkKeyLifetime(types.SlotAt(uint64(time.Now().Unix()), cfg.GenesisTime), true); err != nil {
+		cancel()
+		host.Close()
+		closeDB(db)
+		return nil, err
+	}
+	if err := node.openProtection(); err != nil {
+		cancel()
+		host.Close()
+		closeDB(db)
+		return nil, err
+	}
 
 	return node, nil
 }
 
+// newDiscovery creates the discovery service, advertising the host's QUIC
+// address under the host's key.
+func newDiscovery(cfg *Config, h host.Host, bootnodes []*enr.Record, logger *slog.Logger) (*discover.Service, error) {
+	key, err := p2p.Secp256k1Key(h.Peerstore().PrivKey(h.ID()))
+	if err != nil {
+		return nil, fmt.Errorf("discovery: %w", err)
+	}
+	ip, quicPort := p2p.LocalEndpoint(h.Addrs())
+	if cfg.AdvertiseIP != nil {
+		ip = cfg.AdvertiseIP
+	}
+	return discover.New(discover.Config{
+		PrivateKey: key,
+		ListenAddr: fmt.Sprintf(":%d", cfg.DiscoveryPort),
+		IP:         ip,
+		QUICPort:   quicPort,
+		Bootnodes:  bootnodes,
+		Logger:     logger,
+	}), nil
+}
+
 // Start begins node operation.
-func (n *Node) Start() {
+func (n *Node) Start() error {
+	if n.api != nil {
+		if err := n.api.Start(); err != nil {
+			return err
+		}
+	}
+	if n.metrics != nil {
+		if err := n.metrics.Start(); err != nil {
+			return err
+		}
+	}
+	if n.discovery != nil {
+		if err := n.discovery.Start(n.ctx); err != nil {
+			return err
+		}
+	}
 	n.p2p.Start()
+	n.sync.Start(n.ctx)
 
 	n.wg.Add(1)
 	go n.slotTicker()
@@ -136,13 +285,41 @@ func (n *Node) Start() {
 		"genesis_time", n.config.GenesisTime,
 		"validators", n.config.ValidatorCount,
 	)
+	return nil
 }
 
 // Stop gracefully shuts down the node.
 func (n *Node) Stop() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	if n.api != nil {
+		if err := n.api.Stop(ctx); err != nil {
+			n.logger.Error("failed to stop api server", "error", err)
+		}
+	}
+	if n.metrics != nil {
+		if err := n.metrics.Stop(ctx); err != nil {
+			n.logger.Error("failed to stop metrics server", "error", err)
+		}
+	}
+	cancel()
 	n.cancel()
 	n.wg.Wait()
+	n.sync.Stop()
+	if n.discovery != nil {
+		n.discovery.Stop()
+	}
 	n.p2p.Stop()
+	if err := n.store.FlushForkChoice(); err != nil {
+		n.logger.Error("failed to persist fork choice", "error", err)
+	}
+	if err := closeDB(n.db); err != nil {
+		n.logger.Error("failed to close database", "error", err)
+	}
+	if n.protection != nil {
+		if err := n.protection.Close(); err != nil {
+			n.logger.Error("failed to close slashing protection database", "error", err)
+		}
+	}
 	n.logger.Info("node stopped")
 }
 
@@ -170,43 +347,75 @@ func (n *Node) onTick() {
 
 	slot := n.store.CurrentSlot()
 	interval := n.currentInterval()
+	n.updateMetrics()
+
+	// The ticker fires every second, so each interval is seen several times
+	// but its duties run once
+	if n.lastTick.handled && n.lastTick.slot == slot && n.lastTick.interval == interval {
+		return
+	}
+	n.lastTick = tick{slot: slot, interval: interval, handled: true}
 
 	// Log slot progression at start of each slot
 	if interval == 0 {
-		n.logger.Debug("slot", "slot", slot, "head", n.store.Head[:4], "peers", n.PeerCount())
+		head := n.store.HeadCheckpoint()
+		stats := n.store.Stats()
+		n.logger.Debug("slot",
+			"slot", slot,
+			"head", head.Root[:4],
+			"peers", n.PeerCount(),
+			"sync", n.sync.State(),
+			"blocks", stats.Blocks,
+			"states", stats.States,
+			"pruned_blocks", stats.Prune.BlocksPruned,
+			"pruned_states", stats.Prune.StatesPruned,
+		)
+		n.sync.Prune(slot)
+		n.store.PruneStates()
+		if err := n.checkKeyLifetime(slot, false); err != nil {
+			n.logger.Error("validator keys cannot sign", "slot", slot, "error", err)
+		}
+
+		// Fork choice is written once per slot rather than on every update
+		if err := n.store.FlushForkChoice(); err != nil {
+			n.logger.Warn("failed to persist fork choice", "slot", slot, "error", err)
+		}
+	}
+
+	// Validator duties are suppressed until we have caught up with peers
+	if !n.sync.IsSynced() {
+		return
 	}
 
-	// Interval 0: Proposer produces block
-	if interval == 0 && n.config.ValidatorIndex != nil {
-		// Check if we're the proposer for the current slot (round-robin)
+	// Interval 0: Proposer produces block if it is one of ours (round-robin).
+	// Slot 0 is the genesis block.
+	if interval == 0 && slot > 0 {
 		proposerIndex := uint64(slot) % n.config.ValidatorCount
-		if proposerIndex == *n.config.ValidatorIndex {
-			n.proposeBlock(slot)
+		if key, ok := n.validators[proposerIndex]; ok {
+			n.proposeBlock(slot, proposerIndex, key)
 		}
 	}
 
 	// Interval 1: Validators vote (per spec: "at the start of second interval")
-	if interval == 1 && n.config.ValidatorIndex != nil {
-		n.produceVote(slot)
+	if interval == 1 && len(n.validators) > 0 {
+		n.produceVotes(slot)
 	}
 }
 
 // currentInterval returns the current interval within the slot (0-3).
 func (n *Node) currentInterval() uint64 {
-	return n.store.Time % types.IntervalsPerSlot
+	return n.store.CurrentInterval()
 }
 
 // handleBlock processes an incoming block from the network.
-func (n *Node) handleBlock(ctx context.Context, signedBlock *types.SignedBlock) error {
-	block := &signedBlock.Message
-	if err := n.store.ProcessBlock(block); err != nil {
-		return fmt.Errorf("process block: %w", err)
-	}
-	n.logger.Info("processed block",
-		"slot", block.Slot,
-		"proposer", block.ProposerIndex,
-	)
-	return nil
+// Blocks with an unknown parent are queued while the syncer backfills them.
+func (n *Node) handleBlock(ctx context.Context, from peer.ID, signedBlock *types.SignedBlock) error {
+	return n.sync.OnBlock(ctx, from, signedBlock)
+}
+
+// handleStatus feeds the head a new peer advertised into sync.
+func (n *Node) handleStatus(from peer.ID, status *reqresp.Status) {
+	n.sync.UpdatePeerStatus(from, status)
 }
 
 // handleVote processes an incoming vote from the network.
@@ -221,22 +430,64 @@ func (n *Node) handleVote(ctx context.Context, vote *types.SignedVote) error {
 	return nil
 }
 
+// onEquivocation logs evidence of a validator signing conflicting messages.
+// It runs with the store locked.
+func (n *Node) onEquivocation(e forkchoice.Equivocation) {
+	metrics.Equivocations.WithLabelValues(e.Kind()).Inc()
+	n.logger.Warn("equivocation detected",
+		"kind", e.Kind(),
+		"validator", e.Validator,
+		"slot", e.Slot,
+		"excluded", n.config.ExcludeEquivocators,
+	)
+}
+
+// updateMetrics refreshes the chain, store and peer gauges.
+func (n *Node) updateMetrics() {
+	cp := n.store.Checkpoints()
+	slotOf := func(root types.Root) float64 {
+		if block, exists := n.store.Block(root); exists {
+			return float64(block.Slot)
+		}
+		return 0
+	}
+	metrics.HeadSlot.Set(slotOf(cp.Head))
+	metrics.SafeTargetSlot.Set(slotOf(cp.SafeTarget))
+	metrics.CurrentSlot.Set(float64(n.store.CurrentSlot()))
+	metrics.LatestJustifiedSlot.Set(float64(cp.LatestJustified.Slot))
+	metrics.LatestFinalizedSlot.Set(float64(cp.LatestFinalized.Slot))
+	metrics.ValidatorsCount.Set(float64(len(n.validators)))
+
+	stats := n.store.Stats()
+	metrics.StoreBlocks.Set(float64(stats.Blocks))
+	metrics.StoreStates.Set(float64(stats.States))
+	metrics.PendingBlocks.Set(float64(n.sync.PendingCount()))
+
+	inbound, outbound := n.p2p.PeerCountByDirection()
+	metrics.ConnectedPeers.WithLabelValues("inbound").Set(float64(inbound))
+	metrics.ConnectedPeers.WithLabelValues("outbound").Set(float64(outbound))
+}
+
 // proposeBlock creates and publishes a new block using Store.ProduceBlock
 // which iteratively collects valid attestations per the spec.
-func (n *Node) proposeBlock(slot types.Slot) {
-	validatorIndex := types.ValidatorIndex(*n.config.ValidatorIndex)
-
+func (n *Node) proposeBlock(slot types.Slot, validatorIndex uint64, key *xmss.PrivateKey) {
 	// ProduceBlock iteratively collects attestations and computes state root
-	block, err := n.store.ProduceBlock(slot, validatorIndex)
+	block, err := n.store.ProduceBlock(slot, types.ValidatorIndex(validatorIndex))
 	if err != nil {
 		n.logger.Warn("produce block failed", "slot", slot, "error", err)
 		return
 	}
 
-	// Create signed block (signature is placeholder for Devnet 0)
-	signedBlock := &types.SignedBlock{
-		Message:   *block,
-		Signature: types.Root{},
+	signedBlock, err := n.signBlock(key, block)
+	if err != nil {
+		n.logger.Error("failed to sign block", "slot", slot, "error", err)
+		return
+	}
+
+	// Import our own block before publishing; gossip skips validating it
+	if err := n.store.ProcessBlock(signedBlock); err != nil {
+		n.logger.Error("failed to process own block", "slot", slot, "error", err)
+		return
 	}
 
 	if err := n.p2p.PublishBlock(n.ctx, signedBlock); err != nil {
@@ -244,38 +495,59 @@ func (n *Node) proposeBlock(slot types.Slot) {
 		return
 	}
 
-	n.logger.Info("proposed block", "slot", slot, "attestations", len(block.Body.Attestations))
+	n.logger.Info("proposed block",
+		"slot", slot,
+		"proposer", validatorIndex,
+		"attestations", len(block.Body.Attestations),
+	)
 }
 
-// produceVote creates and publishes a vote.
-func (n *Node) produceVote(slot types.Slot) {
-	target := n.store.GetVoteTarget()
-	head := n.store.Head
-	headBlock := n.store.Blocks[head]
-
-	vote := &types.SignedVote{
-		Data: types.Vote{
-			Slot:        slot,
-			ValidatorID: *n.config.ValidatorIndex,
-			Head:        types.Checkpoint{Root: head, Slot: headBlock.Slot},
-			Target:      target,
-			Source:      n.store.LatestJustified,
-		},
-		Signature: types.Root{}, // Placeholder signature
-	}
-
-	if err := n.p2p.PublishVote(n.ctx, vote); err != nil {
-		n.logger.Error("failed to publish vote", "slot", slot, "error", err)
-		return
+// produceVotes creates a vote for every validator run by this node, signing
+// them in parallel, and publishes them as one batch.
+func (n *Node) produceVotes(slot types.Slot) {
+	head, target, source := n.store.VoteCheckpoints()
+
+	indices := n.config.ValidatorIndices
+	votes := make([]*types.SignedVote, len(indices))
+	var wg sync.WaitGroup
+	for i, index := range indices {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vote, err := n.signVote(n.validators[index], &types.Vote{
+				Slot:        slot,
+				ValidatorID: index,
+				Head:        head,
+				Target:      target,
+				Source:      source,
+			})
+			if err != nil {
+				n.logger.Error("failed to sign vote", "slot", slot, "validator", index, "error", err)
+				return
+			}
+			votes[i] = vote
+		}()
 	}
+	wg.Wait()
 
-	// Process our own vote
-	if err := n.store.ProcessAttestation(vote); err != nil {
-		n.logger.Error("failed to process own vote", "slot", slot, "error", err)
-		return
+	// Process our own votes, keeping only those that were signed
+	signed := votes[:0]
+	for _, vote := range votes {
+		if vote == nil {
+			continue
+		}
+		if err := n.store.ProcessAttestation(vote); err != nil {
+			n.logger.Error("failed to process own vote", "slot", slot, "validator", vote.Data.ValidatorID, "error", err)
+			continue
+		}
+		signed = append(signed, vote)
+	}
+
+	if err := n.p2p.PublishVotes(n.ctx, signed); err != nil {
+		n.logger.Error("failed to publish votes", "slot", slot, "error", err)
 	}
 
-	n.logger.Debug("produced vote", "slot", slot)
+	n.logger.Debug("produced votes", "slot", slot, "count", len(signed))
 }
 
 // CurrentSlot returns the current slot.
@@ -285,7 +557,12 @@ func (n *Node) CurrentSlot() types.Slot {
 
 // Head returns the current head root.
 func (n *Node) Head() types.Root {
-	return n.store.Head
+	return n.store.HeadCheckpoint().Root
+}
+
+// IsSynced reports whether the node has caught up with its peers.
+func (n *Node) IsSynced() bool {
+	return n.sync.IsSynced()
 }
 
 // PeerCount returns the number of connected peers.
@@ -0,0 +1,131 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/storage"
+)
+
+// DatabaseFile is the chain database file name inside the data directory.
+const DatabaseFile = "chain.db"
+
+// openStore returns the fork choice store for the node. With a data
+// directory, a previously persisted store is resumed; otherwise a fresh
+// store is created from genesis or the checkpoint anchor (and persisted if
+// a database is in use).
+func openStore(cfg *Config, logger *slog.Logger) (*forkchoice.Store, *storage.DB, error) {
+	if cfg.DataDir == "" {
+		store, err := newStore(cfg, logger)
+		return store, nil, err
+	}
+
+	if err := os.MkdirAll(cfg.DataDir, 0o700); err != nil {
+		return nil, nil, fmt.Errorf("create data dir: %w", err)
+	}
+	db, err := storage.Open(filepath.Join(cfg.DataDir, DatabaseFile))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	store, err := db.LoadStore()
+	switch {
+	case err == nil:
+		// An unset genesis time adopts the persisted one
+		if cfg.GenesisTime == 0 {
+			cfg.GenesisTime = store.Config.GenesisTime
+		}
+		if store.Config.GenesisTime != cfg.GenesisTime || store.Config.NumValidators != cfg.ValidatorCount {
+			db.Close()
+			return nil, nil, fmt.Errorf("database genesis (time %d, validators %d) does not match config (time %d, validators %d)",
+				store.Config.GenesisTime, store.Config.NumValidators, cfg.GenesisTime, cfg.ValidatorCount)
+		}
+		if cfg.Anchor != nil {
+			logger.Warn("ignoring checkpoint, the database already holds a chain")
+		}
+		logger.Info("resumed chain from database",
+			"head_slot", store.HeadCheckpoint().Slot,
+			"finalized_slot", store.Finalized().Slot,
+			"blocks", store.Stats().Blocks,
+		)
+		return store, db, nil
+
+	case errors.Is(err, storage.ErrNotFound):
+		store, err := newStore(cfg, logger)
+		if err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		store.SetDatabase(db)
+		if err := store.Persist(); err != nil {
+			db.Close()
+			return nil, nil, fmt.Errorf("persist genesis: %w", err)
+		}
+		return store, db, nil
+
+	default:
+		db.Close()
+		return nil, nil, fmt.Errorf("load store: %w", err)
+	}
+}
+
+// newStore creates a fork choice store anchored at the checkpoint anchor if
+// one is configured, and at the genesis block otherwise.
+func newStore(cfg *Config, logger *slog.Logger) (*forkchoice.Store, error) {
+	if cfg.Anchor == nil {
+		return newGenesisStore(cfg, logger)
+	}
+
+	block := &cfg.Anchor.Block.Message
+	store, err := forkchoice.NewCheckpointStore(cfg.Anchor.State, cfg.Anchor.Block)
+	if err != nil {
+		return nil, fmt.Errorf("create checkpoint store: %w", err)
+	}
+	logger.Info("starting from checkpoint",
+		"slot", block.Slot,
+		"root", store.Head[:4],
+		"genesis_time", cfg.GenesisTime,
+	)
+	return store, nil
+}
+
+// newGenesisStore creates a fork choice store anchored at the genesis block.
+func newGenesisStore(cfg *Config, logger *slog.Logger) (*forkchoice.Store, error) {
+	// Set genesis time
+	if cfg.GenesisTime == 0 {
+		cfg.GenesisTime = uint64(time.Now().Unix()) + 10
+		logger.Info("genesis time not set, using now + 10 seconds", "genesis_time", cfg.GenesisTime)
+	}
+
+	// Generate genesis state, defaulting to the devnet validator keys only
+	// when they are explicitly allowed
+	validators := cfg.GenesisValidators
+	if validators == nil {
+		if !cfg.InsecureDevnetKeys {
+			return nil, fmt.Errorf("no genesis validators; pass a registry, or allow the devnet keys for a local devnet with --insecure-devnet-keys")
+		}
+		validators = chain.DevnetValidators(cfg.ValidatorCount)
+	}
+	genesisState := chain.GenerateGenesis(cfg.GenesisTime, validators)
+	genesisBlock := chain.GenesisBlock(genesisState)
+
+	store, err := forkchoice.NewStore(genesisState, genesisBlock)
+	if err != nil {
+		return nil, fmt.Errorf("create store: %w", err)
+	}
+	return store, nil
+}
+
+// closeDB closes the database if one is open.
+func closeDB(db *storage.DB) error {
+	if db == nil {
+		return nil
+	}
+	return db.Close()
+}
@@ -0,0 +1,177 @@
+package node
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/metrics"
+	"github.com/devylongs/gean/slashing"
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+)
+
+// SlashingDatabaseFile is the slashing protection database file name inside
+// the data directory.
+const SlashingDatabaseFile = "slashing.db"
+
+// Validator key expiry warnings start a day before the keys run out and
+// repeat every hour.
+const (
+	KeyExpiryWarningSlots = 24 * 60 * 60 / types.SecondsPerSlot
+	KeyExpiryLogInterval  = 60 * 60 / types.SecondsPerSlot
+)
+
+// loadValidators sets up the signing keys of the validators this node runs.
+// Keystore keys are matched against the genesis registry to find their
+// indices; without keystores, each configured index signs with its devnet key.
+func (n *Node) loadValidators() error {
+	cfg := n.config
+	registry := n.store.Validators
+
+	n.validators = make(map[uint64]*xmss.PrivateKey)
+	switch {
+	case len(cfg.ValidatorKeys) > 0 && len(cfg.ValidatorIndices) > 0:
+		return fmt.Errorf("validator indices and keystores are mutually exclusive")
+
+	case len(cfg.ValidatorKeys) > 0:
+		n.validators = validatorIndices(registry, cfg.ValidatorKeys)
+		if len(n.validators) == 0 {
+			return fmt.Errorf("none of the %d keystores is in the validator registry", len(cfg.ValidatorKeys))
+		}
+
+	case len(cfg.ValidatorIndices) > 0 && !cfg.InsecureDevnetKeys:
+		return fmt.Errorf("validator indices sign with devnet keys that anyone can derive; use keystores, or allow them for a local devnet with --insecure-devnet-keys")
+
+	default:
+		for _, index := range cfg.ValidatorIndices {
+			if index >= uint64(len(registry)) {
+				return fmt.Errorf("validator index %d out of range, registry has %d validators", index, len(registry))
+			}
+			n.validators[index] = xmss.DevnetKey(index)
+		}
+	}
+
+	cfg.ValidatorIndices = make([]uint64, 0, len(n.validators))
+	for index := range n.validators {
+		cfg.ValidatorIndices = append(cfg.ValidatorIndices, index)
+	}
+	sort.Slice(cfg.ValidatorIndices, func(i, j int) bool { return cfg.ValidatorIndices[i] < cfg.ValidatorIndices[j] })
+	return nil
+}
+
+// validatorIndices maps the registry index of every key found in the
+// registry to that key. Keys outside the registry are skipped.
+func validatorIndices(registry []types.Validator, keys []*xmss.PrivateKey) map[uint64]*xmss.PrivateKey {
+	byPubkey := make(map[types.Pubkey]*xmss.PrivateKey, len(keys))
+	for _, key := range keys {
+		byPubkey[types.Pubkey(key.PublicKey())] = key
+	}
+
+	indices := make(map[uint64]*xmss.PrivateKey)
+	for i, v := range registry {
+		if key, ok := byPubkey[v.Pubkey]; ok {
+			indices[uint64(i)] = key
+		}
+	}
+	return indices
+}
+
+// openProtection opens the slashing protection database and restores each
+// key's signing epoch from it. It lives in the data directory, so validators
+// cannot run without one: after a restart an XMSS key would otherwise sign
+// again at epochs it already used.
+func (n *Node) openProtection() error {
+	if len(n.validators) == 0 {
+		return nil
+	}
+	if n.config.DataDir == "" {
+		return fmt.Errorf("validators require a data directory for slashing protection")
+	}
+	db, err := slashing.Open(filepath.Join(n.config.DataDir, SlashingDatabaseFile))
+	if err != nil {
+		return err
+	}
+	for index, key := range n.validators {
+		if err := restoreEpoch(db, key); err != nil {
+			db.Close()
+			return fmt.Errorf("validator %d: %w", index, err)
+		}
+	}
+	n.protection = db
+	return nil
+}
+
+// restoreEpoch marks the key used up to the epoch of the last block or vote
+// recorded for it.
+func restoreEpoch(db *slashing.DB, key *xmss.PrivateKey) error {
+	pubkey := types.Pubkey(key.PublicKey())
+	blockSlot, hasBlock, err := db.LastBlockSlot(pubkey)
+	if err != nil {
+		return err
+	}
+	voteSlot, hasVote, err := db.LastVoteSlot(pubkey)
+	if err != nil {
+		return err
+	}
+	if hasBlock {
+		key.MarkUsed(chain.BlockEpoch(blockSlot))
+	}
+	if hasVote {
+		key.MarkUsed(chain.VoteEpoch(voteSlot))
+	}
+	return nil
+}
+
+// checkKeyLifetime errors if the validator keys have no epochs left for the
+// slot, and keeps the remaining slots metric up to date. Within
+// KeyExpiryWarningSlots of the end it logs an error at startup and every
+// KeyExpiryLogInterval slots so the keys are rotated in time.
+func (n *Node) checkKeyLifetime(slot types.Slot, startup bool) error {
+	if len(n.validators) == 0 {
+		return nil
+	}
+	if uint64(slot) >= chain.KeyLifetimeSlots {
+		metrics.ValidatorKeyRemainingSlots.Set(0)
+		return fmt.Errorf("validator keys expired at slot %d", chain.KeyLifetimeSlots)
+	}
+	remaining := chain.KeyLifetimeSlots - uint64(slot)
+	metrics.ValidatorKeyRemainingSlots.Set(float64(remaining))
+	if remaining <= KeyExpiryWarningSlots && (startup || remaining%KeyExpiryLogInterval == 0) {
+		n.logger.Error("validator keys expire soon, generate new keys and a new genesis",
+			"slot", slot,
+			"remaining_slots", remaining,
+			"expiry_slot", chain.KeyLifetimeSlots,
+		)
+	}
+	return nil
+}
+
+// signBlock signs a block once slashing protection has recorded it.
+func (n *Node) signBlock(key *xmss.PrivateKey, block *types.Block) (*types.SignedBlock, error) {
+	if n.protection != nil {
+		root, err := block.HashTreeRoot()
+		if err != nil {
+			return nil, fmt.Errorf("hash block: %w", err)
+		}
+		if err := n.protection.CheckAndRecordBlock(types.Pubkey(key.PublicKey()), block.Slot, root); err != nil {
+			return nil, fmt.Errorf("slashing protection: %w", err)
+		}
+	}
+	return chain.SignBlock(key, block)
+}
+
+// signVote signs a vote once slashing protection has recorded it.
+func (n *Node) signVote(key *xmss.PrivateKey, vote *types.Vote) (*types.SignedVote, error) {
+	if n.protection != nil {
+		root, err := vote.HashTreeRoot()
+		if err != nil {
+			return nil, fmt.Errorf("hash vote: %w", err)
+		}
+		if err := n.protection.CheckAndRecordVote(types.Pubkey(key.PublicKey()), vote, root); err != nil {
+			return nil, fmt.Errorf("slashing protection: %w", err)
+		}
+	}
+	return chain.SignVote(key, vote)
+}
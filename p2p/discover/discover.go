@@ -0,0 +1,700 @@
+// Package discover implements node discovery with the discv5.1 protocol.
+//
+// The service keeps a table of node records learned from bootnodes and
+// iterative lookups, and answers PING, FINDNODE and TALKREQ from other
+// nodes. Records carrying a QUIC port are reported as libp2p peers to dial;
+// whether a peer belongs to our network is left to the Status handshake.
+package discover
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/netip"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/devylongs/gean/p2p/enr"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Timing
+const (
+	RefreshInterval = 30 * time.Second // interval between random lookups
+	initialRefresh  = 2 * time.Second  // first interval, doubling up to RefreshInterval
+	RequestTimeout  = time.Second      // wait for each response packet
+	challengeTTL    = 10 * time.Second // lifetime of an unanswered WHOAREYOU
+)
+
+const (
+	lookupAlpha       = 3  // concurrent FINDNODE requests in a lookup
+	maxNodesResponse  = 16 // records returned for a FINDNODE
+	maxNodesPerPacket = 3  // records per NODES packet, keeping it under the size limit
+	maxChallenges     = 1024
+	responseBuffer    = 16
+)
+
+var errTimeout = errors.New("request timed out")
+
+// Config holds discovery configuration.
+type Config struct {
+	PrivateKey *secp256k1.PrivateKey
+	ListenAddr string // UDP listen address, e.g. 0.0.0.0:9100
+	IP         net.IP // IP advertised in the local record
+	QUICPort   int    // libp2p QUIC port advertised in the local record
+	Bootnodes  []*enr.Record
+	Logger     *slog.Logger
+}
+
+// Service runs discv5 discovery.
+type Service struct {
+	key       *secp256k1.PrivateKey
+	id        enr.ID
+	cfg       Config
+	self      *enr.Record
+	conn      *net.UDPConn
+	table     *table
+	sessions  sessions
+	bootnodes []*enr.Record
+	logger    *slog.Logger
+
+	mu         sync.Mutex
+	calls      map[string]*call          // pending requests by request ID
+	sent       map[nonce]*call           // pending requests by packet nonce, to answer a WHOAREYOU
+	challenges map[sessionKey]*challenge // WHOAREYOU packets awaiting a handshake
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// call is a request awaiting responses.
+type call struct {
+	node       *enr.Record
+	addr       netip.AddrPort
+	msg        message
+	responses  chan message
+	handshaked bool
+}
+
+// challenge is a WHOAREYOU sent to a node.
+type challenge struct {
+	data   []byte      // masking-iv || header of the WHOAREYOU packet
+	record *enr.Record // the node's record if known; otherwise the handshake must carry it
+	sent   time.Time
+}
+
+// New creates a discovery service. It does not touch the network until Start.
+func New(cfg Config) *Service {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	id := enr.PubkeyID(cfg.PrivateKey.PubKey())
+	return &Service{
+		key:        cfg.PrivateKey,
+		id:         id,
+		cfg:        cfg,
+		table:      newTable(id),
+		bootnodes:  cfg.Bootnodes,
+		logger:     logger,
+		calls:      make(map[string]*call),
+		sent:       make(map[nonce]*call),
+		challenges: make(map[sessionKey]*challenge),
+	}
+}
+
+// Start opens the UDP socket, creates the local record and starts the
+// lookup loop.
+func (s *Service) Start(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp", s.cfg.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("resolve discovery address: %w", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("listen discovery: %w", err)
+	}
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+
+	self, err := LocalRecord(s.key, s.cfg.IP, port, s.cfg.QUICPort)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn = conn
+	s.self = self
+
+	s.ctx, s.cancel = context.WithCancel(ctx)
+	s.wg.Add(2)
+	go s.readLoop()
+	go s.refreshLoop()
+
+	s.logger.Info("discovery started",
+		"addr", conn.LocalAddr().String(),
+		"node_id", s.id,
+		"enr", self.String(),
+	)
+	return nil
+}
+
+// LocalRecord creates the record advertising a node's discovery and QUIC
+// ports. A zero QUIC port is left out. The sequence number is the current
+// time, so records grow across restarts.
+func LocalRecord(key *secp256k1.PrivateKey, ip net.IP, udpPort, quicPort int) (*enr.Record, error) {
+	entries := []enr.Entry{enr.IP(ip), enr.Port(enr.KeyUDP, udpPort)}
+	if quicPort != 0 {
+		entries = append(entries, enr.Port(enr.KeyQUIC, quicPort))
+	}
+	r, err := enr.New(key, uint64(time.Now().Unix()), entries...)
+	if err != nil {
+		return nil, fmt.Errorf("create local record: %w", err)
+	}
+	return r, nil
+}
+
+// Stop closes the socket and waits for the service to exit.
+func (s *Service) Stop() {
+	if s.conn == nil {
+		return
+	}
+	s.cancel()
+	s.conn.Close()
+	s.wg.Wait()
+	s.logger.Info("discovery stopped")
+}
+
+// Self returns the local node record. It is nil until Start.
+func (s *Service) Self() *enr.Record {
+	return s.self
+}
+
+// Nodes returns the records of all known nodes.
+func (s *Service) Nodes() []*enr.Record {
+	return s.table.records()
+}
+
+// Peers returns the libp2p address of every known node with a QUIC port.
+func (s *Service) Peers() []peer.AddrInfo {
+	var peers []peer.AddrInfo
+	for _, r := range s.table.records() {
+		if info, err := r.AddrInfo(); err == nil {
+			peers = append(peers, info)
+		}
+	}
+	return peers
+}
+
+// refreshLoop seeds the table from the bootnodes and runs a lookup for the
+// local ID, then random lookups. Lookups start frequent, to fill the table
+// quickly while a network comes up, and slow down to RefreshInterval.
+func (s *Service) refreshLoop() {
+	defer s.wg.Done()
+
+	interval := initialRefresh
+	target := s.id
+	for {
+		if s.table.len() == 0 {
+			for _, r := range s.bootnodes {
+				s.table.add(r)
+			}
+		}
+		s.lookup(target)
+		s.expireChallenges()
+		s.logger.Debug("discovery lookup done", "nodes", s.table.len(), "peers", len(s.Peers()))
+
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+		interval = min(2*interval, RefreshInterval)
+		rand.Read(target[:])
+	}
+}
+
+// lookup asks the nodes closest to target for nodes closer still, until no
+// unasked node remains among the closest found.
+func (s *Service) lookup(target enr.ID) {
+	asked := map[enr.ID]bool{s.id: true}
+	seen := map[enr.ID]bool{s.id: true}
+	closest := s.table.closest(target, bucketSize)
+	for _, r := range closest {
+		seen[r.NodeID()] = true
+	}
+
+	for s.ctx.Err() == nil {
+		var batch []*enr.Record
+		for _, r := range closest {
+			if !asked[r.NodeID()] {
+				asked[r.NodeID()] = true
+				batch = append(batch, r)
+			}
+			if len(batch) == lookupAlpha {
+				break
+			}
+		}
+		if len(batch) == 0 {
+			return
+		}
+
+		var (
+			mu    sync.Mutex
+			found []*enr.Record
+			wg    sync.WaitGroup
+		)
+		for _, node := range batch {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				records, err := s.findnode(node, lookupDistances(target, node.NodeID()))
+				if err != nil {
+					s.logger.Debug("findnode failed", "node", node.NodeID(), "error", err)
+					return
+				}
+				mu.Lock()
+				found = append(found, records...)
+				mu.Unlock()
+			}()
+		}
+		wg.Wait()
+
+		for _, r := range found {
+			s.table.add(r)
+			if id := r.NodeID(); !seen[id] && r.UDPAddr() != nil {
+				seen[id] = true
+				closest = append(closest, r)
+			}
+		}
+		sortByDistance(target, closest)
+		if len(closest) > bucketSize {
+			closest = closest[:bucketSize]
+		}
+	}
+}
+
+// lookupDistances returns the log distances at which node should hold
+// nodes close to target.
+func lookupDistances(target, node enr.ID) []uint64 {
+	d := uint64(enr.LogDistance(target, node))
+	switch {
+	case d <= 1:
+		return []uint64{1, 2, 3}
+	case d == 256:
+		return []uint64{256, 255, 254}
+	}
+	return []uint64{d, d + 1, d - 1}
+}
+
+// findnode asks a node for the records at the given distances.
+func (s *Service) findnode(node *enr.Record, distances []uint64) ([]*enr.Record, error) {
+	c, err := s.request(node, &findnode{distances: distances})
+	if err != nil {
+		return nil, err
+	}
+	defer s.finish(c)
+
+	var records []*enr.Record
+	for received := uint64(0); ; {
+		m, err := s.wait(c, msgNodes)
+		if err != nil {
+			if len(records) > 0 {
+				return records, nil // keep the partial answer
+			}
+			return nil, err
+		}
+		resp := m.(*nodes)
+		for _, r := range resp.records {
+			if containsDistance(distances, enr.LogDistance(r.NodeID(), node.NodeID())) {
+				records = append(records, r)
+			}
+		}
+		if received++; received >= resp.total || received >= maxNodesResponse {
+			return records, nil
+		}
+	}
+}
+
+func containsDistance(distances []uint64, d int) bool {
+	for _, want := range distances {
+		if want == uint64(d) {
+			return true
+		}
+	}
+	return false
+}
+
+// wait returns the next response of the given kind, failing the node in the
+// table on timeout.
+func (s *Service) wait(c *call, kind byte) (message, error) {
+	timer := time.NewTimer(RequestTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case m := <-c.responses:
+			if m.kind() == kind {
+				s.table.succeeded(c.node.NodeID())
+				return m, nil
+			}
+		case <-timer.C:
+			s.table.failed(c.node.NodeID())
+			s.sessions.delete(c.node.NodeID(), c.addr)
+			return nil, errTimeout
+		case <-s.ctx.Done():
+			return nil, s.ctx.Err()
+		}
+	}
+}
+
+// request sends a request to a node. Without a session the request is sent
+// as a packet the node cannot decrypt, which it answers with a WHOAREYOU
+// that starts the handshake.
+func (s *Service) request(node *enr.Record, m message) (*call, error) {
+	addr, ok := addrPort(node)
+	if !ok {
+		return nil, fmt.Errorf("node has no UDP address")
+	}
+	reqID := make([]byte, maxRequestIDSize)
+	rand.Read(reqID)
+	switch m := m.(type) {
+	case *ping:
+		m.reqID = reqID
+	case *findnode:
+		m.reqID = reqID
+	}
+
+	c := &call{node: node, addr: addr, msg: m, responses: make(chan message, responseBuffer)}
+	h := newHeader(flagMessage, s.id[:])
+
+	var body []byte
+	if sess := s.sessions.get(node.NodeID(), addr); sess != nil {
+		var err error
+		if body, err = encryptMessage(sess.writeKey, h, encodeMessage(m)); err != nil {
+			return nil, err
+		}
+	} else {
+		body = make([]byte, 20)
+		rand.Read(body)
+	}
+
+	s.mu.Lock()
+	s.calls[string(reqID)] = c
+	s.sent[h.nonce] = c
+	s.mu.Unlock()
+
+	if err := s.write(node.NodeID(), addr, h, body); err != nil {
+		s.finish(c)
+		return nil, err
+	}
+	return c, nil
+}
+
+// finish forgets a completed request.
+func (s *Service) finish(c *call) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.calls, string(c.msg.requestID()))
+	for n, pending := range s.sent {
+		if pending == c {
+			delete(s.sent, n)
+		}
+	}
+}
+
+// expireChallenges drops WHOAREYOU challenges that were never answered.
+func (s *Service) expireChallenges() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, ch := range s.challenges {
+		if time.Since(ch.sent) > challengeTTL {
+			delete(s.challenges, key)
+		}
+	}
+}
+
+// readLoop handles incoming packets until the socket is closed.
+func (s *Service) readLoop() {
+	defer s.wg.Done()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := s.conn.ReadFromUDPAddrPort(buf)
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			s.logger.Debug("discovery read failed", "error", err)
+			continue
+		}
+		from = netip.AddrPortFrom(from.Addr().Unmap(), from.Port())
+		if err := s.handlePacket(buf[:n], from); err != nil {
+			s.logger.Debug("dropped discovery packet", "from", from, "error", err)
+		}
+	}
+}
+
+func (s *Service) handlePacket(packet []byte, from netip.AddrPort) error {
+	h, body, err := decodePacket(s.id, packet)
+	if err != nil {
+		return err
+	}
+	switch h.flag {
+	case flagMessage:
+		return s.handleMessagePacket(h, body, from)
+	case flagWhoareyou:
+		return s.handleWhoareyou(h, from)
+	case flagHandshake:
+		return s.handleHandshake(h, body, from)
+	default:
+		return fmt.Errorf("%w: unknown flag %d", errInvalidHeader, h.flag)
+	}
+}
+
+// handleMessagePacket decrypts a message with the session keys, or starts
+// a handshake when there is no session.
+func (s *Service) handleMessagePacket(h *header, body []byte, from netip.AddrPort) error {
+	if len(h.authData) != messageAuthSize {
+		return fmt.Errorf("%w: message authdata size %d", errInvalidHeader, len(h.authData))
+	}
+	var src enr.ID
+	copy(src[:], h.authData)
+
+	sess := s.sessions.get(src, from)
+	if sess == nil {
+		return s.sendWhoareyou(src, from, h.nonce)
+	}
+	plaintext, err := decryptMessage(sess.readKey, h, body)
+	if err != nil {
+		return s.sendWhoareyou(src, from, h.nonce)
+	}
+	return s.handleMessage(src, from, plaintext)
+}
+
+// sendWhoareyou challenges a node to a handshake, answering the packet with
+// the given nonce.
+func (s *Service) sendWhoareyou(id enr.ID, addr netip.AddrPort, n nonce) error {
+	key := sessionKey{id, addr}
+	s.mu.Lock()
+	if ch := s.challenges[key]; ch != nil && time.Since(ch.sent) < RequestTimeout {
+		s.mu.Unlock()
+		return nil // a handshake is already under way
+	}
+	if len(s.challenges) >= maxChallenges {
+		s.mu.Unlock()
+		return fmt.Errorf("too many pending handshakes")
+	}
+	s.mu.Unlock()
+
+	auth := &whoareyouAuth{}
+	rand.Read(auth.idNonce[:])
+	record := s.table.get(id)
+	if record != nil {
+		auth.enrSeq = record.Seq()
+	}
+	h := newHeader(flagWhoareyou, auth.encode())
+	h.nonce = n
+
+	s.mu.Lock()
+	s.challenges[key] = &challenge{data: h.additionalData(), record: record, sent: time.Now()}
+	s.mu.Unlock()
+	return s.write(id, addr, h, nil)
+}
+
+// handleWhoareyou completes the handshake for a request the node could not
+// decrypt, resending the request in a handshake packet.
+func (s *Service) handleWhoareyou(h *header, from netip.AddrPort) error {
+	auth, err := decodeWhoareyouAuth(h.authData)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	c := s.sent[h.nonce]
+	delete(s.sent, h.nonce)
+	if c != nil && c.handshaked {
+		c = nil
+	}
+	if c != nil {
+		c.handshaked = true
+	}
+	s.mu.Unlock()
+	if c == nil || c.addr != from {
+		return fmt.Errorf("unsolicited WHOAREYOU")
+	}
+
+	remote := c.node.NodeID()
+	ephKey, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return err
+	}
+	ephPub := ephKey.PubKey().SerializeCompressed()
+	challengeData := h.additionalData()
+	initiatorKey, recipientKey := deriveKeys(ephKey, c.node.PublicKey(), s.id, remote, challengeData)
+
+	hsAuth := &handshakeAuth{
+		srcID:  s.id,
+		sig:    enr.Sign(s.key, idProofHash(challengeData, ephPub, remote)),
+		ephKey: ephPub,
+	}
+	if auth.enrSeq < s.self.Seq() {
+		hsAuth.record = s.self.Encode()
+	}
+	hs := newHeader(flagHandshake, hsAuth.encode())
+	body, err := encryptMessage(initiatorKey, hs, encodeMessage(c.msg))
+	if err != nil {
+		return err
+	}
+	s.sessions.put(remote, from, &session{writeKey: initiatorKey, readKey: recipientKey})
+	return s.write(remote, from, hs, body)
+}
+
+// handleHandshake verifies a node's answer to our WHOAREYOU and sets up the
+// session.
+func (s *Service) handleHandshake(h *header, body []byte, from netip.AddrPort) error {
+	auth, err := decodeHandshakeAuth(h.authData)
+	if err != nil {
+		return err
+	}
+	key := sessionKey{auth.srcID, from}
+	s.mu.Lock()
+	ch := s.challenges[key]
+	delete(s.challenges, key)
+	s.mu.Unlock()
+	if ch == nil {
+		return fmt.Errorf("handshake without a challenge")
+	}
+
+	record := ch.record
+	if len(auth.record) > 0 {
+		r, err := enr.Decode(auth.record)
+		if err != nil {
+			return fmt.Errorf("handshake record: %w", err)
+		}
+		if r.NodeID() != auth.srcID {
+			return fmt.Errorf("handshake record belongs to another node")
+		}
+		if record == nil || r.Seq() > record.Seq() {
+			record = r
+		}
+	}
+	if record == nil {
+		return fmt.Errorf("handshake without a record of an unknown node")
+	}
+	if !enr.VerifySignature(record.PublicKey(), idProofHash(ch.data, auth.ephKey, s.id), auth.sig) {
+		return fmt.Errorf("invalid handshake signature")
+	}
+	ephPub, err := secp256k1.ParsePubKey(auth.ephKey)
+	if err != nil {
+		return fmt.Errorf("handshake ephemeral key: %w", err)
+	}
+
+	initiatorKey, recipientKey := deriveKeys(s.key, ephPub, auth.srcID, s.id, ch.data)
+	plaintext, err := decryptMessage(initiatorKey, h, body)
+	if err != nil {
+		return fmt.Errorf("decrypt handshake message: %w", err)
+	}
+	s.sessions.put(auth.srcID, from, &session{writeKey: recipientKey, readKey: initiatorKey})
+
+	// Only add nodes reachable at the address they advertise
+	if addr, ok := addrPort(record); ok && addr == from {
+		s.table.add(record)
+	}
+	return s.handleMessage(auth.srcID, from, plaintext)
+}
+
+// handleMessage answers requests and routes responses to pending calls.
+func (s *Service) handleMessage(src enr.ID, from netip.AddrPort, plaintext []byte) error {
+	m, err := decodeMessage(plaintext)
+	if err != nil {
+		return err
+	}
+	switch m := m.(type) {
+	case *ping:
+		return s.send(src, from, &pong{
+			reqID:  m.reqID,
+			enrSeq: s.self.Seq(),
+			ip:     net.IP(from.Addr().AsSlice()),
+			port:   uint64(from.Port()),
+		})
+	case *findnode:
+		return s.sendNodes(src, from, m)
+	case *talkreq:
+		// No talk protocols are supported; an empty response says so
+		return s.send(src, from, &talkresp{reqID: m.reqID})
+	}
+
+	s.mu.Lock()
+	c := s.calls[string(m.requestID())]
+	s.mu.Unlock()
+	if c == nil || c.node.NodeID() != src {
+		return fmt.Errorf("unsolicited response type %#x", m.kind())
+	}
+	select {
+	case c.responses <- m:
+	default:
+	}
+	return nil
+}
+
+// sendNodes answers a FINDNODE, splitting the records across packets.
+func (s *Service) sendNodes(src enr.ID, from netip.AddrPort, req *findnode) error {
+	var records []*enr.Record
+	for _, d := range req.distances {
+		if d == 0 {
+			records = append(records, s.self)
+		}
+	}
+	records = append(records, s.table.atDistances(req.distances, maxNodesResponse-len(records))...)
+
+	total := (len(records) + maxNodesPerPacket - 1) / maxNodesPerPacket
+	if total == 0 {
+		return s.send(src, from, &nodes{reqID: req.reqID, total: 1})
+	}
+	for i := 0; i < len(records); i += maxNodesPerPacket {
+		end := min(i+maxNodesPerPacket, len(records))
+		if err := s.send(src, from, &nodes{reqID: req.reqID, total: uint64(total), records: records[i:end]}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends a message to a node over an established session.
+func (s *Service) send(id enr.ID, addr netip.AddrPort, m message) error {
+	sess := s.sessions.get(id, addr)
+	if sess == nil {
+		return fmt.Errorf("no session with %s", addr)
+	}
+	h := newHeader(flagMessage, s.id[:])
+	body, err := encryptMessage(sess.writeKey, h, encodeMessage(m))
+	if err != nil {
+		return err
+	}
+	return s.write(id, addr, h, body)
+}
+
+func (s *Service) write(dest enr.ID, addr netip.AddrPort, h *header, body []byte) error {
+	_, err := s.conn.WriteToUDPAddrPort(encodePacket(dest, h, body), addr)
+	return err
+}
+
+// addrPort returns the UDP address of a record.
+func addrPort(r *enr.Record) (netip.AddrPort, bool) {
+	udp := r.UDPAddr()
+	if udp == nil {
+		return netip.AddrPort{}, false
+	}
+	addr, ok := netip.AddrFromSlice(udp.IP)
+	return netip.AddrPortFrom(addr.Unmap(), uint16(udp.Port)), ok
+}
+
+// sortByDistance sorts records by XOR distance to target.
+func sortByDistance(target enr.ID, records []*enr.Record) {
+	sort.Slice(records, func(i, j int) bool {
+		return closer(target, records[i].NodeID(), records[j].NodeID())
+	})
+}
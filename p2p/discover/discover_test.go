@@ -0,0 +1,151 @@
+package discover
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/devylongs/gean/p2p/enr"
+)
+
+func startNode(t *testing.T, quicPort int, bootnodes ...*enr.Record) *Service {
+	t.Helper()
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := New(Config{
+		PrivateKey: key,
+		ListenAddr: "127.0.0.1:0",
+		IP:         net.IPv4(127, 0, 0, 1),
+		QUICPort:   quicPort,
+		Bootnodes:  bootnodes,
+	})
+	if err := s.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	t.Cleanup(s.Stop)
+	return s
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDiscoveryThroughBootnode(t *testing.T) {
+	boot := startNode(t, 0)
+	a := startNode(t, 9001, boot.Self())
+	b := startNode(t, 9002, boot.Self())
+
+	// The bootnode learns of both nodes from their handshakes
+	waitFor(t, "the bootnode to learn both nodes", func() bool { return boot.table.len() == 2 })
+	if len(boot.Peers()) != 2 {
+		t.Errorf("bootnode reports %d peers, want 2", len(boot.Peers()))
+	}
+
+	a.lookup(b.id)
+	if a.table.get(b.id) == nil {
+		t.Fatal("lookup did not find the other node")
+	}
+	wantID, _ := b.Self().PeerID()
+	var found bool
+	for _, info := range a.Peers() {
+		found = found || info.ID == wantID
+	}
+	if !found {
+		t.Error("the other node is not reported as a peer")
+	}
+	// The bootnode itself has no QUIC port and is not a libp2p peer
+	if len(a.Peers()) != 1 {
+		t.Errorf("node reports %d peers, want 1", len(a.Peers()))
+	}
+}
+
+func TestMessageEncoding(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
+	record, err := enr.New(key, 7, enr.IP(net.IPv4(10, 0, 0, 1)), enr.Port(enr.KeyUDP, 9100))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, m := range []message{
+		&ping{reqID: []byte{1}, enrSeq: 3},
+		&pong{reqID: []byte{2}, enrSeq: 3, ip: net.IPv4(10, 0, 0, 1).To4(), port: 9100},
+		&findnode{reqID: []byte{3}, distances: []uint64{256, 255}},
+		&nodes{reqID: []byte{4}, total: 1, records: []*enr.Record{record}},
+		&talkreq{reqID: []byte{5}, protocol: []byte("x"), request: []byte("y")},
+		&talkresp{reqID: []byte{6}},
+	} {
+		got, err := decodeMessage(encodeMessage(m))
+		if err != nil {
+			t.Fatalf("decode %T: %v", m, err)
+		}
+		if !bytes.Equal(encodeMessage(got), encodeMessage(m)) {
+			t.Errorf("%T does not round trip", m)
+		}
+	}
+
+	if _, err := decodeMessage([]byte{msgPing, 0xc1, 0x01}); err == nil {
+		t.Error("decoded a PING without an enr-seq")
+	}
+}
+
+func TestPacketHeader(t *testing.T) {
+	var dest enr.ID
+	dest[0] = 0xaa
+	h := newHeader(flagWhoareyou, (&whoareyouAuth{enrSeq: 9}).encode())
+	packet := encodePacket(dest, h, make([]byte, 16))
+
+	got, body, err := decodePacket(dest, packet)
+	if err != nil {
+		t.Fatalf("decodePacket failed: %v", err)
+	}
+	if got.flag != flagWhoareyou || got.nonce != h.nonce || !bytes.Equal(got.authData, h.authData) || len(body) != 16 {
+		t.Error("packet header does not round trip")
+	}
+
+	var other enr.ID
+	if _, _, err := decodePacket(other, packet); err == nil {
+		t.Error("decoded a packet addressed to another node")
+	}
+}
+
+func TestSessionCacheBounded(t *testing.T) {
+	var cache sessions
+	addr := netip.MustParseAddrPort("127.0.0.1:9000")
+	nodeID := func(i int) enr.ID {
+		var id enr.ID
+		id[0], id[1] = byte(i>>8), byte(i)
+		return id
+	}
+
+	for i := 0; i < maxSessions; i++ {
+		cache.put(nodeID(i), addr, &session{})
+	}
+	// Using the first session makes the second the least recently used
+	if cache.get(nodeID(0), addr) == nil {
+		t.Fatal("session 0 missing")
+	}
+	cache.put(nodeID(maxSessions), addr, &session{})
+
+	if len(cache.m) != maxSessions || cache.order.Len() != maxSessions {
+		t.Errorf("cache holds %d sessions, want %d", len(cache.m), maxSessions)
+	}
+	if cache.get(nodeID(1), addr) != nil {
+		t.Error("least recently used session not evicted")
+	}
+	if cache.get(nodeID(0), addr) == nil || cache.get(nodeID(maxSessions), addr) == nil {
+		t.Error("recently used session evicted")
+	}
+}
@@ -0,0 +1,250 @@
+package discover
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/devylongs/gean/p2p/enr"
+	"github.com/devylongs/gean/p2p/rlp"
+)
+
+// Message types
+const (
+	msgPing     byte = 0x01
+	msgPong     byte = 0x02
+	msgFindnode byte = 0x03
+	msgNodes    byte = 0x04
+	msgTalkreq  byte = 0x05
+	msgTalkresp byte = 0x06
+)
+
+// maxRequestIDSize is the maximum size of a request ID.
+const maxRequestIDSize = 8
+
+var errInvalidMessage = errors.New("invalid message")
+
+// message is a decoded discovery message.
+type message interface {
+	kind() byte
+	requestID() []byte
+	encode() []byte // RLP list, without the type byte
+}
+
+type ping struct {
+	reqID  []byte
+	enrSeq uint64
+}
+
+type pong struct {
+	reqID  []byte
+	enrSeq uint64
+	ip     net.IP
+	port   uint64
+}
+
+type findnode struct {
+	reqID     []byte
+	distances []uint64
+}
+
+type nodes struct {
+	reqID   []byte
+	total   uint64
+	records []*enr.Record
+}
+
+type talkreq struct {
+	reqID    []byte
+	protocol []byte
+	request  []byte
+}
+
+type talkresp struct {
+	reqID    []byte
+	response []byte
+}
+
+func (m *ping) kind() byte     { return msgPing }
+func (m *pong) kind() byte     { return msgPong }
+func (m *findnode) kind() byte { return msgFindnode }
+func (m *nodes) kind() byte    { return msgNodes }
+func (m *talkreq) kind() byte  { return msgTalkreq }
+func (m *talkresp) kind() byte { return msgTalkresp }
+
+func (m *ping) requestID() []byte     { return m.reqID }
+func (m *pong) requestID() []byte     { return m.reqID }
+func (m *findnode) requestID() []byte { return m.reqID }
+func (m *nodes) requestID() []byte    { return m.reqID }
+func (m *talkreq) requestID() []byte  { return m.reqID }
+func (m *talkresp) requestID() []byte { return m.reqID }
+
+func (m *ping) encode() []byte {
+	return rlp.EncodeList(rlp.EncodeBytes(m.reqID), rlp.EncodeUint(m.enrSeq))
+}
+
+func (m *pong) encode() []byte {
+	ip := m.ip.To4()
+	if ip == nil {
+		ip = m.ip.To16()
+	}
+	return rlp.EncodeList(rlp.EncodeBytes(m.reqID), rlp.EncodeUint(m.enrSeq), rlp.EncodeBytes(ip), rlp.EncodeUint(m.port))
+}
+
+func (m *findnode) encode() []byte {
+	distances := make([][]byte, len(m.distances))
+	for i, d := range m.distances {
+		distances[i] = rlp.EncodeUint(d)
+	}
+	return rlp.EncodeList(rlp.EncodeBytes(m.reqID), rlp.EncodeList(distances...))
+}
+
+func (m *nodes) encode() []byte {
+	records := make([][]byte, len(m.records))
+	for i, r := range m.records {
+		records[i] = r.Encode()
+	}
+	return rlp.EncodeList(rlp.EncodeBytes(m.reqID), rlp.EncodeUint(m.total), rlp.EncodeList(records...))
+}
+
+func (m *talkreq) encode() []byte {
+	return rlp.EncodeList(rlp.EncodeBytes(m.reqID), rlp.EncodeBytes(m.protocol), rlp.EncodeBytes(m.request))
+}
+
+func (m *talkresp) encode() []byte {
+	return rlp.EncodeList(rlp.EncodeBytes(m.reqID), rlp.EncodeBytes(m.response))
+}
+
+// encodeMessage returns message-pt, the type byte followed by the message.
+func encodeMessage(m message) []byte {
+	return append([]byte{m.kind()}, m.encode()...)
+}
+
+// decodeMessage decodes message-pt. Records in a NODES message that fail
+// to decode or verify are dropped.
+func decodeMessage(b []byte) (message, error) {
+	if len(b) < 2 {
+		return nil, fmt.Errorf("%w: empty", errInvalidMessage)
+	}
+	content, _, err := rlp.SplitList(b[1:])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	items, err := rlp.Items(content)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidMessage, err)
+	}
+	d := decoder{items: items}
+
+	var m message
+	switch b[0] {
+	case msgPing:
+		m = &ping{reqID: d.reqID(), enrSeq: d.uint()}
+	case msgPong:
+		m = &pong{reqID: d.reqID(), enrSeq: d.uint(), ip: net.IP(d.bytes()), port: d.uint()}
+	case msgFindnode:
+		msg := &findnode{reqID: d.reqID()}
+		for _, item := range d.list() {
+			dist, _, err := rlp.SplitUint(item)
+			if err != nil || dist > 256 {
+				d.fail(errors.New("invalid distance"))
+				break
+			}
+			msg.distances = append(msg.distances, dist)
+		}
+		m = msg
+	case msgNodes:
+		msg := &nodes{reqID: d.reqID(), total: d.uint()}
+		for _, item := range d.list() {
+			if r, err := enr.Decode(item); err == nil {
+				msg.records = append(msg.records, r)
+			}
+		}
+		m = msg
+	case msgTalkreq:
+		m = &talkreq{reqID: d.reqID(), protocol: d.bytes(), request: d.bytes()}
+	case msgTalkresp:
+		m = &talkresp{reqID: d.reqID(), response: d.bytes()}
+	default:
+		return nil, fmt.Errorf("%w: unknown type %#x", errInvalidMessage, b[0])
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("%w: type %#x: %v", errInvalidMessage, b[0], d.err)
+	}
+	return m, nil
+}
+
+// decoder reads the fields of a message in order, keeping the first error.
+// Missing trailing fields are an error; extra fields are ignored.
+type decoder struct {
+	items [][]byte
+	err   error
+}
+
+func (d *decoder) next() []byte {
+	if d.err != nil {
+		return nil
+	}
+	if len(d.items) == 0 {
+		d.fail(errors.New("missing field"))
+		return nil
+	}
+	item := d.items[0]
+	d.items = d.items[1:]
+	return item
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) bytes() []byte {
+	item := d.next()
+	if item == nil {
+		return nil
+	}
+	b, _, err := rlp.SplitBytes(item)
+	if err != nil {
+		d.fail(err)
+	}
+	return b
+}
+
+func (d *decoder) reqID() []byte {
+	id := d.bytes()
+	if len(id) > maxRequestIDSize {
+		d.fail(fmt.Errorf("request id of %d bytes", len(id)))
+	}
+	return id
+}
+
+func (d *decoder) uint() uint64 {
+	item := d.next()
+	if item == nil {
+		return 0
+	}
+	u, _, err := rlp.SplitUint(item)
+	if err != nil {
+		d.fail(err)
+	}
+	return u
+}
+
+func (d *decoder) list() [][]byte {
+	item := d.next()
+	if item == nil {
+		return nil
+	}
+	content, _, err := rlp.SplitList(item)
+	if err != nil {
+		d.fail(err)
+		return nil
+	}
+	items, err := rlp.Items(content)
+	if err != nil {
+		d.fail(err)
+	}
+	return items
+}
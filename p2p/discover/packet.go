@@ -0,0 +1,204 @@
+package discover
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/devylongs/gean/p2p/enr"
+)
+
+// Packet layout (discv5.1 wire protocol):
+//
+//	packet        = masking-iv || masked-header || message
+//	masked-header = aes-ctr(dest-id[:16], masking-iv, header)
+//	header        = static-header || authdata
+//	static-header = "discv5" || version || flag || nonce || authdata-size
+//	message       = aes-gcm(session-key, nonce, message-pt, masking-iv || header)
+const (
+	protocolID       = "discv5"
+	protocolVersion  = 1
+	ivSize           = 16
+	nonceSize        = 12
+	staticHeaderSize = 6 + 2 + 1 + nonceSize + 2
+	idNonceSize      = 16
+	minPacketSize    = 63
+	maxPacketSize    = 1280
+)
+
+// Packet flags
+const (
+	flagMessage   byte = 0
+	flagWhoareyou byte = 1
+	flagHandshake byte = 2
+)
+
+// Authdata sizes
+const (
+	messageAuthSize       = 32
+	whoareyouAuthSize     = idNonceSize + 8
+	handshakeAuthHeadSize = 32 + 1 + 1
+)
+
+var errInvalidHeader = errors.New("invalid packet header")
+
+// nonce is the AES-GCM nonce of a packet, also used to match a WHOAREYOU
+// to the packet that triggered it.
+type nonce [nonceSize]byte
+
+// header is the unmasked header of a packet.
+type header struct {
+	iv       [ivSize]byte
+	flag     byte
+	nonce    nonce
+	authData []byte
+}
+
+// newHeader returns a header with a random masking IV and nonce.
+func newHeader(flag byte, authData []byte) *header {
+	h := &header{flag: flag, authData: authData}
+	rand.Read(h.iv[:])
+	rand.Read(h.nonce[:])
+	return h
+}
+
+// encode returns the unmasked header, static part and authdata.
+func (h *header) encode() []byte {
+	out := make([]byte, 0, staticHeaderSize+len(h.authData))
+	out = append(out, protocolID...)
+	out = binary.BigEndian.AppendUint16(out, protocolVersion)
+	out = append(out, h.flag)
+	out = append(out, h.nonce[:]...)
+	out = binary.BigEndian.AppendUint16(out, uint16(len(h.authData)))
+	return append(out, h.authData...)
+}
+
+// additionalData returns masking-iv || header, the additional data of the
+// message encryption and, for WHOAREYOU packets, the challenge data.
+func (h *header) additionalData() []byte {
+	return append(h.iv[:], h.encode()...)
+}
+
+// encodePacket masks the header for dest and appends the encrypted message.
+func encodePacket(dest enr.ID, h *header, message []byte) []byte {
+	head := h.encode()
+	out := make([]byte, ivSize+len(head), ivSize+len(head)+len(message))
+	copy(out, h.iv[:])
+	maskStream(dest, h.iv).XORKeyStream(out[ivSize:], head)
+	return append(out, message...)
+}
+
+// decodePacket unmasks the header of a packet addressed to local. It returns
+// the header and the encrypted message.
+func decodePacket(local enr.ID, packet []byte) (*header, []byte, error) {
+	if len(packet) < minPacketSize || len(packet) > maxPacketSize {
+		return nil, nil, fmt.Errorf("%w: packet size %d", errInvalidHeader, len(packet))
+	}
+	h := new(header)
+	copy(h.iv[:], packet)
+	stream := maskStream(local, h.iv)
+
+	static := make([]byte, staticHeaderSize)
+	stream.XORKeyStream(static, packet[ivSize:ivSize+staticHeaderSize])
+	if string(static[:6]) != protocolID || binary.BigEndian.Uint16(static[6:]) != protocolVersion {
+		return nil, nil, fmt.Errorf("%w: not a discv5 packet", errInvalidHeader)
+	}
+	h.flag = static[8]
+	copy(h.nonce[:], static[9:])
+
+	authSize := int(binary.BigEndian.Uint16(static[21:]))
+	end := ivSize + staticHeaderSize + authSize
+	if end > len(packet) {
+		return nil, nil, fmt.Errorf("%w: authdata exceeds packet", errInvalidHeader)
+	}
+	h.authData = make([]byte, authSize)
+	stream.XORKeyStream(h.authData, packet[ivSize+staticHeaderSize:end])
+	return h, packet[end:], nil
+}
+
+func maskStream(dest enr.ID, iv [ivSize]byte) cipher.Stream {
+	block, _ := aes.NewCipher(dest[:16]) // a 16-byte key never fails
+	return cipher.NewCTR(block, iv[:])
+}
+
+// encryptMessage encrypts message-pt with a 16-byte session key.
+func encryptMessage(key []byte, h *header, plaintext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, h.nonce[:], plaintext, h.additionalData()), nil
+}
+
+// decryptMessage decrypts the message of a packet.
+func decryptMessage(key []byte, h *header, ciphertext []byte) ([]byte, error) {
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, h.nonce[:], ciphertext, h.additionalData())
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// whoareyouAuth is the authdata of a WHOAREYOU packet.
+type whoareyouAuth struct {
+	idNonce [idNonceSize]byte
+	enrSeq  uint64
+}
+
+func (a *whoareyouAuth) encode() []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(nil), a.idNonce[:]...), a.enrSeq)
+}
+
+func decodeWhoareyouAuth(b []byte) (*whoareyouAuth, error) {
+	if len(b) != whoareyouAuthSize {
+		return nil, fmt.Errorf("%w: WHOAREYOU authdata size %d", errInvalidHeader, len(b))
+	}
+	a := new(whoareyouAuth)
+	copy(a.idNonce[:], b)
+	a.enrSeq = binary.BigEndian.Uint64(b[idNonceSize:])
+	return a, nil
+}
+
+// handshakeAuth is the authdata of a handshake packet.
+type handshakeAuth struct {
+	srcID  enr.ID
+	sig    []byte
+	ephKey []byte // compressed ephemeral public key
+	record []byte // RLP-encoded record, empty when the recipient's copy is current
+}
+
+func (a *handshakeAuth) encode() []byte {
+	out := append([]byte(nil), a.srcID[:]...)
+	out = append(out, byte(len(a.sig)), byte(len(a.ephKey)))
+	out = append(out, a.sig...)
+	out = append(out, a.ephKey...)
+	return append(out, a.record...)
+}
+
+func decodeHandshakeAuth(b []byte) (*handshakeAuth, error) {
+	if len(b) < handshakeAuthHeadSize {
+		return nil, fmt.Errorf("%w: handshake authdata too short", errInvalidHeader)
+	}
+	a := new(handshakeAuth)
+	copy(a.srcID[:], b)
+	sigSize, keySize := int(b[32]), int(b[33])
+	b = b[handshakeAuthHeadSize:]
+	if len(b) < sigSize+keySize {
+		return nil, fmt.Errorf("%w: handshake authdata too short", errInvalidHeader)
+	}
+	a.sig = b[:sigSize]
+	a.ephKey = b[sigSize : sigSize+keySize]
+	a.record = b[sigSize+keySize:]
+	return a, nil
+}
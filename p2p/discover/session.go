@@ -0,0 +1,121 @@
+package discover
+
+import (
+	"container/list"
+	"crypto/hkdf"
+	"crypto/sha256"
+	"net/netip"
+	"sync"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/devylongs/gean/p2p/enr"
+)
+
+const (
+	keyAgreementInfo = "discovery v5 key agreement"
+	idProofPrefix    = "discovery v5 identity proof"
+	sessionKeySize   = 16
+)
+
+// session holds the keys agreed with a node in a handshake.
+type session struct {
+	writeKey []byte
+	readKey  []byte
+}
+
+// sessionKey identifies a session: sessions are bound to the node's address
+// as well as its ID.
+type sessionKey struct {
+	id   enr.ID
+	addr netip.AddrPort
+}
+
+// maxSessions bounds the session cache. Every node that completes a
+// handshake adds a session, so the least recently used one is evicted once
+// the cache is full.
+const maxSessions = 1024
+
+// sessions is the session cache, kept in least recently used order.
+type sessions struct {
+	mu    sync.Mutex
+	m     map[sessionKey]*list.Element
+	order list.List // of *sessionEntry, most recently used first
+}
+
+type sessionEntry struct {
+	key  sessionKey
+	sess *session
+}
+
+func (s *sessions) get(id enr.ID, addr netip.AddrPort) *session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, exists := s.m[sessionKey{id, addr}]
+	if !exists {
+		return nil
+	}
+	s.order.MoveToFront(elem)
+	return elem.Value.(*sessionEntry).sess
+}
+
+func (s *sessions) put(id enr.ID, addr netip.AddrPort, sess *session) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.m == nil {
+		s.m = make(map[sessionKey]*list.Element)
+	}
+	key := sessionKey{id, addr}
+	if elem, exists := s.m[key]; exists {
+		elem.Value.(*sessionEntry).sess = sess
+		s.order.MoveToFront(elem)
+		return
+	}
+	if s.order.Len() >= maxSessions {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.m, oldest.Value.(*sessionEntry).key)
+	}
+	s.m[key] = s.order.PushFront(&sessionEntry{key, sess})
+}
+
+func (s *sessions) delete(id enr.ID, addr netip.AddrPort) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := sessionKey{id, addr}
+	if elem, exists := s.m[key]; exists {
+		s.order.Remove(elem)
+		delete(s.m, key)
+	}
+}
+
+// deriveKeys returns the initiator and recipient keys of a handshake. key and
+// pubkey are one side's static or ephemeral key and the other side's key;
+// challenge is the challenge data of the WHOAREYOU packet.
+func deriveKeys(key *secp256k1.PrivateKey, pubkey *secp256k1.PublicKey, initiator, recipient enr.ID, challenge []byte) (initiatorKey, recipientKey []byte) {
+	info := keyAgreementInfo + string(initiator[:]) + string(recipient[:])
+	keys, err := hkdf.Key(sha256.New, ecdh(key, pubkey), challenge, info, 2*sessionKeySize)
+	if err != nil {
+		panic(err) // only fails for oversized keys
+	}
+	return keys[:sessionKeySize], keys[sessionKeySize:]
+}
+
+// ecdh returns the compressed shared point of key and pubkey.
+func ecdh(key *secp256k1.PrivateKey, pubkey *secp256k1.PublicKey) []byte {
+	var point, shared secp256k1.JacobianPoint
+	pubkey.AsJacobian(&point)
+	secp256k1.ScalarMultNonConst(&key.Key, &point, &shared)
+	shared.ToAffine()
+	return secp256k1.NewPublicKey(&shared.X, &shared.Y).SerializeCompressed()
+}
+
+// idProofHash returns the hash signed by a handshake initiator to prove
+// ownership of its node key.
+func idProofHash(challenge, ephKey []byte, recipient enr.ID) []byte {
+	h := sha256.New()
+	h.Write([]byte(idProofPrefix))
+	h.Write(challenge)
+	h.Write(ephKey)
+	h.Write(recipient[:])
+	return h.Sum(nil)
+}
@@ -0,0 +1,180 @@
+package discover
+
+import (
+	"sync"
+
+	"github.com/devylongs/gean/p2p/enr"
+)
+
+const (
+	bucketSize = 16 // nodes per bucket
+	maxFails   = 3  // failed requests before a node is dropped
+)
+
+// tableEntry is a node in the table.
+type tableEntry struct {
+	record *enr.Record
+	fails  int
+}
+
+// table holds known nodes in buckets by log distance from the local node.
+type table struct {
+	self enr.ID
+
+	mu      sync.Mutex
+	buckets [257][]*tableEntry // index is the log distance; 0 is unused
+}
+
+func newTable(self enr.ID) *table {
+	return &table{self: self}
+}
+
+// add inserts a node or updates it to a newer record. When the node's bucket
+// is full, the entry with the most failed requests is replaced; if no entry
+// has failed, the node is dropped.
+func (t *table) add(r *enr.Record) {
+	id := r.NodeID()
+	if id == t.self || r.UDPAddr() == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	dist := enr.LogDistance(t.self, id)
+	bucket := t.buckets[dist]
+	for _, e := range bucket {
+		if e.record.NodeID() == id {
+			if r.Seq() > e.record.Seq() {
+				e.record = r
+			}
+			return
+		}
+	}
+	if len(bucket) < bucketSize {
+		t.buckets[dist] = append(bucket, &tableEntry{record: r})
+		return
+	}
+	worst := 0
+	for i, e := range bucket {
+		if e.fails > bucket[worst].fails {
+			worst = i
+		}
+	}
+	if bucket[worst].fails > 0 {
+		bucket[worst] = &tableEntry{record: r}
+	}
+}
+
+// get returns the record of a node in the table.
+func (t *table) get(id enr.ID) *enr.Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.find(id); e != nil {
+		return e.record
+	}
+	return nil
+}
+
+// succeeded resets a node's failure count after it answered a request.
+func (t *table) succeeded(id enr.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if e := t.find(id); e != nil {
+		e.fails = 0
+	}
+}
+
+// failed records a failed request, dropping the node after maxFails.
+func (t *table) failed(id enr.ID) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	e := t.find(id)
+	if e == nil {
+		return
+	}
+	if e.fails++; e.fails < maxFails {
+		return
+	}
+	dist := enr.LogDistance(t.self, id)
+	bucket := t.buckets[dist]
+	for i := range bucket {
+		if bucket[i] == e {
+			t.buckets[dist] = append(bucket[:i:i], bucket[i+1:]...)
+			return
+		}
+	}
+}
+
+// find returns the entry of a node. The caller holds mu.
+func (t *table) find(id enr.ID) *tableEntry {
+	for _, e := range t.buckets[enr.LogDistance(t.self, id)] {
+		if e.record.NodeID() == id {
+			return e
+		}
+	}
+	return nil
+}
+
+// atDistances returns up to limit records at the given log distances.
+func (t *table) atDistances(distances []uint64, limit int) []*enr.Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []*enr.Record
+	for _, d := range distances {
+		if d == 0 || d > 256 {
+			continue
+		}
+		for _, e := range t.buckets[d] {
+			if len(out) == limit {
+				return out
+			}
+			out = append(out, e.record)
+		}
+	}
+	return out
+}
+
+// closest returns up to n records closest to target.
+func (t *table) closest(target enr.ID, n int) []*enr.Record {
+	all := t.records()
+	sortByDistance(target, all)
+	if len(all) > n {
+		all = all[:n]
+	}
+	return all
+}
+
+// records returns every record in the table.
+func (t *table) records() []*enr.Record {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var out []*enr.Record
+	for _, bucket := range t.buckets {
+		for _, e := range bucket {
+			out = append(out, e.record)
+		}
+	}
+	return out
+}
+
+// len returns the number of nodes in the table.
+func (t *table) len() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	n := 0
+	for _, bucket := range t.buckets {
+		n += len(bucket)
+	}
+	return n
+}
+
+// closer reports whether a is closer to target than b by XOR distance.
+func closer(target, a, b enr.ID) bool {
+	for i := range target {
+		da, db := a[i]^target[i], b[i]^target[i]
+		if da != db {
+			return da < db
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+package discover
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/devylongs/gean/p2p/enr"
+)
+
+// Test vectors from the discv5 wire specification,
+// https://github.com/ethereum/devp2p/blob/master/discv5/discv5-wire-test-vectors.md
+
+const (
+	vectorNodeAKey = "eef77acb6c6a6eebc5b363a475ac583ec7eccdb42b6481424c60f59aa326547f"
+	vectorNodeBKey = "66fb62bfbd66b9177a138c1e5cddbe4f7c30c343e94e68df8769459cb1cde628"
+
+	// Challenge data of a WHOAREYOU with request nonce 0x0102...0c,
+	// id-nonce 0x0102...10 and enr-seq 0
+	vectorChallenge = "000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000000"
+)
+
+func unhex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.Join(strings.Fields(s), ""))
+	if err != nil {
+		t.Fatalf("bad hex %q: %v", s, err)
+	}
+	return b
+}
+
+func vectorKey(t *testing.T, s string) *secp256k1.PrivateKey {
+	t.Helper()
+	return secp256k1.PrivKeyFromBytes(unhex(t, s))
+}
+
+func vectorPubkey(t *testing.T, b []byte) *secp256k1.PublicKey {
+	t.Helper()
+	pub, err := secp256k1.ParsePubKey(b)
+	if err != nil {
+		t.Fatalf("ParsePubKey failed: %v", err)
+	}
+	return pub
+}
+
+func vectorNodeIDs(t *testing.T) (a, b enr.ID) {
+	t.Helper()
+	a = enr.PubkeyID(vectorKey(t, vectorNodeAKey).PubKey())
+	b = enr.PubkeyID(vectorKey(t, vectorNodeBKey).PubKey())
+	if a.String() != "aaaa8419e9f49d0083561b48287df592939a8d19947d8c0ef88f2a4856a69fbb" {
+		t.Fatalf("node A ID = %s", a)
+	}
+	if b.String() != "bbbb9d047f0488c0b5a93c1c3f2d8bafc7c8ff337024a55434a0d0555de64db9" {
+		t.Fatalf("node B ID = %s", b)
+	}
+	return a, b
+}
+
+func TestVectorECDH(t *testing.T) {
+	key := vectorKey(t, "fb757dc581730490a1d7a00deea65e9b1936924caaea8f44d476014856b68736")
+	pub := vectorPubkey(t, unhex(t, "039961e4c2356d61bedb83052c115d311acb3a96f5777296dcf297351130266231"))
+	want := unhex(t, "033b11a2a1f214567e1537ce5e509ffd9b21373247f2a3ff6841f4976f53165e7e")
+	if got := ecdh(key, pub); !bytes.Equal(got, want) {
+		t.Errorf("shared secret = %x, want %x", got, want)
+	}
+}
+
+func TestVectorKeyDerivation(t *testing.T) {
+	a, b := vectorNodeIDs(t)
+	ephKey := vectorKey(t, "fb757dc581730490a1d7a00deea65e9b1936924caaea8f44d476014856b68736")
+	destPub := vectorPubkey(t, unhex(t, "0317931e6e0840220642f230037d285d122bc59063221ef3226b1f403ddc69ca91"))
+
+	initiatorKey, recipientKey := deriveKeys(ephKey, destPub, a, b, unhex(t, vectorChallenge))
+	if want := unhex(t, "dccc82d81bd610f4f76d3ebe97a40571"); !bytes.Equal(initiatorKey, want) {
+		t.Errorf("initiator key = %x, want %x", initiatorKey, want)
+	}
+	if want := unhex(t, "ac74bb8773749920b0d3a8881c173ec5"); !bytes.Equal(recipientKey, want) {
+		t.Errorf("recipient key = %x, want %x", recipientKey, want)
+	}
+}
+
+func TestVectorIDNonceSigning(t *testing.T) {
+	_, b := vectorNodeIDs(t)
+	staticKey := vectorKey(t, "fb757dc581730490a1d7a00deea65e9b1936924caaea8f44d476014856b68736")
+	ephPub := unhex(t, "039961e4c2356d61bedb83052c115d311acb3a96f5777296dcf297351130266231")
+	want := unhex(t, "94852a1e2318c4e5e9d422c98eaf19d1d90d876b29cd06ca7cb7546d0fff7b484fe86c09a064fe72bdbef73ba8e9c34df0cd2b53e9d65528c2c7f336d5dfc6e6")
+
+	hash := idProofHash(unhex(t, vectorChallenge), ephPub, b)
+	if got := enr.Sign(staticKey, hash); !bytes.Equal(got, want) {
+		t.Errorf("id signature = %x, want %x", got, want)
+	}
+	if !enr.VerifySignature(staticKey.PubKey(), hash, want) {
+		t.Error("id signature does not verify")
+	}
+}
+
+func TestVectorMessageEncryption(t *testing.T) {
+	aead, err := newGCM(unhex(t, "9f2d77db7004bf8a1a85107ac686990b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce := unhex(t, "27b5af763c446acd2749fe8e")
+	ad := unhex(t, "93a7400fa0d6a694ebc24d5cf570f65d04215b6ac00757875e3f3a5f42107903")
+	want := unhex(t, "a5d12a2d94b8ccb3ba55558229867dc13bfa3648")
+	if got := aead.Seal(nil, nonce, unhex(t, "01c20101"), ad); !bytes.Equal(got, want) {
+		t.Errorf("ciphertext = %x, want %x", got, want)
+	}
+}
+
+func TestVectorPingPacket(t *testing.T) {
+	a, b := vectorNodeIDs(t)
+	packet := unhex(t, `
+		00000000000000000000000000000000088b3d4342774649325f313964a39e55
+		ea96c005ad52be8c7560413a7008f16c9e6d2f43bbea8814a546b7409ce783d3
+		4c4f53245d08dab84102ed931f66d1492acb308fa1c6715b9d139b81acbdcc`)
+	readKey := make([]byte, sessionKeySize)
+	ping := &ping{reqID: []byte{0, 0, 0, 1}, enrSeq: 2}
+
+	h, body, err := decodePacket(b, packet)
+	if err != nil {
+		t.Fatalf("decodePacket failed: %v", err)
+	}
+	if h.flag != flagMessage || !bytes.Equal(h.authData, a[:]) || h.nonce != (nonce{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}) {
+		t.Fatalf("header = flag %d, nonce %x, authdata %x", h.flag, h.nonce, h.authData)
+	}
+	plaintext, err := decryptMessage(readKey, h, body)
+	if err != nil {
+		t.Fatalf("decryptMessage failed: %v", err)
+	}
+	if !bytes.Equal(plaintext, encodeMessage(ping)) {
+		t.Errorf("message = %x, want %x", plaintext, encodeMessage(ping))
+	}
+
+	// Encoding the same header and message gives the same packet
+	sealed, err := encryptMessage(readKey, h, encodeMessage(ping))
+	if err != nil {
+		t.Fatalf("encryptMessage failed: %v", err)
+	}
+	if got := encodePacket(b, h, sealed); !bytes.Equal(got, packet) {
+		t.Errorf("packet = %x, want %x", got, packet)
+	}
+}
+
+func TestVectorWhoareyouPacket(t *testing.T) {
+	_, b := vectorNodeIDs(t)
+	packet := unhex(t, `
+		00000000000000000000000000000000088b3d434277464933a1ccc59f5967ad
+		1d6035f15e528627dde75cd68292f9e6c27d6b66c8100a873fcbaed4e16b8d`)
+
+	h, body, err := decodePacket(b, packet)
+	if err != nil {
+		t.Fatalf("decodePacket failed: %v", err)
+	}
+	if h.flag != flagWhoareyou || len(body) != 0 || h.nonce != (nonce{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}) {
+		t.Fatalf("header = flag %d, nonce %x, %d body bytes", h.flag, h.nonce, len(body))
+	}
+	auth, err := decodeWhoareyouAuth(h.authData)
+	if err != nil {
+		t.Fatalf("decodeWhoareyouAuth failed: %v", err)
+	}
+	if auth.idNonce != [idNonceSize]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16} || auth.enrSeq != 0 {
+		t.Errorf("authdata = id-nonce %x, enr-seq %d", auth.idNonce, auth.enrSeq)
+	}
+	if got := h.additionalData(); !bytes.Equal(got, unhex(t, vectorChallenge)) {
+		t.Errorf("challenge data = %x, want %x", got, unhex(t, vectorChallenge))
+	}
+	if got := encodePacket(b, h, nil); !bytes.Equal(got, packet) {
+		t.Errorf("packet = %x, want %x", got, packet)
+	}
+}
+
+func TestVectorHandshakePacket(t *testing.T) {
+	a, b := vectorNodeIDs(t)
+	packet := unhex(t, `
+		00000000000000000000000000000000088b3d4342774649305f313964a39e55
+		ea96c005ad521d8c7560413a7008f16c9e6d2f43bbea8814a546b7409ce783d3
+		4c4f53245d08da4bb252012b2cba3f4f374a90a75cff91f142fa9be3e0a5f3ef
+		268ccb9065aeecfd67a999e7fdc137e062b2ec4a0eb92947f0d9a74bfbf44dfb
+		a776b21301f8b65efd5796706adff216ab862a9186875f9494150c4ae06fa4d1
+		f0396c93f215fa4ef524f1eadf5f0f4126b79336671cbcf7a885b1f8bd2a5d83
+		9cf8`)
+	// The WHOAREYOU answered here has enr-seq 1
+	challenge := unhex(t, "000000000000000000000000000000006469736376350001010102030405060708090a0b0c00180102030405060708090a0b0c0d0e0f100000000000000001")
+	ephPub := unhex(t, "039a003ba6517b473fa0cd74aefe99dadfdb34627f90fec6362df85803908f53a5")
+	readKey := unhex(t, "4f9fac6de7567d1e3b1241dffe90f662")
+
+	h, body, err := decodePacket(b, packet)
+	if err != nil {
+		t.Fatalf("decodePacket failed: %v", err)
+	}
+	if h.flag != flagHandshake {
+		t.Fatalf("flag = %d, want %d", h.flag, flagHandshake)
+	}
+	auth, err := decodeHandshakeAuth(h.authData)
+	if err != nil {
+		t.Fatalf("decodeHandshakeAuth failed: %v", err)
+	}
+	if auth.srcID != a || !bytes.Equal(auth.ephKey, ephPub) || len(auth.record) != 0 {
+		t.Fatalf("authdata = src %s, eph-key %x, %d record bytes", auth.srcID, auth.ephKey, len(auth.record))
+	}
+
+	// Node B checks the proof and derives the keys as handleHandshake does
+	nodeA := vectorKey(t, vectorNodeAKey).PubKey()
+	if !enr.VerifySignature(nodeA, idProofHash(challenge, auth.ephKey, b), auth.sig) {
+		t.Error("id signature does not verify")
+	}
+	initiatorKey, _ := deriveKeys(vectorKey(t, vectorNodeBKey), vectorPubkey(t, auth.ephKey), a, b, challenge)
+	if !bytes.Equal(initiatorKey, readKey) {
+		t.Errorf("initiator key = %x, want %x", initiatorKey, readKey)
+	}
+	plaintext, err := decryptMessage(readKey, h, body)
+	if err != nil {
+		t.Fatalf("decryptMessage failed: %v", err)
+	}
+	if want := encodeMessage(&ping{reqID: []byte{0, 0, 0, 1}, enrSeq: 1}); !bytes.Equal(plaintext, want) {
+		t.Errorf("message = %x, want %x", plaintext, want)
+	}
+}
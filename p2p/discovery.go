@@ -0,0 +1,60 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Discovery supplies peers found by node discovery.
+type Discovery interface {
+	Peers() []peer.AddrInfo
+}
+
+// Secp256k1Key converts a libp2p node key to the secp256k1 key type node
+// records are signed with.
+func Secp256k1Key(key crypto.PrivKey) (*secp256k1.PrivateKey, error) {
+	k, ok := key.(*crypto.Secp256k1PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("node key is not a secp256k1 key")
+	}
+	return (*secp256k1.PrivateKey)(k), nil
+}
+
+// LocalEndpoint returns the IP and QUIC port to advertise in the local node
+// record: the first non-loopback IPv4 QUIC address in addrs, such as the
+// host's listen addresses, falling back to loopback.
+func LocalEndpoint(addrs []multiaddr.Multiaddr) (net.IP, int) {
+	var loopback net.IP
+	var loopbackPort int
+	for _, addr := range addrs {
+		if _, err := addr.ValueForProtocol(multiaddr.P_QUIC_V1); err != nil {
+			continue
+		}
+		ipStr, err := addr.ValueForProtocol(multiaddr.P_IP4)
+		if err != nil {
+			continue
+		}
+		portStr, err := addr.ValueForProtocol(multiaddr.P_UDP)
+		if err != nil {
+			continue
+		}
+		ip := net.ParseIP(ipStr)
+		port, _ := strconv.Atoi(portStr)
+		if !ip.IsLoopback() {
+			return ip, port
+		}
+		if loopback == nil {
+			loopback, loopbackPort = ip, port
+		}
+	}
+	if loopback == nil {
+		return net.IPv4(127, 0, 0, 1), 0
+	}
+	return loopback, loopbackPort
+}
@@ -0,0 +1,352 @@
+// Package enr implements Ethereum Node Records (EIP-778) with the "v4"
+// identity scheme: records signed by a secp256k1 key, as used by discv5 and
+// in lean-quickstart bootnode lists.
+package enr
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	"github.com/devylongs/gean/p2p/rlp"
+	"github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+	"golang.org/x/crypto/sha3"
+)
+
+// MaxSize is the maximum size of an encoded record.
+const MaxSize = 300
+
+// Standard keys
+const (
+	KeyID        = "id"
+	KeySecp256k1 = "secp256k1"
+	KeyIP        = "ip"
+	KeyIP6       = "ip6"
+	KeyUDP       = "udp"
+	KeyUDP6      = "udp6"
+	KeyTCP       = "tcp"
+	KeyQUIC      = "quic"
+	KeyQUIC6     = "quic6"
+)
+
+// Errors
+var (
+	ErrTooBig           = errors.New("enr: record exceeds 300 bytes")
+	ErrInvalidSignature = errors.New("enr: invalid signature")
+	ErrUnsupportedID    = errors.New("enr: unsupported identity scheme")
+	ErrNoQUIC           = errors.New("enr: record has no QUIC address")
+)
+
+// ID is a node ID: the keccak256 hash of the uncompressed public key.
+type ID [32]byte
+
+// String returns the ID in hex.
+func (id ID) String() string { return hex.EncodeToString(id[:]) }
+
+// LogDistance returns the bit length of a XOR b, the distance metric of
+// the discovery table. It is zero for equal IDs.
+func LogDistance(a, b ID) int {
+	for i := range a {
+		if x := a[i] ^ b[i]; x != 0 {
+			return (len(a)-i)*8 - bits.LeadingZeros8(x)
+		}
+	}
+	return 0
+}
+
+// Entry is a key and its RLP-encoded value.
+type Entry struct {
+	Key   string
+	Value []byte
+}
+
+// IP returns the ip or ip6 entry for an address.
+func IP(ip net.IP) Entry {
+	if ip4 := ip.To4(); ip4 != nil {
+		return Entry{KeyIP, rlp.EncodeBytes(ip4)}
+	}
+	return Entry{KeyIP6, rlp.EncodeBytes(ip.To16())}
+}
+
+// Port returns a port entry such as KeyUDP or KeyQUIC.
+func Port(key string, port int) Entry {
+	return Entry{key, rlp.EncodeUint(uint64(port))}
+}
+
+// Record is a signed node record.
+type Record struct {
+	seq       uint64
+	entries   []Entry // sorted by key, including id and secp256k1
+	signature []byte
+	pubkey    *secp256k1.PublicKey
+	id        ID
+	raw       []byte
+}
+
+// New creates a record with sequence number seq, signed by key.
+func New(key *secp256k1.PrivateKey, seq uint64, entries ...Entry) (*Record, error) {
+	entries = append([]Entry{
+		{KeyID, rlp.EncodeBytes([]byte("v4"))},
+		{KeySecp256k1, rlp.EncodeBytes(key.PubKey().SerializeCompressed())},
+	}, entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Key == entries[i-1].Key {
+			return nil, fmt.Errorf("enr: duplicate key %q", entries[i].Key)
+		}
+	}
+
+	r := &Record{seq: seq, entries: entries, pubkey: key.PubKey(), id: PubkeyID(key.PubKey())}
+	sig := ecdsa.SignCompact(key, keccak256(r.content()), false)
+	r.signature = sig[1:] // drop the recovery code
+	r.raw = rlp.EncodeList(append([][]byte{rlp.EncodeBytes(r.signature)}, r.items()...)...)
+	if len(r.raw) > MaxSize {
+		return nil, ErrTooBig
+	}
+	return r, nil
+}
+
+// Parse decodes a record in its text form, "enr:" followed by the
+// URL-safe base64 of the RLP encoding.
+func Parse(s string) (*Record, error) {
+	s, ok := strings.CutPrefix(s, "enr:")
+	if !ok {
+		return nil, fmt.Errorf("enr: missing enr: prefix")
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("enr: %w", err)
+	}
+	return Decode(raw)
+}
+
+// Decode decodes an RLP-encoded record and verifies its signature.
+func Decode(raw []byte) (*Record, error) {
+	if len(raw) > MaxSize {
+		return nil, ErrTooBig
+	}
+	content, rest, err := rlp.SplitList(raw)
+	if err != nil {
+		return nil, fmt.Errorf("enr: %w", err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("enr: trailing data after record")
+	}
+
+	r := &Record{raw: bytes.Clone(raw)}
+	if r.signature, content, err = rlp.SplitBytes(content); err != nil {
+		return nil, fmt.Errorf("enr: signature: %w", err)
+	}
+	if r.seq, content, err = rlp.SplitUint(content); err != nil {
+		return nil, fmt.Errorf("enr: seq: %w", err)
+	}
+	for len(content) > 0 {
+		var key []byte
+		if key, content, err = rlp.SplitBytes(content); err != nil {
+			return nil, fmt.Errorf("enr: key: %w", err)
+		}
+		_, _, next, err := rlp.Split(content)
+		if err != nil {
+			return nil, fmt.Errorf("enr: value of %q: %w", key, err)
+		}
+		if n := len(r.entries); n > 0 && r.entries[n-1].Key >= string(key) {
+			return nil, fmt.Errorf("enr: keys not sorted and unique at %q", key)
+		}
+		r.entries = append(r.entries, Entry{string(key), content[:len(content)-len(next)]})
+		content = next
+	}
+
+	if err := r.verify(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// verify checks the v4 identity scheme signature.
+func (r *Record) verify() error {
+	var id []byte
+	if err := r.Load(KeyID, &id); err != nil || string(id) != "v4" {
+		return ErrUnsupportedID
+	}
+	var compressed []byte
+	if err := r.Load(KeySecp256k1, &compressed); err != nil {
+		return fmt.Errorf("enr: %w", err)
+	}
+	pubkey, err := secp256k1.ParsePubKey(compressed)
+	if err != nil {
+		return fmt.Errorf("enr: public key: %w", err)
+	}
+	if !VerifySignature(pubkey, keccak256(r.content()), r.signature) {
+		return ErrInvalidSignature
+	}
+	r.pubkey = pubkey
+	r.id = PubkeyID(pubkey)
+	return nil
+}
+
+// items returns the encoded seq and key/value pairs.
+func (r *Record) items() [][]byte {
+	items := [][]byte{rlp.EncodeUint(r.seq)}
+	for _, e := range r.entries {
+		items = append(items, rlp.EncodeBytes([]byte(e.Key)), e.Value)
+	}
+	return items
+}
+
+// content returns the signed part of the record.
+func (r *Record) content() []byte {
+	return rlp.EncodeList(r.items()...)
+}
+
+// Load decodes the value of key into a *[]byte or *uint64.
+func (r *Record) Load(key string, v any) error {
+	i := sort.Search(len(r.entries), func(i int) bool { return r.entries[i].Key >= key })
+	if i == len(r.entries) || r.entries[i].Key != key {
+		return fmt.Errorf("missing %q entry", key)
+	}
+	value := r.entries[i].Value
+
+	var err error
+	switch v := v.(type) {
+	case *[]byte:
+		*v, _, err = rlp.SplitBytes(value)
+	case *uint64:
+		*v, _, err = rlp.SplitUint(value)
+	default:
+		return fmt.Errorf("unsupported type %T", v)
+	}
+	if err != nil {
+		return fmt.Errorf("%q entry: %w", key, err)
+	}
+	return nil
+}
+
+// Seq returns the sequence number.
+func (r *Record) Seq() uint64 { return r.seq }
+
+// PublicKey returns the node's public key.
+func (r *Record) PublicKey() *secp256k1.PublicKey { return r.pubkey }
+
+// NodeID returns the node ID.
+func (r *Record) NodeID() ID { return r.id }
+
+// Encode returns the RLP encoding of the record.
+func (r *Record) Encode() []byte { return r.raw }
+
+// String returns the text form of the record.
+func (r *Record) String() string {
+	return "enr:" + base64.RawURLEncoding.EncodeToString(r.raw)
+}
+
+// IP returns the IPv4 address, or the IPv6 address if there is none.
+func (r *Record) IP() net.IP {
+	var ip []byte
+	if err := r.Load(KeyIP, &ip); err == nil && len(ip) == net.IPv4len {
+		return net.IP(ip)
+	}
+	if err := r.Load(KeyIP6, &ip); err == nil && len(ip) == net.IPv6len {
+		return net.IP(ip)
+	}
+	return nil
+}
+
+// port returns the port under key, or under key6 for an IPv6 record.
+func (r *Record) port(key, key6 string) int {
+	ip := r.IP()
+	if ip != nil && ip.To4() == nil {
+		key = key6
+	}
+	var port uint64
+	if err := r.Load(key, &port); err != nil || port > 65535 {
+		return 0
+	}
+	return int(port)
+}
+
+// UDP returns the discovery port, or 0 if there is none.
+func (r *Record) UDP() int { return r.port(KeyUDP, KeyUDP6) }
+
+// QUIC returns the libp2p QUIC port, or 0 if there is none.
+func (r *Record) QUIC() int { return r.port(KeyQUIC, KeyQUIC6) }
+
+// UDPAddr returns the discovery address, or nil if the record has none.
+func (r *Record) UDPAddr() *net.UDPAddr {
+	ip, port := r.IP(), r.UDP()
+	if ip == nil || port == 0 {
+		return nil
+	}
+	return &net.UDPAddr{IP: ip, Port: port}
+}
+
+// PeerID returns the libp2p peer ID of the node's key.
+func (r *Record) PeerID() (peer.ID, error) {
+	pubkey, err := crypto.UnmarshalSecp256k1PublicKey(r.pubkey.SerializeCompressed())
+	if err != nil {
+		return "", err
+	}
+	return peer.IDFromPublicKey(pubkey)
+}
+
+// AddrInfo returns the peer ID and QUIC multiaddr to dial the node with
+// libp2p.
+func (r *Record) AddrInfo() (peer.AddrInfo, error) {
+	ip, port := r.IP(), r.QUIC()
+	if ip == nil || port == 0 {
+		return peer.AddrInfo{}, ErrNoQUIC
+	}
+	id, err := r.PeerID()
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	proto := "ip4"
+	if ip.To4() == nil {
+		proto = "ip6"
+	}
+	addr, err := multiaddr.NewMultiaddr(fmt.Sprintf("/%s/%s/udp/%d/quic-v1", proto, ip, port))
+	if err != nil {
+		return peer.AddrInfo{}, err
+	}
+	return peer.AddrInfo{ID: id, Addrs: []multiaddr.Multiaddr{addr}}, nil
+}
+
+// PubkeyID returns the node ID of a public key.
+func PubkeyID(pubkey *secp256k1.PublicKey) ID {
+	var id ID
+	copy(id[:], keccak256(pubkey.SerializeUncompressed()[1:]))
+	return id
+}
+
+// Sign signs a 32-byte hash, returning the 64-byte r || s signature used
+// by node records and discv5.
+func Sign(key *secp256k1.PrivateKey, hash []byte) []byte {
+	return ecdsa.SignCompact(key, hash, false)[1:]
+}
+
+// VerifySignature checks a 64-byte r || s signature of hash. High-s
+// signatures are rejected.
+func VerifySignature(pubkey *secp256k1.PublicKey, hash, sig []byte) bool {
+	if len(sig) != 64 {
+		return false
+	}
+	var r, s secp256k1.ModNScalar
+	if r.SetByteSlice(sig[:32]) || s.SetByteSlice(sig[32:]) || r.IsZero() || s.IsZero() || s.IsOverHalfOrder() {
+		return false
+	}
+	return ecdsa.NewSignature(&r, &s).Verify(hash, pubkey)
+}
+
+func keccak256(data []byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	h.Write(data)
+	return h.Sum(nil)
+}
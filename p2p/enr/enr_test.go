@@ -0,0 +1,92 @@
+package enr
+
+import (
+	"encoding/hex"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+)
+
+// The example record from EIP-778.
+const (
+	exampleRecord = "enr:-IS4QHCYrYZbAKWCBRlAy5zzaDZXJBGkcnh4MHcBFZntXNFrdvJjX04jRzjzCBOonrkTfj499SZuOh8R33Ls8RRcy5wBgmlkgnY0gmlwhH8AAAGJc2VjcDI1NmsxoQPKY0yuDUmstAHYpMa2_oxVtw0RW_QAdpzBQA8yWM0xOIN1ZHCCdl8"
+	exampleKey    = "b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291"
+	exampleNodeID = "a448f24c6d18e575453db13171562b71999873db5b286df957af199ec94617f7"
+)
+
+func TestParseExampleRecord(t *testing.T) {
+	r, err := Parse(exampleRecord)
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if r.Seq() != 1 {
+		t.Errorf("seq = %d, want 1", r.Seq())
+	}
+	if !r.IP().Equal(net.IPv4(127, 0, 0, 1)) || r.UDP() != 30303 {
+		t.Errorf("address = %s:%d, want 127.0.0.1:30303", r.IP(), r.UDP())
+	}
+	if id := r.NodeID(); hex.EncodeToString(id[:]) != exampleNodeID {
+		t.Errorf("node id = %x, want %s", id, exampleNodeID)
+	}
+	if r.String() != exampleRecord {
+		t.Error("record does not round trip to its text form")
+	}
+	if _, err := r.AddrInfo(); !errors.Is(err, ErrNoQUIC) {
+		t.Errorf("AddrInfo error = %v, want ErrNoQUIC", err)
+	}
+
+	raw := r.Encode()
+	raw[len(raw)-1] ^= 1 // change the udp port
+	if _, err := Decode(raw); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("Decode of a tampered record: err = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestNewRecord(t *testing.T) {
+	b, _ := hex.DecodeString(exampleKey)
+	key := secp256k1.PrivKeyFromBytes(b)
+
+	r, err := New(key, 3, IP(net.IPv4(10, 0, 0, 7)), Port(KeyUDP, 9000), Port(KeyQUIC, 9001))
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	parsed, err := Parse(r.String())
+	if err != nil {
+		t.Fatalf("Parse of a new record failed: %v", err)
+	}
+	if id := parsed.NodeID(); hex.EncodeToString(id[:]) != exampleNodeID {
+		t.Errorf("node id = %x, want %s", id, exampleNodeID)
+	}
+
+	info, err := parsed.AddrInfo()
+	if err != nil {
+		t.Fatalf("AddrInfo failed: %v", err)
+	}
+	if len(info.Addrs) != 1 || info.Addrs[0].String() != "/ip4/10.0.0.7/udp/9001/quic-v1" {
+		t.Errorf("addrs = %v, want the QUIC address", info.Addrs)
+	}
+	if pid, _ := parsed.PeerID(); pid != info.ID || pid == "" {
+		t.Errorf("peer id = %s, want %s", info.ID, pid)
+	}
+
+	if _, err := New(key, 1, Port(KeyUDP, 1), Port(KeyUDP, 2)); err == nil {
+		t.Error("New accepted a duplicate key")
+	}
+}
+
+func TestLogDistance(t *testing.T) {
+	var a, b ID
+	if d := LogDistance(a, b); d != 0 {
+		t.Errorf("distance of equal ids = %d, want 0", d)
+	}
+	b[31] = 1
+	if d := LogDistance(a, b); d != 1 {
+		t.Errorf("distance = %d, want 1", d)
+	}
+	b[0] = 0x80
+	if d := LogDistance(a, b); d != 256 {
+		t.Errorf("distance = %d, want 256", d)
+	}
+}
@@ -21,6 +21,7 @@ type Params struct {
 	MCacheGossip      int     // Gossip windows
 	SeenTTL           int     // Seen message TTL (seconds)
 	ValidationMode    string
+	Scoring           ScoreParams
 }
 
 // Domain types for message ID isolation (per networking spec)
@@ -47,6 +48,7 @@ func DefaultParams() Params {
 		MCacheGossip:      3,
 		SeenTTL:           seenTTL,
 		ValidationMode:    "strict_no_sign",
+		Scoring:           DefaultScoreParams(),
 	}
 }
 
@@ -73,3 +73,25 @@ func TestComputeMessageID_DifferentData(t *testing.T) {
 		t.Error("expected different IDs for different data")
 	}
 }
+
+func TestDefaultScoreParams(t *testing.T) {
+	params := DefaultScoreParams()
+
+	if !params.Enabled {
+		t.Fatal("scoring disabled by default")
+	}
+	if !(params.GraylistThreshold < params.PublishThreshold && params.PublishThreshold < params.GossipThreshold && params.GossipThreshold < 0) {
+		t.Errorf("thresholds out of order: graylist %v, publish %v, gossip %v",
+			params.GraylistThreshold, params.PublishThreshold, params.GossipThreshold)
+	}
+
+	// A single invalid message must outweigh the most a peer can earn
+	for name, topic := range map[string]TopicScoreParams{"block": params.Block, "vote": params.Vote} {
+		maxPositive := topic.TimeInMeshWeight*topic.TimeInMeshCap +
+			topic.FirstMessageDeliveriesWeight*topic.FirstMessageDeliveriesCap
+		if -topic.InvalidMessageDeliveriesWeight <= maxPositive {
+			t.Errorf("%s: invalid message penalty %v does not outweigh max positive score %v",
+				name, topic.InvalidMessageDeliveriesWeight, maxPositive)
+		}
+	}
+}
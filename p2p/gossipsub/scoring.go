@@ -0,0 +1,135 @@
+package gossipsub
+
+import "github.com/devylongs/gean/types"
+
+// TopicScoreParams holds peer scoring parameters for a single topic.
+// Decay fields are the time in seconds for a counter to decay to zero.
+type TopicScoreParams struct {
+	TopicWeight float64
+
+	TimeInMeshWeight  float64
+	TimeInMeshQuantum float64 // Seconds
+	TimeInMeshCap     float64
+
+	FirstMessageDeliveriesWeight float64
+	FirstMessageDeliveriesDecay  float64
+	FirstMessageDeliveriesCap    float64
+
+	MeshMessageDeliveriesWeight     float64
+	MeshMessageDeliveriesDecay      float64
+	MeshMessageDeliveriesThreshold  float64
+	MeshMessageDeliveriesCap        float64
+	MeshMessageDeliveriesActivation float64 // Seconds in mesh before deliveries are counted
+	MeshMessageDeliveriesWindow     float64 // Seconds a late duplicate still counts
+
+	MeshFailurePenaltyWeight float64
+	MeshFailurePenaltyDecay  float64
+
+	InvalidMessageDeliveriesWeight float64
+	InvalidMessageDeliveriesDecay  float64
+}
+
+// ScoreParams holds gossipsub peer scoring parameters and thresholds.
+type ScoreParams struct {
+	Enabled bool
+
+	DecayInterval float64 // Seconds between decay ticks
+	DecayToZero   float64 // Counter value treated as zero
+	RetainScore   float64 // Seconds a disconnected peer's score is kept
+
+	BehaviourPenaltyWeight    float64
+	BehaviourPenaltyThreshold float64
+	BehaviourPenaltyDecay     float64
+
+	IPColocationFactorWeight    float64
+	IPColocationFactorThreshold int
+
+	Block TopicScoreParams
+	Vote  TopicScoreParams
+
+	// Thresholds
+	GossipThreshold             float64 // Below this, no gossip to or from the peer
+	PublishThreshold            float64 // Below this, no flood publishing to the peer
+	GraylistThreshold           float64 // Below this, all messages from the peer are ignored
+	AcceptPXThreshold           float64 // Minimum score to accept peer exchange
+	OpportunisticGraftThreshold float64 // Median mesh score that triggers opportunistic grafting
+}
+
+// DefaultScoreParams returns peer scoring parameters tuned to the 4-second
+// slot: counters decay over a whole number of slots, blocks are expected
+// roughly once per slot and votes from every validator once per slot.
+func DefaultScoreParams() ScoreParams {
+	slot := float64(types.SecondsPerSlot)
+
+	// The invalid message penalty is quadratic in the count, so even a single
+	// invalid message outweighs the most a peer can earn on a topic.
+	const invalidWeight = -140.0
+
+	return ScoreParams{
+		Enabled:       true,
+		DecayInterval: slot,
+		DecayToZero:   0.01,
+		RetainScore:   100 * slot,
+
+		BehaviourPenaltyWeight:    -15.9,
+		BehaviourPenaltyThreshold: 6,
+		BehaviourPenaltyDecay:     10 * slot,
+
+		IPColocationFactorWeight:    -35.0,
+		IPColocationFactorThreshold: 10,
+
+		Block: TopicScoreParams{
+			TopicWeight:       0.5,
+			TimeInMeshWeight:  0.0333,
+			TimeInMeshQuantum: slot,
+			TimeInMeshCap:     300,
+
+			FirstMessageDeliveriesWeight: 1.0,
+			FirstMessageDeliveriesDecay:  20 * slot,
+			FirstMessageDeliveriesCap:    23,
+
+			MeshMessageDeliveriesWeight:     -0.72,
+			MeshMessageDeliveriesDecay:      5 * slot,
+			MeshMessageDeliveriesThreshold:  1,
+			MeshMessageDeliveriesCap:        5,
+			MeshMessageDeliveriesActivation: 8 * slot,
+			MeshMessageDeliveriesWindow:     2,
+
+			MeshFailurePenaltyWeight: -0.72,
+			MeshFailurePenaltyDecay:  5 * slot,
+
+			InvalidMessageDeliveriesWeight: invalidWeight,
+			InvalidMessageDeliveriesDecay:  50 * slot,
+		},
+
+		Vote: TopicScoreParams{
+			TopicWeight:       0.5,
+			TimeInMeshWeight:  0.0333,
+			TimeInMeshQuantum: slot,
+			TimeInMeshCap:     300,
+
+			FirstMessageDeliveriesWeight: 0.5,
+			FirstMessageDeliveriesDecay:  5 * slot,
+			FirstMessageDeliveriesCap:    50,
+
+			MeshMessageDeliveriesWeight:     -0.36,
+			MeshMessageDeliveriesDecay:      5 * slot,
+			MeshMessageDeliveriesThreshold:  2,
+			MeshMessageDeliveriesCap:        20,
+			MeshMessageDeliveriesActivation: 8 * slot,
+			MeshMessageDeliveriesWindow:     2,
+
+			MeshFailurePenaltyWeight: -0.36,
+			MeshFailurePenaltyDecay:  5 * slot,
+
+			InvalidMessageDeliveriesWeight: invalidWeight,
+			InvalidMessageDeliveriesDecay:  50 * slot,
+		},
+
+		GossipThreshold:             -4000,
+		PublishThreshold:            -8000,
+		GraylistThreshold:           -16000,
+		AcceptPXThreshold:           100,
+		OpportunisticGraftThreshold: 5,
+	}
+}
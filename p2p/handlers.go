@@ -5,36 +5,69 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/devylongs/gean/p2p/reqresp"
 	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 // BlockHandler processes incoming blocks from gossipsub.
-type BlockHandler func(ctx context.Context, block *types.SignedBlock) error
+// from is the peer that forwarded the block to us.
+type BlockHandler func(ctx context.Context, from peer.ID, block *types.SignedBlock) error
 
 // VoteHandler processes incoming votes from gossipsub.
 type VoteHandler func(ctx context.Context, vote *types.SignedVote) error
 
+// StatusHandler receives the Status of a newly connected peer once the
+// handshake has found it compatible with our chain.
+type StatusHandler func(from peer.ID, status *reqresp.Status)
+
 // MessageHandlers holds handlers for different message types.
 type MessageHandlers struct {
-	OnBlock BlockHandler
-	OnVote  VoteHandler
-	Logger  *slog.Logger
+	OnBlock  BlockHandler
+	OnVote   VoteHandler
+	OnStatus StatusHandler
+	Logger   *slog.Logger
 }
 
-// HandleBlockMessage decodes and processes an incoming block message.
-func (h *MessageHandlers) HandleBlockMessage(ctx context.Context, data []byte) error {
-	// Decompress
+// DecodeBlockMessage decompresses and decodes a gossiped block.
+func DecodeBlockMessage(data []byte) (*types.SignedBlock, error) {
 	decoded, err := DecompressMessage(data)
 	if err != nil {
-		return fmt.Errorf("decompress block: %w", err)
+		return nil, fmt.Errorf("decompress block: %w", err)
 	}
 
-	// Decode SSZ
 	var block types.SignedBlock
 	if err := block.UnmarshalSSZ(decoded); err != nil {
-		return fmt.Errorf("unmarshal block: %w", err)
+		return nil, fmt.Errorf("unmarshal block: %w", err)
+	}
+	return &block, nil
+}
+
+// DecodeVoteMessage decompresses and decodes a gossiped vote.
+func DecodeVoteMessage(data []byte) (*types.SignedVote, error) {
+	decoded, err := DecompressMessage(data)
+	if err != nil {
+		return nil, fmt.Errorf("decompress vote: %w", err)
+	}
+
+	var vote types.SignedVote
+	if err := vote.UnmarshalSSZ(decoded); err != nil {
+		return nil, fmt.Errorf("unmarshal vote: %w", err)
+	}
+	return &vote, nil
+}
+
+// HandleBlockMessage decodes and processes an incoming block message.
+func (h *MessageHandlers) HandleBlockMessage(ctx context.Context, from peer.ID, data []byte) error {
+	block, err := DecodeBlockMessage(data)
+	if err != nil {
+		return err
 	}
+	return h.HandleBlock(ctx, from, block)
+}
 
+// HandleBlock processes an already decoded block.
+func (h *MessageHandlers) HandleBlock(ctx context.Context, from peer.ID, block *types.SignedBlock) error {
 	if h.Logger != nil {
 		h.Logger.Info("received block",
 			"slot", block.Message.Slot,
@@ -43,7 +76,7 @@ func (h *MessageHandlers) HandleBlockMessage(ctx context.Context, data []byte) e
 	}
 
 	if h.OnBlock != nil {
-		return h.OnBlock(ctx, &block)
+		return h.OnBlock(ctx, from, block)
 	}
 
 	return nil
@@ -51,18 +84,15 @@ func (h *MessageHandlers) HandleBlockMessage(ctx context.Context, data []byte) e
 
 // HandleVoteMessage decodes and processes an incoming vote.
 func (h *MessageHandlers) HandleVoteMessage(ctx context.Context, data []byte) error {
-	// Decompress
-	decoded, err := DecompressMessage(data)
+	vote, err := DecodeVoteMessage(data)
 	if err != nil {
-		return fmt.Errorf("decompress vote: %w", err)
-	}
-
-	// Decode SSZ
-	var vote types.SignedVote
-	if err := vote.UnmarshalSSZ(decoded); err != nil {
-		return fmt.Errorf("unmarshal vote: %w", err)
+		return err
 	}
+	return h.HandleVote(ctx, vote)
+}
 
+// HandleVote processes an already decoded vote.
+func (h *MessageHandlers) HandleVote(ctx context.Context, vote *types.SignedVote) error {
 	if h.Logger != nil {
 		h.Logger.Info("received vote",
 			"slot", vote.Data.Slot,
@@ -71,8 +101,15 @@ func (h *MessageHandlers) HandleVoteMessage(ctx context.Context, data []byte) er
 	}
 
 	if h.OnVote != nil {
-		return h.OnVote(ctx, &vote)
+		return h.OnVote(ctx, vote)
 	}
 
 	return nil
 }
+
+// HandleStatus passes on the Status of a peer that completed the handshake.
+func (h *MessageHandlers) HandleStatus(from peer.ID, status *reqresp.Status) {
+	if h.OnStatus != nil {
+		h.OnStatus(from, status)
+	}
+}
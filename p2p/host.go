@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/rand"
 	"fmt"
+	"strings"
 
+	"github.com/devylongs/gean/p2p/enr"
 	"github.com/libp2p/go-libp2p"
 	"github.com/libp2p/go-libp2p/core/crypto"
 	"github.com/libp2p/go-libp2p/core/host"
@@ -12,6 +14,9 @@ import (
 	"github.com/multiformats/go-multiaddr"
 )
 
+// UserAgent is the agent version announced to peers by libp2p identify.
+const UserAgent = "gean"
+
 // HostConfig holds configuration for creating a libp2p host.
 type HostConfig struct {
 	PrivateKey  crypto.PrivKey
@@ -43,6 +48,7 @@ func NewHost(ctx context.Context, cfg HostConfig) (host.Host, error) {
 	h, err := libp2p.New(
 		libp2p.Identity(privKey),
 		libp2p.ListenAddrStrings(listenAddrs...),
+		libp2p.UserAgent(UserAgent),
 	)
 	if err != nil {
 		return nil, fmt.Errorf("create host: %w", err)
@@ -51,24 +57,33 @@ func NewHost(ctx context.Context, cfg HostConfig) (host.Host, error) {
 	return h, nil
 }
 
-// ParseBootnodes parses a list of multiaddr strings into peer.AddrInfo.
-// Skips ENR records (enr:-...) which require separate decoding.
-func ParseBootnodes(addrs []string) ([]peer.AddrInfo, error) {
+// ParseBootnodes parses a list of bootnodes given as multiaddrs with a
+// /p2p peer ID or as ENRs. It returns the peers to dial and the ENRs, which
+// also seed discovery. An ENR without a QUIC port is only used for discovery.
+func ParseBootnodes(addrs []string) ([]peer.AddrInfo, []*enr.Record, error) {
 	var peers []peer.AddrInfo
+	var records []*enr.Record
 	for _, addr := range addrs {
-		// Skip ENR records for now - they need special decoding
-		if len(addr) > 4 && addr[:4] == "enr:" {
+		if strings.HasPrefix(addr, "enr:") {
+			r, err := enr.Parse(addr)
+			if err != nil {
+				return nil, nil, fmt.Errorf("bootnode %q: %w", addr, err)
+			}
+			records = append(records, r)
+			if pi, err := r.AddrInfo(); err == nil {
+				peers = append(peers, pi)
+			}
 			continue
 		}
 		ma, err := multiaddr.NewMultiaddr(addr)
 		if err != nil {
-			continue // Skip unparseable addresses
+			return nil, nil, fmt.Errorf("bootnode %q: %w", addr, err)
 		}
 		pi, err := peer.AddrInfoFromP2pAddr(ma)
 		if err != nil {
-			continue
+			return nil, nil, fmt.Errorf("bootnode %q: %w", addr, err)
 		}
 		peers = append(peers, *pi)
 	}
-	return peers, nil
+	return peers, records, nil
 }
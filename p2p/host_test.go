@@ -0,0 +1,37 @@
+package p2p
+
+import (
+	"net"
+	"testing"
+
+	"github.com/decred/dcrd/dcrec/secp256k1/v4"
+	"github.com/devylongs/gean/p2p/enr"
+)
+
+func TestParseBootnodes(t *testing.T) {
+	key, _ := secp256k1.GeneratePrivateKey()
+	withQUIC, _ := enr.New(key, 1, enr.IP(net.IPv4(10, 0, 0, 1)), enr.Port(enr.KeyUDP, 9100), enr.Port(enr.KeyQUIC, 9000))
+	discoveryOnly, _ := enr.New(key, 2, enr.IP(net.IPv4(10, 0, 0, 2)), enr.Port(enr.KeyUDP, 9100))
+	id, _ := withQUIC.PeerID()
+
+	peers, records, err := ParseBootnodes([]string{
+		withQUIC.String(),
+		discoveryOnly.String(),
+		"/ip4/10.0.0.3/udp/9000/quic-v1/p2p/" + id.String(),
+	})
+	if err != nil {
+		t.Fatalf("ParseBootnodes failed: %v", err)
+	}
+	if len(records) != 2 {
+		t.Errorf("got %d records, want 2", len(records))
+	}
+	if len(peers) != 2 || peers[0].ID != id || peers[0].Addrs[0].String() != "/ip4/10.0.0.1/udp/9000/quic-v1" {
+		t.Errorf("peers = %v, want the QUIC ENR and the multiaddr", peers)
+	}
+
+	for _, bad := range []string{"enr:-bad", "/ip4/10.0.0.3/udp/9000/quic-v1", "nonsense"} {
+		if _, _, err := ParseBootnodes([]string{bad}); err == nil {
+			t.Errorf("ParseBootnodes(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package p2p
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/libp2p/go-libp2p/core/crypto"
+)
+
+// LoadNodeKey reads the secp256k1 node identity key from a file holding its
+// hex encoding, the format of lean-quickstart node key files. A missing file
+// is created with a new random key; created reports whether that happened.
+func LoadNodeKey(path string) (key crypto.PrivKey, created bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		key, err := createNodeKey(path)
+		return key, err == nil, err
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("read node key: %w", err)
+	}
+
+	raw, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(data)), "0x"))
+	if err != nil || len(raw) != 32 {
+		return nil, false, fmt.Errorf("node key %s: want 32 hex-encoded bytes", path)
+	}
+	key, err = crypto.UnmarshalSecp256k1PrivateKey(raw)
+	if err != nil {
+		return nil, false, fmt.Errorf("node key %s: %w", path, err)
+	}
+	return key, false, nil
+}
+
+func createNodeKey(path string) (crypto.PrivKey, error) {
+	key, _, err := crypto.GenerateKeyPairWithReader(crypto.Secp256k1, 256, rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("generate node key: %w", err)
+	}
+	raw, err := key.Raw()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("create node key dir: %w", err)
+	}
+	// O_EXCL keeps a concurrently started node from replacing the key
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("create node key: %w", err)
+	}
+	if _, err := f.WriteString(hex.EncodeToString(raw) + "\n"); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("write node key: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("write node key: %w", err)
+	}
+	return key, nil
+}
@@ -0,0 +1,34 @@
+package p2p
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadNodeKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "node.key")
+
+	key, created, err := LoadNodeKey(path)
+	if err != nil || !created {
+		t.Fatalf("LoadNodeKey of a missing file: created %v, err %v", created, err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("key file not written with mode 0600: %v", err)
+	}
+
+	again, created, err := LoadNodeKey(path)
+	if err != nil || created {
+		t.Fatalf("LoadNodeKey of an existing file: created %v, err %v", created, err)
+	}
+	if !key.Equals(again) {
+		t.Error("reloaded key differs from the created key")
+	}
+
+	if err := os.WriteFile(path, []byte("not hex"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := LoadNodeKey(path); err == nil {
+		t.Error("LoadNodeKey accepted a malformed key file")
+	}
+}
@@ -0,0 +1,476 @@
+package p2p
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/multiformats/go-multiaddr"
+)
+
+// Peer management
+const (
+	DefaultTargetPeers     = 16
+	DefaultMaxInboundPeers = 32
+	PeerManagerInterval    = 5 * time.Second
+	BanDuration            = 10 * time.Minute // a pruned peer is neither dialed nor accepted for this long
+	dialTimeout            = 5 * time.Second
+	dialBackoffBase        = 5 * time.Second
+	dialBackoffMax         = 5 * time.Minute
+	maxDialFailures        = 8               // consecutive failures before a known peer is forgotten; bootnodes are kept
+	maxKnownPeers          = 1024            // known peers kept for dialing; bans are recorded past it
+	statusGracePeriod      = 2 * time.Second // time an inbound peer has to send the first Status
+	goodbyeTimeout         = 2 * time.Second
+)
+
+// PeerInfo describes a connected peer.
+type PeerInfo struct {
+	ID            peer.ID
+	Direction     network.Direction
+	ConnectedAt   time.Time
+	ClientVersion string          // agent version from libp2p identify, empty until identified
+	Status        *reqresp.Status // last Status exchanged, nil before the first
+	Score         float64         // latest gossipsub score
+}
+
+// knownPeer is a peer the manager may dial.
+type knownPeer struct {
+	info     peer.AddrInfo
+	bootnode bool
+	failures int
+	nextDial time.Time // no dial before this; also the end of a ban
+	banned   bool
+}
+
+// peerManager keeps the node connected to a target number of peers. It
+// redials bootnodes and previously seen peers with backoff, dials discovered
+// peers, limits inbound connections, exchanges Status with every new peer
+// and prunes peers with a bad gossipsub score or an incompatible Status.
+type peerManager struct {
+	svc         *Service
+	target      int
+	maxInbound  int
+	pruneScore  float64 // peers scoring below are pruned
+	scorePruned bool    // whether to prune on score
+
+	notifiee network.Notifiee // registered with the host while the service runs
+
+	mu        sync.Mutex
+	connected map[peer.ID]*PeerInfo
+	known     map[peer.ID]*knownPeer
+}
+
+func newPeerManager(svc *Service, cfg ServiceConfig, bootnodes []peer.AddrInfo) *peerManager {
+	m := &peerManager{
+		svc:        svc,
+		target:     cfg.TargetPeers,
+		maxInbound: cfg.MaxInboundPeers,
+		connected:  make(map[peer.ID]*PeerInfo),
+		known:      make(map[peer.ID]*knownPeer),
+	}
+	if m.target == 0 {
+		m.target = DefaultTargetPeers
+	}
+	if m.maxInbound == 0 {
+		m.maxInbound = DefaultMaxInboundPeers
+	}
+	for _, pi := range bootnodes {
+		m.known[pi.ID] = &knownPeer{info: pi, bootnode: true}
+	}
+	m.notifiee = &network.NotifyBundle{
+		ConnectedF:    m.connectedF,
+		DisconnectedF: m.disconnectedF,
+	}
+	return m
+}
+
+func (m *peerManager) connectedF(net network.Network, conn network.Conn) {
+	pid := conn.RemotePeer()
+	dir := conn.Stat().Direction
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if kp := m.known[pid]; kp != nil && kp.banned && time.Now().Before(kp.nextDial) {
+		go m.disconnect(pid, reqresp.GoodbyeBanned)
+		return
+	}
+	if _, exists := m.connected[pid]; !exists {
+		if dir == network.DirInbound && m.inboundCount() >= m.maxInbound {
+			m.svc.logger.Debug("rejecting inbound peer, inbound limit reached", "peer", pid)
+			go m.disconnect(pid, reqresp.GoodbyeTooManyPeers)
+			return
+		}
+		m.connected[pid] = &PeerInfo{ID: pid, Direction: dir, ConnectedAt: time.Now()}
+		if m.svc.reqresp != nil {
+			go m.handshake(pid, dir)
+		}
+	}
+
+	// Outbound connections reveal a dialable address
+	if dir == network.DirOutbound {
+		kp := m.known[pid]
+		if kp == nil {
+			if !m.makeRoom() {
+				return
+			}
+			kp = &knownPeer{info: peer.AddrInfo{ID: pid}}
+			m.known[pid] = kp
+		}
+		kp.info.Addrs = []multiaddr.Multiaddr{conn.RemoteMultiaddr()}
+		kp.failures = 0
+		kp.banned = false
+	}
+}
+
+func (m *peerManager) disconnectedF(net network.Network, conn network.Conn) {
+	pid := conn.RemotePeer()
+	if net.Connectedness(pid) == network.Connected {
+		return // another connection remains
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.connected, pid)
+	if kp := m.known[pid]; kp != nil && !kp.banned {
+		kp.nextDial = time.Now().Add(dialBackoffBase)
+	}
+}
+
+// inboundCount returns the number of inbound peers. The caller holds mu.
+func (m *peerManager) inboundCount() int {
+	n := 0
+	for _, info := range m.connected {
+		if info.Direction == network.DirInbound {
+			n++
+		}
+	}
+	return n
+}
+
+// run prunes and dials peers every PeerManagerInterval.
+func (m *peerManager) run(ctx context.Context) {
+	defer m.svc.wg.Done()
+
+	ticker := time.NewTicker(PeerManagerInterval)
+	defer ticker.Stop()
+
+	for {
+		m.expireBans()
+		m.prune()
+		m.dial(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// prune disconnects and bans peers whose gossipsub score fell below the
+// graylist threshold.
+func (m *peerManager) prune() {
+	if !m.scorePruned {
+		return
+	}
+	for pid, score := range m.svc.PeerScores() {
+		if score < m.pruneScore && m.svc.host.Network().Connectedness(pid) == network.Connected {
+			m.ban(pid, reqresp.GoodbyeBadScore)
+		}
+	}
+}
+
+// ban disconnects a peer and refuses it for BanDuration. Bans are kept even
+// when the known peers are full: there are at most as many as the peers
+// connected within BanDuration.
+func (m *peerManager) ban(pid peer.ID, reason reqresp.Goodbye) {
+	m.mu.Lock()
+	kp := m.known[pid]
+	if kp == nil {
+		m.makeRoom()
+		kp = &knownPeer{info: peer.AddrInfo{ID: pid}}
+		m.known[pid] = kp
+	}
+	kp.banned = true
+	kp.nextDial = time.Now().Add(BanDuration)
+	m.mu.Unlock()
+
+	m.svc.logger.Info("pruning peer", "peer", pid, "reason", reason.String())
+	m.disconnect(pid, reason)
+}
+
+// expireBans lifts the bans that ran out. A banned peer without a dialable
+// address was only known for its ban and is forgotten.
+func (m *peerManager) expireBans() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for pid, kp := range m.known {
+		if !kp.banned || now.Before(kp.nextDial) {
+			continue
+		}
+		if kp.bootnode || len(kp.info.Addrs) > 0 {
+			kp.banned = false
+		} else {
+			delete(m.known, pid)
+		}
+	}
+}
+
+// makeRoom reports whether another peer can be known, forgetting the
+// unconnected peer with the most dial failures if the known peers are full.
+// Bootnodes and banned peers are never forgotten. The caller holds mu.
+func (m *peerManager) makeRoom() bool {
+	if len(m.known) < maxKnownPeers {
+		return true
+	}
+	var worst peer.ID
+	var worstPeer *knownPeer
+	for pid, kp := range m.known {
+		if kp.bootnode || kp.banned {
+			continue
+		}
+		if _, connected := m.connected[pid]; connected {
+			continue
+		}
+		if worstPeer == nil || kp.failures > worstPeer.failures {
+			worst, worstPeer = pid, kp
+		}
+	}
+	if worstPeer == nil {
+		return false
+	}
+	delete(m.known, worst)
+	return true
+}
+
+// disconnect sends a peer a Goodbye with the reason and closes its
+// connections.
+func (m *peerManager) disconnect(pid peer.ID, reason reqresp.Goodbye) {
+	ctx, cancel := context.WithTimeout(context.Background(), goodbyeTimeout)
+	if err := m.svc.Goodbye(ctx, pid, reason); err != nil {
+		m.svc.logger.Debug("failed to send goodbye", "peer", pid, "error", err)
+	}
+	cancel()
+	if err := m.svc.host.Network().ClosePeer(pid); err != nil {
+		m.svc.logger.Debug("failed to close peer", "peer", pid, "error", err)
+	}
+}
+
+// disconnectAll says goodbye to every connected peer at once.
+func (m *peerManager) disconnectAll(reason reqresp.Goodbye) {
+	var wg sync.WaitGroup
+	for _, pid := range m.svc.host.Network().Peers() {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			m.disconnect(pid, reason)
+		}()
+	}
+	wg.Wait()
+}
+
+// handshake exchanges Status with a newly connected peer and passes a
+// compatible status to the status handler. The dialer sends the first
+// Status, so an inbound peer is given statusGracePeriod to send it before we
+// ask. A peer that fails the exchange is disconnected: with an irrelevant
+// network reason if it does not speak the status protocol at all.
+func (m *peerManager) handshake(pid peer.ID, dir network.Direction) {
+	ctx := m.svc.ctx
+	var status *reqresp.Status
+	if dir == network.DirInbound {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(statusGracePeriod):
+		}
+		status = m.status(pid)
+	}
+
+	if status == nil {
+		reqCtx, cancel := context.WithTimeout(ctx, reqresp.TTFBTimeout+reqresp.RespTimeout)
+		peerStatus, err := m.svc.requestStatus(reqCtx, pid)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil || m.svc.host.Network().Connectedness(pid) != network.Connected {
+				return
+			}
+			reason := reqresp.GoodbyeFault
+			if !m.svc.supportsProtocol(pid, reqresp.StatusProtocolV1) {
+				reason = reqresp.GoodbyeIrrelevantNetwork
+			}
+			m.svc.logger.Debug("status handshake failed", "peer", pid, "reason", reason.String(), "error", err)
+			m.disconnect(pid, reason)
+			return
+		}
+		if err := m.setStatus(pid, peerStatus); err != nil {
+			return
+		}
+		status = peerStatus
+	}
+
+	m.svc.logger.Debug("status handshake complete",
+		"peer", pid,
+		"head_slot", status.Head.Slot,
+		"finalized_slot", status.Finalized.Slot,
+	)
+	if m.svc.handlers != nil {
+		m.svc.handlers.HandleStatus(pid, status)
+	}
+}
+
+// dial connects to known and discovered peers while below the target,
+// bootnodes first.
+func (m *peerManager) dial(ctx context.Context) {
+	missing := m.target - m.svc.PeerCount()
+	if missing <= 0 {
+		return
+	}
+	if m.svc.discovery != nil {
+		m.addDiscovered(m.svc.discovery.Peers())
+	}
+
+	candidates := m.candidates(missing)
+	var wg sync.WaitGroup
+	for _, pi := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			dialCtx, cancel := context.WithTimeout(ctx, dialTimeout)
+			err := m.svc.host.Connect(dialCtx, pi)
+			cancel()
+			m.dialed(pi.ID, err)
+		}()
+	}
+	wg.Wait()
+}
+
+// addDiscovered adds discovered peers to the known peers, up to
+// maxKnownPeers. Known peers are not forgotten for new ones: they are
+// forgotten after repeated dial failures instead.
+func (m *peerManager) addDiscovered(peers []peer.AddrInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, pi := range peers {
+		if kp := m.known[pi.ID]; kp != nil {
+			if !kp.bootnode {
+				kp.info.Addrs = pi.Addrs
+			}
+			continue
+		}
+		if len(m.known) >= maxKnownPeers {
+			return
+		}
+		m.known[pi.ID] = &knownPeer{info: pi}
+	}
+}
+
+// candidates returns up to n unconnected peers due to be dialed, bootnodes
+// first, then by fewest recent failures.
+func (m *peerManager) candidates(n int) []peer.AddrInfo {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	var due []*knownPeer
+	for pid, kp := range m.known {
+		if pid == m.svc.host.ID() || len(kp.info.Addrs) == 0 || now.Before(kp.nextDial) {
+			continue
+		}
+		if _, connected := m.connected[pid]; connected {
+			continue
+		}
+		due = append(due, kp)
+	}
+	sort.Slice(due, func(i, j int) bool {
+		if due[i].bootnode != due[j].bootnode {
+			return due[i].bootnode
+		}
+		return due[i].failures < due[j].failures
+	})
+
+	out := make([]peer.AddrInfo, 0, min(n, len(due)))
+	for _, kp := range due[:min(n, len(due))] {
+		out = append(out, kp.info)
+	}
+	return out
+}
+
+// dialed records the outcome of a dial, backing off exponentially after
+// failures.
+func (m *peerManager) dialed(pid peer.ID, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kp := m.known[pid]
+	if kp == nil {
+		return
+	}
+	if err == nil {
+		kp.failures = 0
+		m.svc.logger.Info("connected to peer", "peer", pid, "bootnode", kp.bootnode)
+		return
+	}
+
+	kp.failures++
+	if !kp.bootnode && kp.failures >= maxDialFailures {
+		delete(m.known, pid)
+		return
+	}
+	backoff := min(dialBackoffBase<<(kp.failures-1), dialBackoffMax)
+	kp.nextDial = time.Now().Add(backoff)
+	m.svc.logger.Debug("failed to dial peer", "peer", pid, "failures", kp.failures, "retry_in", backoff, "error", err)
+}
+
+// setStatus records a peer's Status, pruning the peer if it is incompatible
+// with our chain.
+func (m *peerManager) setStatus(pid peer.ID, status *reqresp.Status) error {
+	if m.svc.reqresp != nil {
+		if err := m.svc.reqresp.ValidatePeerStatus(status); err != nil {
+			m.svc.logger.Debug("incompatible peer status", "peer", pid, "error", err)
+			m.ban(pid, reqresp.GoodbyeIrrelevantNetwork)
+			return err
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if info := m.connected[pid]; info != nil {
+		info.Status = status
+	}
+	return nil
+}
+
+// status returns the last Status a connected peer sent, or nil.
+func (m *peerManager) status(pid peer.ID) *reqresp.Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if info := m.connected[pid]; info != nil {
+		return info.Status
+	}
+	return nil
+}
+
+// infos returns a snapshot of the connected peers.
+func (m *peerManager) infos() []PeerInfo {
+	scores := m.svc.PeerScores()
+
+	m.mu.Lock()
+	out := make([]PeerInfo, 0, len(m.connected))
+	for pid, info := range m.connected {
+		snapshot := *info
+		snapshot.Score = scores[pid]
+		out = append(out, snapshot)
+	}
+	m.mu.Unlock()
+
+	for i := range out {
+		if v, err := m.svc.host.Peerstore().Get(out[i].ID, "AgentVersion"); err == nil {
+			out[i].ClientVersion, _ = v.(string)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
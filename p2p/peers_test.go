@@ -0,0 +1,227 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+func newTestHost(t *testing.T) host.Host {
+	t.Helper()
+	h, err := NewHost(context.Background(), HostConfig{ListenAddrs: []string{"/ip4/127.0.0.1/udp/0/quic-v1"}})
+	if err != nil {
+		t.Fatalf("NewHost failed: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func newTestService(t *testing.T, cfg ServiceConfig) *Service {
+	t.Helper()
+	cfg.Host = newTestHost(t)
+	svc, err := NewService(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	t.Cleanup(svc.cancel)
+	// Manage connections without starting the dial loop
+	svc.host.Network().Notify(svc.peers.notifiee)
+	return svc
+}
+
+func waitUntil(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestDialBackoff(t *testing.T) {
+	boot := peer.AddrInfo{ID: "boot"}
+	svc := newTestService(t, ServiceConfig{Bootnodes: []peer.AddrInfo{boot}})
+	m := svc.peers
+	m.addDiscovered([]peer.AddrInfo{{ID: "found", Addrs: svc.host.Addrs()}})
+
+	if got := m.candidates(2); len(got) != 1 || got[0].ID != "found" {
+		t.Fatalf("candidates = %v, want only the discovered peer: the bootnode has no address", got)
+	}
+	boot.Addrs = svc.host.Addrs()
+	m.known[boot.ID].info = boot
+	if got := m.candidates(1); len(got) != 1 || got[0].ID != boot.ID {
+		t.Fatalf("candidates = %v, want the bootnode first", got)
+	}
+
+	dialErr := errors.New("unreachable")
+	m.dialed("found", dialErr)
+	if got := m.candidates(2); len(got) != 1 || got[0].ID != boot.ID {
+		t.Errorf("candidates = %v, want the failed peer backed off", got)
+	}
+	if wait := time.Until(m.known["found"].nextDial); wait < dialBackoffBase/2 || wait > dialBackoffBase {
+		t.Errorf("first backoff = %v, want about %v", wait, dialBackoffBase)
+	}
+	m.dialed("found", dialErr)
+	if wait := time.Until(m.known["found"].nextDial); wait <= dialBackoffBase {
+		t.Errorf("second backoff = %v, want it to grow", wait)
+	}
+
+	for range maxDialFailures {
+		m.dialed("found", dialErr)
+		m.dialed(boot.ID, dialErr)
+	}
+	if m.known["found"] != nil {
+		t.Error("peer kept after repeated dial failures")
+	}
+	if m.known[boot.ID] == nil {
+		t.Error("bootnode forgotten after dial failures")
+	}
+}
+
+func TestInboundLimitAndBan(t *testing.T) {
+	svc := newTestService(t, ServiceConfig{MaxInboundPeers: 1})
+	target := peer.AddrInfo{ID: svc.host.ID(), Addrs: svc.host.Addrs()}
+
+	first, second := newTestHost(t), newTestHost(t)
+	if err := first.Connect(context.Background(), target); err != nil {
+		t.Fatalf("first connect failed: %v", err)
+	}
+	waitUntil(t, "the first inbound peer", func() bool { return len(svc.PeerInfos()) == 1 })
+
+	_ = second.Connect(context.Background(), target)
+	waitUntil(t, "the second peer to be rejected", func() bool {
+		return svc.host.Network().Connectedness(second.ID()) != network.Connected
+	})
+	if infos := svc.PeerInfos(); len(infos) != 1 || infos[0].ID != first.ID() || infos[0].Direction != network.DirInbound {
+		t.Fatalf("peers = %v, want only the first inbound peer", infos)
+	}
+
+	svc.peers.ban(first.ID(), reqresp.GoodbyeBanned)
+	waitUntil(t, "the banned peer to disconnect", func() bool { return len(svc.PeerInfos()) == 0 })
+	_ = first.Connect(context.Background(), target)
+	waitUntil(t, "the banned peer to be refused", func() bool {
+		return svc.host.Network().Connectedness(first.ID()) != network.Connected
+	})
+	if len(svc.PeerInfos()) != 0 {
+		t.Error("banned peer was accepted again")
+	}
+}
+
+func TestKnownPeersBounded(t *testing.T) {
+	svc := newTestService(t, ServiceConfig{})
+	m := svc.peers
+	addrs := svc.host.Addrs()
+
+	var found []peer.AddrInfo
+	for i := range maxKnownPeers + 10 {
+		found = append(found, peer.AddrInfo{ID: peer.ID(fmt.Sprintf("found-%d", i)), Addrs: addrs})
+	}
+	m.addDiscovered(found)
+	if len(m.known) != maxKnownPeers {
+		t.Fatalf("known peers = %d, want %d", len(m.known), maxKnownPeers)
+	}
+
+	// A ban makes room by forgetting the peer with the most failures
+	m.dialed("found-3", errors.New("unreachable"))
+	m.ban("banned", reqresp.GoodbyeBanned)
+	if len(m.known) != maxKnownPeers || m.known["found-3"] != nil || !isBanned(svc, "banned") {
+		t.Errorf("known peers = %d, want the failed peer replaced by the ban", len(m.known))
+	}
+
+	// Expired bans are lifted, and forgotten without an address
+	m.ban("found-4", reqresp.GoodbyeBanned)
+	m.mu.Lock()
+	m.known["banned"].nextDial = time.Now().Add(-time.Second)
+	m.known["found-4"].nextDial = time.Now().Add(-time.Second)
+	m.mu.Unlock()
+	m.expireBans()
+	if m.known["banned"] != nil {
+		t.Error("expired ban without an address kept")
+	}
+	if kp := m.known["found-4"]; kp == nil || kp.banned {
+		t.Error("expired ban of a dialable peer not lifted")
+	}
+}
+
+// newChainStore returns a store with a block at each of the given slots,
+// finalized at the last one.
+func newChainStore(t *testing.T, slots ...types.Slot) *forkchoice.Store {
+	t.Helper()
+	store := forkchoicetest.Store(t, slots...)
+	if len(slots) > 0 {
+		store.LatestFinalized = store.HeadCheckpoint()
+	}
+	return store
+}
+
+func isBanned(svc *Service, pid peer.ID) bool {
+	svc.peers.mu.Lock()
+	defer svc.peers.mu.Unlock()
+	kp := svc.peers.known[pid]
+	return kp != nil && kp.banned
+}
+
+// newStatusService returns a service serving the store's Status and
+// sending handshaked statuses to the returned channel.
+func newStatusService(t *testing.T, store *forkchoice.Store) (*Service, chan peer.ID) {
+	t.Helper()
+	handshaked := make(chan peer.ID, 4)
+	handlers := &MessageHandlers{OnStatus: func(from peer.ID, status *reqresp.Status) { handshaked <- from }}
+	return newTestService(t, ServiceConfig{ReqResp: reqresp.NewHandler(store), Handlers: handlers}), handshaked
+}
+
+func TestStatusHandshake(t *testing.T) {
+	a, aStatuses := newStatusService(t, newChainStore(t, 1, 2, 3))
+	c, cStatuses := newStatusService(t, newChainStore(t))
+
+	// Both sides complete the handshake and pass the status on
+	if err := c.host.Connect(context.Background(), peer.AddrInfo{ID: a.host.ID(), Addrs: a.host.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	for _, got := range []struct {
+		statuses chan peer.ID
+		want     peer.ID
+	}{{cStatuses, a.host.ID()}, {aStatuses, c.host.ID()}} {
+		select {
+		case pid := <-got.statuses:
+			if pid != got.want {
+				t.Errorf("handshaked with %s, want %s", pid, got.want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for the handshake with %s", got.want)
+		}
+	}
+	if infos := c.PeerInfos(); len(infos) != 1 || infos[0].Status == nil || infos[0].Status.Head.Slot != 3 {
+		t.Errorf("peers = %v, want a with its head at slot 3", infos)
+	}
+
+	// A peer finalized on another chain is said goodbye to and banned
+	b, bStatuses := newStatusService(t, newChainStore(t, 3))
+	if err := b.host.Connect(context.Background(), peer.AddrInfo{ID: a.host.ID(), Addrs: a.host.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+	waitUntil(t, "the conflicting peer to disconnect", func() bool {
+		return b.host.Network().Connectedness(a.host.ID()) != network.Connected
+	})
+	// Whichever side validates first bans the other and says goodbye
+	if !isBanned(a, b.host.ID()) && !isBanned(b, a.host.ID()) {
+		t.Error("conflicting peer was not banned")
+	}
+	select {
+	case pid := <-bStatuses:
+		t.Errorf("status of conflicting peer %s passed on", pid)
+	default:
+	}
+}
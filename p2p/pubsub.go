@@ -7,14 +7,23 @@ import (
 	"time"
 
 	"github.com/devylongs/gean/p2p/gossipsub"
+	"github.com/devylongs/gean/types"
 	"github.com/golang/snappy"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	pb "github.com/libp2p/go-libp2p-pubsub/pb"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/peer"
 )
 
 const (
 	NetworkName = "devnet0"
+
+	// ScoreInspectInterval is how often peer score snapshots are taken.
+	ScoreInspectInterval = time.Duration(types.SecondsPerSlot) * time.Second
+
+	// MaxGossipSize bounds gossip messages. Blocks carry an XMSS signature
+	// per attestation, well beyond the 1 MiB pubsub default.
+	MaxGossipSize = 10 * 1024 * 1024
 )
 
 // Topic names (per networking spec: block and vote)
@@ -24,16 +33,15 @@ var (
 )
 
 // NewGossipSub creates a new gossipsub instance with lean consensus parameters.
-func NewGossipSub(ctx context.Context, h host.Host) (*pubsub.PubSub, error) {
-	params := gossipsub.DefaultParams()
-
+// Extra options are appended after the ones derived from params.
+func NewGossipSub(ctx context.Context, h host.Host, params gossipsub.Params, extra ...pubsub.Option) (*pubsub.PubSub, error) {
 	// Start with default gossipsub params and override what we need
 	gsParams := pubsub.DefaultGossipSubParams()
 	gsParams.D = params.D
 	gsParams.Dlo = params.DLow
 	gsParams.Dhi = params.DHigh
 	gsParams.Dlazy = params.DLazy
-	gsParams.HeartbeatInterval = time.Duration(params.HeartbeatInterval * float64(time.Second))
+	gsParams.HeartbeatInterval = seconds(params.HeartbeatInterval)
 	gsParams.FanoutTTL = time.Duration(params.FanoutTTL) * time.Second
 	gsParams.HistoryLength = params.MCacheLen
 	gsParams.HistoryGossip = params.MCacheGossip
@@ -44,11 +52,88 @@ func NewGossipSub(ctx context.Context, h host.Host) (*pubsub.PubSub, error) {
 		pubsub.WithSeenMessagesTTL(time.Duration(params.SeenTTL) * time.Second),
 		pubsub.WithMessageSignaturePolicy(pubsub.StrictNoSign),
 		pubsub.WithFloodPublish(false),
+		pubsub.WithMaxMessageSize(MaxGossipSize),
 	}
+	if params.Scoring.Enabled {
+		scoreParams, thresholds := peerScoreParams(params.Scoring)
+		opts = append(opts, pubsub.WithPeerScore(scoreParams, thresholds))
+	}
+	opts = append(opts, extra...)
 
 	return pubsub.NewGossipSub(ctx, h, opts...)
 }
 
+// peerScoreParams converts scoring parameters into their pubsub form.
+func peerScoreParams(p gossipsub.ScoreParams) (*pubsub.PeerScoreParams, *pubsub.PeerScoreThresholds) {
+	decayInterval := seconds(p.DecayInterval)
+	decay := func(secs float64) float64 {
+		return pubsub.ScoreParameterDecayWithBase(seconds(secs), decayInterval, p.DecayToZero)
+	}
+	topic := func(t gossipsub.TopicScoreParams) *pubsub.TopicScoreParams {
+		return &pubsub.TopicScoreParams{
+			TopicWeight: t.TopicWeight,
+
+			TimeInMeshWeight:  t.TimeInMeshWeight,
+			TimeInMeshQuantum: seconds(t.TimeInMeshQuantum),
+			TimeInMeshCap:     t.TimeInMeshCap,
+
+			FirstMessageDeliveriesWeight: t.FirstMessageDeliveriesWeight,
+			FirstMessageDeliveriesDecay:  decay(t.FirstMessageDeliveriesDecay),
+			FirstMessageDeliveriesCap:    t.FirstMessageDeliveriesCap,
+
+			MeshMessageDeliveriesWeight:     t.MeshMessageDeliveriesWeight,
+			MeshMessageDeliveriesDecay:      decay(t.MeshMessageDeliveriesDecay),
+			MeshMessageDeliveriesThreshold:  t.MeshMessageDeliveriesThreshold,
+			MeshMessageDeliveriesCap:        t.MeshMessageDeliveriesCap,
+			MeshMessageDeliveriesActivation: seconds(t.MeshMessageDeliveriesActivation),
+			MeshMessageDeliveriesWindow:     seconds(t.MeshMessageDeliveriesWindow),
+
+			MeshFailurePenaltyWeight: t.MeshFailurePenaltyWeight,
+			MeshFailurePenaltyDecay:  decay(t.MeshFailurePenaltyDecay),
+
+			InvalidMessageDeliveriesWeight: t.InvalidMessageDeliveriesWeight,
+			InvalidMessageDeliveriesDecay:  decay(t.InvalidMessageDeliveriesDecay),
+		}
+	}
+
+	scoreParams := &pubsub.PeerScoreParams{
+		Topics: map[string]*pubsub.TopicScoreParams{
+			BlockTopic: topic(p.Block),
+			VoteTopic:  topic(p.Vote),
+		},
+		TopicScoreCap: 0, // Uncapped
+
+		// No application-specific scoring yet
+		AppSpecificScore:  func(peer.ID) float64 { return 0 },
+		AppSpecificWeight: 1,
+
+		IPColocationFactorWeight:    p.IPColocationFactorWeight,
+		IPColocationFactorThreshold: p.IPColocationFactorThreshold,
+
+		BehaviourPenaltyWeight:    p.BehaviourPenaltyWeight,
+		BehaviourPenaltyThreshold: p.BehaviourPenaltyThreshold,
+		BehaviourPenaltyDecay:     decay(p.BehaviourPenaltyDecay),
+
+		DecayInterval: decayInterval,
+		DecayToZero:   p.DecayToZero,
+		RetainScore:   seconds(p.RetainScore),
+	}
+
+	thresholds := &pubsub.PeerScoreThresholds{
+		GossipThreshold:             p.GossipThreshold,
+		PublishThreshold:            p.PublishThreshold,
+		GraylistThreshold:           p.GraylistThreshold,
+		AcceptPXThreshold:           p.AcceptPXThreshold,
+		OpportunisticGraftThreshold: p.OpportunisticGraftThreshold,
+	}
+
+	return scoreParams, thresholds
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
 // computePubsubMessageID computes the message ID for gossipsub.
 // ID = SHA256(domain + uint64_le(len(topic)) + topic + data)[:20]
 func computePubsubMessageID(msg *pb.Message) string {
@@ -0,0 +1,25 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devylongs/gean/p2p/gossipsub"
+	"github.com/libp2p/go-libp2p"
+)
+
+func TestNewGossipSubScoring(t *testing.T) {
+	h, err := libp2p.New(libp2p.NoListenAddrs)
+	if err != nil {
+		t.Fatalf("create host: %v", err)
+	}
+	defer h.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// pubsub validates score parameters and thresholds on construction
+	if _, err := NewGossipSub(ctx, h, gossipsub.DefaultParams()); err != nil {
+		t.Fatalf("NewGossipSub with default scoring failed: %v", err)
+	}
+}
@@ -0,0 +1,310 @@
+package p2p
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/network"
+	"github.com/libp2p/go-libp2p/core/peer"
+	"github.com/libp2p/go-libp2p/core/protocol"
+)
+
+// registerReqResp installs the request/response stream handlers on the host.
+func (s *Service) registerReqResp() {
+	s.host.SetStreamHandler(reqresp.StatusProtocolV1, s.handleStatusStream)
+	s.host.SetStreamHandler(reqresp.BlocksByRootProtocolV1, s.handleBlocksByRootStream)
+	s.host.SetStreamHandler(reqresp.BlocksByRangeProtocolV1, s.handleBlocksByRangeStream)
+	s.host.SetStreamHandler(reqresp.GoodbyeProtocolV1, s.handleGoodbyeStream)
+}
+
+// unregisterReqResp removes the request/response stream handlers.
+func (s *Service) unregisterReqResp() {
+	s.host.RemoveStreamHandler(reqresp.StatusProtocolV1)
+	s.host.RemoveStreamHandler(reqresp.BlocksByRootProtocolV1)
+	s.host.RemoveStreamHandler(reqresp.BlocksByRangeProtocolV1)
+	s.host.RemoveStreamHandler(reqresp.GoodbyeProtocolV1)
+}
+
+// handleStatusStream answers an inbound Status request with our own status.
+func (s *Service) handleStatusStream(stream network.Stream) {
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(reqresp.RespTimeout))
+
+	data, err := reqresp.ReadRequest(bufio.NewReader(stream))
+	if err != nil {
+		s.logger.Debug("read status request failed", "peer", stream.Conn().RemotePeer(), "error", err)
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, err.Error())
+		return
+	}
+
+	var peerStatus reqresp.Status
+	if err := peerStatus.UnmarshalSSZ(data); err != nil {
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, "malformed status")
+		return
+	}
+
+	ourStatus := s.reqresp.HandleStatus(&peerStatus)
+	resp, err := ourStatus.MarshalSSZ()
+	if err != nil {
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeServerError, "encode status")
+		return
+	}
+	if err := reqresp.WriteResponse(stream, reqresp.ResponseCodeSuccess, resp); err != nil {
+		s.logger.Debug("write status response failed", "peer", stream.Conn().RemotePeer(), "error", err)
+	}
+	_ = s.peers.setStatus(stream.Conn().RemotePeer(), &peerStatus)
+}
+
+// handleBlocksByRootStream streams back every requested block we know about,
+// one response chunk per block.
+func (s *Service) handleBlocksByRootStream(stream network.Stream) {
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(reqresp.RespTimeout))
+
+	data, err := reqresp.ReadRequest(bufio.NewReader(stream))
+	if err != nil {
+		s.logger.Debug("read blocks_by_root request failed", "peer", stream.Conn().RemotePeer(), "error", err)
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, err.Error())
+		return
+	}
+
+	var request reqresp.BlocksByRootRequest
+	if err := request.UnmarshalSSZ(data); err != nil {
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, err.Error())
+		return
+	}
+
+	response := s.reqresp.HandleBlocksByRoot(&request)
+	s.writeBlocks(stream, response.Blocks)
+}
+
+// handleBlocksByRangeStream streams back the canonical blocks in the
+// requested slots, oldest first.
+func (s *Service) handleBlocksByRangeStream(stream network.Stream) {
+	defer stream.Close()
+	_ = stream.SetDeadline(time.Now().Add(reqresp.RespTimeout))
+
+	data, err := reqresp.ReadRequest(bufio.NewReader(stream))
+	if err != nil {
+		s.logger.Debug("read blocks_by_range request failed", "peer", stream.Conn().RemotePeer(), "error", err)
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, err.Error())
+		return
+	}
+
+	var request reqresp.BlocksByRangeRequest
+	if err := request.UnmarshalSSZ(data); err != nil {
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, err.Error())
+		return
+	}
+
+	response, err := s.reqresp.HandleBlocksByRange(&request)
+	if err != nil {
+		_ = reqresp.WriteError(stream, reqresp.ResponseCodeInvalidRequest, err.Error())
+		return
+	}
+	s.writeBlocks(stream, response.Blocks)
+}
+
+// writeBlocks writes one response chunk per block.
+func (s *Service) writeBlocks(stream network.Stream, blocks []*types.SignedBlock) {
+	for _, block := range blocks {
+		encoded, err := block.MarshalSSZ()
+		if err != nil {
+			_ = reqresp.WriteError(stream, reqresp.ResponseCodeServerError, "encode block")
+			return
+		}
+		if err := reqresp.WriteResponse(stream, reqresp.ResponseCodeSuccess, encoded); err != nil {
+			s.logger.Debug("write block response failed", "peer", stream.Conn().RemotePeer(), "error", err)
+			return
+		}
+	}
+}
+
+// handleGoodbyeStream reads a peer's Goodbye and disconnects from it.
+func (s *Service) handleGoodbyeStream(stream network.Stream) {
+	pid := stream.Conn().RemotePeer()
+	_ = stream.SetDeadline(time.Now().Add(reqresp.RespTimeout))
+
+	data, err := reqresp.ReadRequest(bufio.NewReader(stream))
+	stream.Close()
+	var reason reqresp.Goodbye
+	if err == nil {
+		err = reason.UnmarshalSSZ(data)
+	}
+	if err != nil {
+		s.logger.Debug("read goodbye failed", "peer", pid, "error", err)
+	} else {
+		s.logger.Debug("peer said goodbye", "peer", pid, "reason", reason.String())
+	}
+	_ = s.host.Network().ClosePeer(pid)
+}
+
+// RequestStatus sends our Status to a peer and returns theirs. A status
+// that conflicts with our chain prunes the peer and is returned as an error.
+func (s *Service) RequestStatus(ctx context.Context, pid peer.ID) (*reqresp.Status, error) {
+	status, err := s.requestStatus(ctx, pid)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.peers.setStatus(pid, status); err != nil {
+		return nil, fmt.Errorf("incompatible status: %w", err)
+	}
+	return status, nil
+}
+
+// requestStatus exchanges Status with a peer without validating theirs.
+func (s *Service) requestStatus(ctx context.Context, pid peer.ID) (*reqresp.Status, error) {
+	ourStatus, err := s.reqresp.Status().MarshalSSZ()
+	if err != nil {
+		return nil, fmt.Errorf("encode status: %w", err)
+	}
+
+	stream, err := s.sendRequest(ctx, pid, reqresp.StatusProtocolV1, ourStatus)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	code, data, err := reqresp.ReadResponse(bufio.NewReader(stream))
+	if err != nil {
+		return nil, fmt.Errorf("read status response: %w", err)
+	}
+	if code != reqresp.ResponseCodeSuccess {
+		return nil, responseError(code, data)
+	}
+
+	var status reqresp.Status
+	if err := status.UnmarshalSSZ(data); err != nil {
+		return nil, fmt.Errorf("decode status: %w", err)
+	}
+	return &status, nil
+}
+
+// Goodbye tells a peer why we are about to disconnect from it. It waits
+// until the peer has read the message or ctx is done.
+func (s *Service) Goodbye(ctx context.Context, pid peer.ID, reason reqresp.Goodbye) error {
+	payload, err := reason.MarshalSSZ()
+	if err != nil {
+		return fmt.Errorf("encode goodbye: %w", err)
+	}
+
+	stream, err := s.sendRequest(ctx, pid, reqresp.GoodbyeProtocolV1, payload)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = stream.SetReadDeadline(deadline)
+	}
+	// No response is sent; the peer closes the stream once it has read ours
+	_, _ = io.Copy(io.Discard, stream)
+	return nil
+}
+
+// RequestBlocksByRoot asks a peer for the blocks with the given roots.
+// Blocks the peer does not have are simply absent from the result.
+func (s *Service) RequestBlocksByRoot(ctx context.Context, pid peer.ID, roots []types.Root) ([]*types.SignedBlock, error) {
+	request := &reqresp.BlocksByRootRequest{Roots: roots}
+	payload, err := request.MarshalSSZ()
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+
+	stream, err := s.sendRequest(ctx, pid, reqresp.BlocksByRootProtocolV1, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+	return readBlocks(stream, len(roots))
+}
+
+// RequestBlocksByRange asks a peer for its canonical blocks in count slots
+// from start, step slots apart. Skipped slots and blocks the peer does not
+// have are absent from the result, which is ordered oldest first.
+func (s *Service) RequestBlocksByRange(ctx context.Context, pid peer.ID, start types.Slot, count, step uint64) ([]*types.SignedBlock, error) {
+	request := &reqresp.BlocksByRangeRequest{StartSlot: start, Count: count, Step: step}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+	payload, err := request.MarshalSSZ()
+	if err != nil {
+		return nil, fmt.Errorf("encode request: %w", err)
+	}
+
+	stream, err := s.sendRequest(ctx, pid, reqresp.BlocksByRangeProtocolV1, payload)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	blocks, err := readBlocks(stream, int(count))
+	for i, block := range blocks {
+		slot := block.Message.Slot
+		if !request.Contains(slot) || (i > 0 && slot <= blocks[i-1].Message.Slot) {
+			return blocks[:i], fmt.Errorf("peer returned block at unrequested slot %d", slot)
+		}
+	}
+	return blocks, err
+}
+
+// readBlocks reads up to limit block response chunks, stopping early when
+// the responder closes the stream.
+func readBlocks(stream network.Stream, limit int) ([]*types.SignedBlock, error) {
+	r := bufio.NewReader(stream)
+	var blocks []*types.SignedBlock
+	for len(blocks) < limit {
+		code, data, err := reqresp.ReadResponse(r)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return blocks, fmt.Errorf("read block response: %w", err)
+		}
+		if code != reqresp.ResponseCodeSuccess {
+			return blocks, responseError(code, data)
+		}
+
+		block := new(types.SignedBlock)
+		if err := block.UnmarshalSSZ(data); err != nil {
+			return blocks, fmt.Errorf("decode block: %w", err)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, nil
+}
+
+// supportsProtocol reports whether identify has seen the peer advertise proto.
+func (s *Service) supportsProtocol(pid peer.ID, proto protocol.ID) bool {
+	supported, err := s.host.Peerstore().SupportsProtocols(pid, proto)
+	return err == nil && len(supported) > 0
+}
+
+// sendRequest opens a stream, writes the request and half-closes the write side.
+func (s *Service) sendRequest(ctx context.Context, pid peer.ID, proto protocol.ID, payload []byte) (network.Stream, error) {
+	stream, err := s.host.NewStream(ctx, pid, proto)
+	if err != nil {
+		return nil, fmt.Errorf("open stream: %w", err)
+	}
+	_ = stream.SetDeadline(time.Now().Add(reqresp.TTFBTimeout + reqresp.RespTimeout))
+
+	if err := reqresp.WriteRequest(stream, payload); err != nil {
+		stream.Reset()
+		return nil, fmt.Errorf("write request: %w", err)
+	}
+	if err := stream.CloseWrite(); err != nil {
+		stream.Reset()
+		return nil, fmt.Errorf("close write: %w", err)
+	}
+	return stream, nil
+}
+
+// responseError converts a non-success response chunk into an error.
+func responseError(code byte, data []byte) error {
+	return fmt.Errorf("peer returned error code %d: %s", code, string(data))
+}
@@ -0,0 +1,106 @@
+package reqresp
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/golang/snappy"
+)
+
+// Response codes (per networking spec)
+const (
+	ResponseCodeSuccess             byte = 0
+	ResponseCodeInvalidRequest      byte = 1
+	ResponseCodeServerError         byte = 2
+	ResponseCodeResourceUnavailable byte = 3
+)
+
+// Wire limits and timeouts
+const (
+	MaxPayloadSize      = 10 * 1024 * 1024 // 10 MiB uncompressed
+	MaxErrorMessageSize = 256
+	TTFBTimeout         = 5 * time.Second
+	RespTimeout         = 10 * time.Second
+)
+
+// WriteRequest writes an ssz_snappy request: a varint of the uncompressed
+// length followed by the snappy framed payload.
+func WriteRequest(w io.Writer, data []byte) error {
+	return writePayload(w, data)
+}
+
+// ReadRequest reads an ssz_snappy request and returns the uncompressed payload.
+func ReadRequest(r *bufio.Reader) ([]byte, error) {
+	return readPayload(r)
+}
+
+// WriteResponse writes a single response chunk: the result code, then the
+// ssz_snappy encoded payload.
+func WriteResponse(w io.Writer, code byte, data []byte) error {
+	if _, err := w.Write([]byte{code}); err != nil {
+		return fmt.Errorf("write response code: %w", err)
+	}
+	return writePayload(w, data)
+}
+
+// WriteError writes an error response chunk with a human readable message.
+func WriteError(w io.Writer, code byte, msg string) error {
+	if len(msg) > MaxErrorMessageSize {
+		msg = msg[:MaxErrorMessageSize]
+	}
+	return WriteResponse(w, code, []byte(msg))
+}
+
+// ReadResponse reads a single response chunk. io.EOF is returned when the
+// responder has closed the stream before another chunk started.
+func ReadResponse(r *bufio.Reader) (byte, []byte, error) {
+	code, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	data, err := readPayload(r)
+	if err != nil {
+		return code, nil, err
+	}
+	return code, data, nil
+}
+
+func writePayload(w io.Writer, data []byte) error {
+	if len(data) > MaxPayloadSize {
+		return ErrPayloadTooLarge
+	}
+
+	var lenBuf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(lenBuf[:], uint64(len(data)))
+	if _, err := w.Write(lenBuf[:n]); err != nil {
+		return fmt.Errorf("write length: %w", err)
+	}
+
+	sw := snappy.NewBufferedWriter(w)
+	if _, err := sw.Write(data); err != nil {
+		return fmt.Errorf("write payload: %w", err)
+	}
+	if err := sw.Close(); err != nil {
+		return fmt.Errorf("flush payload: %w", err)
+	}
+	return nil
+}
+
+func readPayload(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("read length: %w", err)
+	}
+	if length > MaxPayloadSize {
+		return nil, ErrPayloadTooLarge
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(snappy.NewReader(r), data); err != nil {
+		return nil, fmt.Errorf("read payload: %w", err)
+	}
+	return data, nil
+}
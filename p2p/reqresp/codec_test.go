@@ -0,0 +1,159 @@
+package reqresp
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/devylongs/gean/types"
+)
+
+func TestRequestRoundTrip(t *testing.T) {
+	status := &Status{
+		Finalized: types.Checkpoint{Root: types.Root{1}, Slot: 3},
+		Head:      types.Checkpoint{Root: types.Root{2}, Slot: 7},
+	}
+	payload, err := status.MarshalSSZ()
+	if err != nil {
+		t.Fatalf("MarshalSSZ failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteRequest(&buf, payload); err != nil {
+		t.Fatalf("WriteRequest failed: %v", err)
+	}
+
+	data, err := ReadRequest(bufio.NewReader(&buf))
+	if err != nil {
+		t.Fatalf("ReadRequest failed: %v", err)
+	}
+
+	var decoded Status
+	if err := decoded.UnmarshalSSZ(data); err != nil {
+		t.Fatalf("UnmarshalSSZ failed: %v", err)
+	}
+	if decoded != *status {
+		t.Errorf("decoded status = %+v, want %+v", decoded, *status)
+	}
+}
+
+func TestResponseChunks(t *testing.T) {
+	chunks := [][]byte{{0x01, 0x02}, bytes.Repeat([]byte{0xab}, 100000), {}}
+
+	var buf bytes.Buffer
+	for _, chunk := range chunks {
+		if err := WriteResponse(&buf, ResponseCodeSuccess, chunk); err != nil {
+			t.Fatalf("WriteResponse failed: %v", err)
+		}
+	}
+	if err := WriteError(&buf, ResponseCodeResourceUnavailable, "not found"); err != nil {
+		t.Fatalf("WriteError failed: %v", err)
+	}
+
+	r := bufio.NewReader(&buf)
+	for i, want := range chunks {
+		code, data, err := ReadResponse(r)
+		if err != nil {
+			t.Fatalf("chunk %d: ReadResponse failed: %v", i, err)
+		}
+		if code != ResponseCodeSuccess {
+			t.Errorf("chunk %d: code = %d, want %d", i, code, ResponseCodeSuccess)
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("chunk %d: payload mismatch", i)
+		}
+	}
+
+	code, data, err := ReadResponse(r)
+	if err != nil {
+		t.Fatalf("error chunk: ReadResponse failed: %v", err)
+	}
+	if code != ResponseCodeResourceUnavailable || string(data) != "not found" {
+		t.Errorf("error chunk = (%d, %q), want (%d, %q)", code, data, ResponseCodeResourceUnavailable, "not found")
+	}
+
+	if _, _, err := ReadResponse(r); !errors.Is(err, io.EOF) {
+		t.Errorf("expected io.EOF after last chunk, got %v", err)
+	}
+}
+
+func TestBlocksByRootRequestEncoding(t *testing.T) {
+	request := &BlocksByRootRequest{Roots: []types.Root{{1}, {2}, {3}}}
+
+	data, err := request.MarshalSSZ()
+	if err != nil {
+		t.Fatalf("MarshalSSZ failed: %v", err)
+	}
+	if len(data) != 3*32 {
+		t.Fatalf("encoded length = %d, want %d", len(data), 3*32)
+	}
+
+	var decoded BlocksByRootRequest
+	if err := decoded.UnmarshalSSZ(data); err != nil {
+		t.Fatalf("UnmarshalSSZ failed: %v", err)
+	}
+	if len(decoded.Roots) != 3 || decoded.Roots[2] != (types.Root{3}) {
+		t.Errorf("decoded roots = %v", decoded.Roots)
+	}
+
+	if err := decoded.UnmarshalSSZ(data[:40]); err == nil {
+		t.Error("expected error for truncated request")
+	}
+}
+
+func TestGoodbyeEncoding(t *testing.T) {
+	data, err := GoodbyeTooManyPeers.MarshalSSZ()
+	if err != nil {
+		t.Fatalf("MarshalSSZ failed: %v", err)
+	}
+	if len(data) != 8 || data[0] != 129 {
+		t.Fatalf("encoded = %x, want a little-endian uint64", data)
+	}
+
+	var decoded Goodbye
+	if err := decoded.UnmarshalSSZ(data); err != nil || decoded != GoodbyeTooManyPeers {
+		t.Errorf("decoded = %v, %v", decoded, err)
+	}
+	if err := decoded.UnmarshalSSZ(data[:4]); err == nil {
+		t.Error("expected error for truncated goodbye")
+	}
+}
+
+func TestBlocksByRangeRequestEncoding(t *testing.T) {
+	request := &BlocksByRangeRequest{StartSlot: 10, Count: 4, Step: 2}
+
+	data, err := request.MarshalSSZ()
+	if err != nil {
+		t.Fatalf("MarshalSSZ failed: %v", err)
+	}
+	var decoded BlocksByRangeRequest
+	if err := decoded.UnmarshalSSZ(data); err != nil || decoded != *request {
+		t.Fatalf("decoded = %+v, %v, want %+v", decoded, err, *request)
+	}
+	if err := decoded.UnmarshalSSZ(data[:16]); err == nil {
+		t.Error("expected error for truncated request")
+	}
+
+	for slot, want := range map[types.Slot]bool{9: false, 10: true, 11: false, 16: true, 18: false} {
+		if got := request.Contains(slot); got != want {
+			t.Errorf("Contains(%d) = %v, want %v", slot, got, want)
+		}
+	}
+
+	for _, tt := range []struct {
+		request BlocksByRangeRequest
+		want    error
+	}{
+		{BlocksByRangeRequest{StartSlot: 1, Count: MaxRequestBlocks, Step: 1}, nil},
+		{BlocksByRangeRequest{StartSlot: 1, Count: MaxRequestBlocks + 1, Step: 1}, ErrTooManyBlocks},
+		{BlocksByRangeRequest{StartSlot: 1, Count: 0, Step: 1}, ErrInvalidRequest},
+		{BlocksByRangeRequest{StartSlot: 1, Count: 1, Step: 0}, ErrInvalidRequest},
+		{BlocksByRangeRequest{StartSlot: 1, Count: 2, Step: 1 << 63}, ErrInvalidRequest},
+	} {
+		if err := tt.request.Validate(); err != tt.want {
+			t.Errorf("Validate(%+v) = %v, want %v", tt.request, err, tt.want)
+		}
+	}
+}
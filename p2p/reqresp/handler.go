@@ -8,37 +8,18 @@ import (
 
 // Protocol IDs for request/response messages
 const (
-	StatusProtocolV1       = "/leanconsensus/req/status/1/ssz_snappy"
-	BlocksByRootProtocolV1 = "/leanconsensus/req/blocks_by_root/1/ssz_snappy"
-	MaxRequestBlocks       = 1024 // 2^10
+	StatusProtocolV1        = "/leanconsensus/req/status/1/ssz_snappy"
+	BlocksByRootProtocolV1  = "/leanconsensus/req/blocks_by_root/1/ssz_snappy"
+	BlocksByRangeProtocolV1 = "/leanconsensus/req/blocks_by_range/1/ssz_snappy"
+	GoodbyeProtocolV1       = "/leanconsensus/req/goodbye/1/ssz_snappy"
+	MaxRequestBlocks        = 1024 // 2^10
 )
 
-// Status is the handshake message exchanged upon connection.
-// It allows nodes to verify compatibility and chain state.
-type Status struct {
-	Finalized types.Checkpoint
-	Head      types.Checkpoint
-}
-
-// BlocksByRootRequest is a request for blocks by their root hashes.
-type BlocksByRootRequest struct {
-	Roots []types.Root
-}
-
-// BlocksByRootResponse is the response containing requested signed blocks.
-type BlocksByRootResponse struct {
-	Blocks []*types.SignedBlock
-}
-
 // NewStatus creates a Status message from the current store state.
 func NewStatus(store *forkchoice.Store) *Status {
-	headBlock := store.Blocks[store.Head]
 	return &Status{
-		Finalized: store.LatestFinalized,
-		Head: types.Checkpoint{
-			Root: store.Head,
-			Slot: headBlock.Slot,
-		},
+		Finalized: store.Finalized(),
+		Head:      store.HeadCheckpoint(),
 	}
 }
 
@@ -52,6 +33,11 @@ func NewHandler(store *forkchoice.Store) *Handler {
 	return &Handler{store: store}
 }
 
+// Status returns our current status, as sent when initiating a handshake.
+func (h *Handler) Status() *Status {
+	return NewStatus(h.store)
+}
+
 // HandleStatus processes an incoming Status request.
 // Returns our current status for the handshake.
 func (h *Handler) HandleStatus(peerStatus *Status) *Status {
@@ -68,12 +54,7 @@ func (h *Handler) HandleBlocksByRoot(request *BlocksByRootRequest) *BlocksByRoot
 			break
 		}
 
-		if block, exists := h.store.Blocks[root]; exists {
-			// Wrap block in SignedBlock (signature is placeholder in Devnet 0)
-			signedBlock := &types.SignedBlock{
-				Message:   *block,
-				Signature: types.Root{},
-			}
+		if signedBlock, exists := h.store.SignedBlock(root); exists {
 			blocks = append(blocks, signedBlock)
 		}
 	}
@@ -81,28 +62,66 @@ func (h *Handler) HandleBlocksByRoot(request *BlocksByRootRequest) *BlocksByRoot
 	return &BlocksByRootResponse{Blocks: blocks}
 }
 
+// HandleBlocksByRange processes a BlocksByRange request.
+// Returns the canonical blocks in the requested slots that we have
+// available, oldest first; skipped slots have no block.
+func (h *Handler) HandleBlocksByRange(request *BlocksByRangeRequest) (*BlocksByRangeResponse, error) {
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	var blocks []*types.SignedBlock
+	for _, root := range h.store.CanonicalRoots(request.StartSlot, request.Count, request.Step) {
+		if signedBlock, exists := h.store.SignedBlock(root); exists {
+			blocks = append(blocks, signedBlock)
+		}
+	}
+	return &BlocksByRangeResponse{Blocks: blocks}, nil
+}
+
 // ValidatePeerStatus validates an incoming peer's status.
-// Returns an error if the peer is on a different chain or too far behind.
+// Returns an error if the peer's finalized checkpoint conflicts with ours.
+//
+// A peer finalized at or before our finalized slot must have finalized the
+// block of our canonical chain at that slot, when we hold it. A peer's
+// finalized block that we hold must also be at the slot it claims. Finalized
+// blocks we know nothing about are accepted: the peer may be ahead of us, or
+// behind the history we hold.
 func (h *Handler) ValidatePeerStatus(peerStatus *Status) error {
-	// For Devnet 0, basic validation:
-	// - Peer's finalized checkpoint should not conflict with ours
-	// - If we have a finalized block at peer's finalized slot, roots should match
-
-	if peerStatus.Finalized.Slot > 0 {
-		// Check if we have this slot in our history
-		if block, exists := h.store.Blocks[peerStatus.Finalized.Root]; exists {
-			if block.Slot != peerStatus.Finalized.Slot {
-				return ErrInvalidStatus
-			}
+	if peerStatus.Head.Slot < peerStatus.Finalized.Slot {
+		return ErrInvalidStatus
+	}
+	// The genesis checkpoint has a zero root, so slot 0 is not compared
+	if peerStatus.Finalized.Slot == 0 {
+		return nil
+	}
+
+	ours := h.store.Finalized()
+	if peerStatus.Finalized.Slot == ours.Slot && peerStatus.Finalized.Root != ours.Root {
+		return ErrFinalizedConflict
+	}
+	if peerStatus.Finalized.Slot < ours.Slot {
+		if root, ok := h.store.CanonicalRoot(peerStatus.Finalized.Slot); ok && root != peerStatus.Finalized.Root {
+			return ErrFinalizedConflict
 		}
 	}
 
+	if block, exists := h.store.SignedBlock(peerStatus.Finalized.Root); exists {
+		if block.Message.Slot != peerStatus.Finalized.Slot {
+			return ErrInvalidStatus
+		}
+	}
 	return nil
 }
 
 // Errors for req/resp handling
 var (
-	ErrInvalidStatus = &Error{Message: "invalid peer status"}
+	ErrInvalidStatus     = &Error{Message: "invalid peer status"}
+	ErrFinalizedConflict = &Error{Message: "finalized checkpoint conflicts with ours"}
+	ErrInvalidRequest    = &Error{Message: "invalid request"}
+	ErrTooManyRoots      = &Error{Message: "too many roots requested"}
+	ErrTooManyBlocks     = &Error{Message: "too many blocks requested"}
+	ErrPayloadTooLarge   = &Error{Message: "payload exceeds max size"}
 )
 
 // Error represents a request/response protocol error.
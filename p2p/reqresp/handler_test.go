@@ -3,35 +3,13 @@ package reqresp
 import (
 	"testing"
 
-	"github.com/devylongs/gean/chain"
-	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/chain/chaintest"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
 	"github.com/devylongs/gean/types"
 )
 
-func setupTestStore(t *testing.T) *forkchoice.Store {
-	genesisState := chain.GenerateGenesis(1000, 4)
-
-	genesisBlock := &types.Block{
-		Slot:          0,
-		ProposerIndex: 0,
-		ParentRoot:    types.Root{},
-		StateRoot:     types.Root{},
-		Body:          types.BlockBody{Attestations: []types.SignedVote{}},
-	}
-
-	stateRoot, _ := genesisState.HashTreeRoot()
-	genesisBlock.StateRoot = stateRoot
-
-	store, err := forkchoice.NewStore(genesisState, genesisBlock)
-	if err != nil {
-		t.Fatalf("NewStore failed: %v", err)
-	}
-
-	return store
-}
-
 func TestNewStatus(t *testing.T) {
-	store := setupTestStore(t)
+	store := forkchoicetest.Store(t)
 
 	status := NewStatus(store)
 
@@ -51,7 +29,7 @@ func TestNewStatus(t *testing.T) {
 }
 
 func TestHandleStatus(t *testing.T) {
-	store := setupTestStore(t)
+	store := forkchoicetest.Store(t)
 	handler := NewHandler(store)
 
 	peerStatus := &Status{
@@ -71,7 +49,7 @@ func TestHandleStatus(t *testing.T) {
 }
 
 func TestHandleBlocksByRoot(t *testing.T) {
-	store := setupTestStore(t)
+	store := forkchoicetest.Store(t)
 	handler := NewHandler(store)
 
 	// Request the genesis block
@@ -97,7 +75,7 @@ func TestHandleBlocksByRoot(t *testing.T) {
 }
 
 func TestHandleBlocksByRootUnknown(t *testing.T) {
-	store := setupTestStore(t)
+	store := forkchoicetest.Store(t)
 	handler := NewHandler(store)
 
 	// Request an unknown block
@@ -119,7 +97,7 @@ func TestHandleBlocksByRootUnknown(t *testing.T) {
 }
 
 func TestValidatePeerStatus(t *testing.T) {
-	store := setupTestStore(t)
+	store := forkchoicetest.Store(t)
 	handler := NewHandler(store)
 
 	// Valid status (genesis)
@@ -133,3 +111,76 @@ func TestValidatePeerStatus(t *testing.T) {
 		t.Errorf("ValidatePeerStatus failed for valid status: %v", err)
 	}
 }
+
+func TestValidatePeerStatusConflict(t *testing.T) {
+	store := forkchoicetest.Store(t)
+	genesisRoot := store.Head
+	store.LatestFinalized = types.Checkpoint{Root: types.Root{9}, Slot: 5}
+	handler := NewHandler(store)
+
+	tests := []struct {
+		name      string
+		finalized types.Checkpoint
+		headSlot  types.Slot
+		want      error
+	}{
+		{"same finalized", types.Checkpoint{Root: types.Root{9}, Slot: 5}, 6, nil},
+		{"conflicting finalized", types.Checkpoint{Root: types.Root{8}, Slot: 5}, 6, ErrFinalizedConflict},
+		{"unknown finalized ahead", types.Checkpoint{Root: types.Root{8}, Slot: 7}, 7, nil},
+		{"known block at wrong slot", types.Checkpoint{Root: genesisRoot, Slot: 3}, 6, ErrInvalidStatus},
+		{"head behind finalized", types.Checkpoint{Root: types.Root{9}, Slot: 5}, 4, ErrInvalidStatus},
+	}
+	for _, tt := range tests {
+		status := &Status{Finalized: tt.finalized, Head: types.Checkpoint{Slot: tt.headSlot}}
+		if err := handler.ValidatePeerStatus(status); err != tt.want {
+			t.Errorf("%s: ValidatePeerStatus = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestValidatePeerStatusCanonical(t *testing.T) {
+	store := forkchoicetest.Store(t)
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(6)...)
+	root2, _ := store.CanonicalRoot(2)
+	root4, _ := store.CanonicalRoot(4)
+	store.LatestFinalized = types.Checkpoint{Root: root4, Slot: 4}
+	handler := NewHandler(store)
+
+	tests := []struct {
+		name      string
+		finalized types.Checkpoint
+		want      error
+	}{
+		{"canonical block behind our finalized", types.Checkpoint{Root: root2, Slot: 2}, nil},
+		{"other block behind our finalized", types.Checkpoint{Root: types.Root{7}, Slot: 2}, ErrFinalizedConflict},
+		{"canonical block at the wrong slot", types.Checkpoint{Root: root2, Slot: 3}, ErrFinalizedConflict},
+	}
+	for _, tt := range tests {
+		status := &Status{Finalized: tt.finalized, Head: types.Checkpoint{Slot: 6}}
+		if err := handler.ValidatePeerStatus(status); err != tt.want {
+			t.Errorf("%s: ValidatePeerStatus = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestHandleBlocksByRange(t *testing.T) {
+	store := forkchoicetest.Store(t)
+	handler := NewHandler(store)
+
+	response, err := handler.HandleBlocksByRange(&BlocksByRangeRequest{StartSlot: 0, Count: 10, Step: 1})
+	if err != nil {
+		t.Fatalf("HandleBlocksByRange failed: %v", err)
+	}
+	if len(response.Blocks) != 1 || response.Blocks[0].Message.Slot != 0 {
+		t.Errorf("got %d blocks, want only genesis", len(response.Blocks))
+	}
+
+	response, err = handler.HandleBlocksByRange(&BlocksByRangeRequest{StartSlot: 1, Count: 10, Step: 1})
+	if err != nil || len(response.Blocks) != 0 {
+		t.Errorf("range after the head = %v, %v, want no blocks", response, err)
+	}
+
+	if _, err := handler.HandleBlocksByRange(&BlocksByRangeRequest{Count: MaxRequestBlocks + 1, Step: 1}); err != ErrTooManyBlocks {
+		t.Errorf("oversized request error = %v, want ErrTooManyBlocks", err)
+	}
+}
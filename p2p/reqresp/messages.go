@@ -0,0 +1,161 @@
+package reqresp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/devylongs/gean/types"
+)
+
+//go:generate go run github.com/ferranbt/fastssz/sszgen --path=. --include=../../types --objs=Status
+
+// Status is the handshake message exchanged upon connection.
+// It allows nodes to verify compatibility and chain state.
+type Status struct {
+	Finalized types.Checkpoint
+	Head      types.Checkpoint
+}
+
+// BlocksByRootRequest is a request for blocks by their root hashes.
+// On the wire it is encoded as an SSZ List[Root, MaxRequestBlocks].
+type BlocksByRootRequest struct {
+	Roots []types.Root
+}
+
+// BlocksByRootResponse is the response containing requested signed blocks.
+type BlocksByRootResponse struct {
+	Blocks []*types.SignedBlock
+}
+
+// MarshalSSZ encodes the request as a list of 32-byte roots.
+func (r *BlocksByRootRequest) MarshalSSZ() ([]byte, error) {
+	if len(r.Roots) > MaxRequestBlocks {
+		return nil, ErrTooManyRoots
+	}
+	buf := make([]byte, 0, len(r.Roots)*32)
+	for _, root := range r.Roots {
+		buf = append(buf, root[:]...)
+	}
+	return buf, nil
+}
+
+// UnmarshalSSZ decodes a list of 32-byte roots.
+func (r *BlocksByRootRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf)%32 != 0 {
+		return ErrInvalidRequest
+	}
+	if len(buf)/32 > MaxRequestBlocks {
+		return ErrTooManyRoots
+	}
+	r.Roots = make([]types.Root, len(buf)/32)
+	for i := range r.Roots {
+		copy(r.Roots[i][:], buf[i*32:(i+1)*32])
+	}
+	return nil
+}
+
+// BlocksByRangeRequest is a request for the canonical blocks in Count slots
+// from StartSlot, Step slots apart.
+type BlocksByRangeRequest struct {
+	StartSlot types.Slot
+	Count     uint64
+	Step      uint64
+}
+
+// BlocksByRangeResponse is the response containing the blocks found in the
+// requested slots, oldest first.
+type BlocksByRangeResponse struct {
+	Blocks []*types.SignedBlock
+}
+
+// Validate checks the request is within MaxRequestBlocks and its last slot
+// does not overflow.
+func (r *BlocksByRangeRequest) Validate() error {
+	if r.Count == 0 || r.Step == 0 {
+		return ErrInvalidRequest
+	}
+	if r.Count > MaxRequestBlocks {
+		return ErrTooManyBlocks
+	}
+	if r.Step > (math.MaxUint64-uint64(r.StartSlot))/r.Count {
+		return ErrInvalidRequest
+	}
+	return nil
+}
+
+// Contains reports whether slot is one of the requested slots.
+func (r *BlocksByRangeRequest) Contains(slot types.Slot) bool {
+	if slot < r.StartSlot || r.Step == 0 {
+		return false
+	}
+	offset := uint64(slot - r.StartSlot)
+	return offset%r.Step == 0 && offset/r.Step < r.Count
+}
+
+// MarshalSSZ encodes the request as three little-endian uint64s.
+func (r *BlocksByRangeRequest) MarshalSSZ() ([]byte, error) {
+	buf := make([]byte, 0, 24)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(r.StartSlot))
+	buf = binary.LittleEndian.AppendUint64(buf, r.Count)
+	buf = binary.LittleEndian.AppendUint64(buf, r.Step)
+	return buf, nil
+}
+
+// UnmarshalSSZ decodes three little-endian uint64s.
+func (r *BlocksByRangeRequest) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != 24 {
+		return ErrInvalidRequest
+	}
+	r.StartSlot = types.Slot(binary.LittleEndian.Uint64(buf[0:8]))
+	r.Count = binary.LittleEndian.Uint64(buf[8:16])
+	r.Step = binary.LittleEndian.Uint64(buf[16:24])
+	return nil
+}
+
+// Goodbye is sent to a peer before disconnecting from it, carrying the
+// reason as an SSZ uint64.
+type Goodbye uint64
+
+// Goodbye reason codes (per networking spec, with client codes from 128)
+const (
+	GoodbyeClientShutdown    Goodbye = 1
+	GoodbyeIrrelevantNetwork Goodbye = 2
+	GoodbyeFault             Goodbye = 3
+	GoodbyeTooManyPeers      Goodbye = 129
+	GoodbyeBadScore          Goodbye = 250
+	GoodbyeBanned            Goodbye = 251
+)
+
+func (g Goodbye) String() string {
+	switch g {
+	case GoodbyeClientShutdown:
+		return "client shutdown"
+	case GoodbyeIrrelevantNetwork:
+		return "irrelevant network"
+	case GoodbyeFault:
+		return "fault or error"
+	case GoodbyeTooManyPeers:
+		return "too many peers"
+	case GoodbyeBadScore:
+		return "bad score"
+	case GoodbyeBanned:
+		return "banned"
+	default:
+		return fmt.Sprintf("unknown (%d)", uint64(g))
+	}
+}
+
+// MarshalSSZ encodes the reason as a little-endian uint64.
+func (g Goodbye) MarshalSSZ() ([]byte, error) {
+	return binary.LittleEndian.AppendUint64(nil, uint64(g)), nil
+}
+
+// UnmarshalSSZ decodes a little-endian uint64 reason.
+func (g *Goodbye) UnmarshalSSZ(buf []byte) error {
+	if len(buf) != 8 {
+		return ErrInvalidRequest
+	}
+	*g = Goodbye(binary.LittleEndian.Uint64(buf))
+	return nil
+}
@@ -0,0 +1,85 @@
+// Code generated by fastssz. DO NOT EDIT.
+// Hash: 67e5f750075b26f7e984a68284e408ab5cdac1585cc3cb14180fd77a43110cd2
+// Version: 0.1.3
+package reqresp
+
+import (
+	ssz "github.com/ferranbt/fastssz"
+)
+
+// MarshalSSZ ssz marshals the Status object
+func (s *Status) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(s)
+}
+
+// MarshalSSZTo ssz marshals the Status object to a target array
+func (s *Status) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+
+	// Field (0) 'Finalized'
+	if dst, err = s.Finalized.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	// Field (1) 'Head'
+	if dst, err = s.Head.MarshalSSZTo(dst); err != nil {
+		return
+	}
+
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the Status object
+func (s *Status) UnmarshalSSZ(buf []byte) error {
+	var err error
+	size := uint64(len(buf))
+	if size != 80 {
+		return ssz.ErrSize
+	}
+
+	// Field (0) 'Finalized'
+	if err = s.Finalized.UnmarshalSSZ(buf[0:40]); err != nil {
+		return err
+	}
+
+	// Field (1) 'Head'
+	if err = s.Head.UnmarshalSSZ(buf[40:80]); err != nil {
+		return err
+	}
+
+	return err
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the Status object
+func (s *Status) SizeSSZ() (size int) {
+	size = 80
+	return
+}
+
+// HashTreeRoot ssz hashes the Status object
+func (s *Status) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(s)
+}
+
+// HashTreeRootWith ssz hashes the Status object with a hasher
+func (s *Status) HashTreeRootWith(hh ssz.HashWalker) (err error) {
+	indx := hh.Index()
+
+	// Field (0) 'Finalized'
+	if err = s.Finalized.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	// Field (1) 'Head'
+	if err = s.Head.HashTreeRootWith(hh); err != nil {
+		return
+	}
+
+	hh.Merkleize(indx)
+	return
+}
+
+// GetTree ssz hashes the Status object
+func (s *Status) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(s)
+}
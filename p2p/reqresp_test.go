@@ -0,0 +1,87 @@
+package p2p
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// newReqRespService starts a service on a loopback QUIC host that serves
+// req/resp from a genesis store.
+func newReqRespService(t *testing.T) (*Service, *forkchoice.Store) {
+	t.Helper()
+	store := forkchoicetest.Store(t)
+	h, err := NewHost(context.Background(), HostConfig{ListenAddrs: []string{"/ip4/127.0.0.1/udp/0/quic-v1"}})
+	if err != nil {
+		t.Fatalf("NewHost failed: %v", err)
+	}
+	t.Cleanup(func() { h.Close() })
+	svc, err := NewService(context.Background(), ServiceConfig{Host: h, ReqResp: reqresp.NewHandler(store)})
+	if err != nil {
+		t.Fatalf("NewService failed: %v", err)
+	}
+	t.Cleanup(svc.cancel)
+	return svc, store
+}
+
+func TestStatusAndBlocksByRootStreams(t *testing.T) {
+	server, store := newReqRespService(t)
+	client, _ := newReqRespService(t)
+	if err := client.host.Connect(context.Background(), peer.AddrInfo{ID: server.host.ID(), Addrs: server.host.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	status, err := client.RequestStatus(context.Background(), server.host.ID())
+	if err != nil {
+		t.Fatalf("RequestStatus failed: %v", err)
+	}
+	if status.Head.Root != store.Head {
+		t.Errorf("status head = %x, want the server's head", status.Head.Root[:4])
+	}
+
+	blocks, err := client.RequestBlocksByRoot(context.Background(), server.host.ID(), []types.Root{{1}, store.Head})
+	if err != nil {
+		t.Fatalf("RequestBlocksByRoot failed: %v", err)
+	}
+	if len(blocks) != 1 {
+		t.Fatalf("got %d blocks, want only the known genesis block", len(blocks))
+	}
+	if root, _ := blocks[0].Message.HashTreeRoot(); root != store.Head {
+		t.Errorf("block root = %x, want the genesis root", root[:4])
+	}
+}
+
+func TestRequestBlocksByRange(t *testing.T) {
+	server, _ := newStatusService(t, newChainStore(t, 1, 2, 4, 5))
+	client := newTestService(t, ServiceConfig{ReqResp: reqresp.NewHandler(newChainStore(t))})
+	if err := client.host.Connect(context.Background(), peer.AddrInfo{ID: server.host.ID(), Addrs: server.host.Addrs()}); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	blocks, err := client.RequestBlocksByRange(context.Background(), server.host.ID(), 1, 5, 1)
+	if err != nil {
+		t.Fatalf("RequestBlocksByRange failed: %v", err)
+	}
+	var slots []uint64
+	for _, block := range blocks {
+		slots = append(slots, uint64(block.Message.Slot))
+	}
+	if len(slots) != 4 || slots[0] != 1 || slots[2] != 4 || slots[3] != 5 {
+		t.Errorf("slots = %v, want 1, 2, 4 and 5", slots)
+	}
+
+	// Slot 3 was skipped
+	blocks, err = client.RequestBlocksByRange(context.Background(), server.host.ID(), 1, 3, 2)
+	if err != nil || len(blocks) != 2 || blocks[1].Message.Slot != 5 {
+		t.Errorf("step 2 returned %d blocks, %v, want slots 1 and 5", len(blocks), err)
+	}
+
+	if _, err := client.RequestBlocksByRange(context.Background(), server.host.ID(), 0, reqresp.MaxRequestBlocks+1, 1); err == nil {
+		t.Error("oversized request succeeded")
+	}
+}
@@ -0,0 +1,180 @@
+// Package rlp implements the subset of Recursive Length Prefix encoding
+// used by node records and the discovery protocol: byte strings, unsigned
+// integers and lists.
+package rlp
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/bits"
+)
+
+// Decoding errors
+var (
+	ErrUnexpectedEnd = errors.New("rlp: value size exceeds available input")
+	ErrNonCanonical  = errors.New("rlp: non-canonical encoding")
+	ErrExpectedList  = errors.New("rlp: expected list")
+	ErrExpectedBytes = errors.New("rlp: expected byte string")
+	ErrUintOverflow  = errors.New("rlp: integer exceeds 64 bits")
+)
+
+// Kind is the kind of an encoded value.
+type Kind int
+
+// Kinds
+const (
+	Byte Kind = iota // single byte below 0x80, encoded as itself
+	String
+	List
+)
+
+// EncodeBytes encodes a byte string.
+func EncodeBytes(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return []byte{b[0]}
+	}
+	return append(header(0x80, len(b)), b...)
+}
+
+// EncodeUint encodes an unsigned integer as its minimal big-endian bytes.
+func EncodeUint(u uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], u)
+	return EncodeBytes(buf[bits.LeadingZeros64(u)/8:])
+}
+
+// EncodeList encodes a list of already encoded items.
+func EncodeList(items ...[]byte) []byte {
+	size := 0
+	for _, item := range items {
+		size += len(item)
+	}
+	out := header(0xc0, size)
+	for _, item := range items {
+		out = append(out, item...)
+	}
+	return out
+}
+
+// header returns the prefix of a string (0x80) or list (0xc0) of size bytes.
+func header(offset byte, size int) []byte {
+	if size < 56 {
+		return []byte{offset + byte(size)}
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(size))
+	sizeBytes := buf[bits.LeadingZeros64(uint64(size))/8:]
+	return append([]byte{offset + 55 + byte(len(sizeBytes))}, sizeBytes...)
+}
+
+// Split returns the kind and content of the first value in b and the input
+// following it.
+func Split(b []byte) (kind Kind, content, rest []byte, err error) {
+	if len(b) == 0 {
+		return 0, nil, nil, ErrUnexpectedEnd
+	}
+	prefix := b[0]
+	var offset, size uint64
+	switch {
+	case prefix < 0x80:
+		return Byte, b[:1], b[1:], nil
+	case prefix < 0xb8:
+		kind, offset, size = String, 1, uint64(prefix-0x80)
+		if size == 1 && len(b) > 1 && b[1] < 0x80 {
+			return 0, nil, nil, ErrNonCanonical
+		}
+	case prefix < 0xc0:
+		kind = String
+		if offset, size, err = longSize(b, prefix-0xb7); err != nil {
+			return 0, nil, nil, err
+		}
+	case prefix < 0xf8:
+		kind, offset, size = List, 1, uint64(prefix-0xc0)
+	default:
+		kind = List
+		if offset, size, err = longSize(b, prefix-0xf7); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	if size > uint64(len(b))-offset {
+		return 0, nil, nil, ErrUnexpectedEnd
+	}
+	return kind, b[offset : offset+size], b[offset+size:], nil
+}
+
+// longSize reads the size of a value whose size takes n bytes.
+func longSize(b []byte, n byte) (offset, size uint64, err error) {
+	if uint64(len(b)) < 1+uint64(n) {
+		return 0, 0, ErrUnexpectedEnd
+	}
+	if b[1] == 0 {
+		return 0, 0, ErrNonCanonical
+	}
+	for _, c := range b[1 : 1+n] {
+		if size>>56 != 0 {
+			return 0, 0, ErrUintOverflow
+		}
+		size = size<<8 | uint64(c)
+	}
+	if size < 56 {
+		return 0, 0, ErrNonCanonical
+	}
+	return 1 + uint64(n), size, nil
+}
+
+// SplitBytes returns the content of the byte string at the start of b.
+func SplitBytes(b []byte) (content, rest []byte, err error) {
+	kind, content, rest, err := Split(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if kind == List {
+		return nil, nil, ErrExpectedBytes
+	}
+	return content, rest, nil
+}
+
+// SplitUint returns the unsigned integer at the start of b.
+func SplitUint(b []byte) (uint64, []byte, error) {
+	content, rest, err := SplitBytes(b)
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(content) > 8 {
+		return 0, nil, ErrUintOverflow
+	}
+	if len(content) > 0 && content[0] == 0 {
+		return 0, nil, ErrNonCanonical
+	}
+	var u uint64
+	for _, c := range content {
+		u = u<<8 | uint64(c)
+	}
+	return u, rest, nil
+}
+
+// SplitList returns the content of the list at the start of b.
+func SplitList(b []byte) (content, rest []byte, err error) {
+	kind, content, rest, err := Split(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	if kind != List {
+		return nil, nil, ErrExpectedList
+	}
+	return content, rest, nil
+}
+
+// Items splits the content of a list into its encoded items.
+func Items(content []byte) ([][]byte, error) {
+	var items [][]byte
+	for len(content) > 0 {
+		_, _, rest, err := Split(content)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, content[:len(content)-len(rest)])
+		content = rest
+	}
+	return items, nil
+}
@@ -0,0 +1,65 @@
+package rlp
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func TestEncode(t *testing.T) {
+	long := bytes.Repeat([]byte{'a'}, 56)
+	for _, tc := range []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"empty string", EncodeBytes(nil), "80"},
+		{"single byte", EncodeBytes([]byte{0x7f}), "7f"},
+		{"byte above 0x7f", EncodeBytes([]byte{0x80}), "8180"},
+		{"dog", EncodeBytes([]byte("dog")), "83646f67"},
+		{"long string", EncodeBytes(long), "b838" + hex.EncodeToString(long)},
+		{"zero", EncodeUint(0), "80"},
+		{"small uint", EncodeUint(15), "0f"},
+		{"uint", EncodeUint(1024), "820400"},
+		{"empty list", EncodeList(), "c0"},
+		{"cat dog", EncodeList(EncodeBytes([]byte("cat")), EncodeBytes([]byte("dog"))), "c88363617483646f67"},
+	} {
+		if got := hex.EncodeToString(tc.got); got != tc.want {
+			t.Errorf("%s: got %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	enc := EncodeList(EncodeUint(1024), EncodeBytes([]byte("dog")), EncodeList())
+	content, rest, err := SplitList(enc)
+	if err != nil || len(rest) != 0 {
+		t.Fatalf("SplitList: %v, %d bytes left", err, len(rest))
+	}
+	items, err := Items(content)
+	if err != nil || len(items) != 3 {
+		t.Fatalf("Items: %d items, err %v", len(items), err)
+	}
+	if u, _, err := SplitUint(items[0]); err != nil || u != 1024 {
+		t.Errorf("SplitUint = %d, %v, want 1024", u, err)
+	}
+	if b, _, err := SplitBytes(items[1]); err != nil || string(b) != "dog" {
+		t.Errorf("SplitBytes = %q, %v, want dog", b, err)
+	}
+	if _, _, err := SplitBytes(items[2]); !errors.Is(err, ErrExpectedBytes) {
+		t.Errorf("SplitBytes of a list: err %v", err)
+	}
+
+	for name, in := range map[string]string{
+		"truncated":          "83646f",
+		"non-canonical byte": "8105",
+		"leading zero uint":  "820001",
+		"short long form":    "b801ff",
+	} {
+		b, _ := hex.DecodeString(in)
+		if _, _, err := SplitUint(b); err == nil {
+			t.Errorf("%s: decoded without error", name)
+		}
+	}
+}
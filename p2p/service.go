@@ -2,14 +2,19 @@ package p2p
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"sync"
 
+	"github.com/devylongs/gean/p2p/gossipsub"
+	"github.com/devylongs/gean/p2p/reqresp"
 	"github.com/devylongs/gean/types"
 	"github.com/libp2p/go-libp2p/core/host"
+	"github.com/libp2p/go-libp2p/core/network"
 	"github.com/libp2p/go-libp2p/core/peer"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/multiformats/go-multiaddr"
 )
 
 // Service manages p2p networking for the consensus client.
@@ -17,8 +22,16 @@ type Service struct {
 	host     host.Host
 	pubsub   *pubsub.PubSub
 	handlers *MessageHandlers
+	reqresp  *reqresp.Handler
+	chain    Chain
 	logger   *slog.Logger
 
+	discovery Discovery
+	peers     *peerManager
+
+	scoresMu sync.RWMutex
+	scores   map[peer.ID]float64
+
 	blockTopic *pubsub.Topic
 	blockSub   *pubsub.Subscription
 	voteTopic  *pubsub.Topic
@@ -33,8 +46,18 @@ type Service struct {
 type ServiceConfig struct {
 	Host      host.Host
 	Handlers  *MessageHandlers
+	ReqResp   *reqresp.Handler
+	Chain     Chain             // nil skips consensus checks in gossip validation
+	Gossip    *gossipsub.Params // nil uses gossipsub.DefaultParams
 	Bootnodes []peer.AddrInfo
+	Discovery Discovery // nil dials only bootnodes and previously connected peers
 	Logger    *slog.Logger
+
+	// TargetPeers is the peer count below which known and discovered peers
+	// are dialed. Zero uses DefaultTargetPeers.
+	TargetPeers int
+	// MaxInboundPeers caps peers that dialed us. Zero uses DefaultMaxInboundPeers.
+	MaxInboundPeers int
 }
 
 // NewService creates a new p2p service.
@@ -46,12 +69,46 @@ func NewService(ctx context.Context, cfg ServiceConfig) (*Service, error) {
 		logger = slog.Default()
 	}
 
+	params := gossipsub.DefaultParams()
+	if cfg.Gossip != nil {
+		params = *cfg.Gossip
+	}
+
+	svc := &Service{
+		host:     cfg.Host,
+		handlers: cfg.Handlers,
+		reqresp:  cfg.ReqResp,
+		chain:    cfg.Chain,
+		logger:   logger,
+		scores:   make(map[peer.ID]float64),
+		ctx:      ctx,
+		cancel:   cancel,
+
+		discovery: cfg.Discovery,
+	}
+	svc.peers = newPeerManager(svc, cfg, cfg.Bootnodes)
+	if params.Scoring.Enabled {
+		svc.peers.scorePruned = true
+		svc.peers.pruneScore = params.Scoring.GraylistThreshold
+	}
+
 	// Create gossipsub
-	ps, err := NewGossipSub(ctx, cfg.Host)
+	var extra []pubsub.Option
+	if params.Scoring.Enabled {
+		extra = append(extra, pubsub.WithPeerScoreInspect(svc.updateScores, ScoreInspectInterval))
+	}
+	ps, err := NewGossipSub(ctx, cfg.Host, params, extra...)
 	if err != nil {
 		cancel()
 		return nil, fmt.Errorf("create gossipsub: %w", err)
 	}
+	svc.pubsub = ps
+
+	// Validate messages before they are forwarded to the mesh
+	if err := svc.registerValidators(); err != nil {
+		cancel()
+		return nil, err
+	}
 
 	// Join topics
 	blockTopic, err := ps.Join(BlockTopic)
@@ -79,48 +136,44 @@ func NewService(ctx context.Context, cfg ServiceConfig) (*Service, error) {
 		return nil, fmt.Errorf("subscribe vote topic: %w", err)
 	}
 
-	svc := &Service{
-		host:       cfg.Host,
-		pubsub:     ps,
-		handlers:   cfg.Handlers,
-		logger:     logger,
-		blockTopic: blockTopic,
-		blockSub:   blockSub,
-		voteTopic:  voteTopic,
-		voteSub:    voteSub,
-		ctx:        ctx,
-		cancel:     cancel,
-	}
-
-	// Connect to bootnodes
-	for _, pi := range cfg.Bootnodes {
-		if err := cfg.Host.Connect(ctx, pi); err != nil {
-			logger.Warn("failed to connect to bootnode",
-				"peer", pi.ID,
-				"error", err,
-			)
-		} else {
-			logger.Info("connected to bootnode", "peer", pi.ID)
-		}
+	svc.blockTopic = blockTopic
+	svc.blockSub = blockSub
+	svc.voteTopic = voteTopic
+	svc.voteSub = voteSub
+
+	if svc.reqresp != nil {
+		svc.registerReqResp()
 	}
 
 	return svc, nil
 }
 
-// Start begins processing incoming messages.
+// Start begins processing incoming messages and managing peers. Peers that
+// connected before Start are handshaked now.
 func (s *Service) Start() {
-	s.wg.Add(2)
+	s.host.Network().Notify(s.peers.notifiee)
+	for _, conn := range s.host.Network().Conns() {
+		s.peers.connectedF(s.host.Network(), conn)
+	}
+
+	s.wg.Add(3)
 	go s.processBlocks()
 	go s.processVotes()
+	go s.peers.run(s.ctx)
 	s.logger.Info("p2p service started",
 		"peer_id", s.host.ID(),
 		"addrs", s.host.Addrs(),
 	)
 }
 
-// Stop shuts down the p2p service.
+// Stop says goodbye to every peer and shuts down the p2p service.
 func (s *Service) Stop() {
+	s.host.Network().StopNotify(s.peers.notifiee)
+	s.peers.disconnectAll(reqresp.GoodbyeClientShutdown)
 	s.cancel()
+	if s.reqresp != nil {
+		s.unregisterReqResp()
+	}
 	s.blockSub.Cancel()
 	s.voteSub.Cancel()
 	s.wg.Wait()
@@ -148,11 +201,87 @@ func (s *Service) PublishVote(ctx context.Context, vote *types.SignedVote) error
 	return s.voteTopic.Publish(ctx, compressed)
 }
 
+// PublishVotes publishes a batch of votes, continuing past failures. The
+// returned error joins the failures of individual votes.
+func (s *Service) PublishVotes(ctx context.Context, votes []*types.SignedVote) error {
+	var errs []error
+	for _, vote := range votes {
+		if err := s.PublishVote(ctx, vote); err != nil {
+			errs = append(errs, fmt.Errorf("validator %d: %w", vote.Data.ValidatorID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ID returns the local peer ID.
+func (s *Service) ID() peer.ID {
+	return s.host.ID()
+}
+
+// Addrs returns the addresses the host listens on.
+func (s *Service) Addrs() []multiaddr.Multiaddr {
+	return s.host.Addrs()
+}
+
 // PeerCount returns the number of connected peers.
 func (s *Service) PeerCount() int {
 	return len(s.host.Network().Peers())
 }
 
+// PeerCountByDirection returns the number of connected peers that dialed us
+// and that we dialed. A peer with connections both ways counts as outbound.
+func (s *Service) PeerCountByDirection() (inbound, outbound int) {
+	for _, pid := range s.host.Network().Peers() {
+		dir := network.DirInbound
+		for _, conn := range s.host.Network().ConnsToPeer(pid) {
+			if conn.Stat().Direction == network.DirOutbound {
+				dir = network.DirOutbound
+			}
+		}
+		if dir == network.DirOutbound {
+			outbound++
+		} else {
+			inbound++
+		}
+	}
+	return inbound, outbound
+}
+
+// Peers returns the IDs of all connected peers.
+func (s *Service) Peers() []peer.ID {
+	return s.host.Network().Peers()
+}
+
+// PeerInfos returns the connected peers and what we know about them.
+func (s *Service) PeerInfos() []PeerInfo {
+	return s.peers.infos()
+}
+
+// PeerScores returns the latest gossipsub score of each peer. Empty when
+// peer scoring is disabled.
+func (s *Service) PeerScores() map[peer.ID]float64 {
+	s.scoresMu.RLock()
+	defer s.scoresMu.RUnlock()
+	scores := make(map[peer.ID]float64, len(s.scores))
+	for pid, score := range s.scores {
+		scores[pid] = score
+	}
+	return scores
+}
+
+// updateScores receives periodic score snapshots from gossipsub.
+func (s *Service) updateScores(scores map[peer.ID]float64) {
+	s.scoresMu.Lock()
+	s.scores = scores
+	s.scoresMu.Unlock()
+
+	for pid, score := range scores {
+		if score < 0 {
+			s.logger.Debug("negative peer score", "peer", pid, "score", score)
+		}
+	}
+}
+
 // processBlocks handles incoming block messages.
 func (s *Service) processBlocks() {
 	defer s.wg.Done()
@@ -172,8 +301,14 @@ func (s *Service) processBlocks() {
 			continue
 		}
 
+		// Decoded by the topic validator
+		block, ok := msg.ValidatorData.(*types.SignedBlock)
+		if !ok {
+			continue
+		}
+
 		if s.handlers != nil {
-			if err := s.handlers.HandleBlockMessage(s.ctx, msg.Data); err != nil {
+			if err := s.handlers.HandleBlock(s.ctx, msg.ReceivedFrom, block); err != nil {
 				s.logger.Error("handle block error", "error", err)
 			}
 		}
@@ -199,8 +334,14 @@ func (s *Service) processVotes() {
 			continue
 		}
 
+		// Decoded by the topic validator
+		vote, ok := msg.ValidatorData.(*types.SignedVote)
+		if !ok {
+			continue
+		}
+
 		if s.handlers != nil {
-			if err := s.handlers.HandleVoteMessage(s.ctx, msg.Data); err != nil {
+			if err := s.handlers.HandleVote(s.ctx, vote); err != nil {
 				s.logger.Error("handle vote error", "error", err)
 			}
 		}
@@ -0,0 +1,206 @@
+package p2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/metrics"
+	"github.com/devylongs/gean/types"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Chain is the view of consensus state gossip messages are validated against.
+// *forkchoice.Store satisfies it.
+type Chain interface {
+	CurrentSlot() types.Slot
+	NumValidators() uint64
+	Finalized() types.Checkpoint
+	HasBlock(root types.Root) bool
+	VerifyBlockSignature(block *types.SignedBlock) error
+	ValidateAttestation(vote *types.SignedVote) error
+}
+
+// Gossip validation limits
+const (
+	MaxClockDisparitySlots = 1               // Slots a message may be ahead of our clock
+	ValidationTimeout      = 2 * time.Second // Per-message validation budget
+)
+
+// Gossip validation errors
+var (
+	ErrFutureSlot       = errors.New("slot too far in future")
+	ErrFinalizedSlot    = errors.New("slot at or before finalized checkpoint")
+	ErrInvalidProposer  = errors.New("invalid proposer index")
+	ErrDuplicateBlock   = errors.New("block already known")
+	ErrUnknownParent    = errors.New("unknown parent block")
+	ErrInvalidValidator = errors.New("validator index out of range")
+)
+
+// ValidateBlock runs the consensus checks on a gossiped block. Blocks that
+// may become valid later (future slot, unknown parent) or add nothing
+// (duplicates, finalized history) are ignored; provably bad ones rejected.
+// The proposer signature only needs the validator registry, so it is checked
+// before the parent: an orphan is queued only if its proposer signed it.
+// Attestation signatures are checked on import.
+func ValidateBlock(chain Chain, block *types.SignedBlock) (pubsub.ValidationResult, error) {
+	msg := &block.Message
+
+	if msg.Slot > chain.CurrentSlot()+MaxClockDisparitySlots {
+		return pubsub.ValidationIgnore, fmt.Errorf("%w: %d", ErrFutureSlot, msg.Slot)
+	}
+	if msg.Slot <= chain.Finalized().Slot {
+		return pubsub.ValidationIgnore, fmt.Errorf("%w: %d", ErrFinalizedSlot, msg.Slot)
+	}
+	if expected := uint64(msg.Slot) % chain.NumValidators(); msg.ProposerIndex != expected {
+		return pubsub.ValidationReject, fmt.Errorf("%w: %d, expected %d", ErrInvalidProposer, msg.ProposerIndex, expected)
+	}
+
+	root, err := msg.HashTreeRoot()
+	if err != nil {
+		return pubsub.ValidationReject, fmt.Errorf("hash block: %w", err)
+	}
+	if chain.HasBlock(root) {
+		return pubsub.ValidationIgnore, ErrDuplicateBlock
+	}
+	if err := chain.VerifyBlockSignature(block); err != nil {
+		return pubsub.ValidationReject, err
+	}
+	if !chain.HasBlock(msg.ParentRoot) {
+		return pubsub.ValidationIgnore, ErrUnknownParent
+	}
+
+	return pubsub.ValidationAccept, nil
+}
+
+// ValidateVote runs the consensus checks on a gossiped vote, including its
+// signature. Votes for blocks we have not seen yet are ignored rather than
+// rejected.
+func ValidateVote(chain Chain, vote *types.SignedVote) (pubsub.ValidationResult, error) {
+	if vote.Data.ValidatorID >= chain.NumValidators() {
+		return pubsub.ValidationReject, fmt.Errorf("%w: %d", ErrInvalidValidator, vote.Data.ValidatorID)
+	}
+
+	if err := chain.ValidateAttestation(vote); err != nil {
+		if errors.Is(err, forkchoice.ErrUnknownVoteRoot) || errors.Is(err, forkchoice.ErrFutureVote) {
+			return pubsub.ValidationIgnore, err
+		}
+		return pubsub.ValidationReject, err
+	}
+
+	return pubsub.ValidationAccept, nil
+}
+
+// registerValidators installs the block and vote topic validators.
+func (s *Service) registerValidators() error {
+	if err := s.pubsub.RegisterTopicValidator(BlockTopic, s.countResults("block", s.validateBlockMessage),
+		pubsub.WithValidatorTimeout(ValidationTimeout)); err != nil {
+		return fmt.Errorf("register block validator: %w", err)
+	}
+	if err := s.pubsub.RegisterTopicValidator(VoteTopic, s.countResults("vote", s.validateVoteMessage),
+		pubsub.WithValidatorTimeout(ValidationTimeout)); err != nil {
+		return fmt.Errorf("register vote validator: %w", err)
+	}
+	return nil
+}
+
+// countResults wraps a topic validator to count the messages received from
+// peers by validation result.
+func (s *Service) countResults(topic string, validate pubsub.ValidatorEx) pubsub.ValidatorEx {
+	return func(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+		result := validate(ctx, from, msg)
+		if from != s.host.ID() {
+			metrics.GossipMessages.WithLabelValues(topic, resultLabel(result)).Inc()
+		}
+		return result
+	}
+}
+
+func resultLabel(result pubsub.ValidationResult) string {
+	switch result {
+	case pubsub.ValidationAccept:
+		return "accept"
+	case pubsub.ValidationReject:
+		return "reject"
+	default:
+		return "ignore"
+	}
+}
+
+// validateBlockMessage is the gossipsub validator for BlockTopic. The decoded
+// block is attached to the message so the subscriber does not decode it again.
+func (s *Service) validateBlockMessage(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	// Our own blocks are already in the store
+	if from == s.host.ID() {
+		return pubsub.ValidationAccept
+	}
+
+	block, err := DecodeBlockMessage(msg.Data)
+	if err != nil {
+		s.logger.Debug("rejected block", "peer", from, "error", err)
+		return pubsub.ValidationReject
+	}
+
+	if s.chain != nil {
+		result, err := ValidateBlock(s.chain, block)
+		if errors.Is(err, ErrUnknownParent) && s.handlers != nil {
+			// Orphans are not forwarded, but are still handed over so the
+			// syncer can fetch their ancestors
+			if err := s.handlers.HandleBlock(s.ctx, from, block); err != nil {
+				s.logger.Debug("handle orphan block error", "error", err)
+			}
+		}
+		if result != pubsub.ValidationAccept {
+			s.logValidation("block", from, result, err, "slot", block.Message.Slot)
+			return result
+		}
+	}
+
+	msg.ValidatorData = block
+	return pubsub.ValidationAccept
+}
+
+// validateVoteMessage is the gossipsub validator for VoteTopic.
+func (s *Service) validateVoteMessage(ctx context.Context, from peer.ID, msg *pubsub.Message) pubsub.ValidationResult {
+	if from == s.host.ID() {
+		return pubsub.ValidationAccept
+	}
+
+	vote, err := DecodeVoteMessage(msg.Data)
+	if err != nil {
+		s.logger.Debug("rejected vote", "peer", from, "error", err)
+		metrics.AttestationsInvalid.WithLabelValues("gossip").Inc()
+		return pubsub.ValidationReject
+	}
+
+	if s.chain != nil {
+		start := time.Now()
+		result, err := ValidateVote(s.chain, vote)
+		metrics.AttestationValidationTime.Observe(time.Since(start).Seconds())
+		switch result {
+		case pubsub.ValidationAccept:
+			metrics.AttestationsValid.WithLabelValues("gossip").Inc()
+		case pubsub.ValidationReject:
+			metrics.AttestationsInvalid.WithLabelValues("gossip").Inc()
+		}
+		if result != pubsub.ValidationAccept {
+			s.logValidation("vote", from, result, err, "slot", vote.Data.Slot, "validator", vote.Data.ValidatorID)
+			return result
+		}
+	}
+
+	msg.ValidatorData = vote
+	return pubsub.ValidationAccept
+}
+
+func (s *Service) logValidation(kind string, from peer.ID, result pubsub.ValidationResult, err error, args ...any) {
+	action := "ignored"
+	if result == pubsub.ValidationReject {
+		action = "rejected"
+	}
+	args = append(args, "peer", from, "error", err)
+	s.logger.Debug(action+" "+kind, args...)
+}
@@ -0,0 +1,105 @@
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	gchain "github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/types"
+	pubsub "github.com/libp2p/go-libp2p-pubsub"
+)
+
+type fakeChain struct {
+	slot       types.Slot
+	finalized  types.Checkpoint
+	blocks     map[types.Root]bool
+	sigResult  error
+	voteResult error
+}
+
+func (c *fakeChain) CurrentSlot() types.Slot                       { return c.slot }
+func (c *fakeChain) NumValidators() uint64                         { return 4 }
+func (c *fakeChain) Finalized() types.Checkpoint                   { return c.finalized }
+func (c *fakeChain) HasBlock(root types.Root) bool                 { return c.blocks[root] }
+func (c *fakeChain) VerifyBlockSignature(*types.SignedBlock) error { return c.sigResult }
+func (c *fakeChain) ValidateAttestation(*types.SignedVote) error   { return c.voteResult }
+
+func testBlock(slot types.Slot, parent types.Root) *types.SignedBlock {
+	return &types.SignedBlock{Message: types.Block{
+		Slot:          slot,
+		ProposerIndex: uint64(slot) % 4,
+		ParentRoot:    parent,
+		Body:          types.BlockBody{Attestations: []types.SignedVote{}},
+	}}
+}
+
+func TestValidateBlock(t *testing.T) {
+	parent := types.Root{1}
+	known := testBlock(5, parent)
+	knownRoot, _ := known.Message.HashTreeRoot()
+
+	chain := &fakeChain{
+		slot:      5,
+		finalized: types.Checkpoint{Slot: 2},
+		blocks:    map[types.Root]bool{parent: true, knownRoot: true},
+	}
+
+	wrongProposer := testBlock(5, parent)
+	wrongProposer.Message.ProposerIndex = 3
+
+	tests := []struct {
+		name      string
+		block     *types.SignedBlock
+		sigResult error
+		result    pubsub.ValidationResult
+		err       error
+	}{
+		{"valid", testBlock(6, parent), nil, pubsub.ValidationAccept, nil},
+		{"future slot", testBlock(7, parent), nil, pubsub.ValidationIgnore, ErrFutureSlot},
+		{"finalized slot", testBlock(2, parent), nil, pubsub.ValidationIgnore, ErrFinalizedSlot},
+		{"wrong proposer", wrongProposer, nil, pubsub.ValidationReject, ErrInvalidProposer},
+		{"duplicate", known, nil, pubsub.ValidationIgnore, ErrDuplicateBlock},
+		{"unknown parent", testBlock(5, types.Root{2}), nil, pubsub.ValidationIgnore, ErrUnknownParent},
+		{"bad signature", testBlock(6, parent), gchain.ErrInvalidSignature, pubsub.ValidationReject, gchain.ErrInvalidSignature},
+		{"orphan with bad signature", testBlock(5, types.Root{2}), gchain.ErrInvalidSignature, pubsub.ValidationReject, gchain.ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain.sigResult = tt.sigResult
+			result, err := ValidateBlock(chain, tt.block)
+			if result != tt.result {
+				t.Errorf("result = %v, want %v", result, tt.result)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateVote(t *testing.T) {
+	tests := []struct {
+		name       string
+		validator  uint64
+		voteResult error
+		result     pubsub.ValidationResult
+	}{
+		{"valid", 1, nil, pubsub.ValidationAccept},
+		{"validator out of range", 4, nil, pubsub.ValidationReject},
+		{"unknown root", 1, fmt.Errorf("target: %w", forkchoice.ErrUnknownVoteRoot), pubsub.ValidationIgnore},
+		{"future vote", 1, forkchoice.ErrFutureVote, pubsub.ValidationIgnore},
+		{"inconsistent checkpoints", 1, errors.New("source slot 3 > target slot 2"), pubsub.ValidationReject},
+		{"bad signature", 1, gchain.ErrInvalidSignature, pubsub.ValidationReject},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := &fakeChain{voteResult: tt.voteResult}
+			vote := &types.SignedVote{Data: types.Vote{ValidatorID: tt.validator}}
+			if result, _ := ValidateVote(chain, vote); result != tt.result {
+				t.Errorf("result = %v, want %v", result, tt.result)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Package slashing keeps a record of every block and vote our validators
+// sign and refuses to sign messages that would get them slashed: a second
+// block for the same slot, a second vote for the same slot, or a vote that
+// surrounds or is surrounded by an earlier one.
+package slashing
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devylongs/gean/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names. Each holds one nested bucket per validator public key.
+var (
+	blocksBucket = []byte("blocks")
+	votesBucket  = []byte("votes")
+)
+
+// Slashing protection errors
+var (
+	ErrDoubleProposal = errors.New("double proposal")
+	ErrDoubleVote     = errors.New("double vote")
+	ErrSurroundVote   = errors.New("surround vote")
+	ErrBelowWatermark = errors.New("slot below lowest recorded slot")
+)
+
+// DB is the slashing protection database, backed by bbolt. Checks and the
+// records they create are written in a single transaction, so it is safe
+// for concurrent use.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens (or creates) the slashing protection database file at path.
+func Open(path string) (*DB, error) {
+	bdb, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open slashing protection database: %w", err)
+	}
+
+	err = bdb.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, votesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("create bucket %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+
+	return &DB{bolt: bdb}, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// blockRecord is a signed block. A zero signing root means the block is
+// known only by its slot, and conflicts with any block at that slot.
+type blockRecord struct {
+	Slot        types.Slot
+	SigningRoot types.Root
+}
+
+// voteRecord is a signed vote. A zero signing root means the vote is known
+// only by its slots, and conflicts with any vote at that slot. A slot holds
+// several records when an import brought in a conflicting vote: each span is
+// kept for the surround check.
+type voteRecord struct {
+	Slot        types.Slot
+	SourceSlot  types.Slot
+	TargetSlot  types.Slot
+	SigningRoot types.Root
+}
+
+// CheckAndRecordBlock returns an error if signing a block at slot with
+// signingRoot could be slashable, and otherwise records it. Signing the same
+// block again is allowed.
+func (db *DB) CheckAndRecordBlock(pubkey types.Pubkey, slot types.Slot, signingRoot types.Root) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(blocksBucket).CreateBucketIfNotExists(pubkey[:])
+		if err != nil {
+			return err
+		}
+
+		if data := bucket.Get(slotKey(slot)); data != nil {
+			prev := decodeBlock(slot, data)
+			if prev.SigningRoot == signingRoot && signingRoot != (types.Root{}) {
+				return nil
+			}
+			return fmt.Errorf("%w: slot %d already signed with root %x", ErrDoubleProposal, slot, prev.SigningRoot[:4])
+		}
+		if k, _ := bucket.Cursor().First(); k != nil && slot < types.Slot(binary.BigEndian.Uint64(k)) {
+			return fmt.Errorf("%w: block slot %d, lowest %d", ErrBelowWatermark, slot, binary.BigEndian.Uint64(k))
+		}
+
+		return bucket.Put(slotKey(slot), signingRoot[:])
+	})
+}
+
+// CheckAndRecordVote returns an error if signing vote with signingRoot could
+// be slashable, and otherwise records it. Signing the same vote again is
+// allowed.
+func (db *DB) CheckAndRecordVote(pubkey types.Pubkey, vote *types.Vote, signingRoot types.Root) error {
+	next := voteRecord{
+		Slot:        vote.Slot,
+		SourceSlot:  vote.Source.Slot,
+		TargetSlot:  vote.Target.Slot,
+		SigningRoot: signingRoot,
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(votesBucket).CreateBucketIfNotExists(pubkey[:])
+		if err != nil {
+			return err
+		}
+
+		if data := bucket.Get(slotKey(next.Slot)); data != nil {
+			prev := decodeVotes(next.Slot, data)
+			if len(prev) == 1 && prev[0].SigningRoot == signingRoot && signingRoot != (types.Root{}) {
+				return nil
+			}
+			return fmt.Errorf("%w: slot %d already signed with root %x", ErrDoubleVote, next.Slot, prev[0].SigningRoot[:4])
+		}
+		if k, _ := bucket.Cursor().First(); k != nil && next.Slot < types.Slot(binary.BigEndian.Uint64(k)) {
+			return fmt.Errorf("%w: vote slot %d, lowest %d", ErrBelowWatermark, next.Slot, binary.BigEndian.Uint64(k))
+		}
+
+		err = bucket.ForEach(func(k, v []byte) error {
+			for _, prev := range decodeVotes(types.Slot(binary.BigEndian.Uint64(k)), v) {
+				if surrounds(next, prev) || surrounds(prev, next) {
+					return fmt.Errorf("%w: source %d target %d against slot %d vote with source %d target %d",
+						ErrSurroundVote, next.SourceSlot, next.TargetSlot, prev.Slot, prev.SourceSlot, prev.TargetSlot)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(slotKey(next.Slot), encodeVotes([]voteRecord{next}))
+	})
+}
+
+// LastBlockSlot returns the highest slot a block is recorded at for pubkey,
+// and false if none is.
+func (db *DB) LastBlockSlot(pubkey types.Pubkey) (types.Slot, bool, error) {
+	return db.lastSlot(blocksBucket, pubkey)
+}
+
+// LastVoteSlot returns the highest slot a vote is recorded at for pubkey,
+// and false if none is.
+func (db *DB) LastVoteSlot(pubkey types.Pubkey) (types.Slot, bool, error) {
+	return db.lastSlot(votesBucket, pubkey)
+}
+
+func (db *DB) lastSlot(name []byte, pubkey types.Pubkey) (types.Slot, bool, error) {
+	var slot types.Slot
+	var found bool
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(name).Bucket(pubkey[:])
+		if bucket == nil {
+			return nil
+		}
+		if k, _ := bucket.Cursor().Last(); k != nil {
+			slot, found = types.Slot(binary.BigEndian.Uint64(k)), true
+		}
+		return nil
+	})
+	return slot, found, err
+}
+
+// surrounds reports whether the source-target span of a strictly contains
+// that of b.
+func surrounds(a, b voteRecord) bool {
+	return a.SourceSlot < b.SourceSlot && a.TargetSlot > b.TargetSlot
+}
+
+func slotKey(slot types.Slot) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(slot))
+	return key
+}
+
+func decodeBlock(slot types.Slot, data []byte) blockRecord {
+	r := blockRecord{Slot: slot}
+	copy(r.SigningRoot[:], data)
+	return r
+}
+
+// voteRecordSize is the encoded size of a vote record: source slot, target
+// slot and signing root.
+const voteRecordSize = 8 + 8 + 32
+
+// encodeVotes packs the records of a slot one after another.
+func encodeVotes(rs []voteRecord) []byte {
+	data := make([]byte, 0, len(rs)*voteRecordSize)
+	for _, r := range rs {
+		data = binary.BigEndian.AppendUint64(data, uint64(r.SourceSlot))
+		data = binary.BigEndian.AppendUint64(data, uint64(r.TargetSlot))
+		data = append(data, r.SigningRoot[:]...)
+	}
+	return data
+}
+
+func decodeVotes(slot types.Slot, data []byte) []voteRecord {
+	rs := make([]voteRecord, 0, len(data)/voteRecordSize)
+	for ; len(data) >= voteRecordSize; data = data[voteRecordSize:] {
+		r := voteRecord{
+			Slot:       slot,
+			SourceSlot: types.Slot(binary.BigEndian.Uint64(data[0:8])),
+			TargetSlot: types.Slot(binary.BigEndian.Uint64(data[8:16])),
+		}
+		copy(r.SigningRoot[:], data[16:voteRecordSize])
+		rs = append(rs, r)
+	}
+	return rs
+}
@@ -0,0 +1,182 @@
+package slashing
+
+import (
+	"encoding/hex"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/devylongs/gean/types"
+)
+
+var testPubkey = types.Pubkey{1}
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "slashing.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func vote(slot, source, target types.Slot) *types.Vote {
+	return &types.Vote{
+		Slot:   slot,
+		Source: types.Checkpoint{Slot: source},
+		Target: types.Checkpoint{Slot: target},
+	}
+}
+
+func TestCheckAndRecordBlock(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.CheckAndRecordBlock(testPubkey, 5, types.Root{1}); err != nil {
+		t.Fatalf("first block: %v", err)
+	}
+	if err := db.CheckAndRecordBlock(testPubkey, 5, types.Root{1}); err != nil {
+		t.Errorf("same block again: %v", err)
+	}
+	if err := db.CheckAndRecordBlock(testPubkey, 5, types.Root{2}); !errors.Is(err, ErrDoubleProposal) {
+		t.Errorf("conflicting block: err = %v, want ErrDoubleProposal", err)
+	}
+	if err := db.CheckAndRecordBlock(testPubkey, 4, types.Root{3}); !errors.Is(err, ErrBelowWatermark) {
+		t.Errorf("earlier block: err = %v, want ErrBelowWatermark", err)
+	}
+	if err := db.CheckAndRecordBlock(types.Pubkey{2}, 5, types.Root{2}); err != nil {
+		t.Errorf("other validator: %v", err)
+	}
+}
+
+func TestCheckAndRecordVote(t *testing.T) {
+	db := openTestDB(t)
+
+	if err := db.CheckAndRecordVote(testPubkey, vote(10, 4, 8), types.Root{1}); err != nil {
+		t.Fatalf("first vote: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		vote *types.Vote
+		root types.Root
+		want error
+	}{
+		{"same vote again", vote(10, 4, 8), types.Root{1}, nil},
+		{"double vote", vote(10, 4, 8), types.Root{2}, ErrDoubleVote},
+		{"below watermark", vote(9, 4, 8), types.Root{3}, ErrBelowWatermark},
+		{"surrounding", vote(11, 3, 9), types.Root{4}, ErrSurroundVote},
+		{"surrounded", vote(12, 5, 7), types.Root{5}, ErrSurroundVote},
+		{"next slot", vote(13, 4, 9), types.Root{6}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := db.CheckAndRecordVote(testPubkey, tt.vote, tt.root)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLastSlots(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, found, err := db.LastBlockSlot(testPubkey); err != nil || found {
+		t.Errorf("LastBlockSlot of a new validator = %v, %v, want none", found, err)
+	}
+	db.CheckAndRecordBlock(testPubkey, 5, types.Root{1})
+	db.CheckAndRecordBlock(testPubkey, 300, types.Root{2})
+	db.CheckAndRecordVote(testPubkey, vote(7, 0, 6), types.Root{3})
+
+	if slot, found, err := db.LastBlockSlot(testPubkey); err != nil || !found || slot != 300 {
+		t.Errorf("LastBlockSlot = %d, %v, %v, want 300", slot, found, err)
+	}
+	if slot, found, err := db.LastVoteSlot(testPubkey); err != nil || !found || slot != 7 {
+		t.Errorf("LastVoteSlot = %d, %v, %v, want 7", slot, found, err)
+	}
+}
+
+func TestInterchangeRoundTrip(t *testing.T) {
+	src := openTestDB(t)
+	if err := src.CheckAndRecordBlock(testPubkey, 5, types.Root{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := src.CheckAndRecordVote(testPubkey, vote(5, 2, 4), types.Root{2}); err != nil {
+		t.Fatal(err)
+	}
+
+	ic, err := src.Export(1000)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	dst := openTestDB(t)
+	if err := dst.Import(ic, 2000); !errors.Is(err, ErrGenesisMismatch) {
+		t.Errorf("Import other network: err = %v, want ErrGenesisMismatch", err)
+	}
+
+	// A conflicting local record leaves the slot with an unknown root
+	if err := dst.CheckAndRecordBlock(testPubkey, 5, types.Root{9}); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.Import(ic, 1000); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+	if err := dst.CheckAndRecordBlock(testPubkey, 5, types.Root{1}); !errors.Is(err, ErrDoubleProposal) {
+		t.Errorf("block at conflicting slot: err = %v, want ErrDoubleProposal", err)
+	}
+	if err := dst.CheckAndRecordVote(testPubkey, vote(5, 2, 4), types.Root{2}); err != nil {
+		t.Errorf("imported vote again: %v", err)
+	}
+	if err := dst.CheckAndRecordVote(testPubkey, vote(5, 2, 4), types.Root{3}); !errors.Is(err, ErrDoubleVote) {
+		t.Errorf("conflicting imported vote: err = %v, want ErrDoubleVote", err)
+	}
+
+	out, err := dst.Export(1000)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if len(out.Data) != 1 || len(out.Data[0].SignedBlocks) != 1 || out.Data[0].SignedBlocks[0].SigningRoot != "" {
+		t.Errorf("exported blocks = %+v, want one block with unknown root", out.Data)
+	}
+	if len(out.Data[0].SignedVotes) != 1 || out.Data[0].SignedVotes[0] != ic.Data[0].SignedVotes[0] {
+		t.Errorf("exported votes = %+v, want %+v", out.Data[0].SignedVotes, ic.Data[0].SignedVotes)
+	}
+}
+
+func TestImportKeepsConflictingSpans(t *testing.T) {
+	db := openTestDB(t)
+	if err := db.CheckAndRecordVote(testPubkey, vote(10, 2, 9), types.Root{1}); err != nil {
+		t.Fatal(err)
+	}
+	ic := &Interchange{
+		Metadata: InterchangeMetadata{InterchangeFormatVersion: InterchangeVersion, GenesisTime: "1000"},
+		Data: []InterchangeValidator{{
+			Pubkey:      "0x" + hex.EncodeToString(testPubkey[:]),
+			SignedVotes: []InterchangeVote{{Slot: "10", SourceSlot: "5", TargetSlot: "6"}},
+		}},
+	}
+	if err := db.Import(ic, 1000); err != nil {
+		t.Fatalf("Import failed: %v", err)
+	}
+
+	// Surrounded by the local (2, 9) vote
+	if err := db.CheckAndRecordVote(testPubkey, vote(11, 3, 5), types.Root{2}); !errors.Is(err, ErrSurroundVote) {
+		t.Errorf("vote surrounded by the local record: err = %v, want ErrSurroundVote", err)
+	}
+	// Surrounds the imported (5, 6) vote
+	if err := db.CheckAndRecordVote(testPubkey, vote(12, 4, 7), types.Root{3}); !errors.Is(err, ErrSurroundVote) {
+		t.Errorf("vote surrounding the imported record: err = %v, want ErrSurroundVote", err)
+	}
+	if err := db.CheckAndRecordVote(testPubkey, vote(10, 2, 9), types.Root{1}); !errors.Is(err, ErrDoubleVote) {
+		t.Errorf("vote at the conflicting slot: err = %v, want ErrDoubleVote", err)
+	}
+
+	out, err := db.Export(1000)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if votes := out.Data[0].SignedVotes; len(votes) != 2 || votes[0].SigningRoot != "" || votes[1].SigningRoot != "" {
+		t.Errorf("exported votes = %+v, want both spans with unknown roots", votes)
+	}
+}
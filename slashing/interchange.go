@@ -0,0 +1,267 @@
+package slashing
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/devylongs/gean/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// InterchangeVersion is the interchange format version. The format follows
+// EIP-3076, with votes recorded by slot, source slot and target slot.
+const InterchangeVersion = "5"
+
+// ErrGenesisMismatch is returned when importing records from another network.
+var ErrGenesisMismatch = errors.New("interchange genesis does not match")
+
+// Interchange is the JSON document used to move slashing protection records
+// between clients. Numbers are decimal strings and roots 0x-prefixed hex.
+type Interchange struct {
+	Metadata InterchangeMetadata    `json:"metadata"`
+	Data     []InterchangeValidator `json:"data"`
+}
+
+// InterchangeMetadata identifies the format version and network.
+type InterchangeMetadata struct {
+	InterchangeFormatVersion string `json:"interchange_format_version"`
+	GenesisTime              string `json:"genesis_time"`
+}
+
+// InterchangeValidator holds the records of one validator.
+type InterchangeValidator struct {
+	Pubkey       string             `json:"pubkey"`
+	SignedBlocks []InterchangeBlock `json:"signed_blocks"`
+	SignedVotes  []InterchangeVote  `json:"signed_votes"`
+}
+
+// InterchangeBlock is a signed block. SigningRoot may be omitted.
+type InterchangeBlock struct {
+	Slot        string `json:"slot"`
+	SigningRoot string `json:"signing_root,omitempty"`
+}
+
+// InterchangeVote is a signed vote. SigningRoot may be omitted.
+type InterchangeVote struct {
+	Slot        string `json:"slot"`
+	SourceSlot  string `json:"source_slot"`
+	TargetSlot  string `json:"target_slot"`
+	SigningRoot string `json:"signing_root,omitempty"`
+}
+
+// Export returns every record in the database.
+func (db *DB) Export(genesisTime uint64) (*Interchange, error) {
+	ic := &Interchange{
+		Metadata: InterchangeMetadata{
+			InterchangeFormatVersion: InterchangeVersion,
+			GenesisTime:              strconv.FormatUint(genesisTime, 10),
+		},
+		Data: []InterchangeValidator{},
+	}
+	index := make(map[string]int) // pubkey -> position in ic.Data
+
+	validator := func(pubkey []byte) *InterchangeValidator {
+		key := "0x" + hex.EncodeToString(pubkey)
+		i, ok := index[key]
+		if !ok {
+			i = len(ic.Data)
+			index[key] = i
+			ic.Data = append(ic.Data, InterchangeValidator{
+				Pubkey:       key,
+				SignedBlocks: []InterchangeBlock{},
+				SignedVotes:  []InterchangeVote{},
+			})
+		}
+		return &ic.Data[i]
+	}
+
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		err := forEachValidator(tx.Bucket(blocksBucket), func(pubkey []byte, bucket *bolt.Bucket) error {
+			v := validator(pubkey)
+			return bucket.ForEach(func(k, data []byte) error {
+				r := decodeBlock(types.Slot(binary.BigEndian.Uint64(k)), data)
+				v.SignedBlocks = append(v.SignedBlocks, InterchangeBlock{
+					Slot:        strconv.FormatUint(uint64(r.Slot), 10),
+					SigningRoot: formatRoot(r.SigningRoot),
+				})
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		return forEachValidator(tx.Bucket(votesBucket), func(pubkey []byte, bucket *bolt.Bucket) error {
+			v := validator(pubkey)
+			return bucket.ForEach(func(k, data []byte) error {
+				for _, r := range decodeVotes(types.Slot(binary.BigEndian.Uint64(k)), data) {
+					v.SignedVotes = append(v.SignedVotes, InterchangeVote{
+						Slot:        strconv.FormatUint(uint64(r.Slot), 10),
+						SourceSlot:  strconv.FormatUint(uint64(r.SourceSlot), 10),
+						TargetSlot:  strconv.FormatUint(uint64(r.TargetSlot), 10),
+						SigningRoot: formatRoot(r.SigningRoot),
+					})
+				}
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ic, nil
+}
+
+// Import merges the records of ic into the database. Where an imported
+// record and an existing one share a slot but not a signing root, the slot
+// is kept with an unknown root, so nothing more can be signed for it. The
+// source and target of both conflicting votes are kept for the surround
+// check.
+func (db *DB) Import(ic *Interchange, genesisTime uint64) error {
+	if ic.Metadata.InterchangeFormatVersion != InterchangeVersion {
+		return fmt.Errorf("unsupported interchange format version %q", ic.Metadata.InterchangeFormatVersion)
+	}
+	if ic.Metadata.GenesisTime != strconv.FormatUint(genesisTime, 10) {
+		return fmt.Errorf("%w: genesis time %s, expected %d", ErrGenesisMismatch, ic.Metadata.GenesisTime, genesisTime)
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		for i, v := range ic.Data {
+			pubkey, err := parsePubkey(v.Pubkey)
+			if err != nil {
+				return fmt.Errorf("validator %d: %w", i, err)
+			}
+			if err := importBlocks(tx, pubkey, v.SignedBlocks); err != nil {
+				return fmt.Errorf("validator %s: %w", v.Pubkey, err)
+			}
+			if err := importVotes(tx, pubkey, v.SignedVotes); err != nil {
+				return fmt.Errorf("validator %s: %w", v.Pubkey, err)
+			}
+		}
+		return nil
+	})
+}
+
+func importBlocks(tx *bolt.Tx, pubkey types.Pubkey, blocks []InterchangeBlock) error {
+	bucket, err := tx.Bucket(blocksBucket).CreateBucketIfNotExists(pubkey[:])
+	if err != nil {
+		return err
+	}
+	for _, b := range blocks {
+		slot, err := strconv.ParseUint(b.Slot, 10, 64)
+		if err != nil {
+			return fmt.Errorf("block slot %q: %w", b.Slot, err)
+		}
+		root, err := parseRoot(b.SigningRoot)
+		if err != nil {
+			return fmt.Errorf("block at slot %d: %w", slot, err)
+		}
+
+		key := slotKey(types.Slot(slot))
+		if data := bucket.Get(key); data != nil && decodeBlock(types.Slot(slot), data).SigningRoot != root {
+			root = types.Root{}
+		}
+		if err := bucket.Put(key, root[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importVotes(tx *bolt.Tx, pubkey types.Pubkey, votes []InterchangeVote) error {
+	bucket, err := tx.Bucket(votesBucket).CreateBucketIfNotExists(pubkey[:])
+	if err != nil {
+		return err
+	}
+	for _, v := range votes {
+		slot, err1 := strconv.ParseUint(v.Slot, 10, 64)
+		source, err2 := strconv.ParseUint(v.SourceSlot, 10, 64)
+		target, err3 := strconv.ParseUint(v.TargetSlot, 10, 64)
+		if err := errors.Join(err1, err2, err3); err != nil {
+			return fmt.Errorf("vote at slot %q: %w", v.Slot, err)
+		}
+		root, err := parseRoot(v.SigningRoot)
+		if err != nil {
+			return fmt.Errorf("vote at slot %d: %w", slot, err)
+		}
+
+		r := voteRecord{
+			Slot:        types.Slot(slot),
+			SourceSlot:  types.Slot(source),
+			TargetSlot:  types.Slot(target),
+			SigningRoot: root,
+		}
+		key := slotKey(r.Slot)
+		records := []voteRecord{r}
+		if data := bucket.Get(key); data != nil {
+			records = mergeVote(decodeVotes(r.Slot, data), r)
+		}
+		if err := bucket.Put(key, encodeVotes(records)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeVote adds an imported vote to the records of its slot. The same vote
+// again changes nothing; a different one makes every root at the slot
+// unknown, keeping each distinct span.
+func mergeVote(records []voteRecord, r voteRecord) []voteRecord {
+	if len(records) == 1 && records[0] == r {
+		return records
+	}
+	known := false
+	for i := range records {
+		records[i].SigningRoot = types.Root{}
+		if records[i].SourceSlot == r.SourceSlot && records[i].TargetSlot == r.TargetSlot {
+			known = true
+		}
+	}
+	if !known {
+		r.SigningRoot = types.Root{}
+		records = append(records, r)
+	}
+	return records
+}
+
+// forEachValidator calls fn with every nested per-validator bucket.
+func forEachValidator(parent *bolt.Bucket, fn func(pubkey []byte, bucket *bolt.Bucket) error) error {
+	return parent.ForEachBucket(func(k []byte) error {
+		return fn(k, parent.Bucket(k))
+	})
+}
+
+// formatRoot encodes a signing root, omitting an unknown (zero) root.
+func formatRoot(root types.Root) string {
+	if root == (types.Root{}) {
+		return ""
+	}
+	return "0x" + hex.EncodeToString(root[:])
+}
+
+// parseRoot decodes a signing root; an empty string is an unknown root.
+func parseRoot(s string) (types.Root, error) {
+	var root types.Root
+	if s == "" {
+		return root, nil
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != len(root) {
+		return root, fmt.Errorf("invalid signing root %q", s)
+	}
+	copy(root[:], b)
+	return root, nil
+}
+
+func parsePubkey(s string) (types.Pubkey, error) {
+	var pk types.Pubkey
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil || len(b) != len(pk) {
+		return pk, fmt.Errorf("invalid pubkey %q", s)
+	}
+	copy(pk[:], b)
+	return pk, nil
+}
@@ -0,0 +1,301 @@
+package spectest
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/devylongs/gean/types"
+)
+
+// Fixture JSON mirrors the camelCase layout written by leanSpec's fixture
+// filler. Roots and byte strings are 0x-prefixed hex; SSZ lists may appear
+// either as bare arrays or wrapped in {"data": [...]}.
+
+// hexBytes is a 0x-prefixed hex string.
+type hexBytes []byte
+
+func (h *hexBytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		return fmt.Errorf("decode hex %q: %w", s, err)
+	}
+	*h = b
+	return nil
+}
+
+// decodeFixed decodes a 0x-prefixed hex string of exactly len(dst) bytes.
+func decodeFixed(data []byte, dst []byte, what string) error {
+	var b hexBytes
+	if err := b.UnmarshalJSON(data); err != nil {
+		return err
+	}
+	if len(b) != len(dst) {
+		return fmt.Errorf("%s has %d bytes, want %d", what, len(b), len(dst))
+	}
+	copy(dst, b)
+	return nil
+}
+
+// hexRoot is a 0x-prefixed 32-byte root.
+type hexRoot types.Root
+
+func (h *hexRoot) UnmarshalJSON(data []byte) error { return decodeFixed(data, h[:], "root") }
+
+// hexPubkey is a 0x-prefixed XMSS public key.
+type hexPubkey types.Pubkey
+
+func (h *hexPubkey) UnmarshalJSON(data []byte) error { return decodeFixed(data, h[:], "pubkey") }
+
+// hexSignature is a 0x-prefixed XMSS signature.
+type hexSignature types.Signature
+
+func (h *hexSignature) UnmarshalJSON(data []byte) error {
+	return decodeFixed(data, h[:], "signature")
+}
+
+// list decodes an SSZ list in either of its JSON forms.
+type list[T any] []T
+
+func (l *list[T]) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var wrapped struct {
+			Data []T `json:"data"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return err
+		}
+		*l = wrapped.Data
+		return nil
+	}
+	var items []T
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+	*l = items
+	return nil
+}
+
+type jsonCheckpoint struct {
+	Root hexRoot `json:"root"`
+	Slot uint64  `json:"slot"`
+}
+
+func (c jsonCheckpoint) toCheckpoint() types.Checkpoint {
+	return types.Checkpoint{Root: types.Root(c.Root), Slot: types.Slot(c.Slot)}
+}
+
+type jsonConfig struct {
+	NumValidators uint64 `json:"numValidators"`
+	GenesisTime   uint64 `json:"genesisTime"`
+}
+
+type jsonValidator struct {
+	Pubkey hexPubkey `json:"pubkey"`
+}
+
+type jsonBlockHeader struct {
+	Slot          uint64  `json:"slot"`
+	ProposerIndex uint64  `json:"proposerIndex"`
+	ParentRoot    hexRoot `json:"parentRoot"`
+	StateRoot     hexRoot `json:"stateRoot"`
+	BodyRoot      hexRoot `json:"bodyRoot"`
+}
+
+func (h jsonBlockHeader) toHeader() types.BlockHeader {
+	return types.BlockHeader{
+		Slot:          types.Slot(h.Slot),
+		ProposerIndex: h.ProposerIndex,
+		ParentRoot:    types.Root(h.ParentRoot),
+		StateRoot:     types.Root(h.StateRoot),
+		BodyRoot:      types.Root(h.BodyRoot),
+	}
+}
+
+type jsonVote struct {
+	ValidatorID uint64         `json:"validatorId"`
+	Slot        uint64         `json:"slot"`
+	Head        jsonCheckpoint `json:"head"`
+	Target      jsonCheckpoint `json:"target"`
+	Source      jsonCheckpoint `json:"source"`
+}
+
+type jsonSignedVote struct {
+	Data      jsonVote     `json:"data"`
+	Signature hexSignature `json:"signature"`
+}
+
+func (v jsonSignedVote) toSignedVote() types.SignedVote {
+	return types.SignedVote{
+		Data: types.Vote{
+			ValidatorID: v.Data.ValidatorID,
+			Slot:        types.Slot(v.Data.Slot),
+			Head:        v.Data.Head.toCheckpoint(),
+			Target:      v.Data.Target.toCheckpoint(),
+			Source:      v.Data.Source.toCheckpoint(),
+		},
+		Signature: types.Signature(v.Signature),
+	}
+}
+
+type jsonBlockBody struct {
+	Attestations list[jsonSignedVote] `json:"attestations"`
+}
+
+type jsonBlock struct {
+	Slot          uint64        `json:"slot"`
+	ProposerIndex uint64        `json:"proposerIndex"`
+	ParentRoot    hexRoot       `json:"parentRoot"`
+	StateRoot     hexRoot       `json:"stateRoot"`
+	Body          jsonBlockBody `json:"body"`
+}
+
+func (b jsonBlock) toBlock() *types.Block {
+	attestations := make([]types.SignedVote, 0, len(b.Body.Attestations))
+	for _, vote := range b.Body.Attestations {
+		attestations = append(attestations, vote.toSignedVote())
+	}
+	return &types.Block{
+		Slot:          types.Slot(b.Slot),
+		ProposerIndex: b.ProposerIndex,
+		ParentRoot:    types.Root(b.ParentRoot),
+		StateRoot:     types.Root(b.StateRoot),
+		Body:          types.BlockBody{Attestations: attestations},
+	}
+}
+
+type jsonSignedBlock struct {
+	Message   jsonBlock    `json:"message"`
+	Signature hexSignature `json:"signature"`
+}
+
+func (b jsonSignedBlock) toSignedBlock() *types.SignedBlock {
+	return &types.SignedBlock{Message: *b.Message.toBlock(), Signature: types.Signature(b.Signature)}
+}
+
+type jsonState struct {
+	Config                   jsonConfig          `json:"config"`
+	Slot                     uint64              `json:"slot"`
+	LatestBlockHeader        jsonBlockHeader     `json:"latestBlockHeader"`
+	LatestJustified          jsonCheckpoint      `json:"latestJustified"`
+	LatestFinalized          jsonCheckpoint      `json:"latestFinalized"`
+	HistoricalBlockHashes    list[hexRoot]       `json:"historicalBlockHashes"`
+	JustifiedSlots           list[bool]          `json:"justifiedSlots"`
+	JustificationsRoots      list[hexRoot]       `json:"justificationsRoots"`
+	JustificationsValidators list[bool]          `json:"justificationsValidators"`
+	Validators               list[jsonValidator] `json:"validators"`
+}
+
+func (s jsonState) toState() *types.State {
+	return &types.State{
+		Config: types.Config{
+			NumValidators: s.Config.NumValidators,
+			GenesisTime:   s.Config.GenesisTime,
+		},
+		Slot:                    types.Slot(s.Slot),
+		LatestBlockHeader:       s.LatestBlockHeader.toHeader(),
+		LatestJustified:         s.LatestJustified.toCheckpoint(),
+		LatestFinalized:         s.LatestFinalized.toCheckpoint(),
+		HistoricalBlockHashes:   toRoots(s.HistoricalBlockHashes),
+		JustifiedSlots:          packBits(s.JustifiedSlots),
+		JustificationRoots:      toRoots(s.JustificationsRoots),
+		JustificationValidators: packBits(s.JustificationsValidators),
+		Validators:              toValidators(s.Validators),
+	}
+}
+
+func toValidators(validators []jsonValidator) []types.Validator {
+	result := make([]types.Validator, len(validators))
+	for i, v := range validators {
+		result[i] = types.Validator{Pubkey: types.Pubkey(v.Pubkey)}
+	}
+	return result
+}
+
+func toRoots(roots []hexRoot) []types.Root {
+	result := make([]types.Root, len(roots))
+	for i, root := range roots {
+		result[i] = types.Root(root)
+	}
+	return result
+}
+
+// packBits packs booleans little-endian into bytes, the same layout the
+// state transition uses for its bit fields.
+func packBits(bits []bool) []byte {
+	packed := make([]byte, (len(bits)+7)/8)
+	for i, bit := range bits {
+		if bit {
+			packed[i/8] |= 1 << (i % 8)
+		}
+	}
+	return packed
+}
+
+// stateExpectation lists the post-state fields a fixture checks. Absent
+// fields are not compared.
+type stateExpectation struct {
+	Slot                       *uint64  `json:"slot"`
+	LatestJustifiedSlot        *uint64  `json:"latestJustifiedSlot"`
+	LatestJustifiedRoot        *hexRoot `json:"latestJustifiedRoot"`
+	LatestFinalizedSlot        *uint64  `json:"latestFinalizedSlot"`
+	LatestFinalizedRoot        *hexRoot `json:"latestFinalizedRoot"`
+	LatestBlockHeaderSlot      *uint64  `json:"latestBlockHeaderSlot"`
+	LatestBlockHeaderStateRoot *hexRoot `json:"latestBlockHeaderStateRoot"`
+	HistoricalBlockHashesCount *int     `json:"historicalBlockHashesCount"`
+	ConfigNumValidators        *uint64  `json:"configNumValidators"`
+	JustificationsRootsCount   *int     `json:"justificationsRootsCount"`
+	StateRoot                  *hexRoot `json:"stateRoot"`
+}
+
+// stateTransitionTest applies blocks to a pre-state.
+type stateTransitionTest struct {
+	Pre             jsonState         `json:"pre"`
+	Blocks          []jsonSignedBlock `json:"blocks"`
+	Post            *stateExpectation `json:"post"`
+	ExpectException *string           `json:"expectException"`
+}
+
+// storeChecks lists the fork choice store fields checked after a step.
+type storeChecks struct {
+	Time                *uint64  `json:"time"`
+	HeadSlot            *uint64  `json:"headSlot"`
+	HeadRoot            *hexRoot `json:"headRoot"`
+	LatestJustifiedSlot *uint64  `json:"latestJustifiedSlot"`
+	LatestJustifiedRoot *hexRoot `json:"latestJustifiedRoot"`
+	LatestFinalizedSlot *uint64  `json:"latestFinalizedSlot"`
+	LatestFinalizedRoot *hexRoot `json:"latestFinalizedRoot"`
+	SafeTarget          *hexRoot `json:"safeTarget"`
+}
+
+// forkChoiceStep is one tick, block or attestation fed to the store.
+type forkChoiceStep struct {
+	StepType    string           `json:"stepType"`
+	Valid       *bool            `json:"valid"`
+	Time        *uint64          `json:"time"`
+	Block       *jsonSignedBlock `json:"block"`
+	Attestation *jsonSignedVote  `json:"attestation"`
+	Checks      *storeChecks     `json:"checks"`
+}
+
+// forkChoiceTest initialises a store from an anchor and replays steps.
+type forkChoiceTest struct {
+	AnchorState jsonState        `json:"anchorState"`
+	AnchorBlock jsonBlock        `json:"anchorBlock"`
+	Steps       []forkChoiceStep `json:"steps"`
+}
+
+// sszTest checks the encoding and hash tree root of a single container.
+type sszTest struct {
+	TypeName   string          `json:"typeName"`
+	Value      json.RawMessage `json:"value"`
+	Serialized hexBytes        `json:"serialized"`
+	Root       hexRoot         `json:"root"`
+}
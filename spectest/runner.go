@@ -0,0 +1,379 @@
+// Package spectest runs leanSpec consensus test vectors against gean's
+// state transition, fork choice and SSZ implementations.
+package spectest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/types"
+)
+
+// Kind identifies a family of test vectors.
+type Kind string
+
+// Test vector kinds, named after the fixture directories leanSpec writes.
+const (
+	KindStateTransition Kind = "state_transition"
+	KindForkChoice      Kind = "fork_choice"
+	KindSSZ             Kind = "ssz"
+)
+
+// ErrUnsupported is returned for fixtures this runner cannot execute.
+var ErrUnsupported = errors.New("unsupported fixture")
+
+// Case is a single named test vector.
+type Case struct {
+	Kind Kind
+	Name string
+	File string
+
+	raw json.RawMessage
+}
+
+// Load walks dir and returns every test case found in its JSON fixtures,
+// sorted by kind and name. The kind is taken from the first path element
+// below dir that names a known fixture family; other files are ignored.
+func Load(dir string) ([]Case, error) {
+	var cases []Case
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		kind, ok := kindOf(rel)
+		if !ok {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var fixtures map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fixtures); err != nil {
+			return fmt.Errorf("parse %s: %w", rel, err)
+		}
+		for name, raw := range fixtures {
+			cases = append(cases, Case{Kind: kind, Name: name, File: rel, raw: raw})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(cases, func(i, j int) bool {
+		if cases[i].Kind != cases[j].Kind {
+			return cases[i].Kind < cases[j].Kind
+		}
+		return cases[i].Name < cases[j].Name
+	})
+	return cases, nil
+}
+
+func kindOf(rel string) (Kind, bool) {
+	for _, part := range strings.Split(filepath.ToSlash(rel), "/") {
+		switch part {
+		case string(KindStateTransition):
+			return KindStateTransition, true
+		case string(KindForkChoice):
+			return KindForkChoice, true
+		case string(KindSSZ), "ssz_static":
+			return KindSSZ, true
+		}
+	}
+	return "", false
+}
+
+// Run executes the case and returns nil if gean matches the expected outcome.
+func (c Case) Run() error {
+	switch c.Kind {
+	case KindStateTransition:
+		var test stateTransitionTest
+		if err := json.Unmarshal(c.raw, &test); err != nil {
+			return fmt.Errorf("decode fixture: %w", err)
+		}
+		return runStateTransition(&test)
+	case KindForkChoice:
+		var test forkChoiceTest
+		if err := json.Unmarshal(c.raw, &test); err != nil {
+			return fmt.Errorf("decode fixture: %w", err)
+		}
+		return runForkChoice(&test)
+	case KindSSZ:
+		var test sszTest
+		if err := json.Unmarshal(c.raw, &test); err != nil {
+			return fmt.Errorf("decode fixture: %w", err)
+		}
+		return runSSZ(&test)
+	default:
+		return fmt.Errorf("%w: kind %q", ErrUnsupported, c.Kind)
+	}
+}
+
+func runStateTransition(test *stateTransitionTest) error {
+	state := test.Pre.toState()
+
+	var err error
+	for i, jb := range test.Blocks {
+		state, err = chain.StateTransition(state, jb.toSignedBlock(), true)
+		if err != nil {
+			err = fmt.Errorf("block %d (slot %d): %w", i, jb.Message.Slot, err)
+			break
+		}
+	}
+
+	if test.ExpectException != nil {
+		if err == nil {
+			return fmt.Errorf("expected failure %q, transition succeeded", *test.ExpectException)
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if test.Post == nil {
+		return nil
+	}
+	return checkState(state, test.Post)
+}
+
+func checkState(state *types.State, want *stateExpectation) error {
+	var errs []error
+	check := func(field string, got, expected any) {
+		if got != expected {
+			errs = append(errs, fmt.Errorf("%s: got %v, want %v", field, got, expected))
+		}
+	}
+
+	if want.Slot != nil {
+		check("slot", uint64(state.Slot), *want.Slot)
+	}
+	if want.LatestJustifiedSlot != nil {
+		check("latestJustifiedSlot", uint64(state.LatestJustified.Slot), *want.LatestJustifiedSlot)
+	}
+	if want.LatestJustifiedRoot != nil {
+		check("latestJustifiedRoot", state.LatestJustified.Root, types.Root(*want.LatestJustifiedRoot))
+	}
+	if want.LatestFinalizedSlot != nil {
+		check("latestFinalizedSlot", uint64(state.LatestFinalized.Slot), *want.LatestFinalizedSlot)
+	}
+	if want.LatestFinalizedRoot != nil {
+		check("latestFinalizedRoot", state.LatestFinalized.Root, types.Root(*want.LatestFinalizedRoot))
+	}
+	if want.LatestBlockHeaderSlot != nil {
+		check("latestBlockHeaderSlot", uint64(state.LatestBlockHeader.Slot), *want.LatestBlockHeaderSlot)
+	}
+	if want.LatestBlockHeaderStateRoot != nil {
+		check("latestBlockHeaderStateRoot", state.LatestBlockHeader.StateRoot, types.Root(*want.LatestBlockHeaderStateRoot))
+	}
+	if want.HistoricalBlockHashesCount != nil {
+		check("historicalBlockHashesCount", len(state.HistoricalBlockHashes), *want.HistoricalBlockHashesCount)
+	}
+	if want.ConfigNumValidators != nil {
+		check("configNumValidators", state.Config.NumValidators, *want.ConfigNumValidators)
+	}
+	if want.JustificationsRootsCount != nil {
+		check("justificationsRootsCount", len(state.JustificationRoots), *want.JustificationsRootsCount)
+	}
+	if want.StateRoot != nil {
+		root, err := state.HashTreeRoot()
+		if err != nil {
+			return fmt.Errorf("hash state: %w", err)
+		}
+		check("stateRoot", types.Root(root), types.Root(*want.StateRoot))
+	}
+	return errors.Join(errs...)
+}
+
+func runForkChoice(test *forkChoiceTest) error {
+	store, err := forkchoice.NewStore(test.AnchorState.toState(), test.AnchorBlock.toBlock())
+	if err != nil {
+		return fmt.Errorf("create store: %w", err)
+	}
+
+	for i, step := range test.Steps {
+		err := applyStep(store, &step)
+		valid := step.Valid == nil || *step.Valid
+		if valid && err != nil {
+			return fmt.Errorf("step %d (%s): %w", i, step.StepType, err)
+		}
+		if !valid && err == nil {
+			return fmt.Errorf("step %d (%s): expected rejection", i, step.StepType)
+		}
+		if step.Checks != nil {
+			if err := checkStore(store, step.Checks); err != nil {
+				return fmt.Errorf("step %d (%s): %w", i, step.StepType, err)
+			}
+		}
+	}
+	return nil
+}
+
+func applyStep(store *forkchoice.Store, step *forkChoiceStep) error {
+	switch step.StepType {
+	case "tick":
+		if step.Time == nil {
+			return errors.New("tick step without time")
+		}
+		store.AdvanceTime(*step.Time, false)
+		return nil
+	case "block":
+		if step.Block == nil {
+			return errors.New("block step without block")
+		}
+		return store.ProcessBlock(step.Block.toSignedBlock())
+	case "attestation":
+		if step.Attestation == nil {
+			return errors.New("attestation step without attestation")
+		}
+		vote := step.Attestation.toSignedVote()
+		return store.ProcessAttestation(&vote)
+	default:
+		return fmt.Errorf("%w: step type %q", ErrUnsupported, step.StepType)
+	}
+}
+
+func checkStore(store *forkchoice.Store, want *storeChecks) error {
+	var errs []error
+	check := func(field string, got, expected any) {
+		if got != expected {
+			errs = append(errs, fmt.Errorf("%s: got %v, want %v", field, got, expected))
+		}
+	}
+
+	head := store.HeadCheckpoint()
+	justified := store.Justified()
+	finalized := store.Finalized()
+
+	if want.Time != nil {
+		check("time", store.Time, *want.Time)
+	}
+	if want.HeadSlot != nil {
+		check("headSlot", uint64(head.Slot), *want.HeadSlot)
+	}
+	if want.HeadRoot != nil {
+		check("headRoot", head.Root, types.Root(*want.HeadRoot))
+	}
+	if want.LatestJustifiedSlot != nil {
+		check("latestJustifiedSlot", uint64(justified.Slot), *want.LatestJustifiedSlot)
+	}
+	if want.LatestJustifiedRoot != nil {
+		check("latestJustifiedRoot", justified.Root, types.Root(*want.LatestJustifiedRoot))
+	}
+	if want.LatestFinalizedSlot != nil {
+		check("latestFinalizedSlot", uint64(finalized.Slot), *want.LatestFinalizedSlot)
+	}
+	if want.LatestFinalizedRoot != nil {
+		check("latestFinalizedRoot", finalized.Root, types.Root(*want.LatestFinalizedRoot))
+	}
+	if want.SafeTarget != nil {
+		check("safeTarget", store.SafeTarget, types.Root(*want.SafeTarget))
+	}
+	return errors.Join(errs...)
+}
+
+// sszObject is implemented by every generated SSZ container.
+type sszObject interface {
+	MarshalSSZ() ([]byte, error)
+	HashTreeRoot() ([32]byte, error)
+}
+
+// decodeValue converts a fixture value into the named container.
+func decodeValue(typeName string, raw json.RawMessage) (sszObject, error) {
+	switch typeName {
+	case "Checkpoint":
+		var v jsonCheckpoint
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		cp := v.toCheckpoint()
+		return &cp, nil
+	case "Config":
+		var v jsonConfig
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return &types.Config{NumValidators: v.NumValidators, GenesisTime: v.GenesisTime}, nil
+	case "Validator":
+		var v jsonValidator
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return &types.Validator{Pubkey: types.Pubkey(v.Pubkey)}, nil
+	case "BlockHeader":
+		var v jsonBlockHeader
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		header := v.toHeader()
+		return &header, nil
+	case "SignedVote":
+		var v jsonSignedVote
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		vote := v.toSignedVote()
+		return &vote, nil
+	case "Block":
+		var v jsonBlock
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v.toBlock(), nil
+	case "SignedBlock":
+		var v jsonSignedBlock
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v.toSignedBlock(), nil
+	case "State":
+		var v jsonState
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, err
+		}
+		return v.toState(), nil
+	default:
+		return nil, fmt.Errorf("%w: type %q", ErrUnsupported, typeName)
+	}
+}
+
+func runSSZ(test *sszTest) error {
+	value, err := decodeValue(test.TypeName, test.Value)
+	if err != nil {
+		return fmt.Errorf("decode %s: %w", test.TypeName, err)
+	}
+
+	encoded, err := value.MarshalSSZ()
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", test.TypeName, err)
+	}
+	if test.Serialized != nil && !bytes.Equal(encoded, test.Serialized) {
+		return fmt.Errorf("serialized: got %x, want %x", encoded, []byte(test.Serialized))
+	}
+
+	root, err := value.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash %s: %w", test.TypeName, err)
+	}
+	if types.Root(root) != types.Root(test.Root) {
+		return fmt.Errorf("root: got %x, want %x", root, test.Root[:])
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+package spectest
+
+import (
+	"os"
+	"testing"
+)
+
+// FixturesEnv names the environment variable pointing at a directory of
+// leanSpec-generated fixtures. TestSpec is skipped when it is unset.
+const FixturesEnv = "LEAN_SPEC_FIXTURES"
+
+func runCases(t *testing.T, dir string) {
+	t.Helper()
+	cases, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load(%s) failed: %v", dir, err)
+	}
+	if len(cases) == 0 {
+		t.Fatalf("no fixtures found in %s", dir)
+	}
+
+	passed := 0
+	for _, c := range cases {
+		ok := t.Run(string(c.Kind)+"/"+c.Name, func(t *testing.T) {
+			if err := c.Run(); err != nil {
+				t.Errorf("%s: %v", c.File, err)
+			}
+		})
+		if ok {
+			passed++
+		}
+	}
+	t.Logf("%d/%d cases passed", passed, len(cases))
+}
+
+// TestSpec runs the leanSpec fixtures in $LEAN_SPEC_FIXTURES.
+func TestSpec(t *testing.T) {
+	dir := os.Getenv(FixturesEnv)
+	if dir == "" {
+		t.Skipf("%s not set", FixturesEnv)
+	}
+	runCases(t, dir)
+}
+
+// TestRegressionFixtures runs the fixtures checked in under
+// testdata/regression. They use leanSpec's fixture layout but were
+// generated by gean itself, so they guard against regressions in gean and
+// the runner; they say nothing about conformance with leanSpec.
+func TestRegressionFixtures(t *testing.T) {
+	runCases(t, "testdata/regression")
+}
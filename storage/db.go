@@ -0,0 +1,373 @@
+// Package storage persists blocks, states and fork choice data on disk.
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devylongs/gean/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names
+var (
+	blocksBucket      = []byte("blocks")
+	statesBucket      = []byte("states")
+	metaBucket        = []byte("meta")
+	knownVotesBucket  = []byte("votes_known")
+	newVotesBucket    = []byte("votes_new")
+	signedVotesBucket = []byte("votes_signed")
+	blockSlotsBucket  = []byte("block_slots") // slot || root of every stored block, in slot order
+	canonicalBucket   = []byte("canonical")   // slot -> root of the finalized chain
+
+	forkChoiceKey = []byte("forkchoice")
+)
+
+// ErrNotFound is returned when a requested record does not exist.
+var ErrNotFound = errors.New("not found")
+
+// DB is an embedded key/value database backed by bbolt.
+type DB struct {
+	bolt *bolt.DB
+}
+
+// Open opens (or creates) the database file at path.
+func Open(path string) (*DB, error) {
+	bdb, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+
+	err = bdb.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{blocksBucket, statesBucket, metaBucket, knownVotesBucket, newVotesBucket, signedVotesBucket, blockSlotsBucket, canonicalBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return fmt.Errorf("create bucket %s: %w", name, err)
+			}
+		}
+		if err := indexBlockSlots(tx); err != nil {
+			return err
+		}
+		return indexCanonical(tx)
+	})
+	if err != nil {
+		bdb.Close()
+		return nil, err
+	}
+
+	return &DB{bolt: bdb}, nil
+}
+
+// Close closes the database.
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+// PutBlock stores a signed block and, if non-nil, its post-state in a single transaction.
+func (db *DB) PutBlock(root types.Root, block *types.SignedBlock, state *types.State) error {
+	blockData, err := block.MarshalSSZ()
+	if err != nil {
+		return fmt.Errorf("marshal block: %w", err)
+	}
+	var stateData []byte
+	if state != nil {
+		if stateData, err = state.MarshalSSZ(); err != nil {
+			return fmt.Errorf("marshal state: %w", err)
+		}
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(blocksBucket).Put(root[:], blockData); err != nil {
+			return err
+		}
+		if err := tx.Bucket(blockSlotsBucket).Put(blockSlotKey(block.Message.Slot, root), nil); err != nil {
+			return err
+		}
+		if stateData == nil {
+			return nil
+		}
+		return tx.Bucket(statesBucket).Put(root[:], stateData)
+	})
+}
+
+// PutCanonicalBlock stores a block of the finalized chain, without its
+// state, and records it as canonical at its slot.
+func (db *DB) PutCanonicalBlock(root types.Root, block *types.SignedBlock) error {
+	blockData, err := block.MarshalSSZ()
+	if err != nil {
+		return fmt.Errorf("marshal block: %w", err)
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(blocksBucket).Put(root[:], blockData); err != nil {
+			return err
+		}
+		if err := tx.Bucket(blockSlotsBucket).Put(blockSlotKey(block.Message.Slot, root), nil); err != nil {
+			return err
+		}
+		return tx.Bucket(canonicalBucket).Put(slotKey(block.Message.Slot), root[:])
+	})
+}
+
+// PutCanonical records the roots of finalized blocks by slot.
+func (db *DB) PutCanonical(roots map[types.Slot]types.Root) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(canonicalBucket)
+		for slot, root := range roots {
+			if err := bucket.Put(slotKey(slot), bytes.Clone(root[:])); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// CanonicalRoot returns the root of the finalized block at slot. Returns
+// ErrNotFound for a skipped slot or one not yet finalized or backfilled.
+func (db *DB) CanonicalRoot(slot types.Slot) (types.Root, error) {
+	var root types.Root
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(canonicalBucket).Get(slotKey(slot))
+		if data == nil {
+			return ErrNotFound
+		}
+		copy(root[:], data)
+		return nil
+	})
+	return root, err
+}
+
+// Prune deletes the given blocks and states.
+func (db *DB) Prune(blocks, states []types.Root) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		for _, root := range blocks {
+			data := tx.Bucket(blocksBucket).Get(root[:])
+			if data == nil {
+				continue
+			}
+			block := new(types.SignedBlock)
+			if err := block.UnmarshalSSZ(data); err != nil {
+				return fmt.Errorf("unmarshal block %x: %w", root[:4], err)
+			}
+			if err := tx.Bucket(blockSlotsBucket).Delete(blockSlotKey(block.Message.Slot, root)); err != nil {
+				return err
+			}
+			if err := tx.Bucket(blocksBucket).Delete(root[:]); err != nil {
+				return err
+			}
+		}
+		for _, root := range states {
+			if err := tx.Bucket(statesBucket).Delete(root[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Block returns the signed block with the given root.
+func (db *DB) Block(root types.Root) (*types.SignedBlock, error) {
+	block := new(types.SignedBlock)
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(blocksBucket).Get(root[:])
+		if data == nil {
+			return ErrNotFound
+		}
+		return block.UnmarshalSSZ(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// State returns the post-state of the block with the given root.
+func (db *DB) State(root types.Root) (*types.State, error) {
+	state := new(types.State)
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(statesBucket).Get(root[:])
+		if data == nil {
+			return ErrNotFound
+		}
+		return state.UnmarshalSSZ(data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// ForEachBlockFrom calls fn for every stored block at or after slot, in
+// slot order.
+func (db *DB) ForEachBlockFrom(slot types.Slot, fn func(root types.Root, block *types.SignedBlock) error) error {
+	return db.bolt.View(func(tx *bolt.Tx) error {
+		blocks := tx.Bucket(blocksBucket)
+		c := tx.Bucket(blockSlotsBucket).Cursor()
+		for k, _ := c.Seek(blockSlotKey(slot, types.Root{})); k != nil; k, _ = c.Next() {
+			var root types.Root
+			copy(root[:], k[8:])
+
+			data := blocks.Get(root[:])
+			if data == nil {
+				return fmt.Errorf("indexed block %x missing", root[:4])
+			}
+			block := new(types.SignedBlock)
+			if err := block.UnmarshalSSZ(data); err != nil {
+				return fmt.Errorf("unmarshal block %x: %w", root[:4], err)
+			}
+			if err := fn(root, block); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// indexBlockSlots fills the block slot index from the blocks bucket when the
+// index is empty, as in a database written before the index existed.
+func indexBlockSlots(tx *bolt.Tx) error {
+	index := tx.Bucket(blockSlotsBucket)
+	if k, _ := index.Cursor().First(); k != nil {
+		return nil
+	}
+	return tx.Bucket(blocksBucket).ForEach(func(k, v []byte) error {
+		var root types.Root
+		copy(root[:], k)
+
+		block := new(types.SignedBlock)
+		if err := block.UnmarshalSSZ(v); err != nil {
+			return fmt.Errorf("unmarshal block %x: %w", root[:4], err)
+		}
+		return index.Put(blockSlotKey(block.Message.Slot, root), nil)
+	})
+}
+
+// indexCanonical fills the canonical index when it is empty, as in a
+// database written before the index existed, by following parent roots back
+// from the finalized block through the stored blocks.
+func indexCanonical(tx *bolt.Tx) error {
+	index := tx.Bucket(canonicalBucket)
+	if k, _ := index.Cursor().First(); k != nil {
+		return nil
+	}
+	data := tx.Bucket(metaBucket).Get(forkChoiceKey)
+	if data == nil {
+		return nil
+	}
+	checkpoints, err := decodeCheckpoints(data)
+	if err != nil {
+		return err
+	}
+
+	blocks := tx.Bucket(blocksBucket)
+	for root := checkpoints.LatestFinalized.Root; ; {
+		data := blocks.Get(root[:])
+		if data == nil {
+			return nil // history before a checkpoint anchor is backfilled later
+		}
+		block := new(types.SignedBlock)
+		if err := block.UnmarshalSSZ(data); err != nil {
+			return fmt.Errorf("unmarshal block %x: %w", root[:4], err)
+		}
+		// bbolt holds on to the value until the transaction commits
+		if err := index.Put(slotKey(block.Message.Slot), bytes.Clone(root[:])); err != nil {
+			return err
+		}
+		if block.Message.Slot == 0 {
+			return nil
+		}
+		root = block.Message.ParentRoot
+	}
+}
+
+// putVotes replaces the contents of a votes bucket.
+func putVotes(tx *bolt.Tx, name []byte, votes map[types.ValidatorIndex]types.Checkpoint) error {
+	if err := tx.DeleteBucket(name); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+	bucket, err := tx.CreateBucket(name)
+	if err != nil {
+		return err
+	}
+	for validator, checkpoint := range votes {
+		data, err := checkpoint.MarshalSSZ()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(validatorKey(validator), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readVotes loads a votes bucket into a map.
+func readVotes(tx *bolt.Tx, name []byte) (map[types.ValidatorIndex]types.Checkpoint, error) {
+	votes := make(map[types.ValidatorIndex]types.Checkpoint)
+	err := tx.Bucket(name).ForEach(func(k, v []byte) error {
+		var checkpoint types.Checkpoint
+		if err := checkpoint.UnmarshalSSZ(v); err != nil {
+			return err
+		}
+		votes[types.ValidatorIndex(binary.BigEndian.Uint64(k))] = checkpoint
+		return nil
+	})
+	return votes, err
+}
+
+// putSignedVotes replaces the contents of the signed votes bucket.
+func putSignedVotes(tx *bolt.Tx, votes map[types.ValidatorIndex]types.SignedVote) error {
+	if err := tx.DeleteBucket(signedVotesBucket); err != nil && !errors.Is(err, bolt.ErrBucketNotFound) {
+		return err
+	}
+	bucket, err := tx.CreateBucket(signedVotesBucket)
+	if err != nil {
+		return err
+	}
+	for validator, vote := range votes {
+		data, err := vote.MarshalSSZ()
+		if err != nil {
+			return err
+		}
+		if err := bucket.Put(validatorKey(validator), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readSignedVotes loads the signed votes bucket into a map.
+func readSignedVotes(tx *bolt.Tx) (map[types.ValidatorIndex]types.SignedVote, error) {
+	votes := make(map[types.ValidatorIndex]types.SignedVote)
+	err := tx.Bucket(signedVotesBucket).ForEach(func(k, v []byte) error {
+		var vote types.SignedVote
+		if err := vote.UnmarshalSSZ(v); err != nil {
+			return err
+		}
+		votes[types.ValidatorIndex(binary.BigEndian.Uint64(k))] = vote
+		return nil
+	})
+	return votes, err
+}
+
+// blockSlotKey orders the block slot index by slot, then root.
+func blockSlotKey(slot types.Slot, root types.Root) []byte {
+	key := make([]byte, 8+32)
+	binary.BigEndian.PutUint64(key, uint64(slot))
+	copy(key[8:], root[:])
+	return key
+}
+
+func slotKey(slot types.Slot) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(slot))
+	return key
+}
+
+func validatorKey(index types.ValidatorIndex) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(index))
+	return key
+}
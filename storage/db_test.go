@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/chain/chaintest"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/types"
+	"github.com/devylongs/gean/xmss"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestLoadStoreEmpty(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "chain.db"))
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer db.Close()
+
+	if _, err := db.LoadStore(); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LoadStore error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	store := forkchoicetest.Store(t)
+	store.SetDatabase(db)
+	if err := store.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(5)...)
+	store.LatestNewVotes[2] = types.Checkpoint{Root: store.Head, Slot: 5}
+	head, target, source := store.VoteCheckpoints()
+	vote, err := chain.SignVote(xmss.DevnetKey(3), &types.Vote{ValidatorID: 3, Slot: 5, Head: head, Target: target, Source: source})
+	if err != nil {
+		t.Fatalf("SignVote failed: %v", err)
+	}
+	if err := store.ProcessAttestation(vote); err != nil {
+		t.Fatalf("ProcessAttestation failed: %v", err)
+	}
+	if err := store.FlushForkChoice(); err != nil {
+		t.Fatalf("FlushForkChoice failed: %v", err)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+
+	loaded, err := db.LoadStore()
+	if err != nil {
+		t.Fatalf("LoadStore failed: %v", err)
+	}
+
+	if loaded.Head != store.Head || loaded.Blocks[loaded.Head].Slot != 5 {
+		t.Error("head mismatch after reload")
+	}
+	if loaded.LatestJustified != store.LatestJustified || loaded.LatestFinalized != store.LatestFinalized {
+		t.Error("checkpoints mismatch after reload")
+	}
+	if len(loaded.Blocks) != len(store.Blocks) || len(loaded.States) != len(store.States) {
+		t.Errorf("loaded %d blocks / %d states, want %d / %d",
+			len(loaded.Blocks), len(loaded.States), len(store.Blocks), len(store.States))
+	}
+	if loaded.LatestNewVotes[2] != store.LatestNewVotes[2] {
+		t.Error("new votes mismatch after reload")
+	}
+	if loaded.SignedVotes[3] != *vote {
+		t.Error("signed votes mismatch after reload")
+	}
+
+	headState, _ := store.States[store.Head].HashTreeRoot()
+	loadedState, _ := loaded.States[loaded.Head].HashTreeRoot()
+	if headState != loadedState {
+		t.Error("head state mismatch after reload")
+	}
+}
+
+func TestForEachBlockFrom(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	store := forkchoicetest.Store(t)
+	store.SetDatabase(db)
+	if err := store.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(5)...)
+	root4, _ := store.CanonicalRoot(4)
+
+	slotsFrom := func(start types.Slot) []types.Slot {
+		t.Helper()
+		var slots []types.Slot
+		err := db.ForEachBlockFrom(start, func(root types.Root, block *types.SignedBlock) error {
+			slots = append(slots, block.Message.Slot)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("ForEachBlockFrom failed: %v", err)
+		}
+		return slots
+	}
+
+	if got := slotsFrom(3); len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("blocks from slot 3 = %v, want 3, 4 and 5", got)
+	}
+	if err := db.Prune([]types.Root{root4}, nil); err != nil {
+		t.Fatalf("Prune failed: %v", err)
+	}
+	if got := slotsFrom(3); len(got) != 2 || got[1] != 5 {
+		t.Errorf("blocks from slot 3 after pruning slot 4 = %v, want 3 and 5", got)
+	}
+
+	// A database without the index has it rebuilt on open
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(blockSlotsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(blockSlotsBucket)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("clear index failed: %v", err)
+	}
+	db.Close()
+	if db, err = Open(path); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	if got := slotsFrom(0); len(got) != 5 {
+		t.Errorf("rebuilt index holds slots %v, want 0 to 5 without 4", got)
+	}
+}
+
+func TestCanonicalIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	store := forkchoicetest.Store(t)
+	store.SetDatabase(db)
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(5)...)
+	root3, _ := store.CanonicalRoot(3)
+	store.LatestFinalized = types.Checkpoint{Root: root3, Slot: 3}
+	if err := store.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+
+	if root, err := db.CanonicalRoot(3); err != nil || root != root3 {
+		t.Fatalf("CanonicalRoot(3) = %x, %v, want the finalized block", root[:4], err)
+	}
+	if _, err := db.CanonicalRoot(4); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CanonicalRoot(4) error = %v, want ErrNotFound before finalization", err)
+	}
+
+	// A database without the index has it rebuilt on open
+	err = db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(canonicalBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket(canonicalBucket)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("clear index failed: %v", err)
+	}
+	db.Close()
+	if db, err = Open(path); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	for slot := types.Slot(0); slot <= 3; slot++ {
+		if _, err := db.CanonicalRoot(slot); err != nil {
+			t.Errorf("rebuilt index lacks slot %d: %v", slot, err)
+		}
+	}
+	if root, _ := db.CanonicalRoot(3); root != root3 {
+		t.Error("rebuilt index has the wrong block at slot 3")
+	}
+}
+
+func TestReloadAfterPruningPersistedHead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chain.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	store := forkchoicetest.Store(t)
+	store.SetDatabase(db)
+	if err := store.Persist(); err != nil {
+		t.Fatalf("Persist failed: %v", err)
+	}
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(5)...)
+	if err := store.FlushForkChoice(); err != nil {
+		t.Fatalf("FlushForkChoice failed: %v", err)
+	}
+	persistedHead := store.Head
+
+	// Range sync moves the head well past the retention window within a
+	// slot, so the state of the persisted head is pruned before the next
+	// flush
+	var slots []types.Slot
+	for slot := types.Slot(6); slot <= 5+forkchoice.StateRetentionSlots+10; slot++ {
+		slots = append(slots, slot)
+	}
+	chaintest.Extend(t, store, store.LatestKnownVotes, slots...)
+	if store.PruneStates() == 0 {
+		t.Fatal("PruneStates dropped nothing")
+	}
+	if _, err := db.State(persistedHead); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("state of the first persisted head was kept: %v", err)
+	}
+	db.Close()
+
+	if db, err = Open(path); err != nil {
+		t.Fatalf("reopen failed: %v", err)
+	}
+	defer db.Close()
+	loaded, err := db.LoadStore()
+	if err != nil {
+		t.Fatalf("LoadStore failed: %v", err)
+	}
+	if loaded.Head != store.Head {
+		t.Error("reloaded head is not the head at the time of pruning")
+	}
+}
@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// checkpointsSize is the encoded size of forkchoice.Checkpoints:
+// head root, safe target root, justified and finalized checkpoints.
+const checkpointsSize = 32 + 32 + 40 + 40
+
+// PutForkChoice stores the fork choice checkpoints and latest votes.
+func (db *DB) PutForkChoice(checkpoints *forkchoice.Checkpoints, votes *forkchoice.Votes) error {
+	data, err := encodeCheckpoints(checkpoints)
+	if err != nil {
+		return err
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(metaBucket).Put(forkChoiceKey, data); err != nil {
+			return err
+		}
+		if err := putVotes(tx, knownVotesBucket, votes.Known); err != nil {
+			return fmt.Errorf("put known votes: %w", err)
+		}
+		if err := putVotes(tx, newVotesBucket, votes.New); err != nil {
+			return fmt.Errorf("put new votes: %w", err)
+		}
+		if err := putSignedVotes(tx, votes.Signed); err != nil {
+			return fmt.Errorf("put signed votes: %w", err)
+		}
+		return nil
+	})
+}
+
+// LoadStore rebuilds a fork choice store from the database and attaches the
+// database to it. Returns ErrNotFound if nothing has been persisted yet.
+func (db *DB) LoadStore() (*forkchoice.Store, error) {
+	var checkpoints *forkchoice.Checkpoints
+	var known, latestNew map[types.ValidatorIndex]types.Checkpoint
+	var signed map[types.ValidatorIndex]types.SignedVote
+
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(forkChoiceKey)
+		if data == nil {
+			return ErrNotFound
+		}
+
+		var err error
+		if checkpoints, err = decodeCheckpoints(data); err != nil {
+			return err
+		}
+		if known, err = readVotes(tx, knownVotesBucket); err != nil {
+			return fmt.Errorf("read known votes: %w", err)
+		}
+		if latestNew, err = readVotes(tx, newVotesBucket); err != nil {
+			return fmt.Errorf("read new votes: %w", err)
+		}
+		if signed, err = readSignedVotes(tx); err != nil {
+			return fmt.Errorf("read signed votes: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Only blocks descending from the finalized checkpoint are loaded; older
+	// canonical blocks stay on disk to serve history, and the slot index
+	// keeps them from being read at all.
+	allBlocks := make(map[types.Root]*types.SignedBlock)
+	err = db.ForEachBlockFrom(checkpoints.LatestFinalized.Slot, func(root types.Root, block *types.SignedBlock) error {
+		allBlocks[root] = block
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	blocks := make(map[types.Root]*types.Block)
+	for root, block := range allBlocks {
+		blocks[root] = &block.Message
+	}
+	if _, exists := blocks[checkpoints.LatestFinalized.Root]; exists {
+		keep := forkchoice.Descendants(blocks, checkpoints.LatestFinalized.Root)
+		for root := range blocks {
+			if !keep[root] {
+				delete(blocks, root)
+			}
+		}
+	}
+
+	signatures := make(map[types.Root]types.Signature)
+	states := make(map[types.Root]*types.State)
+	for root := range blocks {
+		signatures[root] = allBlocks[root].Signature
+
+		state, err := db.State(root)
+		if errors.Is(err, ErrNotFound) {
+			continue // Pruned; the store regenerates it on demand
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load state %x: %w", root[:4], err)
+		}
+		states[root] = state
+	}
+
+	headBlock, exists := blocks[checkpoints.Head]
+	if !exists {
+		return nil, fmt.Errorf("head block %x missing from database", checkpoints.Head[:4])
+	}
+	headState, exists := states[checkpoints.Head]
+	if !exists {
+		return nil, fmt.Errorf("head state %x missing from database", checkpoints.Head[:4])
+	}
+
+	store := &forkchoice.Store{
+		Time:             uint64(headBlock.Slot) * types.IntervalsPerSlot,
+		Config:           headState.Config,
+		Head:             checkpoints.Head,
+		SafeTarget:       checkpoints.SafeTarget,
+		LatestJustified:  checkpoints.LatestJustified,
+		LatestFinalized:  checkpoints.LatestFinalized,
+		Validators:       headState.Validators,
+		Blocks:           blocks,
+		Signatures:       signatures,
+		States:           states,
+		LatestKnownVotes: known,
+		LatestNewVotes:   latestNew,
+		SignedVotes:      signed,
+	}
+	store.SetDatabase(db)
+	return store, nil
+}
+
+func encodeCheckpoints(cp *forkchoice.Checkpoints) ([]byte, error) {
+	buf := make([]byte, 0, checkpointsSize)
+	buf = append(buf, cp.Head[:]...)
+	buf = append(buf, cp.SafeTarget[:]...)
+
+	buf, err := cp.LatestJustified.MarshalSSZTo(buf)
+	if err != nil {
+		return nil, fmt.Errorf("marshal justified: %w", err)
+	}
+	buf, err = cp.LatestFinalized.MarshalSSZTo(buf)
+	if err != nil {
+		return nil, fmt.Errorf("marshal finalized: %w", err)
+	}
+	return buf, nil
+}
+
+func decodeCheckpoints(data []byte) (*forkchoice.Checkpoints, error) {
+	if len(data) != checkpointsSize {
+		return nil, fmt.Errorf("invalid fork choice record size %d", len(data))
+	}
+
+	cp := new(forkchoice.Checkpoints)
+	copy(cp.Head[:], data[0:32])
+	copy(cp.SafeTarget[:], data[32:64])
+	if err := cp.LatestJustified.UnmarshalSSZ(data[64:104]); err != nil {
+		return nil, fmt.Errorf("unmarshal justified: %w", err)
+	}
+	if err := cp.LatestFinalized.UnmarshalSSZ(data[104:144]); err != nil {
+		return nil, fmt.Errorf("unmarshal finalized: %w", err)
+	}
+	return cp, nil
+}
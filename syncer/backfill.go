@@ -0,0 +1,120 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/devylongs/gean/storage"
+	"github.com/devylongs/gean/types"
+)
+
+// Backfill parameters
+const (
+	BackfillInterval  = SyncInterval
+	BackfillBatchSize = 64 // Blocks fetched per backfill round
+)
+
+// History is the block archive that backfill fills in. Backfilled blocks
+// are finalized, so they are stored as canonical at their slot.
+type History interface {
+	Block(root types.Root) (*types.SignedBlock, error)
+	PutCanonicalBlock(root types.Root, block *types.SignedBlock) error
+}
+
+// runBackfill fetches the canonical blocks before the finalized checkpoint
+// that are missing from history, newest first, until it reaches genesis.
+// A node started from a checkpoint holds no blocks before it; backfill runs
+// only while synced so it never competes with catching up to the head.
+func (s *Syncer) runBackfill(ctx context.Context) {
+	defer s.wg.Done()
+
+	root, done, err := s.nextMissing(s.store.Finalized().Root)
+	if err != nil {
+		s.logger.Error("backfill failed", "error", err)
+		return
+	}
+	if done {
+		return
+	}
+	s.logger.Info("backfilling history", "from", root[:4])
+
+	ticker := time.NewTicker(BackfillInterval)
+	defer ticker.Stop()
+
+	for !done {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !s.IsSynced() {
+			continue
+		}
+		if root, done, err = s.backfillStep(ctx, root); err != nil {
+			s.logger.Debug("backfill step failed", "root", root[:4], "error", err)
+		}
+	}
+	s.logger.Info("backfill complete")
+}
+
+// backfillStep fetches up to BackfillBatchSize missing blocks, starting with
+// root. It returns the next missing root and whether history is complete.
+func (s *Syncer) backfillStep(ctx context.Context, root types.Root) (types.Root, bool, error) {
+	for i := 0; i < BackfillBatchSize; i++ {
+		block, err := s.fetchBlock(ctx, root)
+		if err != nil {
+			return root, false, err
+		}
+		if err := s.history.PutCanonicalBlock(root, block); err != nil {
+			return root, false, fmt.Errorf("store block: %w", err)
+		}
+
+		next, done, err := s.nextMissing(root)
+		if err != nil || done {
+			return next, done, err
+		}
+		root = next
+		if i == BackfillBatchSize-1 {
+			s.logger.Info("backfill progress", "slot", block.Message.Slot)
+		}
+	}
+	return root, false, nil
+}
+
+// nextMissing walks history back from root to the first block it does not
+// hold. done reports that the walk reached genesis instead.
+func (s *Syncer) nextMissing(root types.Root) (types.Root, bool, error) {
+	for !root.IsZero() {
+		block, err := s.history.Block(root)
+		if errors.Is(err, storage.ErrNotFound) {
+			return root, false, nil
+		}
+		if err != nil {
+			return root, false, fmt.Errorf("read block %x: %w", root[:4], err)
+		}
+		if block.Message.Slot == 0 {
+			break
+		}
+		root = block.Message.ParentRoot
+	}
+	return root, true, nil
+}
+
+// fetchBlock requests a block by root from each peer in turn.
+func (s *Syncer) fetchBlock(ctx context.Context, root types.Root) (*types.SignedBlock, error) {
+	for _, pid := range s.network.Peers() {
+		blocks, err := s.network.RequestBlocksByRoot(ctx, pid, []types.Root{root})
+		if err != nil || len(blocks) == 0 {
+			continue
+		}
+		blockRoot, err := blocks[0].Message.HashTreeRoot()
+		if err != nil || blockRoot != root {
+			s.logger.Debug("peer returned unrequested block", "peer", pid)
+			continue
+		}
+		return blocks[0], nil
+	}
+	return nil, fmt.Errorf("no peer has block %x", root[:4])
+}
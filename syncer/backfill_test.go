@@ -0,0 +1,61 @@
+package syncer
+
+import (
+	"context"
+	"testing"
+
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/storage"
+	"github.com/devylongs/gean/types"
+)
+
+// memHistory is an in-memory History.
+type memHistory map[types.Root]*types.SignedBlock
+
+func (h memHistory) Block(root types.Root) (*types.SignedBlock, error) {
+	if block, exists := h[root]; exists {
+		return block, nil
+	}
+	return nil, storage.ErrNotFound
+}
+
+func (h memHistory) PutCanonicalBlock(root types.Root, block *types.SignedBlock) error {
+	h[root] = block
+	return nil
+}
+
+func TestBackfillFromCheckpoint(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, BackfillBatchSize+10)
+
+	// Start the local store from the remote head, as checkpoint sync would
+	anchor, _ := remote.SignedBlock(remote.Head)
+	state, _ := remote.State(remote.Head)
+	local, err := forkchoice.NewCheckpointStore(state, anchor)
+	if err != nil {
+		t.Fatalf("NewCheckpointStore failed: %v", err)
+	}
+	history := memHistory{local.Head: anchor}
+	s := New(Config{Store: local, Network: &fakeNetwork{remote: remote}, History: history})
+
+	root, done, err := s.nextMissing(local.Finalized().Root)
+	if err != nil || done || root != anchor.Message.ParentRoot {
+		t.Fatalf("nextMissing = %x, %v, %v, want the anchor's parent", root[:4], done, err)
+	}
+
+	root, done, err = s.backfillStep(context.Background(), root)
+	if err != nil || done {
+		t.Fatalf("first backfillStep = %v, %v, want a full batch", done, err)
+	}
+	if len(history) != BackfillBatchSize+1 {
+		t.Errorf("history holds %d blocks after one batch, want %d", len(history), BackfillBatchSize+1)
+	}
+
+	if _, done, err = s.backfillStep(context.Background(), root); err != nil || !done {
+		t.Fatalf("second backfillStep = %v, %v, want done", done, err)
+	}
+	if want := len(remote.Blocks); len(history) != want {
+		t.Errorf("history holds %d blocks, want all %d", len(history), want)
+	}
+}
@@ -0,0 +1,126 @@
+package syncer
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/devylongs/gean/types"
+)
+
+// Pending pool limits
+const (
+	MaxPendingBlocks = 256 // Total orphan blocks held at once
+	MaxPendingDepth  = 64  // Ancestors fetched for a single orphan chain
+	MaxPendingAge    = 32  // Slots an orphan may wait for its parent
+)
+
+// Pending pool errors
+var (
+	ErrAlreadyPending = errors.New("block already pending")
+	ErrPoolFull       = errors.New("pending block pool full")
+	ErrTooDeep        = errors.New("orphan chain exceeds max depth")
+)
+
+// PendingPool holds blocks whose parent is not yet known, indexed by their
+// own root and by their parent root so children can be replayed in order.
+type PendingPool struct {
+	mu       sync.Mutex
+	blocks   map[types.Root]*types.SignedBlock
+	children map[types.Root][]types.Root
+}
+
+// NewPendingPool creates an empty pending block pool.
+func NewPendingPool() *PendingPool {
+	return &PendingPool{
+		blocks:   make(map[types.Root]*types.SignedBlock),
+		children: make(map[types.Root][]types.Root),
+	}
+}
+
+// Add parks a block until its parent arrives. depth is the distance from the
+// gossip block that triggered the backfill.
+func (p *PendingPool) Add(root types.Root, block *types.SignedBlock, depth int) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.blocks[root]; exists {
+		return ErrAlreadyPending
+	}
+	if depth > MaxPendingDepth {
+		return ErrTooDeep
+	}
+	if len(p.blocks) >= MaxPendingBlocks {
+		return ErrPoolFull
+	}
+
+	parent := block.Message.ParentRoot
+	p.blocks[root] = block
+	p.children[parent] = append(p.children[parent], root)
+	return nil
+}
+
+// Has reports whether a block with the given root is pending.
+func (p *PendingPool) Has(root types.Root) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, exists := p.blocks[root]
+	return exists
+}
+
+// PopChildren removes and returns all pending blocks whose parent is root.
+func (p *PendingPool) PopChildren(root types.Root) []*types.SignedBlock {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	childRoots := p.children[root]
+	delete(p.children, root)
+
+	blocks := make([]*types.SignedBlock, 0, len(childRoots))
+	for _, childRoot := range childRoots {
+		if block, exists := p.blocks[childRoot]; exists {
+			blocks = append(blocks, block)
+			delete(p.blocks, childRoot)
+		}
+	}
+	return blocks
+}
+
+// Prune drops blocks at or below the finalized slot, which can never be
+// imported, and blocks that have waited longer than MaxPendingAge slots.
+func (p *PendingPool) Prune(currentSlot, finalizedSlot types.Slot) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pruned := 0
+	for root, block := range p.blocks {
+		slot := block.Message.Slot
+		if slot <= finalizedSlot || slot+MaxPendingAge < currentSlot {
+			p.removeLocked(root, block.Message.ParentRoot)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// Len returns the number of pending blocks.
+func (p *PendingPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.blocks)
+}
+
+func (p *PendingPool) removeLocked(root, parent types.Root) {
+	delete(p.blocks, root)
+	siblings := p.children[parent]
+	for i, sibling := range siblings {
+		if sibling == root {
+			siblings = append(siblings[:i], siblings[i+1:]...)
+			break
+		}
+	}
+	if len(siblings) == 0 {
+		delete(p.children, parent)
+	} else {
+		p.children[parent] = siblings
+	}
+}
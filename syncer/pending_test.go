@@ -0,0 +1,143 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/devylongs/gean/chain"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/types"
+)
+
+func makeBlock(slot types.Slot, parent types.Root) (types.Root, *types.SignedBlock) {
+	block := &types.SignedBlock{Message: types.Block{Slot: slot, ParentRoot: parent}}
+	root, _ := block.Message.HashTreeRoot()
+	return root, block
+}
+
+func TestPendingPoolChildren(t *testing.T) {
+	pool := NewPendingPool()
+
+	parent := types.Root{0xaa}
+	rootA, blockA := makeBlock(5, parent)
+	rootB, blockB := makeBlock(6, parent)
+	rootC, blockC := makeBlock(7, rootA)
+
+	for _, tc := range []struct {
+		root  types.Root
+		block *types.SignedBlock
+	}{{rootA, blockA}, {rootB, blockB}, {rootC, blockC}} {
+		if err := pool.Add(tc.root, tc.block, 0); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+	}
+
+	if err := pool.Add(rootA, blockA, 0); !errors.Is(err, ErrAlreadyPending) {
+		t.Errorf("duplicate Add error = %v, want ErrAlreadyPending", err)
+	}
+
+	children := pool.PopChildren(parent)
+	if len(children) != 2 {
+		t.Fatalf("PopChildren returned %d blocks, want 2", len(children))
+	}
+	if pool.Has(rootA) || pool.Has(rootB) {
+		t.Error("popped blocks still pending")
+	}
+	if !pool.Has(rootC) {
+		t.Error("grandchild should still be pending")
+	}
+	if got := pool.PopChildren(rootA); len(got) != 1 || got[0] != blockC {
+		t.Errorf("PopChildren(rootA) = %v, want [blockC]", got)
+	}
+	if pool.Len() != 0 {
+		t.Errorf("Len = %d, want 0", pool.Len())
+	}
+}
+
+func TestPendingPoolLimits(t *testing.T) {
+	pool := NewPendingPool()
+
+	root, block := makeBlock(1, types.Root{})
+	if err := pool.Add(root, block, MaxPendingDepth+1); !errors.Is(err, ErrTooDeep) {
+		t.Errorf("Add error = %v, want ErrTooDeep", err)
+	}
+
+	for i := 0; i < MaxPendingBlocks; i++ {
+		root, block := makeBlock(types.Slot(i+1), types.Root{byte(i)})
+		if err := pool.Add(root, block, 0); err != nil {
+			t.Fatalf("Add %d failed: %v", i, err)
+		}
+	}
+	root, block = makeBlock(types.Slot(MaxPendingBlocks+1), types.Root{})
+	if err := pool.Add(root, block, 0); !errors.Is(err, ErrPoolFull) {
+		t.Errorf("Add error = %v, want ErrPoolFull", err)
+	}
+}
+
+func TestPendingPoolPrune(t *testing.T) {
+	pool := NewPendingPool()
+
+	finalizedRoot, finalizedBlock := makeBlock(3, types.Root{1})
+	staleRoot, staleBlock := makeBlock(10, types.Root{2})
+	freshRoot, freshBlock := makeBlock(50, types.Root{3})
+	pool.Add(finalizedRoot, finalizedBlock, 0)
+	pool.Add(staleRoot, staleBlock, 0)
+	pool.Add(freshRoot, freshBlock, 0)
+
+	if pruned := pool.Prune(50, 3); pruned != 2 {
+		t.Errorf("Prune removed %d blocks, want 2", pruned)
+	}
+	if !pool.Has(freshRoot) || pool.Len() != 1 {
+		t.Error("expected only the fresh block to remain")
+	}
+}
+
+func TestOrphanFetchesParentFromSender(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, 2)
+	parent := remote.Blocks[remote.Head].ParentRoot
+	orphan, _ := remote.SignedBlock(remote.Head)
+
+	local := forkchoicetest.Store(t)
+	network := &fakeNetwork{remote: remote}
+	s := New(Config{Store: local, Network: network})
+
+	if err := s.OnBlock(context.Background(), "sender", orphan); err != nil {
+		t.Fatalf("OnBlock failed: %v", err)
+	}
+	s.Stop()
+
+	if len(network.rootRequests) != 1 {
+		t.Fatalf("made %d BlocksByRoot requests, want 1", len(network.rootRequests))
+	}
+	request := network.rootRequests[0]
+	if request.pid != "sender" || len(request.roots) != 1 || request.roots[0] != parent {
+		t.Errorf("requested %v from %s, want the orphan's parent from the sender", request.roots, request.pid)
+	}
+	// The orphan can only be processed once its parent is in the store
+	if !local.HasBlock(parent) || !local.HasBlock(remote.Head) {
+		t.Error("parent and orphan not both imported")
+	}
+	if s.PendingCount() != 0 {
+		t.Errorf("%d blocks still pending", s.PendingCount())
+	}
+}
+
+func TestOrphanWithBadSignatureNotQueued(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, 2)
+	orphan, _ := remote.SignedBlock(remote.Head)
+	orphan.Signature[0] ^= 0xff
+
+	network := &fakeNetwork{remote: remote}
+	s := New(Config{Store: forkchoicetest.Store(t), Network: network})
+	if err := s.OnBlock(context.Background(), "sender", orphan); !errors.Is(err, chain.ErrInvalidSignature) {
+		t.Errorf("OnBlock error = %v, want ErrInvalidSignature", err)
+	}
+	s.Stop()
+
+	if s.PendingCount() != 0 || len(network.rootRequests) != 0 {
+		t.Error("orphan with a bad signature was queued")
+	}
+}
@@ -0,0 +1,354 @@
+package syncer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Range sync parameters
+const (
+	SyncInterval      = time.Duration(types.SecondsPerSlot) * time.Second
+	SyncTolerance     = 2    // Slots a peer may be ahead before we consider ourselves behind
+	MaxSyncBlocks     = 8192 // Blocks fetched by root in a single sync round
+	RootBatchSize     = 64   // Roots requested per BlocksByRoot request
+	RangeBatchSize    = 64   // Slots requested per BlocksByRange request
+	ImportBatchSize   = 64   // Blocks imported between progress logs
+	StatusPollTimeout = reqresp.TTFBTimeout + reqresp.RespTimeout
+)
+
+// State is the sync state of the node.
+type State int
+
+const (
+	StateSyncing State = iota
+	StateSynced
+)
+
+func (st State) String() string {
+	switch st {
+	case StateSyncing:
+		return "syncing"
+	case StateSynced:
+		return "synced"
+	default:
+		return "unknown"
+	}
+}
+
+// State returns the current sync state.
+func (s *Syncer) State() State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// IsSynced reports whether our head has caught up with the best known peer.
+func (s *Syncer) IsSynced() bool {
+	return s.State() == StateSynced
+}
+
+// UpdatePeerStatus records the status a peer advertised to us, as in the
+// handshake with a new peer. A peer far enough ahead starts a sync round
+// without waiting for the next poll.
+func (s *Syncer) UpdatePeerStatus(pid peer.ID, status *reqresp.Status) {
+	s.setPeerStatus(pid, status)
+	if s.isBehind(status) {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *Syncer) setPeerStatus(pid peer.ID, status *reqresp.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peerStatus[pid] = status
+}
+
+func (s *Syncer) setState(state State) {
+	s.mu.Lock()
+	prev := s.state
+	s.state = state
+	s.mu.Unlock()
+
+	if prev != state {
+		s.logger.Info("sync state changed", "from", prev, "to", state, "head_slot", s.headSlot())
+	}
+}
+
+// run periodically checks peers and range syncs when we fall behind, and
+// also as soon as a new peer advertises a head ahead of ours.
+func (s *Syncer) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(SyncInterval)
+	defer ticker.Stop()
+
+	s.syncStep(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.syncStep(ctx)
+		case <-s.wake:
+			s.syncStep(ctx)
+		}
+	}
+}
+
+// syncStep refreshes peer statuses and syncs from the peer with the best head.
+func (s *Syncer) syncStep(ctx context.Context) {
+	s.pollStatuses(ctx)
+
+	pid, best := s.bestPeer()
+	if best == nil || !s.isBehind(best) {
+		s.setState(StateSynced)
+		return
+	}
+
+	s.setState(StateSyncing)
+	s.logger.Info("range syncing",
+		"peer", pid,
+		"head_slot", s.headSlot(),
+		"peer_head_slot", best.Head.Slot,
+	)
+
+	if err := s.syncFromPeer(ctx, pid, best); err != nil {
+		s.logger.Warn("range sync failed", "peer", pid, "error", err)
+		return
+	}
+	if !s.isBehind(best) {
+		s.setState(StateSynced)
+	}
+}
+
+// pollStatuses requests a fresh Status from every connected peer at once,
+// so a slow peer only delays the round by StatusPollTimeout.
+func (s *Syncer) pollStatuses(ctx context.Context) {
+	peers := s.network.Peers()
+	connected := make(map[peer.ID]struct{}, len(peers))
+
+	var wg sync.WaitGroup
+	for _, pid := range peers {
+		connected[pid] = struct{}{}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reqCtx, cancel := context.WithTimeout(ctx, StatusPollTimeout)
+			defer cancel()
+			status, err := s.network.RequestStatus(reqCtx, pid)
+			if err != nil {
+				s.logger.Debug("status request failed", "peer", pid, "error", err)
+				return
+			}
+			s.setPeerStatus(pid, status)
+		}()
+	}
+	wg.Wait()
+
+	// Forget peers that have disconnected
+	s.mu.Lock()
+	for pid := range s.peerStatus {
+		if _, ok := connected[pid]; !ok {
+			delete(s.peerStatus, pid)
+		}
+	}
+	s.mu.Unlock()
+}
+
+// bestPeer returns the peer advertising the highest head slot.
+func (s *Syncer) bestPeer() (peer.ID, *reqresp.Status) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var bestID peer.ID
+	var best *reqresp.Status
+	for pid, status := range s.peerStatus {
+		if best == nil || status.Head.Slot > best.Head.Slot {
+			bestID, best = pid, status
+		}
+	}
+	return bestID, best
+}
+
+// isBehind reports whether the peer's head is unknown to us and far enough ahead.
+func (s *Syncer) isBehind(status *reqresp.Status) bool {
+	if s.store.HasBlock(status.Head.Root) {
+		return false
+	}
+	return status.Head.Slot > s.headSlot()+SyncTolerance
+}
+
+func (s *Syncer) headSlot() types.Slot {
+	return s.store.HeadCheckpoint().Slot
+}
+
+// syncFromPeer requests the slots after our head from the peer in batches of
+// RangeBatchSize and imports the blocks oldest first, until the peer's head
+// is imported. A peer that fails the first BlocksByRange request, as one
+// that does not serve it, is synced by walking back from its head instead.
+func (s *Syncer) syncFromPeer(ctx context.Context, pid peer.ID, status *reqresp.Status) error {
+	next := s.headSlot() + 1
+	for first := true; next <= status.Head.Slot && !s.store.HasBlock(status.Head.Root); first = false {
+		count := min(uint64(status.Head.Slot-next)+1, RangeBatchSize)
+		blocks, err := s.network.RequestBlocksByRange(ctx, pid, next, count, 1)
+		if err != nil {
+			if first {
+				s.logger.Debug("blocks_by_range failed, syncing by root", "peer", pid, "error", err)
+				return s.syncByRoot(ctx, pid, status)
+			}
+			return fmt.Errorf("request blocks by range: %w", err)
+		}
+		if err := s.importRange(ctx, pid, blocks); err != nil {
+			return err
+		}
+
+		next += types.Slot(count)
+		s.logger.Info("range sync progress",
+			"slot", next-1,
+			"peer_head_slot", status.Head.Slot,
+			"head_slot", s.headSlot(),
+		)
+	}
+	return nil
+}
+
+// importRange imports the blocks of a BlocksByRange response. A block whose
+// parent is unknown shows our head is on a fork the peer does not share, so
+// the peer's branch back to a block we have is fetched by root first.
+func (s *Syncer) importRange(ctx context.Context, pid peer.ID, blocks []*types.SignedBlock) error {
+	for _, block := range blocks {
+		if !s.store.HasBlock(block.Message.ParentRoot) {
+			branch, err := s.fetchChain(ctx, pid, block.Message.ParentRoot)
+			if err != nil {
+				return fmt.Errorf("fetch branch: %w", err)
+			}
+			if err := s.importChain(ctx, pid, branch); err != nil {
+				return err
+			}
+		}
+		if err := s.importBlock(ctx, pid, block, 0); err != nil {
+			return fmt.Errorf("import block at slot %d: %w", block.Message.Slot, err)
+		}
+	}
+	return nil
+}
+
+// syncByRoot walks back from the peer's head until it reaches a block we
+// already have, then imports the fetched chain oldest first.
+func (s *Syncer) syncByRoot(ctx context.Context, pid peer.ID, status *reqresp.Status) error {
+	chain, err := s.fetchChain(ctx, pid, status.Head.Root)
+	if err != nil {
+		return err
+	}
+	return s.importChain(ctx, pid, chain)
+}
+
+// importChain imports a chain given newest first, starting from its oldest
+// block.
+func (s *Syncer) importChain(ctx context.Context, pid peer.ID, chain []*types.SignedBlock) error {
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := s.importBlock(ctx, pid, chain[i], 0); err != nil {
+			return fmt.Errorf("import block at slot %d: %w", chain[i].Message.Slot, err)
+		}
+		if imported := len(chain) - i; imported%ImportBatchSize == 0 {
+			s.logger.Info("range sync progress",
+				"imported", imported,
+				"remaining", i,
+				"head_slot", s.headSlot(),
+			)
+		}
+	}
+	return nil
+}
+
+// fetchChain requests blocks by root from the peer, following parent roots
+// from head back to the first ancestor already in the store. The result is
+// ordered newest first.
+//
+// Each request carries up to RootBatchSize roots: the next root on the walk
+// and, speculatively, the unknown parents and vote roots of blocks already
+// fetched. Votes point at ancestors further back, so the walk later finds
+// most parents already fetched instead of making a request per block.
+func (s *Syncer) fetchChain(ctx context.Context, pid peer.ID, head types.Root) ([]*types.SignedBlock, error) {
+	var (
+		chain   []*types.SignedBlock
+		fetched = make(map[types.Root]*types.SignedBlock)
+		seen    = map[types.Root]struct{}{head: {}} // Roots requested or queued as hints
+		hints   []types.Root
+	)
+	addHint := func(root types.Root) {
+		if _, exists := seen[root]; exists || s.store.HasBlock(root) {
+			return
+		}
+		seen[root] = struct{}{}
+		hints = append(hints, root)
+	}
+
+	root := head
+	for {
+		if s.store.HasBlock(root) {
+			return chain, nil
+		}
+		if len(chain) >= MaxSyncBlocks {
+			return nil, fmt.Errorf("no common ancestor within %d blocks", MaxSyncBlocks)
+		}
+
+		block, exists := fetched[root]
+		if !exists {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+
+			// Newest hints first, as they are closest to the walk
+			batch := []types.Root{root}
+			for len(hints) > 0 && len(batch) < RootBatchSize && len(fetched) < MaxSyncBlocks {
+				hint := hints[len(hints)-1]
+				hints = hints[:len(hints)-1]
+				if hint != root {
+					batch = append(batch, hint)
+				}
+			}
+			requested := make(map[types.Root]struct{}, len(batch))
+			for _, r := range batch {
+				requested[r] = struct{}{}
+			}
+
+			blocks, err := s.network.RequestBlocksByRoot(ctx, pid, batch)
+			if err != nil {
+				return nil, fmt.Errorf("request blocks: %w", err)
+			}
+			for _, b := range blocks {
+				blockRoot, err := b.Message.HashTreeRoot()
+				if err != nil {
+					return nil, fmt.Errorf("hash block: %w", err)
+				}
+				if _, ok := requested[blockRoot]; !ok {
+					return nil, fmt.Errorf("peer returned unrequested block")
+				}
+				fetched[blockRoot] = b
+				addHint(b.Message.ParentRoot)
+				for _, vote := range b.Message.Body.Attestations {
+					addHint(vote.Data.Head.Root)
+					addHint(vote.Data.Target.Root)
+				}
+			}
+
+			if block, exists = fetched[root]; !exists {
+				return nil, fmt.Errorf("peer does not have block %x", root[:4])
+			}
+		}
+
+		chain = append(chain, block)
+		root = block.Message.ParentRoot
+	}
+}
@@ -0,0 +1,225 @@
+package syncer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/devylongs/gean/chain/chaintest"
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/forkchoice/forkchoicetest"
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// fakeNetwork serves requests from a single remote store.
+type fakeNetwork struct {
+	remote  *forkchoice.Store
+	noRange bool // fail BlocksByRange, as a peer that does not serve it
+
+	rangeRequests int
+
+	mu           sync.Mutex
+	rootRequests []rootRequest
+}
+
+// rootRequest records a BlocksByRoot request made to the fake network.
+type rootRequest struct {
+	pid   peer.ID
+	roots []types.Root
+}
+
+func (f *fakeNetwork) Peers() []peer.ID { return []peer.ID{"remote"} }
+
+func (f *fakeNetwork) RequestStatus(ctx context.Context, pid peer.ID) (*reqresp.Status, error) {
+	return reqresp.NewStatus(f.remote), nil
+}
+
+func (f *fakeNetwork) RequestBlocksByRoot(ctx context.Context, pid peer.ID, roots []types.Root) ([]*types.SignedBlock, error) {
+	f.mu.Lock()
+	f.rootRequests = append(f.rootRequests, rootRequest{pid: pid, roots: roots})
+	f.mu.Unlock()
+	response := reqresp.NewHandler(f.remote).HandleBlocksByRoot(&reqresp.BlocksByRootRequest{Roots: roots})
+	return response.Blocks, nil
+}
+
+func (f *fakeNetwork) RequestBlocksByRange(ctx context.Context, pid peer.ID, start types.Slot, count, step uint64) ([]*types.SignedBlock, error) {
+	f.rangeRequests++
+	if f.noRange {
+		return nil, errors.New("protocols not supported")
+	}
+	request := &reqresp.BlocksByRangeRequest{StartSlot: start, Count: count, Step: step}
+	response, err := reqresp.NewHandler(f.remote).HandleBlocksByRange(request)
+	if err != nil {
+		return nil, err
+	}
+	return response.Blocks, nil
+}
+
+// buildChain produces one block per slot on the given store.
+func buildChain(t *testing.T, store *forkchoice.Store, slots int) {
+	t.Helper()
+	chaintest.Extend(t, store, store.LatestKnownVotes, chaintest.Slots(slots)...)
+}
+
+func TestRangeSyncCatchesUp(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, 20)
+
+	local := forkchoicetest.Store(t)
+	network := &fakeNetwork{remote: remote}
+	s := New(Config{Store: local, Network: network})
+
+	if remote.Blocks[remote.Head].Slot != 20 {
+		t.Fatalf("remote head slot = %d, want 20", remote.Blocks[remote.Head].Slot)
+	}
+
+	s.syncStep(context.Background())
+
+	if _, exists := local.Blocks[remote.Head]; !exists {
+		t.Fatal("remote head not imported")
+	}
+	local.LatestKnownVotes[0] = types.Checkpoint{Root: remote.Head, Slot: 20}
+	local.UpdateHead()
+	if local.Head != remote.Head {
+		t.Fatalf("local head slot %d, want remote head slot %d",
+			local.Blocks[local.Head].Slot, remote.Blocks[remote.Head].Slot)
+	}
+	if !s.IsSynced() {
+		t.Errorf("state = %s, want synced", s.State())
+	}
+	if network.rangeRequests != 1 {
+		t.Errorf("made %d BlocksByRange requests, want 1", network.rangeRequests)
+	}
+}
+
+func TestRangeSyncFallsBackToRoots(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, 10)
+
+	local := forkchoicetest.Store(t)
+	s := New(Config{Store: local, Network: &fakeNetwork{remote: remote, noRange: true}})
+	s.syncStep(context.Background())
+
+	if !local.HasBlock(remote.Head) {
+		t.Fatal("remote head not imported by root")
+	}
+}
+
+func TestRangeSyncAcrossFork(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, RangeBatchSize+10)
+
+	// Our head is a block at slot 2 built directly on genesis
+	local := forkchoicetest.Store(t)
+	chaintest.Extend(t, local, local.LatestKnownVotes, 2)
+	if local.HeadCheckpoint().Slot != 2 || remote.HasBlock(local.Head) {
+		t.Fatal("local head is not a fork block at slot 2")
+	}
+
+	network := &fakeNetwork{remote: remote}
+	s := New(Config{Store: local, Network: network})
+	s.syncStep(context.Background())
+
+	if !local.HasBlock(remote.Head) {
+		t.Fatal("remote head not imported")
+	}
+	if network.rangeRequests != 2 {
+		t.Errorf("made %d BlocksByRange requests, want 2", network.rangeRequests)
+	}
+}
+
+func TestRangeSyncWithinTolerance(t *testing.T) {
+	remote := forkchoicetest.Store(t)
+	buildChain(t, remote, SyncTolerance)
+
+	local := forkchoicetest.Store(t)
+	s := New(Config{Store: local, Network: &fakeNetwork{remote: remote}})
+
+	s.syncStep(context.Background())
+
+	if !s.IsSynced() {
+		t.Errorf("state = %s, want synced", s.State())
+	}
+	if len(local.Blocks) != 1 {
+		t.Errorf("local store has %d blocks, want only genesis", len(local.Blocks))
+	}
+}
+
+func TestUpdatePeerStatusWakesSync(t *testing.T) {
+	s := New(Config{Store: forkchoicetest.Store(t), Network: &fakeNetwork{remote: forkchoicetest.Store(t)}})
+
+	s.UpdatePeerStatus("near", &reqresp.Status{Head: types.Checkpoint{Root: types.Root{1}, Slot: SyncTolerance}})
+	select {
+	case <-s.wake:
+		t.Fatal("peer within tolerance woke the sync loop")
+	default:
+	}
+
+	s.UpdatePeerStatus("ahead", &reqresp.Status{Head: types.Checkpoint{Root: types.Root{2}, Slot: 50}})
+	select {
+	case <-s.wake:
+	default:
+		t.Fatal("peer ahead did not wake the sync loop")
+	}
+	if pid, best := s.bestPeer(); pid != "ahead" || best.Head.Slot != 50 {
+		t.Errorf("best peer = %s, want the peer ahead", pid)
+	}
+}
+
+// blockNetwork serves BlocksByRoot from a fixed set of blocks.
+type blockNetwork struct {
+	fakeNetwork
+	blocks map[types.Root]*types.SignedBlock
+}
+
+func (b *blockNetwork) RequestBlocksByRoot(ctx context.Context, pid peer.ID, roots []types.Root) ([]*types.SignedBlock, error) {
+	b.mu.Lock()
+	b.rootRequests = append(b.rootRequests, rootRequest{pid: pid, roots: roots})
+	b.mu.Unlock()
+	var blocks []*types.SignedBlock
+	for _, root := range roots {
+		if block, exists := b.blocks[root]; exists {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks, nil
+}
+
+func TestFetchChainBatchesRoots(t *testing.T) {
+	local := forkchoicetest.Store(t)
+	network := &blockNetwork{blocks: make(map[types.Root]*types.SignedBlock)}
+
+	// A 40 block chain on top of genesis, each block voting for the block
+	// eight slots before it
+	roots := []types.Root{local.Head}
+	for slot := types.Slot(1); slot <= 40; slot++ {
+		block := &types.SignedBlock{Message: types.Block{
+			Slot:       slot,
+			ParentRoot: roots[slot-1],
+			Body:       types.BlockBody{Attestations: []types.SignedVote{}},
+		}}
+		if slot > 8 {
+			target := types.Checkpoint{Root: roots[slot-8], Slot: slot - 8}
+			block.Message.Body.Attestations = append(block.Message.Body.Attestations,
+				types.SignedVote{Data: types.Vote{Head: target, Target: target}})
+		}
+		root, _ := block.Message.HashTreeRoot()
+		network.blocks[root] = block
+		roots = append(roots, root)
+	}
+
+	s := New(Config{Store: local, Network: network})
+	chain, err := s.fetchChain(context.Background(), "remote", roots[40])
+	if err != nil {
+		t.Fatalf("fetchChain failed: %v", err)
+	}
+	if len(chain) != 40 || chain[0].Message.Slot != 40 || chain[39].Message.Slot != 1 {
+		t.Fatalf("fetched %d blocks, want slots 40 down to 1", len(chain))
+	}
+	if requests := len(network.rootRequests); requests > 15 {
+		t.Errorf("made %d BlocksByRoot requests for 40 blocks", requests)
+	}
+}
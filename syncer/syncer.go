@@ -0,0 +1,225 @@
+// Package syncer keeps the fork choice store in step with the network.
+package syncer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/devylongs/gean/forkchoice"
+	"github.com/devylongs/gean/p2p/reqresp"
+	"github.com/devylongs/gean/types"
+	"github.com/libp2p/go-libp2p/core/peer"
+)
+
+// Network is the subset of the p2p service used to talk to peers.
+type Network interface {
+	Peers() []peer.ID
+	RequestStatus(ctx context.Context, pid peer.ID) (*reqresp.Status, error)
+	RequestBlocksByRoot(ctx context.Context, pid peer.ID, roots []types.Root) ([]*types.SignedBlock, error)
+	RequestBlocksByRange(ctx context.Context, pid peer.ID, start types.Slot, count, step uint64) ([]*types.SignedBlock, error)
+}
+
+// Config holds syncer configuration.
+type Config struct {
+	Store   *forkchoice.Store
+	Network Network
+	History History // nil disables backfilling history before the finalized checkpoint
+	Logger  *slog.Logger
+}
+
+// Syncer imports blocks into the store, parking orphans in a pending pool
+// and backfilling their missing ancestors from the peer that sent them.
+// It also range syncs from peers whose head is ahead of ours, and backfills
+// history missing from before the finalized checkpoint.
+type Syncer struct {
+	store   *forkchoice.Store
+	network Network
+	history History
+	pending *PendingPool
+	logger  *slog.Logger
+
+	mu         sync.Mutex
+	state      State
+	inflight   map[types.Root]struct{} // Parent roots currently being fetched
+	peerStatus map[peer.ID]*reqresp.Status
+	wake       chan struct{} // Signalled when a peer ahead of us is seen
+	wg         sync.WaitGroup
+}
+
+// New creates a new syncer.
+func New(cfg Config) *Syncer {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Syncer{
+		store:      cfg.Store,
+		network:    cfg.Network,
+		history:    cfg.History,
+		pending:    NewPendingPool(),
+		logger:     logger,
+		state:      StateSyncing,
+		inflight:   make(map[types.Root]struct{}),
+		peerStatus: make(map[peer.ID]*reqresp.Status),
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Start launches the range sync loop and, with a history, backfill.
+func (s *Syncer) Start(ctx context.Context) {
+	s.wg.Add(1)
+	go s.run(ctx)
+	if s.history != nil {
+		s.wg.Add(1)
+		go s.runBackfill(ctx)
+	}
+}
+
+// Stop waits for the sync loop and in-flight requests to finish.
+// The context passed to Start and OnBlock must be cancelled first.
+func (s *Syncer) Stop() {
+	s.wg.Wait()
+}
+
+// OnBlock imports a block received from a peer. If its parent is unknown the
+// block is queued and the missing ancestors are requested from that peer.
+func (s *Syncer) OnBlock(ctx context.Context, from peer.ID, signedBlock *types.SignedBlock) error {
+	return s.importBlock(ctx, from, signedBlock, 0)
+}
+
+// Prune drops pending blocks that can no longer be imported.
+func (s *Syncer) Prune(currentSlot types.Slot) {
+	if pruned := s.pending.Prune(currentSlot, s.store.Finalized().Slot); pruned > 0 {
+		s.logger.Debug("pruned pending blocks", "count", pruned, "remaining", s.pending.Len())
+	}
+}
+
+// PendingCount returns the number of orphan blocks waiting for a parent.
+func (s *Syncer) PendingCount() int {
+	return s.pending.Len()
+}
+
+func (s *Syncer) importBlock(ctx context.Context, from peer.ID, signedBlock *types.SignedBlock, depth int) error {
+	block := &signedBlock.Message
+	blockRoot, err := block.HashTreeRoot()
+	if err != nil {
+		return fmt.Errorf("hash block: %w", err)
+	}
+
+	err = s.store.ProcessBlock(signedBlock)
+	if errors.Is(err, forkchoice.ErrUnknownParent) {
+		return s.queueOrphan(ctx, from, blockRoot, signedBlock, depth)
+	}
+	if err != nil {
+		return fmt.Errorf("process block: %w", err)
+	}
+
+	s.logger.Info("processed block",
+		"slot", block.Slot,
+		"proposer", block.ProposerIndex,
+	)
+	s.processChildren(blockRoot)
+	return nil
+}
+
+// queueOrphan parks a block whose parent is unknown and requests the parent.
+// The block cannot be imported yet, but its proposer signature can be
+// checked, so unsigned blocks cannot fill the pool or trigger requests.
+func (s *Syncer) queueOrphan(ctx context.Context, from peer.ID, blockRoot types.Root, signedBlock *types.SignedBlock, depth int) error {
+	block := &signedBlock.Message
+	if finalized := s.store.Finalized(); block.Slot <= finalized.Slot {
+		return fmt.Errorf("orphan block at slot %d not after finalized slot %d", block.Slot, finalized.Slot)
+	}
+	if err := s.store.VerifyBlockSignature(signedBlock); err != nil {
+		return fmt.Errorf("orphan block: %w", err)
+	}
+
+	if err := s.pending.Add(blockRoot, signedBlock, depth); err != nil {
+		if errors.Is(err, ErrAlreadyPending) {
+			return nil
+		}
+		return fmt.Errorf("queue orphan block: %w", err)
+	}
+
+	s.logger.Debug("queued orphan block",
+		"slot", block.Slot,
+		"parent", block.ParentRoot[:4],
+		"depth", depth,
+		"pending", s.pending.Len(),
+	)
+	s.requestParent(ctx, from, block.ParentRoot, depth+1)
+	return nil
+}
+
+// requestParent fetches a missing ancestor in the background unless it is
+// already pending, already being fetched, or too deep.
+func (s *Syncer) requestParent(ctx context.Context, from peer.ID, parentRoot types.Root, depth int) {
+	if depth > MaxPendingDepth || s.pending.Has(parentRoot) {
+		return
+	}
+
+	s.mu.Lock()
+	if _, exists := s.inflight[parentRoot]; exists {
+		s.mu.Unlock()
+		return
+	}
+	s.inflight[parentRoot] = struct{}{}
+	s.mu.Unlock()
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		defer func() {
+			s.mu.Lock()
+			delete(s.inflight, parentRoot)
+			s.mu.Unlock()
+		}()
+
+		blocks, err := s.network.RequestBlocksByRoot(ctx, from, []types.Root{parentRoot})
+		if err != nil {
+			s.logger.Debug("request parent failed", "peer", from, "root", parentRoot[:4], "error", err)
+			return
+		}
+
+		for _, signedBlock := range blocks {
+			root, err := signedBlock.Message.HashTreeRoot()
+			if err != nil || root != parentRoot {
+				s.logger.Debug("peer returned unrequested block", "peer", from)
+				continue
+			}
+			if err := s.importBlock(ctx, from, signedBlock, depth); err != nil {
+				s.logger.Debug("import fetched parent failed", "root", parentRoot[:4], "error", err)
+			}
+		}
+	}()
+}
+
+// processChildren replays pending descendants of a newly imported block.
+func (s *Syncer) processChildren(root types.Root) {
+	queue := []types.Root{root}
+	for len(queue) > 0 {
+		parent := queue[0]
+		queue = queue[1:]
+
+		for _, child := range s.pending.PopChildren(parent) {
+			block := &child.Message
+			if err := s.store.ProcessBlock(child); err != nil {
+				s.logger.Warn("replay pending block failed", "slot", block.Slot, "error", err)
+				continue
+			}
+			childRoot, err := block.HashTreeRoot()
+			if err != nil {
+				continue
+			}
+			s.logger.Info("processed pending block",
+				"slot", block.Slot,
+				"proposer", block.ProposerIndex,
+			)
+			queue = append(queue, childRoot)
+		}
+	}
+}
@@ -1,6 +1,6 @@
 package types
 
-//go:generate go run github.com/ferranbt/fastssz/sszgen --path=. --objs=Checkpoint,Config,Vote,SignedVote,BlockHeader,BlockBody,Block,SignedBlock,State
+//go:generate go run github.com/ferranbt/fastssz/sszgen --path=. --objs=Checkpoint,Config,Validator,Vote,SignedVote,BlockHeader,BlockBody,Block,SignedBlock,State
 
 // SSZ Containers
 
@@ -15,6 +15,11 @@ type Config struct {
 	GenesisTime   uint64
 }
 
+// Validator is a registry entry holding the validator's XMSS public key.
+type Validator struct {
+	Pubkey Pubkey `ssz-size:"64"`
+}
+
 // Vote is a validator's attestation for head, target, and source.
 type Vote struct {
 	ValidatorID uint64
@@ -26,7 +31,7 @@ type Vote struct {
 
 type SignedVote struct {
 	Data      Vote
-	Signature Root `ssz-size:"32"`
+	Signature Signature `ssz-size:"4896"`
 }
 
 type BlockHeader struct {
@@ -51,7 +56,7 @@ type Block struct {
 
 type SignedBlock struct {
 	Message   Block
-	Signature Root `ssz-size:"32"`
+	Signature Signature `ssz-size:"4896"`
 }
 
 // State is the main consensus state object.
@@ -66,7 +71,9 @@ type State struct {
 	HistoricalBlockHashes []Root `ssz-max:"262144" ssz-size:"?,32"`
 	JustifiedSlots        []byte `ssz-max:"32768"`
 
-	// Justification tracking (unused in Devnet 0 but required for SSZ compatibility)
+	// Pending justification votes: one bit per validator for each tracked target root
 	JustificationRoots      []Root `ssz-max:"262144" ssz-size:"?,32"`
 	JustificationValidators []byte `ssz-max:"134217728"` // 262144 * 4096 / 8 = 134217728
+
+	Validators []Validator `ssz-max:"4096"`
 }
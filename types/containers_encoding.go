@@ -1,5 +1,5 @@
 // Code generated by fastssz. DO NOT EDIT.
-// Hash: f1c8ae74e2c8761ac626a30e1bd49fc8a508740119be7be1ad05641470e28f56
+// Hash: 6c88ab425530c2359e2c7d665faa38d96de3302e71f679fafdbc84fd335fd412
 // Version: 0.1.3
 package types
 
@@ -137,6 +137,62 @@ func (c *Config) GetTree() (*ssz.Node, error) {
 	return ssz.ProofTree(c)
 }
 
+// MarshalSSZ ssz marshals the Validator object
+func (v *Validator) MarshalSSZ() ([]byte, error) {
+	return ssz.MarshalSSZ(v)
+}
+
+// MarshalSSZTo ssz marshals the Validator object to a target array
+func (v *Validator) MarshalSSZTo(buf []byte) (dst []byte, err error) {
+	dst = buf
+
+	// Field (0) 'Pubkey'
+	dst = append(dst, v.Pubkey[:]...)
+
+	return
+}
+
+// UnmarshalSSZ ssz unmarshals the Validator object
+func (v *Validator) UnmarshalSSZ(buf []byte) error {
+	var err error
+	size := uint64(len(buf))
+	if size != 64 {
+		return ssz.ErrSize
+	}
+
+	// Field (0) 'Pubkey'
+	copy(v.Pubkey[:], buf[0:64])
+
+	return err
+}
+
+// SizeSSZ returns the ssz encoded size in bytes for the Validator object
+func (v *Validator) SizeSSZ() (size int) {
+	size = 64
+	return
+}
+
+// HashTreeRoot ssz hashes the Validator object
+func (v *Validator) HashTreeRoot() ([32]byte, error) {
+	return ssz.HashWithDefaultHasher(v)
+}
+
+// HashTreeRootWith ssz hashes the Validator object with a hasher
+func (v *Validator) HashTreeRootWith(hh ssz.HashWalker) (err error) {
+	indx := hh.Index()
+
+	// Field (0) 'Pubkey'
+	hh.PutBytes(v.Pubkey[:])
+
+	hh.Merkleize(indx)
+	return
+}
+
+// GetTree ssz hashes the Validator object
+func (v *Validator) GetTree() (*ssz.Node, error) {
+	return ssz.ProofTree(v)
+}
+
 // MarshalSSZ ssz marshals the Vote object
 func (v *Vote) MarshalSSZ() ([]byte, error) {
 	return ssz.MarshalSSZ(v)
@@ -271,7 +327,7 @@ func (s *SignedVote) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 func (s *SignedVote) UnmarshalSSZ(buf []byte) error {
 	var err error
 	size := uint64(len(buf))
-	if size != 168 {
+	if size != 5032 {
 		return ssz.ErrSize
 	}
 
@@ -281,14 +337,14 @@ func (s *SignedVote) UnmarshalSSZ(buf []byte) error {
 	}
 
 	// Field (1) 'Signature'
-	copy(s.Signature[:], buf[136:168])
+	copy(s.Signature[:], buf[136:5032])
 
 	return err
 }
 
 // SizeSSZ returns the ssz encoded size in bytes for the SignedVote object
 func (s *SignedVote) SizeSSZ() (size int) {
-	size = 168
+	size = 5032
 	return
 }
 
@@ -460,13 +516,13 @@ func (b *BlockBody) UnmarshalSSZ(buf []byte) error {
 	// Field (0) 'Attestations'
 	{
 		buf = tail[o0:]
-		num, err := ssz.DivideInt2(len(buf), 168, 4096)
+		num, err := ssz.DivideInt2(len(buf), 5032, 4096)
 		if err != nil {
 			return err
 		}
 		b.Attestations = make([]SignedVote, num)
 		for ii := 0; ii < num; ii++ {
-			if err = b.Attestations[ii].UnmarshalSSZ(buf[ii*168 : (ii+1)*168]); err != nil {
+			if err = b.Attestations[ii].UnmarshalSSZ(buf[ii*5032 : (ii+1)*5032]); err != nil {
 				return err
 			}
 		}
@@ -479,7 +535,7 @@ func (b *BlockBody) SizeSSZ() (size int) {
 	size = 4
 
 	// Field (0) 'Attestations'
-	size += len(b.Attestations) * 168
+	size += len(b.Attestations) * 5032
 
 	return
 }
@@ -646,7 +702,7 @@ func (s *SignedBlock) MarshalSSZ() ([]byte, error) {
 // MarshalSSZTo ssz marshals the SignedBlock object to a target array
 func (s *SignedBlock) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 	dst = buf
-	offset := int(36)
+	offset := int(4900)
 
 	// Offset (0) 'Message'
 	dst = ssz.WriteOffset(dst, offset)
@@ -666,7 +722,7 @@ func (s *SignedBlock) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 func (s *SignedBlock) UnmarshalSSZ(buf []byte) error {
 	var err error
 	size := uint64(len(buf))
-	if size < 36 {
+	if size < 4900 {
 		return ssz.ErrSize
 	}
 
@@ -678,12 +734,12 @@ func (s *SignedBlock) UnmarshalSSZ(buf []byte) error {
 		return ssz.ErrOffset
 	}
 
-	if o0 != 36 {
+	if o0 != 4900 {
 		return ssz.ErrInvalidVariableOffset
 	}
 
 	// Field (1) 'Signature'
-	copy(s.Signature[:], buf[4:36])
+	copy(s.Signature[:], buf[4:4900])
 
 	// Field (0) 'Message'
 	{
@@ -697,7 +753,7 @@ func (s *SignedBlock) UnmarshalSSZ(buf []byte) error {
 
 // SizeSSZ returns the ssz encoded size in bytes for the SignedBlock object
 func (s *SignedBlock) SizeSSZ() (size int) {
-	size = 36
+	size = 4900
 
 	// Field (0) 'Message'
 	size += s.Message.SizeSSZ()
@@ -739,7 +795,7 @@ func (s *State) MarshalSSZ() ([]byte, error) {
 // MarshalSSZTo ssz marshals the State object to a target array
 func (s *State) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 	dst = buf
-	offset := int(232)
+	offset := int(236)
 
 	// Field (0) 'Config'
 	if dst, err = s.Config.MarshalSSZTo(dst); err != nil {
@@ -778,6 +834,10 @@ func (s *State) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 
 	// Offset (8) 'JustificationValidators'
 	dst = ssz.WriteOffset(dst, offset)
+	offset += len(s.JustificationValidators)
+
+	// Offset (9) 'Validators'
+	dst = ssz.WriteOffset(dst, offset)
 
 	// Field (5) 'HistoricalBlockHashes'
 	if size := len(s.HistoricalBlockHashes); size > 262144 {
@@ -811,6 +871,17 @@ func (s *State) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 	}
 	dst = append(dst, s.JustificationValidators...)
 
+	// Field (9) 'Validators'
+	if size := len(s.Validators); size > 4096 {
+		err = ssz.ErrListTooBigFn("State.Validators", size, 4096)
+		return
+	}
+	for ii := 0; ii < len(s.Validators); ii++ {
+		if dst, err = s.Validators[ii].MarshalSSZTo(dst); err != nil {
+			return
+		}
+	}
+
 	return
 }
 
@@ -818,12 +889,12 @@ func (s *State) MarshalSSZTo(buf []byte) (dst []byte, err error) {
 func (s *State) UnmarshalSSZ(buf []byte) error {
 	var err error
 	size := uint64(len(buf))
-	if size < 232 {
+	if size < 236 {
 		return ssz.ErrSize
 	}
 
 	tail := buf
-	var o5, o6, o7, o8 uint64
+	var o5, o6, o7, o8, o9 uint64
 
 	// Field (0) 'Config'
 	if err = s.Config.UnmarshalSSZ(buf[0:16]); err != nil {
@@ -853,7 +924,7 @@ func (s *State) UnmarshalSSZ(buf []byte) error {
 		return ssz.ErrOffset
 	}
 
-	if o5 != 232 {
+	if o5 != 236 {
 		return ssz.ErrInvalidVariableOffset
 	}
 
@@ -872,6 +943,11 @@ func (s *State) UnmarshalSSZ(buf []byte) error {
 		return ssz.ErrOffset
 	}
 
+	// Offset (9) 'Validators'
+	if o9 = ssz.ReadOffset(buf[232:236]); o9 > size || o8 > o9 {
+		return ssz.ErrOffset
+	}
+
 	// Field (5) 'HistoricalBlockHashes'
 	{
 		buf = tail[o5:o6]
@@ -912,7 +988,7 @@ func (s *State) UnmarshalSSZ(buf []byte) error {
 
 	// Field (8) 'JustificationValidators'
 	{
-		buf = tail[o8:]
+		buf = tail[o8:o9]
 		if len(buf) > 134217728 {
 			return ssz.ErrBytesLength
 		}
@@ -921,12 +997,27 @@ func (s *State) UnmarshalSSZ(buf []byte) error {
 		}
 		s.JustificationValidators = append(s.JustificationValidators, buf...)
 	}
+
+	// Field (9) 'Validators'
+	{
+		buf = tail[o9:]
+		num, err := ssz.DivideInt2(len(buf), 64, 4096)
+		if err != nil {
+			return err
+		}
+		s.Validators = make([]Validator, num)
+		for ii := 0; ii < num; ii++ {
+			if err = s.Validators[ii].UnmarshalSSZ(buf[ii*64 : (ii+1)*64]); err != nil {
+				return err
+			}
+		}
+	}
 	return err
 }
 
 // SizeSSZ returns the ssz encoded size in bytes for the State object
 func (s *State) SizeSSZ() (size int) {
-	size = 232
+	size = 236
 
 	// Field (5) 'HistoricalBlockHashes'
 	size += len(s.HistoricalBlockHashes) * 32
@@ -940,6 +1031,9 @@ func (s *State) SizeSSZ() (size int) {
 	// Field (8) 'JustificationValidators'
 	size += len(s.JustificationValidators)
 
+	// Field (9) 'Validators'
+	size += len(s.Validators) * 64
+
 	return
 }
 
@@ -1027,6 +1121,22 @@ func (s *State) HashTreeRootWith(hh ssz.HashWalker) (err error) {
 		hh.MerkleizeWithMixin(elemIndx, byteLen, (134217728+31)/32)
 	}
 
+	// Field (9) 'Validators'
+	{
+		subIndx := hh.Index()
+		num := uint64(len(s.Validators))
+		if num > 4096 {
+			err = ssz.ErrIncorrectListSize
+			return
+		}
+		for _, elem := range s.Validators {
+			if err = elem.HashTreeRootWith(hh); err != nil {
+				return
+			}
+		}
+		hh.MerkleizeWithMixin(subIndx, num, 4096)
+	}
+
 	hh.Merkleize(indx)
 	return
 }
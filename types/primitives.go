@@ -8,6 +8,18 @@ type Root [32]byte
 
 func (r Root) IsZero() bool { return r == Root{} }
 
+// Sizes of the XMSS signature scheme (see package xmss)
+const (
+	SignatureSize = 4896
+	PubkeySize    = 64
+)
+
+// Signature is an XMSS signature.
+type Signature [SignatureSize]byte
+
+// Pubkey is an XMSS public key.
+type Pubkey [PubkeySize]byte
+
 // IsJustifiableAfter checks if this slot is a valid candidate for justification
 // after the given finalized slot. Per 3SF-mini spec:
 // - delta <= 5 (immediate)
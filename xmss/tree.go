@@ -0,0 +1,122 @@
+package xmss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Address kinds separate the hash domains.
+const (
+	kindChain   = 1 // Winternitz chain step
+	kindLeaf    = 2 // Compression of chain ends into a leaf
+	kindNode    = 3 // Internal tree node
+	kindMessage = 4 // Message digest
+	kindSecret  = 5 // Chain start derived from the secret seed
+	kindRandom  = 6 // Per-signature randomness
+)
+
+// address tweaks every hash call with its position in the hypertree.
+type address struct {
+	layer uint8
+	kind  uint8
+	tree  uint32
+	index uint32 // Leaf index, or node index within its level
+	chain uint8  // Chain index, or level for tree nodes
+	pos   uint8  // Position within a chain
+}
+
+func (a address) bytes() [12]byte {
+	var b [12]byte
+	b[0] = a.layer
+	b[1] = a.kind
+	binary.BigEndian.PutUint32(b[2:], a.tree)
+	binary.BigEndian.PutUint32(b[6:], a.index)
+	b[10] = a.chain
+	b[11] = a.pos
+	return b
+}
+
+// hashWith computes SHA-256(seed || address || inputs...).
+func hashWith(seed [HashSize]byte, addr address, inputs ...[]byte) [HashSize]byte {
+	h := sha256.New()
+	h.Write(seed[:])
+	a := addr.bytes()
+	h.Write(a[:])
+	for _, in := range inputs {
+		h.Write(in)
+	}
+	var out [HashSize]byte
+	h.Sum(out[:0])
+	return out
+}
+
+func (k *PrivateKey) hash(addr address, inputs ...[]byte) [HashSize]byte {
+	return hashWith(k.publicSeed, addr, inputs...)
+}
+
+// prf derives secret values from the secret seed.
+func (k *PrivateKey) prf(addr address, inputs ...[]byte) [HashSize]byte {
+	return hashWith(k.secretSeed, addr, inputs...)
+}
+
+// chainSecret returns the start of the Winternitz chain at addr.
+func (k *PrivateKey) chainSecret(addr address) [HashSize]byte {
+	addr.kind = kindSecret
+	addr.pos = 0
+	return k.prf(addr)
+}
+
+// chain advances x from position start by steps hash applications.
+func chain(publicSeed [HashSize]byte, addr address, x [HashSize]byte, start, steps int) [HashSize]byte {
+	for i := start; i < start+steps; i++ {
+		addr.pos = uint8(i)
+		x = hashWith(publicSeed, addr, x[:])
+	}
+	return x
+}
+
+// tree holds every level of a Merkle tree, leaves first.
+type tree [][][HashSize]byte
+
+func (t tree) root() [HashSize]byte {
+	return t[len(t)-1][0]
+}
+
+// authPath returns the concatenated sibling nodes from leaf to root.
+func (t tree) authPath(leaf uint32) []byte {
+	path := make([]byte, 0, TreeHeight*HashSize)
+	index := leaf
+	for h := 0; h < TreeHeight; h++ {
+		sibling := t[h][index^1]
+		path = append(path, sibling[:]...)
+		index >>= 1
+	}
+	return path
+}
+
+// buildTree computes all WOTS public keys of a tree and hashes them up to
+// the root.
+func (k *PrivateKey) buildTree(layer uint8, treeIndex uint32) tree {
+	leaves := make([][HashSize]byte, 1<<TreeHeight)
+	ends := make([]byte, numChains*HashSize)
+	for leaf := range leaves {
+		for c := 0; c < numChains; c++ {
+			addr := address{layer: layer, kind: kindChain, tree: treeIndex, index: uint32(leaf), chain: uint8(c)}
+			end := chain(k.publicSeed, addr, k.chainSecret(addr), 0, w-1)
+			copy(ends[c*HashSize:], end[:])
+		}
+		leaves[leaf] = k.hash(address{layer: layer, kind: kindLeaf, tree: treeIndex, index: uint32(leaf)}, ends)
+	}
+
+	t := tree{leaves}
+	for h := 0; h < TreeHeight; h++ {
+		level := t[h]
+		parents := make([][HashSize]byte, len(level)/2)
+		for i := range parents {
+			addr := address{layer: layer, kind: kindNode, tree: treeIndex, index: uint32(i), chain: uint8(h + 1)}
+			parents[i] = k.hash(addr, level[2*i][:], level[2*i+1][:])
+		}
+		t = append(t, parents)
+	}
+	return t
+}
@@ -0,0 +1,291 @@
+// Package xmss implements a stateful hash-based signature scheme in the XMSS
+// family, used for post-quantum validator signatures.
+//
+// Keys are organised as a two-layer hypertree (XMSS^MT) of Winternitz one-time
+// signatures over SHA-256. Every signature is bound to an epoch; each epoch
+// may be used to sign at most one message, and a key can sign Lifetime epochs.
+// A key only signs at epochs after the last one it signed at.
+// Only the top tree is built at key generation; bottom trees are built lazily
+// as signing reaches them.
+package xmss
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// Scheme parameters
+const (
+	HashSize    = 32
+	TreeHeight  = 9                   // Height of each tree in the hypertree
+	Layers      = 2                   // Number of tree layers
+	LogLifetime = TreeHeight * Layers // log2 of the number of epochs
+	Lifetime    = uint64(1) << LogLifetime
+
+	logW           = 4                   // Winternitz parameter log2(w)
+	w              = 1 << logW           // Chain length
+	msgChains      = HashSize * 8 / logW // 64 chains for the message digest
+	checksumChains = 3                   // Base-w digits of the max checksum 64 * 15
+	numChains      = msgChains + checksumChains
+
+	layerSigSize = (numChains + TreeHeight) * HashSize // WOTS signature and auth path
+)
+
+// Encoded sizes
+const (
+	SeedSize      = 2 * HashSize                   // secret seed || public seed
+	PublicKeySize = 2 * HashSize                   // top root || public seed
+	SignatureSize = HashSize + Layers*layerSigSize // randomness || one WOTS signature and auth path per layer
+)
+
+// Signing errors
+var (
+	ErrEpochOutOfRange = errors.New("epoch outside key lifetime")
+	ErrEpochUsed       = errors.New("epoch not after the last signed epoch")
+)
+
+// PublicKey is the root of the top tree followed by the public seed.
+type PublicKey [PublicKeySize]byte
+
+// Signature is an encoded XMSS^MT signature.
+type Signature [SignatureSize]byte
+
+// PrivateKey holds the seeds and the cached trees needed for signing, and
+// the last epoch it signed at. It is safe for concurrent use.
+type PrivateKey struct {
+	secretSeed [HashSize]byte
+	publicSeed [HashSize]byte
+	top        tree
+	bottom     *bottomCache // Shared by the copies DevnetKey hands out
+
+	mu        sync.Mutex
+	used      bool // Whether lastEpoch is set
+	lastEpoch uint64
+}
+
+// bottomCache holds the most recently used bottom tree.
+type bottomCache struct {
+	mu    sync.Mutex
+	tree  tree
+	index uint32
+}
+
+// GenerateKey creates a private key from randomness read from rand.
+func GenerateKey(rand io.Reader) (*PrivateKey, error) {
+	var seed [SeedSize]byte
+	if _, err := io.ReadFull(rand, seed[:]); err != nil {
+		return nil, fmt.Errorf("read seed: %w", err)
+	}
+	return NewKeyFromSeed(seed), nil
+}
+
+// NewKeyFromSeed deterministically derives a private key from seed.
+func NewKeyFromSeed(seed [SeedSize]byte) *PrivateKey {
+	k := &PrivateKey{bottom: new(bottomCache)}
+	copy(k.secretSeed[:], seed[:HashSize])
+	copy(k.publicSeed[:], seed[HashSize:])
+	k.top = k.buildTree(Layers-1, 0)
+	return k
+}
+
+// devnetKeys caches the keys DevnetKey copies.
+var devnetKeys sync.Map // uint64 -> *PrivateKey
+
+// DevnetKey returns the deterministic key for a validator index. The seed is
+// public, so these keys are only suitable for devnets and tests. The trees
+// are cached, but every call returns a new *PrivateKey that has not signed
+// yet, so each caller keeps its own record of the epochs it used.
+func DevnetKey(index uint64) *PrivateKey {
+	if k, ok := devnetKeys.Load(index); ok {
+		return k.(*PrivateKey).copy()
+	}
+
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], index)
+
+	var seed [SeedSize]byte
+	secret := sha256.Sum256(append([]byte("gean devnet secret seed"), buf[:]...))
+	public := sha256.Sum256(append([]byte("gean devnet public seed"), buf[:]...))
+	copy(seed[:HashSize], secret[:])
+	copy(seed[HashSize:], public[:])
+
+	k, _ := devnetKeys.LoadOrStore(index, NewKeyFromSeed(seed))
+	return k.(*PrivateKey).copy()
+}
+
+// copy returns a key with the same seeds and trees that has not signed yet.
+func (k *PrivateKey) copy() *PrivateKey {
+	return &PrivateKey{
+		secretSeed: k.secretSeed,
+		publicSeed: k.publicSeed,
+		top:        k.top,
+		bottom:     k.bottom,
+	}
+}
+
+// Seed returns the seed the key was derived from.
+func (k *PrivateKey) Seed() [SeedSize]byte {
+	var seed [SeedSize]byte
+	copy(seed[:HashSize], k.secretSeed[:])
+	copy(seed[HashSize:], k.publicSeed[:])
+	return seed
+}
+
+// PublicKey returns the public key.
+func (k *PrivateKey) PublicKey() PublicKey {
+	var pk PublicKey
+	root := k.top.root()
+	copy(pk[:HashSize], root[:])
+	copy(pk[HashSize:], k.publicSeed[:])
+	return pk
+}
+
+// LastEpoch returns the last epoch the key signed at or was marked used up
+// to, and false if there is none.
+func (k *PrivateKey) LastEpoch() (uint64, bool) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lastEpoch, k.used
+}
+
+// MarkUsed records that every epoch up to and including epoch may already
+// have been signed at, as by an earlier run of the same key, so Sign refuses
+// them.
+func (k *PrivateKey) MarkUsed(epoch uint64) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if !k.used || epoch > k.lastEpoch {
+		k.used, k.lastEpoch = true, epoch
+	}
+}
+
+// Sign signs a 32-byte message at the given epoch. Signing two messages at
+// one epoch would reveal the one-time key, so Sign refuses any epoch that is
+// not after the last one the key signed at.
+func (k *PrivateKey) Sign(epoch uint64, message [HashSize]byte) (Signature, error) {
+	var sig Signature
+	if epoch >= Lifetime {
+		return sig, fmt.Errorf("%w: %d >= %d", ErrEpochOutOfRange, epoch, Lifetime)
+	}
+
+	k.mu.Lock()
+	if k.used && epoch <= k.lastEpoch {
+		last := k.lastEpoch
+		k.mu.Unlock()
+		return sig, fmt.Errorf("%w: %d, last %d", ErrEpochUsed, epoch, last)
+	}
+	k.used, k.lastEpoch = true, epoch
+	k.mu.Unlock()
+
+	treeIndex := uint32(epoch >> TreeHeight)
+	leaf := uint32(epoch & (1<<TreeHeight - 1))
+	pkRoot := k.top.root()
+
+	k.bottom.mu.Lock()
+	if k.bottom.tree == nil || k.bottom.index != treeIndex {
+		k.bottom.tree = k.buildTree(0, treeIndex)
+		k.bottom.index = treeIndex
+	}
+	bottom := k.bottom.tree
+	k.bottom.mu.Unlock()
+
+	randomness := k.prf(address{kind: kindRandom, index: leaf, tree: treeIndex}, message[:])
+	copy(sig[:HashSize], randomness[:])
+
+	// Bottom layer signs the message digest
+	digest := k.hash(address{kind: kindMessage, tree: treeIndex, index: leaf}, randomness[:], pkRoot[:], message[:])
+	out := sig[HashSize:]
+	k.signLayer(out[:layerSigSize], bottom, 0, treeIndex, leaf, digest)
+
+	// Top layer signs the bottom tree root
+	bottomRoot := bottom.root()
+	digest = k.hash(address{layer: 1, kind: kindMessage, index: treeIndex}, bottomRoot[:])
+	k.signLayer(out[layerSigSize:], k.top, 1, 0, treeIndex, digest)
+
+	return sig, nil
+}
+
+// signLayer writes the WOTS signature of digest at leaf and its auth path.
+func (k *PrivateKey) signLayer(out []byte, t tree, layer uint8, treeIndex, leaf uint32, digest [HashSize]byte) {
+	for c, d := range digits(digest) {
+		addr := address{layer: layer, kind: kindChain, tree: treeIndex, index: leaf, chain: uint8(c)}
+		start := k.chainSecret(addr)
+		end := chain(k.publicSeed, addr, start, 0, int(d))
+		copy(out[c*HashSize:], end[:])
+	}
+	path := t.authPath(leaf)
+	copy(out[numChains*HashSize:], path)
+}
+
+// Verify reports whether sig is a valid signature of message at epoch by pk.
+func Verify(pk PublicKey, epoch uint64, message [HashSize]byte, sig []byte) bool {
+	if len(sig) != SignatureSize || epoch >= Lifetime {
+		return false
+	}
+
+	var publicSeed, pkRoot [HashSize]byte
+	copy(pkRoot[:], pk[:HashSize])
+	copy(publicSeed[:], pk[HashSize:])
+
+	treeIndex := uint32(epoch >> TreeHeight)
+	leaf := uint32(epoch & (1<<TreeHeight - 1))
+	randomness := sig[:HashSize]
+	layers := sig[HashSize:]
+
+	digest := hashWith(publicSeed, address{kind: kindMessage, tree: treeIndex, index: leaf}, randomness, pkRoot[:], message[:])
+	bottomRoot := rootFromSignature(publicSeed, layers[:layerSigSize], 0, treeIndex, leaf, digest)
+
+	digest = hashWith(publicSeed, address{layer: 1, kind: kindMessage, index: treeIndex}, bottomRoot[:])
+	root := rootFromSignature(publicSeed, layers[layerSigSize:], 1, 0, treeIndex, digest)
+
+	return root == pkRoot
+}
+
+// rootFromSignature recomputes a tree root from one layer's WOTS signature
+// and auth path.
+func rootFromSignature(publicSeed [HashSize]byte, sig []byte, layer uint8, treeIndex, leaf uint32, digest [HashSize]byte) [HashSize]byte {
+	ends := make([]byte, 0, numChains*HashSize)
+	for c, d := range digits(digest) {
+		var start [HashSize]byte
+		copy(start[:], sig[c*HashSize:])
+		addr := address{layer: layer, kind: kindChain, tree: treeIndex, index: leaf, chain: uint8(c)}
+		end := chain(publicSeed, addr, start, int(d), w-1-int(d))
+		ends = append(ends, end[:]...)
+	}
+	node := hashWith(publicSeed, address{layer: layer, kind: kindLeaf, tree: treeIndex, index: leaf}, ends)
+
+	path := sig[numChains*HashSize:]
+	index := leaf
+	for h := 0; h < TreeHeight; h++ {
+		sibling := path[h*HashSize : (h+1)*HashSize]
+		addr := address{layer: layer, kind: kindNode, tree: treeIndex, index: index >> 1, chain: uint8(h + 1)}
+		if index&1 == 0 {
+			node = hashWith(publicSeed, addr, node[:], sibling)
+		} else {
+			node = hashWith(publicSeed, addr, sibling, node[:])
+		}
+		index >>= 1
+	}
+	return node
+}
+
+// digits splits a digest into base-w message digits followed by the
+// checksum digits.
+func digits(digest [HashSize]byte) [numChains]byte {
+	var out [numChains]byte
+	var checksum int
+	for i, b := range digest {
+		out[2*i] = b >> 4
+		out[2*i+1] = b & 0x0f
+		checksum += 2*(w-1) - int(out[2*i]) - int(out[2*i+1])
+	}
+	for i := 0; i < checksumChains; i++ {
+		shift := logW * (checksumChains - 1 - i)
+		out[msgChains+i] = byte(checksum>>shift) & (w - 1)
+	}
+	return out
+}
@@ -0,0 +1,100 @@
+package xmss
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"testing"
+)
+
+func TestSignVerify(t *testing.T) {
+	key, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+	pk := key.PublicKey()
+	message := [HashSize]byte{1, 2, 3}
+
+	// Epochs in the first and a later bottom tree
+	for _, epoch := range []uint64{0, 7, 1 << TreeHeight, Lifetime - 1} {
+		sig, err := key.Sign(epoch, message)
+		if err != nil {
+			t.Fatalf("Sign(%d) failed: %v", epoch, err)
+		}
+		if !Verify(pk, epoch, message, sig[:]) {
+			t.Errorf("signature at epoch %d does not verify", epoch)
+		}
+		if Verify(pk, epoch+1, message, sig[:]) {
+			t.Errorf("signature at epoch %d verifies at epoch %d", epoch, epoch+1)
+		}
+	}
+}
+
+func TestVerifyRejectsTampering(t *testing.T) {
+	key := DevnetKey(0)
+	message := [HashSize]byte{42}
+	sig, err := key.Sign(3, message)
+	if err != nil {
+		t.Fatalf("Sign failed: %v", err)
+	}
+
+	other := message
+	other[0] ^= 1
+	if Verify(key.PublicKey(), 3, other, sig[:]) {
+		t.Error("signature verifies for a different message")
+	}
+	if Verify(DevnetKey(1).PublicKey(), 3, message, sig[:]) {
+		t.Error("signature verifies under a different key")
+	}
+	for _, offset := range []int{0, HashSize, SignatureSize - 1} {
+		bad := sig
+		bad[offset] ^= 1
+		if Verify(key.PublicKey(), 3, message, bad[:]) {
+			t.Errorf("signature with byte %d flipped verifies", offset)
+		}
+	}
+	if Verify(key.PublicKey(), 3, message, sig[:SignatureSize-1]) {
+		t.Error("truncated signature verifies")
+	}
+}
+
+func TestSignEpochOutOfRange(t *testing.T) {
+	if _, err := DevnetKey(0).Sign(Lifetime, [HashSize]byte{}); !errors.Is(err, ErrEpochOutOfRange) {
+		t.Errorf("err = %v, want ErrEpochOutOfRange", err)
+	}
+}
+
+func TestKeyFromSeed(t *testing.T) {
+	key := DevnetKey(5)
+	restored := NewKeyFromSeed(key.Seed())
+
+	pk1, pk2 := key.PublicKey(), restored.PublicKey()
+	if !bytes.Equal(pk1[:], pk2[:]) {
+		t.Error("key restored from seed has a different public key")
+	}
+}
+
+func TestSignRefusesUsedEpochs(t *testing.T) {
+	key := DevnetKey(0)
+	if _, err := key.Sign(10, [HashSize]byte{1}); err != nil {
+		t.Fatalf("Sign(10) failed: %v", err)
+	}
+	for _, epoch := range []uint64{10, 9} {
+		if _, err := key.Sign(epoch, [HashSize]byte{2}); !errors.Is(err, ErrEpochUsed) {
+			t.Errorf("Sign(%d) err = %v, want ErrEpochUsed", epoch, err)
+		}
+	}
+	if last, used := key.LastEpoch(); !used || last != 10 {
+		t.Errorf("LastEpoch = %d, %v, want 10", last, used)
+	}
+
+	// A restored key refuses the epochs an earlier run used
+	restored := DevnetKey(0)
+	restored.MarkUsed(20)
+	if _, err := restored.Sign(20, [HashSize]byte{3}); !errors.Is(err, ErrEpochUsed) {
+		t.Errorf("Sign(20) after MarkUsed(20) err = %v, want ErrEpochUsed", err)
+	}
+	if _, err := restored.Sign(21, [HashSize]byte{3}); err != nil {
+		t.Errorf("Sign(21) failed: %v", err)
+	}
+}